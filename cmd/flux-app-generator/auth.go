@@ -0,0 +1,169 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/huh"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/helm"
+	"github.com/EffectiveSloth/flux-app-generator/internal/plugins"
+)
+
+// Environment variables holding the repository credentials, so that they stay out of the shell history.
+const (
+	envRepoUsername = "FLUX_APP_GENERATOR_REPO_USERNAME"
+	envRepoPassword = "FLUX_APP_GENERATOR_REPO_PASSWORD"
+	envRepoToken    = "FLUX_APP_GENERATOR_REPO_TOKEN"
+)
+
+var (
+	// repoAuth holds the settings used to reach the Helm repository, resolved by resolveRepoAuth.
+	repoAuth helm.Auth
+	// repoSecretRef and repoCertSecretRef name the Secrets referenced by the generated repository.
+	repoSecretRef     string
+	repoCertSecretRef string
+	// repoExternalSecret describes the ExternalSecret creating the credentials Secret, when requested.
+	repoExternalSecret struct {
+		storeKind string
+		storeName string
+		key       string
+	}
+)
+
+// addRepoAuthFlags adds the flags setting the credentials and TLS options of the Helm repository.
+func addRepoAuthFlags(fs *flag.FlagSet, auth *helm.Auth) {
+	fs.StringVar(&auth.Username, "repo-username", "", "username of the Helm repository (or $"+envRepoUsername+")")
+	fs.StringVar(&auth.Password, "repo-password", "", "password of the Helm repository (or $"+envRepoPassword+")")
+	fs.StringVar(&auth.Token, "repo-token", "", "bearer token of the Helm repository (or $"+envRepoToken+")")
+	fs.StringVar(&auth.CAFile, "repo-ca-file", "", "CA certificate file verifying the Helm repository")
+	fs.StringVar(&auth.CertFile, "repo-cert-file", "", "client certificate file for the Helm repository")
+	fs.StringVar(&auth.KeyFile, "repo-key-file", "", "client key file for the Helm repository")
+	fs.BoolVar(&auth.InsecureSkipTLSVerify, "repo-insecure-skip-tls-verify", false, "skip the TLS certificate check of the Helm repository")
+}
+
+// resolveRepoAuth combines the repository settings of the flags, the environment and the
+// repositories.yaml of the Helm CLI, in that order of precedence, and registers them for the
+// repository URL.
+func resolveRepoAuth(repoURL string, flags helm.Auth) error {
+	if repoURL == "" {
+		return nil
+	}
+
+	auth := flags.WithDefaults(helm.Auth{
+		Username: os.Getenv(envRepoUsername),
+		Password: os.Getenv(envRepoPassword),
+		Token:    os.Getenv(envRepoToken),
+	})
+	repositories, err := helm.LoadRepositories(helm.DefaultRepositoriesFile())
+	if err != nil {
+		return err
+	}
+	if repository, ok := helm.FindRepository(repositories, repoURL); ok {
+		auth = auth.WithDefaults(repository.Auth())
+	}
+
+	repoAuth = auth
+	if auth.IsZero() {
+		return nil
+	}
+	if err := helm.SetAuth(repoURL, auth); err != nil {
+		return fmt.Errorf("invalid settings for repository %s: %w", repoURL, err)
+	}
+	return nil
+}
+
+// repoSecretFields returns the wizard fields naming the Secrets of the generated repository,
+// asked only when the repository needs credentials or TLS settings.
+func repoSecretFields(opts *cliOptions) []huh.Field {
+	var fields []huh.Field
+	if repoAuth.HasCredentials() && !opts.isSet("repo-secret-ref") {
+		repoSecretRef = helmRepoName + "-auth"
+		fields = append(fields, huh.NewInput().
+			Title("Credentials Secret").
+			Description("Secret with the username and password Flux uses for the repository, leave empty to skip").
+			Value(&repoSecretRef))
+	}
+	if (repoAuth.CAFile != "" || repoAuth.CertFile != "") && !opts.isSet("repo-cert-secret-ref") {
+		repoCertSecretRef = helmRepoName + "-tls"
+		fields = append(fields, huh.NewInput().
+			Title("TLS Secret").
+			Description("Secret with the ca.crt, tls.crt and tls.key Flux uses for the repository, leave empty to skip").
+			Value(&repoCertSecretRef))
+	}
+	return fields
+}
+
+// promptRepoExternalSecret offers to create the credentials Secret with an ExternalSecret.
+func promptRepoExternalSecret(opts *cliOptions) error {
+	if repoSecretRef == "" || opts.isSet("repo-secret-store") {
+		return nil
+	}
+
+	var create bool
+	confirm := huh.NewForm(
+		huh.NewGroup(
+			huh.NewConfirm().
+				Title(fmt.Sprintf("Create the %s Secret with an ExternalSecret?", repoSecretRef)).
+				Value(&create),
+		),
+	).WithTheme(huh.ThemeCharm())
+	if err := confirm.Run(); err != nil {
+		return err
+	}
+	if !create {
+		return nil
+	}
+
+	repoExternalSecret.storeKind = "ClusterSecretStore"
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[string]().
+				Title("Secret Store Type").
+				Options(
+					huh.NewOption("Cluster Secret Store", "ClusterSecretStore"),
+					huh.NewOption("Secret Store", "SecretStore"),
+				).
+				Value(&repoExternalSecret.storeKind),
+			huh.NewInput().
+				Title("Secret Store Name").
+				Value(&repoExternalSecret.storeName).
+				Validate(required("secret store name")),
+			huh.NewInput().
+				Title("Secret Key").
+				Description("Key of the entry holding the username and password properties").
+				Value(&repoExternalSecret.key).
+				Validate(required("secret key")),
+		).Title("🔐 Repository Credentials"),
+	).WithTheme(huh.ThemeCharm())
+	return form.Run()
+}
+
+// repoExternalSecretPlugin returns the externalsecret plugin instance creating the credentials Secret.
+func repoExternalSecretPlugin() (plugins.PluginConfig, bool) {
+	if repoSecretRef == "" || repoExternalSecret.storeName == "" {
+		return plugins.PluginConfig{}, false
+	}
+	return plugins.PluginConfig{
+		PluginName: "externalsecret",
+		Values: map[string]interface{}{
+			"name":               repoSecretRef,
+			"secret_store_type":  repoExternalSecret.storeKind,
+			"secret_store_name":  repoExternalSecret.storeName,
+			"secret_key":         repoExternalSecret.key,
+			"target_secret_name": repoSecretRef,
+			"refresh_interval":   "60m",
+		},
+	}, true
+}
+
+// required returns a validation function rejecting empty values.
+func required(name string) func(string) error {
+	return func(s string) error {
+		if s == "" {
+			return fmt.Errorf("%s is required", name)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/helm"
+)
+
+func TestResolveRepoAuth(t *testing.T) {
+	resetFormVariables(t)
+
+	helmConfig := filepath.Join(t.TempDir(), "repositories.yaml")
+	require.NoError(t, os.WriteFile(helmConfig, []byte(`repositories:
+- name: internal
+  url: https://charts.internal.example.com/
+  username: helm-user
+  password: helm-password
+  caFile: /etc/ssl/internal-ca.pem
+`), 0o600))
+	t.Setenv("HELM_REPOSITORY_CONFIG", helmConfig)
+	t.Setenv(envRepoUsername, "")
+	t.Setenv(envRepoPassword, "")
+	t.Setenv(envRepoToken, "")
+
+	// Settings of the Helm CLI are used for the matching repository
+	opts, err := parseFlags([]string{"--repo-url", "https://charts.internal.example.com", "--repo-ca-file", "/etc/ssl/custom-ca.pem"}, io.Discard)
+	require.NoError(t, err)
+	// The CA file does not exist, so registering the settings fails after they are resolved
+	assert.ErrorContains(t, resolveRepoAuth(helmRepoURL, opts.auth), "failed to read CA file")
+	assert.Equal(t, helm.Auth{Username: "helm-user", Password: "helm-password", CAFile: "/etc/ssl/custom-ca.pem"}, repoAuth)
+
+	// The environment takes precedence over the Helm CLI, flags over the environment
+	t.Setenv(envRepoToken, "env-token")
+	opts, err = parseFlags([]string{"--repo-url", "https://charts.example.com"}, io.Discard)
+	require.NoError(t, err)
+	require.NoError(t, resolveRepoAuth(helmRepoURL, opts.auth))
+	assert.Equal(t, helm.Auth{Token: "env-token"}, repoAuth)
+
+	opts, err = parseFlags([]string{"--repo-url", "https://charts.example.com", "--repo-username", "flag-user", "--repo-password", "flag-password"}, io.Discard)
+	require.NoError(t, err)
+	require.NoError(t, resolveRepoAuth(helmRepoURL, opts.auth))
+	assert.Equal(t, helm.Auth{Username: "flag-user", Password: "flag-password"}, repoAuth)
+}
+
+func TestBuildConfig_RepoSecrets(t *testing.T) {
+	resetFormVariables(t)
+
+	_, err := parseFlags([]string{
+		"--repo-name", "internal",
+		"--repo-secret-ref", "internal-auth",
+		"--repo-cert-secret-ref", "internal-tls",
+		"--repo-secret-store", "vault-backend",
+		"--repo-secret-key", "helm/internal",
+	}, io.Discard)
+	require.NoError(t, err)
+
+	config := buildConfig()
+	assert.Equal(t, "internal-auth", config.RepoSecretRef)
+	assert.Equal(t, "internal-tls", config.RepoCertSecretRef)
+	require.Len(t, config.Plugins, 1)
+	assert.Equal(t, "externalsecret", config.Plugins[0].PluginName)
+	assert.Equal(t, "internal-auth", config.Plugins[0].Values["target_secret_name"])
+	assert.Equal(t, "ClusterSecretStore", config.Plugins[0].Values["secret_store_type"])
+	assert.Equal(t, "helm/internal", config.Plugins[0].Values["secret_key"])
+	assert.Empty(t, pluginInstances, "the plugin instances of the wizard are left untouched")
+}
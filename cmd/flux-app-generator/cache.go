@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/helm"
+)
+
+// cacheOptions holds the flags controlling the cache of repository indexes and charts.
+type cacheOptions struct {
+	offline bool
+	ttl     time.Duration
+}
+
+// addCacheFlags adds the flags controlling the cache of repository indexes and charts.
+func addCacheFlags(fs *flag.FlagSet, opts *cacheOptions) {
+	fs.BoolVar(&opts.offline, "offline", false, "never download; use only the repository indexes and charts in the cache")
+	fs.DurationVar(&opts.ttl, "cache-ttl", helm.DefaultCacheTTL, "how long a cached repository index is used before it is checked for changes")
+}
+
+// validateCacheFlags checks the cache flags.
+func validateCacheFlags(opts cacheOptions) error {
+	if opts.ttl < 0 {
+		return fmt.Errorf("invalid --cache-ttl %s: must not be negative", opts.ttl)
+	}
+	return nil
+}
+
+// configureCache sets the cache used to fetch repository indexes and charts. Without a cache
+// directory the indexes are only kept in memory, which offline mode cannot work with.
+func configureCache(opts cacheOptions) error {
+	dir, err := helm.DefaultCacheDir()
+	if err != nil {
+		if opts.offline {
+			return fmt.Errorf("--offline requires a cache: %w", err)
+		}
+		dir = ""
+	}
+	helm.SetCache(helm.NewCache(dir, opts.ttl, opts.offline))
+	return nil
+}
+
+// runCache runs the cache command and returns the process exit code.
+func runCache(args []string, output io.Writer) int {
+	fs := flag.NewFlagSet("flux-app-generator cache", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(fs.Output(), "Usage: flux-app-generator cache clean\n\n")
+		_, _ = fmt.Fprintf(fs.Output(), "Removes the cached repository indexes and charts ($%s overrides the cache directory).\n", helm.CacheDirEnv)
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 1
+	}
+	if fs.NArg() != 1 || fs.Arg(0) != "clean" {
+		fs.Usage()
+		return 1
+	}
+
+	dir, err := helm.DefaultCacheDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		return 1
+	}
+	if err := helm.NewCache(dir, 0, false).Clean(); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		return 1
+	}
+	_, _ = fmt.Fprintf(output, "🧹 Cleaned cache %s\n", dir)
+	return 0
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/helm"
+)
+
+func TestParseFlags_Cache(t *testing.T) {
+	resetFormVariables(t)
+
+	opts, err := parseFlags([]string{"--offline", "--cache-ttl", "30m"}, io.Discard)
+	require.NoError(t, err)
+	assert.True(t, opts.cache.offline)
+	assert.Equal(t, 30*time.Minute, opts.cache.ttl)
+
+	opts, err = parseFlags(nil, io.Discard)
+	require.NoError(t, err)
+	assert.False(t, opts.cache.offline)
+	assert.Equal(t, helm.DefaultCacheTTL, opts.cache.ttl)
+}
+
+func TestRunCache(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cache")
+	t.Setenv(helm.CacheDirEnv, dir)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "indexes"), 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "indexes", "index.yaml"), []byte("entries: {}\n"), 0o600))
+
+	var out bytes.Buffer
+	assert.Equal(t, 0, runCache([]string{"clean"}, &out))
+	assert.Contains(t, out.String(), dir)
+	_, err := os.Stat(dir)
+	assert.True(t, os.IsNotExist(err))
+
+	assert.Equal(t, 1, runCache(nil, io.Discard))
+	assert.Equal(t, 1, runCache([]string{"purge"}, io.Discard))
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/charmbracelet/huh"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/helm"
+)
+
+// readmePreviewLines is the number of README lines shown by the wizard.
+const readmePreviewLines = 25
+
+var (
+	// chartInfo is the inspected chart version, nil until inspectChart succeeds.
+	chartInfo *helm.Chart
+	// kubeVersion is the Kubernetes version checked against the kubeVersion of the chart, the
+	// version of the cluster when empty.
+	kubeVersion string
+	// includeDeprecated offers deprecated charts in the chart selector, where they are hidden by default.
+	includeDeprecated bool
+)
+
+// chartSelectorHeight is the number of rows of the chart and version selectors.
+const chartSelectorHeight = 15
+
+// chartOptions returns the chart selector options for the charts matching the query, flagging
+// deprecated ones.
+func chartOptions(charts []helm.ChartSummary, query string) []huh.Option[string] {
+	matching := helm.FilterCharts(charts, query, includeDeprecated)
+	if len(matching) == 0 {
+		return []huh.Option[string]{huh.NewOption(fmt.Sprintf("No chart matches %q", query), "")}
+	}
+	options := make([]huh.Option[string], len(matching))
+	for i, chart := range matching {
+		displayName := chart.Name
+		if chart.Deprecated {
+			displayName += " ⚠️  deprecated"
+		}
+		if chart.Description != "" {
+			displayName = fmt.Sprintf("%s - %s", displayName, chart.Description)
+		}
+		options[i] = huh.NewOption(displayName, chart.Name)
+	}
+	return options
+}
+
+// chartDescription describes the highlighted chart of the selector in full, as long descriptions
+// are cut to the width of the terminal in the options.
+func chartDescription(charts []helm.ChartSummary, name string) string {
+	for _, chart := range charts {
+		if chart.Name != name {
+			continue
+		}
+		description := chart.Description
+		if len(chart.Keywords) > 0 {
+			description = fmt.Sprintf("%s\nKeywords: %s", description, strings.Join(chart.Keywords, ", "))
+		}
+		return description
+	}
+	return "Choose a chart from the Helm repository"
+}
+
+// inspectChart downloads the selected chart version and warns when it is deprecated or does not
+// support the Kubernetes version. Failing to inspect the chart is only a warning.
+func inspectChart(ctx context.Context, w io.Writer) error {
+	if chartInfo != nil && chartInfo.Metadata.Name == selectedChart && chartInfo.Metadata.Version == selectedVersion {
+		return nil
+	}
+	chart, err := loadSelectedChart(ctx)
+	if err != nil {
+		// A chart that fails verification must not be used at all
+		if isCancelled(err) || errors.Is(err, helm.ErrVerification) {
+			return err
+		}
+		_, _ = fmt.Fprintf(w, "⚠️  Warning: failed to inspect chart %s@%s: %s\n", selectedChart, selectedVersion, err)
+		return nil
+	}
+	chartInfo = chart
+
+	if chart.Metadata.Deprecated {
+		_, _ = fmt.Fprintf(w, "⚠️  Warning: chart %s is deprecated\n", selectedChart)
+	}
+	if version := targetKubeVersion(); version != "" {
+		if err := chart.CheckKubeVersion(version); err != nil {
+			_, _ = fmt.Fprintf(w, "⚠️  Warning: %s\n", err)
+		}
+	}
+	return nil
+}
+
+// loadSelectedChart loads the selected chart version, from disk for a local chart.
+func loadSelectedChart(ctx context.Context) (*helm.Chart, error) {
+	if localChartPath != "" {
+		return helm.LoadChartPath(localChartPath)
+	}
+	return helm.InspectChart(ctx, helmRepoURL, selectedChart, selectedVersion)
+}
+
+// targetKubeVersion returns the Kubernetes version of --kube-version or of the connected cluster,
+// or "" when it is unknown.
+func targetKubeVersion() string {
+	if kubeVersion != "" {
+		return kubeVersion
+	}
+	if k8sConnected && k8sClient != nil {
+		if version, err := k8sClient.ServerVersion(); err == nil {
+			return version
+		}
+	}
+	return ""
+}
+
+// chartSummary describes a chart: its description, requirements, CRDs, subcharts and the
+// beginning of its README.
+func chartSummary(chart *helm.Chart) string {
+	var b strings.Builder
+	if chart.Metadata.Description != "" {
+		fmt.Fprintf(&b, "%s\n\n", chart.Metadata.Description)
+	}
+	if chart.Metadata.AppVersion != "" {
+		fmt.Fprintf(&b, "App version: %s\n", chart.Metadata.AppVersion)
+	}
+	if chart.Metadata.KubeVersion != "" {
+		fmt.Fprintf(&b, "Kubernetes: %s\n", chart.Metadata.KubeVersion)
+	}
+	if chart.Metadata.Deprecated {
+		b.WriteString("⚠️  This chart is deprecated\n")
+	}
+	if len(chart.CRDs) > 0 {
+		fmt.Fprintf(&b, "CRDs: %d file(s) in crds/\n", len(chart.CRDs))
+	}
+	if chart.Schema != nil {
+		b.WriteString("Values are validated by values.schema.json\n")
+	}
+
+	if len(chart.Metadata.Dependencies) > 0 {
+		b.WriteString("\nSubcharts:\n")
+		for _, dependency := range chart.Metadata.Dependencies {
+			state := "always enabled"
+			if dependency.Condition != "" {
+				state = "disabled"
+				if chart.DependencyEnabled(dependency) {
+					state = "enabled"
+				}
+				state = fmt.Sprintf("%s, toggled by %s", state, dependency.Condition)
+			}
+			fmt.Fprintf(&b, "  • %s %s (%s)\n", dependency.Key(), dependency.Version, state)
+		}
+	}
+
+	if chart.README != "" {
+		lines := strings.Split(strings.TrimSpace(chart.README), "\n")
+		b.WriteString("\nREADME:\n")
+		if len(lines) > readmePreviewLines {
+			lines = append(lines[:readmePreviewLines], "…")
+		}
+		b.WriteString(strings.Join(lines, "\n"))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// chartDefaultValues returns the default values of the selected chart version, with the defaults
+// of its enabled subcharts nested under their keys.
+func chartDefaultValues(ctx context.Context) (string, error) {
+	if chartInfo != nil && chartInfo.Metadata.Name == selectedChart && chartInfo.Metadata.Version == selectedVersion {
+		return chartInfo.ValuesWithSubcharts(subchartToggles)
+	}
+	if localChartPath != "" {
+		chart, err := helm.LoadChartPath(localChartPath)
+		if err != nil {
+			return "", err
+		}
+		return chart.ValuesWithSubcharts(subchartToggles)
+	}
+	return helm.DownloadAndExtractValuesYAML(ctx, helmRepoURL, selectedChart, selectedVersion)
+}
+
+// showChartInfo shows the inspected chart in the wizard.
+func showChartInfo() error {
+	if chartInfo == nil {
+		return nil
+	}
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewNote().
+				Title(fmt.Sprintf("%s %s", chartInfo.Metadata.Name, chartInfo.Metadata.Version)).
+				Description(chartSummary(chartInfo)).
+				Next(true).
+				NextLabel("Continue"),
+		).Title("📖 Chart"),
+	).WithTheme(huh.ThemeCharm())
+	return form.Run()
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/helm"
+)
+
+const chartTestYAML = `apiVersion: v2
+name: podinfo
+version: 6.9.0
+appVersion: 6.9.0
+description: Podinfo Helm chart for Kubernetes
+kubeVersion: ">=1.30.0-0"
+deprecated: true
+dependencies:
+  - name: redis
+    version: 17.x.x
+    repository: https://charts.bitnami.com/bitnami
+    condition: redis.enabled
+`
+
+// newTestChartRepository serves a Helm repository holding version 6.9.0 of the podinfo chart,
+// packaged from the given files.
+func newTestChartRepository(t *testing.T, files map[string]string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: "podinfo/" + name, Mode: 0o644, Size: int64(len(content))}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gzw.Close())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/index.yaml":
+			_, _ = w.Write([]byte("apiVersion: v1\nentries:\n  podinfo:\n    - version: 6.9.0\n      urls:\n        - podinfo-6.9.0.tgz\n"))
+		case "/podinfo-6.9.0.tgz":
+			_, _ = w.Write(buf.Bytes())
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestInspectChart(t *testing.T) {
+	resetFormVariables(t)
+	helmRepoURL = newTestChartRepository(t, map[string]string{
+		"Chart.yaml":  chartTestYAML,
+		"values.yaml": "replicaCount: 1\nredis:\n  enabled: false\n",
+		"README.md":   "# Podinfo\n",
+	})
+	selectedChart, selectedVersion, kubeVersion = "podinfo", "6.9.0", "v1.29.3"
+
+	var out bytes.Buffer
+	require.NoError(t, inspectChart(context.Background(), &out))
+	require.NotNil(t, chartInfo)
+	assert.Contains(t, out.String(), "chart podinfo is deprecated")
+	assert.Contains(t, out.String(), "chart podinfo 6.9.0 requires Kubernetes >=1.30.0-0, not v1.29.3")
+
+	// The default values come from the inspected chart
+	values, err := chartDefaultValues(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "replicaCount: 1\nredis:\n  enabled: false\n", values)
+
+	summary := chartSummary(chartInfo)
+	assert.Contains(t, summary, "Podinfo Helm chart for Kubernetes")
+	assert.Contains(t, summary, "Kubernetes: >=1.30.0-0")
+	assert.Contains(t, summary, "redis 17.x.x (disabled, toggled by redis.enabled)")
+	assert.True(t, strings.HasSuffix(summary, "README:\n# Podinfo"))
+
+	// A supported Kubernetes version does not warn
+	chartInfo, kubeVersion = nil, "v1.30.1"
+	out.Reset()
+	require.NoError(t, inspectChart(context.Background(), &out))
+	assert.NotContains(t, out.String(), "requires Kubernetes")
+}
+
+func TestInspectChart_Failure(t *testing.T) {
+	resetFormVariables(t)
+	helmRepoURL = newTestChartRepository(t, map[string]string{"values.yaml": "replicaCount: 1\n"})
+	selectedChart, selectedVersion = "podinfo", "6.9.0"
+
+	// A chart that cannot be inspected is only a warning
+	var out bytes.Buffer
+	require.NoError(t, inspectChart(context.Background(), &out))
+	assert.Nil(t, chartInfo)
+	assert.Contains(t, out.String(), "failed to inspect chart podinfo@6.9.0")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, inspectChart(ctx, &out), context.Canceled)
+}
+
+func TestParseFlags_KubeVersion(t *testing.T) {
+	resetFormVariables(t)
+
+	_, err := parseFlags([]string{"--kube-version", "v1.29.3"}, &bytes.Buffer{})
+	require.NoError(t, err)
+	assert.Equal(t, "v1.29.3", kubeVersion)
+
+	_, err = parseFlags([]string{"--kube-version", "latest"}, &bytes.Buffer{})
+	assert.ErrorContains(t, err, "invalid --kube-version")
+}
+
+func TestChartOptions(t *testing.T) {
+	resetFormVariables(t)
+	charts := []helm.ChartSummary{
+		{Name: "legacy", Description: "Old web server", Deprecated: true},
+		{Name: "nginx", Description: "Web server", Keywords: []string{"http", "proxy"}},
+		{Name: "redis", Description: "Key-value store"},
+	}
+
+	options := chartOptions(charts, "web")
+	require.Len(t, options, 1, "deprecated charts are hidden")
+	assert.Equal(t, "nginx - Web server", options[0].Key)
+
+	includeDeprecated = true
+	options = chartOptions(charts, "web")
+	require.Len(t, options, 2)
+	assert.Equal(t, "legacy ⚠️  deprecated - Old web server", options[0].Key)
+
+	options = chartOptions(charts, "postgres")
+	require.Len(t, options, 1)
+	assert.Empty(t, options[0].Value)
+
+	assert.Equal(t, "Web server\nKeywords: http, proxy", chartDescription(charts, "nginx"))
+}
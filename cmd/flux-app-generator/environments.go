@@ -0,0 +1,233 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/huh"
+	"gopkg.in/yaml.v3"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/generator"
+	"github.com/EffectiveSloth/flux-app-generator/internal/models"
+	"github.com/EffectiveSloth/flux-app-generator/internal/semver"
+)
+
+// environments are the overlays the app is generated with, a single app directory when empty.
+var environments []models.Environment
+
+// environmentFlags holds the per-environment overrides given on the command line, by environment.
+var environmentFlags struct {
+	chartVersions map[string]string
+	intervals     map[string]string
+}
+
+// addEnvironmentFlags adds the flags generating a base and one overlay per environment.
+func addEnvironmentFlags(fs *flag.FlagSet) {
+	fs.Func("environments", "comma-separated environments to generate a base/ and one overlays/<env>/ for (e.g. staging,production)", func(s string) error {
+		environments = nil
+		for _, name := range splitList(s) {
+			environments = append(environments, models.Environment{Name: name})
+		}
+		return nil
+	})
+	fs.Func("env-chart-version", "comma-separated chart versions of --environments (e.g. staging=6.9.0,production=6.8.1)", func(s string) error {
+		var err error
+		environmentFlags.chartVersions, err = parseEnvironmentOverrides(s)
+		return err
+	})
+	fs.Func("env-interval", "comma-separated HelmRelease intervals of --environments (e.g. production=30m)", func(s string) error {
+		var err error
+		environmentFlags.intervals, err = parseEnvironmentOverrides(s)
+		return err
+	})
+}
+
+// parseEnvironmentOverrides parses a comma-separated list of env=value overrides.
+func parseEnvironmentOverrides(s string) (map[string]string, error) {
+	overrides := make(map[string]string)
+	for _, item := range splitList(s) {
+		env, value, ok := strings.Cut(item, "=")
+		env, value = strings.TrimSpace(env), strings.TrimSpace(value)
+		if !ok || env == "" || value == "" {
+			return nil, fmt.Errorf("%q is not env=value", item)
+		}
+		overrides[env] = value
+	}
+	return overrides, nil
+}
+
+// applyEnvironmentFlags sets the overrides of --env-chart-version and --env-interval on the
+// environments of --environments.
+func applyEnvironmentFlags() error {
+	index := make(map[string]int, len(environments))
+	for i, env := range environments {
+		index[env.Name] = i
+	}
+	for flagName, overrides := range map[string]map[string]string{
+		"env-chart-version": environmentFlags.chartVersions,
+		"env-interval":      environmentFlags.intervals,
+	} {
+		for env, value := range overrides {
+			i, ok := index[env]
+			if !ok {
+				return fmt.Errorf("--%s: %q is not one of --environments", flagName, env)
+			}
+			if flagName == "env-chart-version" {
+				environments[i].ChartVersion = value
+			} else {
+				environments[i].Interval = value
+			}
+		}
+	}
+	return nil
+}
+
+// environmentNames returns the names of the environments.
+func environmentNames(envs []models.Environment) []string {
+	names := make([]string, len(envs))
+	for i, env := range envs {
+		names[i] = env.Name
+	}
+	return names
+}
+
+// promptEnvironments asks for the environments of the app, then for the chart version, interval
+// and values of each of them.
+func promptEnvironments(opts *cliOptions) error {
+	if opts.isSet("environments") {
+		return nil
+	}
+
+	names := strings.Join(environmentNames(environments), ",")
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Environments").
+				Description("Comma-separated environments, each a Kustomize overlay of a shared base (leave empty for a single app directory)").
+				Placeholder("staging,production").
+				Value(&names).
+				Validate(func(s string) error {
+					config := &models.AppConfig{SourceKind: sourceKind}
+					for _, name := range splitList(s) {
+						config.Environments = append(config.Environments, models.Environment{Name: name})
+					}
+					return config.ValidateEnvironments()
+				}),
+		).Title("🌍 Environments"),
+	).WithTheme(huh.ThemeCharm())
+	if err := form.Run(); err != nil {
+		return err
+	}
+
+	previous := make(map[string]models.Environment, len(environments))
+	for _, env := range environments {
+		previous[env.Name] = env
+	}
+	environments = nil
+	for _, name := range splitList(names) {
+		env, ok := previous[name]
+		if !ok {
+			env = models.Environment{Name: name}
+		}
+		environments = append(environments, env)
+	}
+	if len(environments) == 0 {
+		return nil
+	}
+
+	groups := make([]*huh.Group, 0, len(environments))
+	valuesText := make([]string, len(environments))
+	for i := range environments {
+		env := &environments[i]
+		if len(env.Values) > 0 {
+			if data, err := yaml.Marshal(env.Values); err == nil {
+				valuesText[i] = string(data)
+			}
+		}
+
+		var fields []huh.Field
+		if sourceKind != models.SourceKindGitRepository {
+			fields = append(fields, huh.NewInput().
+				Title("Chart Version").
+				Description("Chart version deployed in this environment (leave empty for the version of the base)").
+				Placeholder(selectedVersion).
+				Value(&env.ChartVersion).
+				Validate(func(s string) error {
+					if s == "" {
+						return nil
+					}
+					_, err := semver.Parse(s)
+					return err
+				}))
+		}
+		fields = append(fields,
+			huh.NewSelect[string]().
+				Title("Sync Interval").
+				Description("How often Flux reconciles the HelmRelease in this environment").
+				Options(
+					huh.NewOption(fmt.Sprintf("Same as the base (%s)", interval), ""),
+					huh.NewOption("1 minute", "1m"),
+					huh.NewOption("5 minutes", "5m"),
+					huh.NewOption("10 minutes", "10m"),
+					huh.NewOption("30 minutes", "30m"),
+					huh.NewOption("1 hour", "1h"),
+				).
+				Value(&env.Interval),
+			huh.NewText().
+				Title("Values").
+				Description("Helm values of this environment, merged over the values of the base").
+				Placeholder("replicaCount: 3").
+				Value(&valuesText[i]).
+				Validate(func(s string) error {
+					_, err := parseEnvironmentValues(s)
+					return err
+				}),
+		)
+		groups = append(groups, huh.NewGroup(fields...).Title(fmt.Sprintf("🌍 Environment %s", env.Name)))
+	}
+	if err := huh.NewForm(groups...).WithTheme(huh.ThemeCharm()).Run(); err != nil {
+		return err
+	}
+
+	for i, text := range valuesText {
+		values, err := parseEnvironmentValues(text)
+		if err != nil {
+			return err
+		}
+		environments[i].Values = values
+	}
+	return nil
+}
+
+// parseEnvironmentValues parses the Helm values of an environment, nil when there are none.
+func parseEnvironmentValues(s string) (map[string]interface{}, error) {
+	var values map[string]interface{}
+	if err := yaml.Unmarshal([]byte(s), &values); err != nil {
+		return nil, fmt.Errorf("invalid values: %w", err)
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+	return values, nil
+}
+
+// printEnvironments prints the environments of the app with their overrides and overlay directory.
+func printEnvironments(config *models.AppConfig, appDir string) {
+	fmt.Printf("🌍 Environments:\n")
+	for _, env := range config.Environments {
+		var overrides []string
+		if env.ChartVersion != "" {
+			overrides = append(overrides, "chart "+env.ChartVersion)
+		}
+		if env.Interval != "" {
+			overrides = append(overrides, "interval "+env.Interval)
+		}
+		name := env.Name
+		if len(overrides) > 0 {
+			name = fmt.Sprintf("%s (%s)", name, strings.Join(overrides, ", "))
+		}
+		fmt.Printf("   - %s: %s/\n", name, filepath.Join(appDir, filepath.FromSlash(generator.OverlayDir(env.Name))))
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/models"
+)
+
+func TestParseFlags_Environments(t *testing.T) {
+	resetFormVariables(t)
+
+	opts, err := parseFlags([]string{
+		"--environments", "staging, production",
+		"--env-chart-version", "production=6.8.0",
+		"--env-interval", "staging=1m,production=30m",
+	}, io.Discard)
+	require.NoError(t, err)
+	assert.True(t, opts.isSet("environments"))
+	assert.Equal(t, []models.Environment{
+		{Name: "staging", Interval: "1m"},
+		{Name: "production", ChartVersion: "6.8.0", Interval: "30m"},
+	}, environments)
+	assert.Equal(t, environments, buildConfig().Environments)
+}
+
+func TestParseFlags_InvalidEnvironments(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		message string
+	}{
+		{"override of an unknown environment", []string{"--environments", "staging", "--env-interval", "production=1m"}, `"production" is not one of --environments`},
+		{"override without environments", []string{"--env-chart-version", "staging=1.0.0"}, "is not one of --environments"},
+		{"invalid override", []string{"--environments", "staging", "--env-interval", "staging"}, "is not env=value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetFormVariables(t)
+
+			_, err := parseFlags(tt.args, io.Discard)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.message)
+		})
+	}
+}
+
+func TestApplySpec_Environments(t *testing.T) {
+	resetFormVariables(t)
+
+	opts, err := parseFlags(nil, io.Discard)
+	require.NoError(t, err)
+	spec := models.NewAppSpec(&models.AppConfig{Environments: []models.Environment{{Name: "staging"}}})
+	applySpec(spec, opts)
+	assert.Equal(t, []string{"staging"}, environmentNames(environments))
+	assert.True(t, opts.isSet("environments"))
+
+	// --environments replaces the environments of the spec
+	resetFormVariables(t)
+	opts, err = parseFlags([]string{"--environments", "production"}, io.Discard)
+	require.NoError(t, err)
+	applySpec(spec, opts)
+	assert.Equal(t, []string{"production"}, environmentNames(environments))
+}
+
+func TestParseEnvironmentValues(t *testing.T) {
+	values, err := parseEnvironmentValues("replicaCount: 3\n")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"replicaCount": 3}, values)
+
+	values, err = parseEnvironmentValues("")
+	require.NoError(t, err)
+	assert.Nil(t, values)
+
+	_, err = parseEnvironmentValues("- not a mapping")
+	assert.Error(t, err)
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/filesystem"
+	"github.com/EffectiveSloth/flux-app-generator/internal/helm"
+	"github.com/EffectiveSloth/flux-app-generator/internal/models"
+	"github.com/EffectiveSloth/flux-app-generator/internal/semver"
+	"github.com/EffectiveSloth/flux-app-generator/internal/values"
+)
+
+// Default values applied when the corresponding flag is not provided.
+const (
+	defaultInterval      = "5m"
+	defaultValuesPrefill = "default"
+)
+
+// cliOptions holds the parsed command-line flags that are not stored directly in the form variables.
+type cliOptions struct {
+	noInput   bool
+	fromFile  string
+	saveSpec  string
+	outputDir string
+	force     bool
+	dryRun    bool
+	diff      bool
+	archive   string
+	// skipSchemaValidation generates values that do not match the values schema of the chart.
+	skipSchemaValidation bool
+	auth                 helm.Auth // Repository settings given on the command line
+	cache                cacheOptions
+	network              networkOptions
+	verify               verifyOptions
+	set                  map[string]bool // Names of the flags explicitly provided on the command line.
+}
+
+// isSet reports whether the named flag was explicitly provided on the command line.
+func (o *cliOptions) isSet(name string) bool {
+	return o.set[name]
+}
+
+// requiredField describes a configuration field that must be provided before generation.
+type requiredField struct {
+	flag        string
+	description string
+	value       *string
+}
+
+// requiredFields returns the fields that must be set, in the order the wizard asks for them.
+func requiredFields() []requiredField {
+	fields := []requiredField{
+		{flag: "app-name", description: "application name", value: &appName},
+		{flag: "namespace", description: "Kubernetes namespace", value: &namespace},
+	}
+	// Charts of a GitRepository are taken from a path of the repository, at whatever version it holds
+	if sourceKind == models.SourceKindGitRepository {
+		return append(fields,
+			requiredField{flag: "repo-name", description: "GitRepository name", value: &helmRepoName},
+			requiredField{flag: "git-url", description: "Git repository URL", value: &gitURL},
+			requiredField{flag: "git-path", description: "chart path in the Git repository", value: &gitChartPath},
+			requiredField{flag: "chart", description: "chart name", value: &selectedChart},
+		)
+	}
+	fields = append(fields,
+		requiredField{flag: "repo-name", description: "Helm repository name", value: &helmRepoName},
+		requiredField{flag: "repo-url", description: "Helm repository URL", value: &helmRepoURL},
+		requiredField{flag: "chart", description: "chart name", value: &selectedChart},
+	)
+	// A version constraint is resolved to the chart version once the chart is known
+	if versionConstraint == "" {
+		fields = append(fields, requiredField{flag: "chart-version", description: "chart version", value: &selectedVersion})
+	}
+	return fields
+}
+
+// newFlagSet creates the flag set binding every flag to its form variable.
+func newFlagSet(opts *cliOptions, output io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet("flux-app-generator", flag.ContinueOnError)
+	fs.SetOutput(output)
+
+	fs.StringVar(&appName, "app-name", "", "name of the Flux application")
+	fs.StringVar(&namespace, "namespace", "", "Kubernetes namespace for the application")
+	fs.StringVar(&helmRepoName, "repo-name", "", "name for the HelmRepository resource")
+	fs.StringVar(&helmRepoURL, "repo-url", "", "URL of the Helm repository (https:// or oci://)")
+	fs.StringVar(&selectedChart, "chart", "", "chart to deploy from the Helm repository")
+	fs.StringVar(&selectedVersion, "chart-version", "", "version of the chart to deploy")
+	fs.StringVar(&versionConstraint, "version-constraint", "", "deploy the newest chart version matching this semver constraint (e.g. ~1.4 or '>=2 <3')")
+	fs.BoolVar(&trackConstraint, "track-constraint", false, "write --version-constraint into the HelmRelease so Flux follows new matching versions")
+	fs.BoolVar(&includePrereleases, "include-prereleases", false, "offer prerelease chart versions, hidden by default")
+	fs.BoolVar(&includeDeprecated, "include-deprecated", false, "offer deprecated charts in the chart selector, hidden by default")
+	fs.StringVar(&localChartPath, "chart-path", "", "local chart directory or packaged .tgz, deployed through a GitRepository instead of --repo-url")
+	fs.StringVar(&gitURL, "git-url", "", "URL of the Git repository holding --chart-path, its origin remote by default")
+	fs.StringVar(&gitBranch, "git-branch", "", "branch of the Git repository holding --chart-path, its checked out branch by default")
+	fs.StringVar(&gitChartPath, "git-path", "", "path of --chart-path in the Git repository, detected from the working tree by default")
+	fs.StringVar(&kubeVersion, "kube-version", "", "Kubernetes version checked against the kubeVersion of the chart, the version of the cluster by default")
+	fs.StringVar(&interval, "interval", defaultInterval, "how often Flux should check for changes")
+	fs.StringVar(&sourceKind, "source-kind", "", "Flux source of the chart (HelmRepository|OCIRepository|GitRepository), HelmRepository by default")
+	fs.StringVar(&valuesPrefill, "values-prefill", defaultValuesPrefill, "how to initialize the Helm values file (default|overrides|empty)")
+	fs.Func("values-keys", "comma-separated keys of the chart values to override with --values-prefill overrides (e.g. image,resources.limits)", func(s string) error {
+		valuesKeys = splitList(s)
+		return nil
+	})
+	fs.Func("subcharts", "comma-separated subcharts to enable or disable through their condition (e.g. redis=true,postgresql=false)", func(s string) error {
+		toggles, err := parseSubchartToggles(s)
+		if err != nil {
+			return err
+		}
+		subchartToggles = toggles
+		return nil
+	})
+	addRepoAuthFlags(fs, &opts.auth)
+	addCacheFlags(fs, &opts.cache)
+	addNetworkFlags(fs, &opts.network)
+	addVerifyFlags(fs, &opts.verify)
+	addCosignFlags(fs)
+	addValuesFromFlags(fs)
+	addReleaseFlags(fs)
+	addNamespaceFlags(fs)
+	addEnvironmentFlags(fs)
+	addKustomizationFlags(fs)
+	fs.StringVar(&repoSecretRef, "repo-secret-ref", "", "Secret with the repository credentials, referenced by the generated source")
+	fs.StringVar(&repoCertSecretRef, "repo-cert-secret-ref", "", "Secret with the repository CA and client certificate, referenced by the generated source")
+	fs.StringVar(&repoExternalSecret.storeName, "repo-secret-store", "", "create the --repo-secret-ref Secret with an ExternalSecret from this secret store")
+	fs.StringVar(&repoExternalSecret.storeKind, "repo-secret-store-kind", "ClusterSecretStore", "kind of the --repo-secret-store (ClusterSecretStore|SecretStore)")
+	fs.StringVar(&repoExternalSecret.key, "repo-secret-key", "", "key of the --repo-secret-store entry holding the username and password")
+	fs.BoolVar(&opts.noInput, "no-input", false, "never prompt; fail if a required field is missing")
+	fs.StringVar(&opts.fromFile, "from-file", "", "read the application from a YAML or JSON app spec file; flags override its fields")
+	fs.StringVar(&opts.outputDir, "output-dir", "", "root directory the app directory is generated in (e.g. apps/staging)")
+	fs.BoolVar(&opts.force, "force", false, "overwrite the files of an existing app directory")
+	fs.BoolVar(&opts.dryRun, "dry-run", false, "print the rendered manifests instead of writing them")
+	fs.BoolVar(&opts.skipSchemaValidation, "skip-schema-validation", false, "generate values that do not match the values.schema.json of the chart")
+	fs.BoolVar(&opts.diff, "diff", false, "print a unified diff against the existing app directory instead of writing; exits with status 2 when there are differences")
+	fs.StringVar(&opts.archive, "archive", "", "write the app to a .tar, .tar.gz or .zip archive instead of the output directory")
+	fs.StringVar(&opts.saveSpec, "save-spec", "", "save the collected answers to a YAML or JSON app spec file for later replay")
+
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(fs.Output(), "Usage: flux-app-generator [flags]\n")
+		_, _ = fmt.Fprintf(fs.Output(), "       flux-app-generator upgrade [flags] <app-dir>\n")
+		_, _ = fmt.Fprintf(fs.Output(), "       flux-app-generator validate [flags] <app-dir>\n")
+		_, _ = fmt.Fprintf(fs.Output(), "       flux-app-generator cache clean\n\n")
+		_, _ = fmt.Fprintf(fs.Output(), "Any field not provided through flags is asked for interactively unless --no-input is set.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	return fs
+}
+
+// parseFlags parses the command-line arguments into the form variables.
+func parseFlags(args []string, output io.Writer) (*cliOptions, error) {
+	opts := &cliOptions{set: make(map[string]bool)}
+	fs := newFlagSet(opts, output)
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	if opts.dryRun && opts.diff {
+		return nil, fmt.Errorf("--dry-run and --diff cannot be used together")
+	}
+	if opts.archive != "" {
+		if opts.dryRun || opts.diff {
+			return nil, fmt.Errorf("--archive cannot be used with --dry-run or --diff")
+		}
+		if _, err := filesystem.ArchiveFormatFromPath(opts.archive); err != nil {
+			return nil, fmt.Errorf("invalid --archive: %w", err)
+		}
+	}
+	fs.Visit(func(f *flag.Flag) {
+		opts.set[f.Name] = true
+	})
+
+	if localChartPath != "" {
+		for _, name := range []string{"repo-url", "chart", "chart-version", "version-constraint"} {
+			if opts.set[name] {
+				return nil, fmt.Errorf("--chart-path cannot be used with --%s", name)
+			}
+		}
+		if opts.set["source-kind"] && sourceKind != models.SourceKindGitRepository {
+			return nil, fmt.Errorf("--chart-path requires --source-kind %s", models.SourceKindGitRepository)
+		}
+	}
+
+	if repoExternalSecret.storeName != "" && (repoSecretRef == "" || repoExternalSecret.key == "") {
+		return nil, fmt.Errorf("--repo-secret-store requires --repo-secret-ref and --repo-secret-key")
+	}
+
+	// Cosign settings imply the verification
+	if opts.set["cosign-secret-ref"] || opts.set["cosign-oidc-issuer"] || opts.set["cosign-oidc-subject"] {
+		if opts.set["cosign-verify"] && !cosign.verify {
+			return nil, fmt.Errorf("--cosign-secret-ref, --cosign-oidc-issuer and --cosign-oidc-subject require --cosign-verify")
+		}
+		cosign.verify = true
+		opts.set["cosign-verify"] = true
+	}
+
+	// Namespace settings imply its manifest
+	if err := applyNamespaceFlags(opts); err != nil {
+		return nil, err
+	}
+
+	// Picking values keys implies an overrides-only values file
+	if opts.set["values-keys"] {
+		if !opts.set["values-prefill"] {
+			valuesPrefill = "overrides"
+			opts.set["values-prefill"] = true
+		} else if valuesPrefill != "overrides" {
+			return nil, fmt.Errorf("--values-keys requires --values-prefill overrides")
+		}
+	}
+
+	if err := applyEnvironmentFlags(); err != nil {
+		return nil, err
+	}
+	if err := validateKustomizationFlags(opts); err != nil {
+		return nil, err
+	}
+	if err := validateFlagValues(); err != nil {
+		return nil, err
+	}
+	if err := validateCacheFlags(opts.cache); err != nil {
+		return nil, err
+	}
+	if err := validateNetworkFlags(opts.network); err != nil {
+		return nil, err
+	}
+
+	return opts, nil
+}
+
+// validateFlagValues checks the values that have a restricted format.
+func validateFlagValues() error {
+	if _, err := time.ParseDuration(interval); err != nil {
+		return fmt.Errorf("invalid --interval %q: %w", interval, err)
+	}
+	if valuesPrefill != "default" && valuesPrefill != "overrides" && valuesPrefill != "empty" {
+		return fmt.Errorf("invalid --values-prefill %q: must be 'default', 'overrides' or 'empty'", valuesPrefill)
+	}
+	switch sourceKind {
+	case "", models.SourceKindHelmRepository, models.SourceKindOCIRepository, models.SourceKindGitRepository:
+	default:
+		return fmt.Errorf("invalid --source-kind %q: must be '%s', '%s' or '%s'", sourceKind,
+			models.SourceKindHelmRepository, models.SourceKindOCIRepository, models.SourceKindGitRepository)
+	}
+	if repoExternalSecret.storeKind != "ClusterSecretStore" && repoExternalSecret.storeKind != "SecretStore" {
+		return fmt.Errorf("invalid --repo-secret-store-kind %q: must be 'ClusterSecretStore' or 'SecretStore'", repoExternalSecret.storeKind)
+	}
+	for _, key := range valuesKeys {
+		if _, err := values.ParsePath(key); err != nil {
+			return fmt.Errorf("invalid --values-keys: %w", err)
+		}
+	}
+	if kubeVersion != "" {
+		if _, err := semver.Parse(kubeVersion); err != nil {
+			return fmt.Errorf("invalid --kube-version %q: %w", kubeVersion, err)
+		}
+	}
+	return validateVersionConstraint()
+}
+
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// missingFields returns the required fields that have not been provided yet.
+func missingFields() []requiredField {
+	var missing []requiredField
+	for _, field := range requiredFields() {
+		if *field.value == "" {
+			missing = append(missing, field)
+		}
+	}
+	return missing
+}
+
+// formatMissingFields renders the missing fields as a list of flags for error messages.
+func formatMissingFields(missing []requiredField) string {
+	var b strings.Builder
+	b.WriteString("missing required fields:")
+	for _, field := range missing {
+		fmt.Fprintf(&b, "\n   --%s (%s)", field.flag, field.description)
+	}
+	return b.String()
+}
+
+// specFieldFlags maps the app spec fields checked by AppConfig.Validate to the flags setting them.
+// Fields of list items, such as spec.valuesSources[0].name, map through their list.
+var specFieldFlags = map[string]string{
+	"spec.cosignVerify":                      "cosign-verify",
+	"spec.cosignSecretRef":                   "cosign-secret-ref",
+	"spec.cosignOIDCIssuer":                  "cosign-oidc-issuer",
+	"spec.cosignOIDCSubject":                 "cosign-oidc-subject",
+	"spec.environments.name":                 "environments",
+	"spec.environments.chartVersion":         "env-chart-version",
+	"spec.environments.interval":             "env-interval",
+	"spec.valuesKind":                        "values-kind",
+	"spec.valuesSources":                     "values-sources",
+	"spec.release.releaseName":               "release-name",
+	"spec.release.targetNamespace":           "release-target-namespace",
+	"spec.release.serviceAccountName":        "release-service-account",
+	"spec.release.timeout":                   "release-timeout",
+	"spec.release.maxHistory":                "release-max-history",
+	"spec.release.dependsOn":                 "release-depends-on",
+	"spec.release.installRetries":            "install-retries",
+	"spec.release.upgradeRetries":            "upgrade-retries",
+	"spec.release.installCRDs":               "install-crds",
+	"spec.release.upgradeCRDs":               "upgrade-crds",
+	"spec.release.driftDetection":            "drift-detection",
+	"spec.namespaceManifest.podSecurity":     "pod-security",
+	"spec.namespaceManifest.labels":          "namespace-labels",
+	"spec.namespaceManifest.annotations":     "namespace-annotations",
+	"spec.fluxKustomization.clustersDir":     "clusters-dir",
+	"spec.fluxKustomization.targetNamespace": "kustomization-target-namespace",
+	"spec.fluxKustomization.timeout":         "kustomization-timeout",
+	"spec.fluxKustomization.dependsOn":       "kustomization-depends-on",
+	"spec.fluxKustomization.healthChecks":    "kustomization-health-checks",
+}
+
+// specIndex matches the list indexes of app spec fields.
+var specIndex = regexp.MustCompile(`\[\d+\]`)
+
+// flagError reports an invalid app spec field by the flag setting it, for configurations
+// collected from flags and the wizard rather than read from an app spec. Other errors are
+// returned unchanged.
+func flagError(err error) error {
+	var specErr *models.SpecError
+	if !errors.As(err, &specErr) {
+		return err
+	}
+	for field := specIndex.ReplaceAllString(specErr.Field, ""); field != ""; {
+		if name, ok := specFieldFlags[field]; ok {
+			return fmt.Errorf("invalid --%s: %s", name, specErr.Message)
+		}
+		i := strings.LastIndex(field, ".")
+		if i < 0 {
+			break
+		}
+		field = field[:i]
+	}
+	return err
+}
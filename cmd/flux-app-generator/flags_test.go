@@ -0,0 +1,211 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/helm"
+	"github.com/EffectiveSloth/flux-app-generator/internal/models"
+)
+
+// resetFormVariables sets every form variable, including the ones applySpec fills from an app spec,
+// to its zero value before the test runs and again once it completes.
+func resetFormVariables(t *testing.T) {
+	t.Helper()
+	reset := func() {
+		appName = ""
+		namespace = ""
+		helmRepoName = ""
+		helmRepoURL = ""
+		selectedChart = ""
+		selectedVersion = ""
+		interval = ""
+		valuesPrefill = ""
+		valuesKeys = nil
+		valuesKind, valuesSources = "", nil
+		subchartToggles = nil
+		environments = nil
+		environmentFlags.chartVersions, environmentFlags.intervals = nil, nil
+		fluxKustomization.clustersDir, fluxKustomization.namespace, fluxKustomization.sourceRef = "", "", ""
+		fluxKustomization.prune, fluxKustomization.wait = false, false
+		fluxKustomization.timeout, fluxKustomization.targetNamespace = "", ""
+		fluxKustomization.dependsOn, fluxKustomization.healthChecks = nil, nil
+		release = models.ReleaseOptions{}
+		namespaceManifest.generate, namespaceManifest.kustomization = false, false
+		namespaceManifest.labels, namespaceManifest.annotations, namespaceManifest.podSecurity = nil, nil, ""
+		sourceKind = ""
+		versionConstraint = ""
+		trackConstraint = false
+		includePrereleases = false
+		includeDeprecated = false
+		repoAuth = helm.Auth{}
+		repoSecretRef = ""
+		repoCertSecretRef = ""
+		repoExternalSecret.storeKind, repoExternalSecret.storeName, repoExternalSecret.key = "", "", ""
+		specValues = nil
+		pluginInstances = nil
+		kubeVersion = ""
+		chartInfo = nil
+		localChartPath, gitURL, gitBranch, gitChartPath = "", "", "", ""
+		cosign.verify, cosign.secretRef, cosign.oidcIssuer, cosign.oidcSubject = false, "", "", ""
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+func TestParseFlags_AllFields(t *testing.T) {
+	resetFormVariables(t)
+
+	opts, err := parseFlags([]string{
+		"--app-name", "my-app",
+		"--namespace", "apps",
+		"--repo-name", "bitnami",
+		"--repo-url", "https://charts.bitnami.com/bitnami",
+		"--chart", "nginx",
+		"--chart-version", "15.0.0",
+		"--interval", "10m",
+		"--values-prefill", "empty",
+		"--no-input",
+	}, io.Discard)
+	require.NoError(t, err)
+
+	assert.True(t, opts.noInput)
+	assert.Equal(t, "my-app", appName)
+	assert.Equal(t, "apps", namespace)
+	assert.Equal(t, "bitnami", helmRepoName)
+	assert.Equal(t, "https://charts.bitnami.com/bitnami", helmRepoURL)
+	assert.Equal(t, "nginx", selectedChart)
+	assert.Equal(t, "15.0.0", selectedVersion)
+	assert.Equal(t, "10m", interval)
+	assert.Equal(t, "empty", valuesPrefill)
+	assert.True(t, opts.isSet("interval"))
+	assert.True(t, opts.isSet("values-prefill"))
+	assert.Empty(t, missingFields())
+}
+
+func TestParseFlags_Defaults(t *testing.T) {
+	resetFormVariables(t)
+
+	opts, err := parseFlags([]string{"--app-name", "my-app"}, io.Discard)
+	require.NoError(t, err)
+
+	assert.False(t, opts.noInput)
+	assert.Equal(t, defaultInterval, interval)
+	assert.Equal(t, defaultValuesPrefill, valuesPrefill)
+	assert.True(t, opts.isSet("app-name"))
+	assert.False(t, opts.isSet("interval"))
+	assert.False(t, opts.isSet("values-prefill"))
+}
+
+func TestParseFlags_InvalidValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		message string
+	}{
+		{"invalid interval", []string{"--interval", "often"}, "invalid --interval"},
+		{"invalid values prefill", []string{"--values-prefill", "partial"}, "invalid --values-prefill"},
+		{"unexpected argument", []string{"extra"}, "unexpected arguments: extra"},
+		{"unknown flag", []string{"--unknown"}, "flag provided but not defined"},
+		{"invalid archive", []string{"--archive", "app.rar"}, "invalid --archive"},
+		{"archive with dry run", []string{"--archive", "app.zip", "--dry-run"}, "cannot be used with"},
+		{"values keys with default prefill", []string{"--values-keys", "image", "--values-prefill", "default"}, "requires --values-prefill overrides"},
+		{"invalid source kind", []string{"--source-kind", "Bucket"}, "invalid --source-kind"},
+		{"secret store without secret ref", []string{"--repo-secret-store", "vault", "--repo-secret-key", "charts"}, "requires --repo-secret-ref"},
+		{"invalid secret store kind", []string{"--repo-secret-store-kind", "Vault"}, "invalid --repo-secret-store-kind"},
+		{"invalid values key", []string{"--values-keys", `podLabels."app`}, "invalid --values-keys"},
+		{"invalid version constraint", []string{"--version-constraint", "~latest"}, "invalid --version-constraint"},
+		{"tracking without constraint", []string{"--track-constraint"}, "requires --version-constraint"},
+		{"negative cache ttl", []string{"--cache-ttl", "-1h"}, "invalid --cache-ttl"},
+		{"negative timeout", []string{"--timeout", "-1s"}, "invalid --timeout"},
+		{"negative retries", []string{"--retries", "-1"}, "invalid --retries"},
+		{"invalid subchart toggle", []string{"--subcharts", "redis=yes"}, "not key=true|false"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetFormVariables(t)
+
+			_, err := parseFlags(tt.args, io.Discard)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.message)
+		})
+	}
+}
+
+func TestParseFlags_ValuesKeys(t *testing.T) {
+	resetFormVariables(t)
+
+	opts, err := parseFlags([]string{"--values-keys", "image, resources.limits,,"}, io.Discard)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"image", "resources.limits"}, valuesKeys)
+	assert.Equal(t, "overrides", valuesPrefill)
+	assert.True(t, opts.isSet("values-prefill"))
+}
+
+func TestParseFlags_Help(t *testing.T) {
+	resetFormVariables(t)
+
+	var out strings.Builder
+	_, err := parseFlags([]string{"--help"}, &out)
+	assert.True(t, errors.Is(err, flag.ErrHelp))
+	for _, command := range []string{"upgrade [flags] <app-dir>", "validate [flags] <app-dir>", "cache clean"} {
+		assert.Contains(t, out.String(), "flux-app-generator "+command+"\n")
+	}
+}
+
+func TestMissingFields(t *testing.T) {
+	resetFormVariables(t)
+
+	_, err := parseFlags([]string{"--app-name", "my-app", "--repo-url", "https://example.com"}, io.Discard)
+	require.NoError(t, err)
+
+	missing := missingFields()
+	flags := make([]string, len(missing))
+	for i, field := range missing {
+		flags[i] = field.flag
+	}
+	assert.Equal(t, []string{"namespace", "repo-name", "chart", "chart-version"}, flags)
+
+	message := formatMissingFields(missing)
+	assert.Contains(t, message, "--namespace (Kubernetes namespace)")
+	assert.Contains(t, message, "--chart-version (chart version)")
+	assert.NotContains(t, message, "--app-name")
+}
+
+func TestParseFlags_DryRunAndDiffConflict(t *testing.T) {
+	resetFormVariables(t)
+
+	_, err := parseFlags([]string{"--dry-run", "--diff"}, io.Discard)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot be used together")
+}
+
+func TestFlagError(t *testing.T) {
+	tests := []struct {
+		field string
+		want  string
+	}{
+		{"spec.release.timeout", "invalid --release-timeout: bad value"},
+		{"spec.valuesSources[1].name", "invalid --values-sources: bad value"},
+		{"spec.environments[0].chartVersion", "invalid --env-chart-version: bad value"},
+		{"spec.fluxKustomization.healthChecks[2].kind", "invalid --kustomization-health-checks: bad value"},
+		{"spec.unknown", "invalid app spec field 'spec.unknown': bad value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			err := flagError(&models.SpecError{Field: tt.field, Message: "bad value"})
+			assert.EqualError(t, err, tt.want)
+		})
+	}
+
+	err := errors.New("other")
+	assert.Equal(t, err, flagError(err))
+}
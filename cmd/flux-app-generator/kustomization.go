@@ -0,0 +1,291 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/huh"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/generator"
+	"github.com/EffectiveSloth/flux-app-generator/internal/git"
+	"github.com/EffectiveSloth/flux-app-generator/internal/models"
+)
+
+// fluxKustomization holds the Flux Kustomization reconciling the app, generated when clustersDir is set.
+var fluxKustomization struct {
+	clustersDir     string
+	namespace       string
+	sourceRef       string
+	prune           bool
+	wait            bool
+	timeout         string
+	targetNamespace string
+	dependsOn       []string
+	healthChecks    []models.HealthCheck
+}
+
+// healthCheckAPIVersions are the API versions of the kinds health checks can omit it for.
+var healthCheckAPIVersions = map[string]string{
+	"HelmRelease": "helm.toolkit.fluxcd.io/v2",
+	"Deployment":  "apps/v1",
+	"StatefulSet": "apps/v1",
+	"DaemonSet":   "apps/v1",
+}
+
+// addKustomizationFlags adds the flags generating the Flux Kustomization of the app.
+func addKustomizationFlags(fs *flag.FlagSet) {
+	fs.StringVar(&fluxKustomization.clustersDir, "clusters-dir", "", "cluster directory to write a Flux Kustomization reconciling the app to (e.g. clusters/production)")
+	fs.StringVar(&fluxKustomization.namespace, "kustomization-namespace", models.DefaultFluxNamespace, "namespace of the Flux Kustomization")
+	fs.StringVar(&fluxKustomization.sourceRef, "kustomization-source", models.DefaultFluxNamespace, "GitRepository the Flux Kustomization reads the app from")
+	fs.BoolVar(&fluxKustomization.prune, "kustomization-prune", true, "have the Flux Kustomization delete the resources removed from the app")
+	fs.BoolVar(&fluxKustomization.wait, "kustomization-wait", false, "have the Flux Kustomization wait for every resource of the app to be ready")
+	fs.StringVar(&fluxKustomization.timeout, "kustomization-timeout", "", "timeout of the apply and health checks of the Flux Kustomization (e.g. 5m)")
+	fs.StringVar(&fluxKustomization.targetNamespace, "kustomization-target-namespace", "", "namespace the Flux Kustomization moves every resource of the app to")
+	fs.Func("kustomization-depends-on", "comma-separated Kustomizations reconciled before the app, as name or namespace/name", func(s string) error {
+		fluxKustomization.dependsOn = splitList(s)
+		return nil
+	})
+	fs.Func("kustomization-health-checks", "comma-separated resources the Flux Kustomization checks, as [apiVersion/]Kind/name (e.g. HelmRelease/podinfo)", func(s string) error {
+		checks, err := parseHealthChecks(s)
+		if err != nil {
+			return err
+		}
+		fluxKustomization.healthChecks = checks
+		return nil
+	})
+}
+
+// validateKustomizationFlags checks that the Flux Kustomization flags come with --clusters-dir.
+func validateKustomizationFlags(opts *cliOptions) error {
+	if fluxKustomization.clustersDir == "" {
+		for name := range opts.set {
+			if strings.HasPrefix(name, "kustomization-") {
+				return fmt.Errorf("--%s requires --clusters-dir", name)
+			}
+		}
+		return nil
+	}
+	if fluxKustomization.timeout != "" {
+		if _, err := time.ParseDuration(fluxKustomization.timeout); err != nil {
+			return fmt.Errorf("invalid --kustomization-timeout %q: %w", fluxKustomization.timeout, err)
+		}
+	}
+	return nil
+}
+
+// parseHealthChecks parses a comma-separated list of [apiVersion/]Kind/name health checks.
+func parseHealthChecks(s string) ([]models.HealthCheck, error) {
+	var checks []models.HealthCheck
+	for _, item := range splitList(s) {
+		parts := strings.Split(item, "/")
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("%q is not [apiVersion/]Kind/name", item)
+		}
+		check := models.HealthCheck{
+			APIVersion: strings.Join(parts[:len(parts)-2], "/"),
+			Kind:       parts[len(parts)-2],
+			Name:       parts[len(parts)-1],
+		}
+		if check.Kind == "" || check.Name == "" {
+			return nil, fmt.Errorf("%q is not [apiVersion/]Kind/name", item)
+		}
+		if check.APIVersion == "" {
+			apiVersion, ok := healthCheckAPIVersions[check.Kind]
+			if !ok {
+				return nil, fmt.Errorf("%q needs the apiVersion of %s", item, check.Kind)
+			}
+			check.APIVersion = apiVersion
+		}
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
+
+// formatHealthChecks formats health checks the way --kustomization-health-checks takes them.
+func formatHealthChecks(checks []models.HealthCheck) string {
+	items := make([]string, len(checks))
+	for i, check := range checks {
+		items[i] = check.Kind + "/" + check.Name
+		if healthCheckAPIVersions[check.Kind] != check.APIVersion {
+			items[i] = check.APIVersion + "/" + items[i]
+		}
+	}
+	return strings.Join(items, ",")
+}
+
+// applyKustomizationSpec fills the Flux Kustomization from an app spec, unless --clusters-dir is
+// set. An app spec without one generates no Flux Kustomization.
+func applyKustomizationSpec(k *models.FluxKustomization, opts *cliOptions) {
+	if opts.isSet("clusters-dir") {
+		return
+	}
+	opts.set["clusters-dir"] = true
+	if k == nil {
+		fluxKustomization.clustersDir = ""
+		return
+	}
+	fluxKustomization.clustersDir = k.ClustersDir
+	fluxKustomization.namespace = k.Namespace
+	fluxKustomization.sourceRef = k.SourceRef
+	fluxKustomization.prune = k.Prune
+	fluxKustomization.wait = k.Wait
+	fluxKustomization.timeout = k.Timeout
+	fluxKustomization.targetNamespace = k.TargetNamespace
+	fluxKustomization.dependsOn = append([]string(nil), k.DependsOn...)
+	fluxKustomization.healthChecks = append([]models.HealthCheck(nil), k.HealthChecks...)
+}
+
+// buildFluxKustomization returns the Flux Kustomization of the app, nil when none is generated.
+func buildFluxKustomization() *models.FluxKustomization {
+	if fluxKustomization.clustersDir == "" {
+		return nil
+	}
+	return &models.FluxKustomization{
+		ClustersDir:     fluxKustomization.clustersDir,
+		Namespace:       fluxKustomization.namespace,
+		SourceRef:       fluxKustomization.sourceRef,
+		Prune:           fluxKustomization.prune,
+		Wait:            fluxKustomization.wait,
+		Timeout:         fluxKustomization.timeout,
+		TargetNamespace: fluxKustomization.targetNamespace,
+		DependsOn:       append([]string(nil), fluxKustomization.dependsOn...),
+		HealthChecks:    append([]models.HealthCheck(nil), fluxKustomization.healthChecks...),
+	}
+}
+
+// appRepositoryPath returns the path the Flux Kustomization reconciles the app directory at: its
+// path in the Git repository containing it, or outside of one its path relative to the working
+// directory, assumed to be the root of the repository.
+func appRepositoryPath(appDir string) (string, error) {
+	if repo, err := git.Discover(appDir); err == nil {
+		return repo.RelativePath(appDir)
+	}
+	if filepath.IsAbs(appDir) {
+		return "", fmt.Errorf("cannot tell the path of %s in the Git repository, use a relative --output-dir", appDir)
+	}
+	return "./" + filepath.ToSlash(filepath.Clean(appDir)), nil
+}
+
+// promptFluxKustomization asks whether to generate the Flux Kustomization reconciling the app,
+// and with which settings.
+func promptFluxKustomization(opts *cliOptions) error {
+	if opts.isSet("clusters-dir") {
+		return nil
+	}
+
+	var generate bool
+	confirm := huh.NewForm(
+		huh.NewGroup(
+			huh.NewConfirm().
+				Title("Generate Flux Kustomization?").
+				Description("Write the Flux Kustomization reconciling the app from Git into a cluster directory").
+				Value(&generate),
+		),
+	).WithTheme(huh.ThemeCharm())
+	if err := confirm.Run(); err != nil {
+		return err
+	}
+	if !generate {
+		return nil
+	}
+
+	dependsOn := strings.Join(fluxKustomization.dependsOn, ",")
+	healthChecks := formatHealthChecks(fluxKustomization.healthChecks)
+	if healthChecks == "" {
+		healthChecks = "HelmRelease/" + appName
+	}
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Clusters Directory").
+				Description("Cluster directory of the Flux repository the Kustomization is written to").
+				Placeholder("clusters/production").
+				Value(&fluxKustomization.clustersDir).
+				Validate(func(s string) error {
+					if strings.TrimSpace(s) == "" {
+						return fmt.Errorf("clusters directory is required")
+					}
+					return nil
+				}),
+			huh.NewInput().
+				Title("Source").
+				Description("GitRepository the app is read from").
+				Value(&fluxKustomization.sourceRef),
+			huh.NewConfirm().
+				Title("Prune").
+				Description("Delete the resources removed from the app").
+				Value(&fluxKustomization.prune),
+			huh.NewConfirm().
+				Title("Wait").
+				Description("Wait for every resource of the app to be ready, instead of the health checks").
+				Value(&fluxKustomization.wait),
+		).Title("🔁 Flux Kustomization"),
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Timeout").
+				Description("Timeout of the apply and health checks (leave empty for the interval)").
+				Placeholder("5m").
+				Value(&fluxKustomization.timeout).
+				Validate(func(s string) error {
+					if s == "" {
+						return nil
+					}
+					_, err := time.ParseDuration(s)
+					return err
+				}),
+			huh.NewInput().
+				Title("Target Namespace").
+				Description("Namespace every resource of the app is moved to (leave empty to keep theirs)").
+				Value(&fluxKustomization.targetNamespace),
+			huh.NewInput().
+				Title("Depends On").
+				Description("Comma-separated Kustomizations reconciled before the app, as name or namespace/name").
+				Placeholder("infrastructure").
+				Value(&dependsOn),
+		).Title("🔁 Flux Kustomization"),
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Health Checks").
+				Description("Comma-separated resources that must be ready, as [apiVersion/]Kind/name").
+				Value(&healthChecks).
+				Validate(func(s string) error {
+					_, err := parseHealthChecks(s)
+					return err
+				}),
+		).Title("🔁 Flux Kustomization").WithHideFunc(func() bool {
+			return fluxKustomization.wait
+		}),
+	).WithTheme(huh.ThemeCharm())
+	if err := form.Run(); err != nil {
+		return err
+	}
+
+	fluxKustomization.dependsOn = splitList(dependsOn)
+	checks, err := parseHealthChecks(healthChecks)
+	if err != nil {
+		return err
+	}
+	if fluxKustomization.wait {
+		// Flux ignores the health checks of Kustomizations that wait for every resource
+		checks = nil
+	}
+	fluxKustomization.healthChecks = checks
+	return nil
+}
+
+// fluxKustomizationFiles returns the paths of the Flux Kustomizations of the app.
+func fluxKustomizationFiles(config *models.AppConfig) ([]string, error) {
+	files, err := generator.FluxKustomizationFiles(config)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, len(files))
+	for i, file := range files {
+		paths[i] = path.Join(filepath.ToSlash(config.FluxKustomization.ClustersDir), file.Path)
+	}
+	return paths, nil
+}
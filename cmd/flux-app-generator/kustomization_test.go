@@ -0,0 +1,135 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/models"
+)
+
+func TestParseFlags_FluxKustomization(t *testing.T) {
+	resetFormVariables(t)
+
+	_, err := parseFlags([]string{
+		"--clusters-dir", "clusters/production",
+		"--kustomization-wait",
+		"--kustomization-timeout", "3m",
+		"--kustomization-target-namespace", "apps",
+		"--kustomization-depends-on", "infrastructure, flux-system/crds",
+		"--kustomization-health-checks", "HelmRelease/podinfo,source.toolkit.fluxcd.io/v1/HelmRepository/podinfo",
+	}, io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, &models.FluxKustomization{
+		ClustersDir:     "clusters/production",
+		Namespace:       "flux-system",
+		SourceRef:       "flux-system",
+		Prune:           true,
+		Wait:            true,
+		Timeout:         "3m",
+		TargetNamespace: "apps",
+		DependsOn:       []string{"infrastructure", "flux-system/crds"},
+		HealthChecks: []models.HealthCheck{
+			{APIVersion: "helm.toolkit.fluxcd.io/v2", Kind: "HelmRelease", Name: "podinfo"},
+			{APIVersion: "source.toolkit.fluxcd.io/v1", Kind: "HelmRepository", Name: "podinfo"},
+		},
+	}, buildConfig().FluxKustomization)
+
+	// No Flux Kustomization is generated without a clusters directory
+	resetFormVariables(t)
+	_, err = parseFlags(nil, io.Discard)
+	require.NoError(t, err)
+	assert.Nil(t, buildConfig().FluxKustomization)
+}
+
+func TestParseFlags_InvalidFluxKustomization(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		message string
+	}{
+		{"setting without clusters dir", []string{"--kustomization-wait"}, "--kustomization-wait requires --clusters-dir"},
+		{"invalid timeout", []string{"--clusters-dir", "clusters", "--kustomization-timeout", "soon"}, "invalid --kustomization-timeout"},
+		{"health check of an unknown kind", []string{"--clusters-dir", "clusters", "--kustomization-health-checks", "Job/migrate"}, "needs the apiVersion of Job"},
+		{"health check without name", []string{"--clusters-dir", "clusters", "--kustomization-health-checks", "Deployment"}, "is not [apiVersion/]Kind/name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetFormVariables(t)
+
+			_, err := parseFlags(tt.args, io.Discard)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.message)
+		})
+	}
+}
+
+func TestFormatHealthChecks(t *testing.T) {
+	checks, err := parseHealthChecks("Deployment/web, monitoring.coreos.com/v1/ServiceMonitor/web")
+	require.NoError(t, err)
+	assert.Equal(t, "Deployment/web,monitoring.coreos.com/v1/ServiceMonitor/web", formatHealthChecks(checks))
+}
+
+func TestApplySpec_FluxKustomization(t *testing.T) {
+	resetFormVariables(t)
+
+	opts, err := parseFlags(nil, io.Discard)
+	require.NoError(t, err)
+	kustomization := &models.FluxKustomization{ClustersDir: "clusters/staging", Namespace: "flux-system", SourceRef: "fleet", DependsOn: []string{"infrastructure"}}
+	applySpec(models.NewAppSpec(&models.AppConfig{FluxKustomization: kustomization}), opts)
+	assert.Equal(t, kustomization, buildConfig().FluxKustomization)
+	assert.True(t, opts.isSet("clusters-dir"))
+
+	// A spec without Flux Kustomization generates none, and the wizard does not ask for one
+	resetFormVariables(t)
+	opts, err = parseFlags(nil, io.Discard)
+	require.NoError(t, err)
+	applySpec(models.NewAppSpec(&models.AppConfig{}), opts)
+	assert.Nil(t, buildConfig().FluxKustomization)
+	assert.True(t, opts.isSet("clusters-dir"))
+
+	// --clusters-dir replaces the Flux Kustomization of the spec
+	resetFormVariables(t)
+	opts, err = parseFlags([]string{"--clusters-dir", "clusters/production"}, io.Discard)
+	require.NoError(t, err)
+	applySpec(models.NewAppSpec(&models.AppConfig{FluxKustomization: kustomization}), opts)
+	assert.Equal(t, "clusters/production", buildConfig().FluxKustomization.ClustersDir)
+	assert.Equal(t, "flux-system", buildConfig().FluxKustomization.SourceRef)
+}
+
+func TestAppRepositoryPath(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, ".git"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, ".git", "HEAD"), []byte("ref: refs/heads/main\n"), 0o600))
+	t.Chdir(root)
+
+	path, err := appRepositoryPath(filepath.Join("apps", "podinfo"))
+	require.NoError(t, err)
+	assert.Equal(t, "./apps/podinfo", path)
+
+	path, err = appRepositoryPath(filepath.Join(root, "podinfo"))
+	require.NoError(t, err)
+	assert.Equal(t, "./podinfo", path)
+
+	_, err = appRepositoryPath(filepath.Join(filepath.Dir(root), "podinfo"))
+	assert.Error(t, err)
+}
+
+func TestAppRepositoryPath_OtherRepository(t *testing.T) {
+	root := t.TempDir()
+	for _, repo := range []string{"work", "fleet"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, repo, ".git"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(root, repo, ".git", "HEAD"), []byte("ref: refs/heads/main\n"), 0o600))
+	}
+	t.Chdir(filepath.Join(root, "work"))
+
+	// The output directory is in the fleet repository and does not exist yet
+	path, err := appRepositoryPath(filepath.Join("..", "fleet", "apps", "podinfo"))
+	require.NoError(t, err)
+	assert.Equal(t, "./apps/podinfo", path)
+}
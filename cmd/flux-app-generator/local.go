@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/huh"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/git"
+	"github.com/EffectiveSloth/flux-app-generator/internal/helm"
+	"github.com/EffectiveSloth/flux-app-generator/internal/models"
+)
+
+// defaultGitBranch is the branch of the GitRepository when it cannot be detected.
+const defaultGitBranch = "main"
+
+var (
+	// localChartPath is the local chart directory or packaged chart deployed through a GitRepository.
+	localChartPath string
+	// gitURL and gitBranch locate the Git repository Flux pulls the local chart from.
+	gitURL    string
+	gitBranch string
+	// gitChartPath is the path of the chart in the Git repository, such as ./charts/foo.
+	gitChartPath string
+)
+
+// isLocalChart reports whether a repository URL entered in the wizard is the path of a local chart.
+func isLocalChart(s string) bool {
+	if s == "" || strings.Contains(s, "://") {
+		return false
+	}
+	_, err := os.Stat(s)
+	return err == nil
+}
+
+// resolveLocalChart reads the name and version of the local chart and fills the GitRepository
+// settings from the Git repository holding it. For a replayed app spec without --chart-path the
+// chart is looked up in the Git repository of the working directory.
+func resolveLocalChart() error {
+	if localChartPath == "" {
+		if sourceKind != models.SourceKindGitRepository || gitChartPath == "" {
+			return nil
+		}
+		repo, err := git.Discover(".")
+		if err != nil {
+			return nil
+		}
+		candidate := filepath.Join(repo.Root, filepath.FromSlash(gitChartPath))
+		if _, err := os.Stat(candidate); err != nil {
+			return nil
+		}
+		localChartPath = candidate
+	}
+	if versionConstraint != "" {
+		return fmt.Errorf("a version constraint does not apply to the local chart %s", localChartPath)
+	}
+
+	chart, err := helm.LoadChartPath(localChartPath)
+	if err != nil {
+		return err
+	}
+	selectedChart, selectedVersion = chart.Metadata.Name, chart.Metadata.Version
+	sourceKind = models.SourceKindGitRepository
+	helmRepoURL = ""
+
+	repo, err := git.Discover(localChartPath)
+	switch {
+	case errors.Is(err, git.ErrNotRepository):
+		// The path of the chart in the repository is asked for or required by --git-path
+	case err != nil:
+		return err
+	default:
+		if gitChartPath == "" {
+			if gitChartPath, err = repo.RelativePath(localChartPath); err != nil {
+				return err
+			}
+		}
+		if gitURL == "" && repo.RemoteURL != "" {
+			gitURL = git.FluxURL(repo.RemoteURL)
+		}
+		if gitBranch == "" {
+			gitBranch = repo.Branch
+		}
+	}
+	if gitBranch == "" {
+		gitBranch = defaultGitBranch
+	}
+	if helmRepoName == "" && gitURL != "" {
+		helmRepoName = git.Name(gitURL)
+	}
+	return nil
+}
+
+// gitRepositoryFields asks for the GitRepository settings that could not be detected.
+func gitRepositoryFields() []huh.Field {
+	var fields []huh.Field
+	if gitURL == "" {
+		fields = append(fields, huh.NewInput().
+			Title("Git Repository URL").
+			Description("URL Flux clones the chart from, ssh:// or https://").
+			Placeholder("ssh://git@github.com/org/fleet.git").
+			Value(&gitURL).
+			Validate(func(s string) error {
+				if s == "" {
+					return fmt.Errorf("git repository URL is required")
+				}
+				return nil
+			}))
+	}
+	if gitChartPath == "" {
+		fields = append(fields, huh.NewInput().
+			Title("Chart Path").
+			Description("Path of the chart in the Git repository").
+			Placeholder("./charts/my-chart").
+			Value(&gitChartPath).
+			Validate(func(s string) error {
+				if s == "" {
+					return fmt.Errorf("chart path is required")
+				}
+				return nil
+			}))
+	}
+	return fields
+}
+
+// loadGitChart loads the chart of a GitRepository-sourced app from the working tree holding the
+// app directory, assuming the chart is in the same repository.
+func loadGitChart(appDir, chartPath string) (*helm.Chart, error) {
+	repo, err := git.Discover(appDir)
+	if err != nil {
+		return nil, err
+	}
+	return helm.LoadChartPath(filepath.Join(repo.Root, filepath.FromSlash(chartPath)))
+}
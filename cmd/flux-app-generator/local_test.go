@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/generator"
+	"github.com/EffectiveSloth/flux-app-generator/internal/models"
+)
+
+// newLocalChartRepository creates a Git working tree holding the podinfo chart in charts/podinfo.
+func newLocalChartRepository(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	files := map[string]string{
+		".git/HEAD":                            "ref: refs/heads/production\n",
+		".git/config":                          "[remote \"origin\"]\n\turl = git@github.com:acme/fleet.git\n",
+		"charts/podinfo/Chart.yaml":            "apiVersion: v2\nname: podinfo\nversion: 6.9.0\n",
+		"charts/podinfo/values.yaml":           "replicaCount: 1\n",
+		"charts/podinfo/values.schema.json":    validateTestSchema,
+		"charts/podinfo/templates/deploy.yaml": "kind: Deployment\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o750))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+	return root
+}
+
+func TestResolveLocalChart(t *testing.T) {
+	resetFormVariables(t)
+	root := newLocalChartRepository(t)
+	localChartPath = filepath.Join(root, "charts", "podinfo")
+
+	require.NoError(t, resolveLocalChart())
+	assert.Equal(t, models.SourceKindGitRepository, sourceKind)
+	assert.Equal(t, "podinfo", selectedChart)
+	assert.Equal(t, "6.9.0", selectedVersion)
+	assert.Equal(t, "./charts/podinfo", gitChartPath)
+	assert.Equal(t, "ssh://git@github.com/acme/fleet.git", gitURL)
+	assert.Equal(t, "production", gitBranch)
+	assert.Equal(t, "fleet", helmRepoName)
+
+	appName, namespace = "podinfo", "apps"
+	assert.Empty(t, missingFields())
+	config := buildConfig()
+	assert.True(t, config.UsesGitRepository())
+	assert.Equal(t, "./charts/podinfo", config.ChartPath)
+
+	defaults, err := chartDefaultValues(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "replicaCount: 1\n", defaults)
+}
+
+func TestResolveLocalChart_OutsideRepository(t *testing.T) {
+	resetFormVariables(t)
+	localChartPath = filepath.Join(t.TempDir(), "podinfo")
+	require.NoError(t, os.MkdirAll(localChartPath, 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(localChartPath, "Chart.yaml"), []byte("name: podinfo\nversion: 1.0.0\n"), 0o600))
+
+	require.NoError(t, resolveLocalChart())
+	assert.Equal(t, defaultGitBranch, gitBranch)
+	// The Git repository has to be given
+	var flags []string
+	for _, field := range missingFields() {
+		flags = append(flags, field.flag)
+	}
+	assert.Equal(t, []string{"app-name", "namespace", "repo-name", "git-url", "git-path"}, flags)
+
+	versionConstraint = "~1.0"
+	assert.ErrorContains(t, resolveLocalChart(), "version constraint does not apply")
+}
+
+func TestParseFlags_ChartPath(t *testing.T) {
+	resetFormVariables(t)
+	_, err := parseFlags([]string{"--chart-path", "charts/podinfo", "--git-branch", "main"}, io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, "charts/podinfo", localChartPath)
+	assert.Equal(t, "main", gitBranch)
+
+	tests := []struct {
+		name    string
+		args    []string
+		message string
+	}{
+		{"repository URL", []string{"--chart-path", "charts/podinfo", "--repo-url", "https://example.com"}, "cannot be used with --repo-url"},
+		{"chart version", []string{"--chart-path", "charts/podinfo", "--chart-version", "1.0.0"}, "cannot be used with --chart-version"},
+		{"source kind", []string{"--chart-path", "charts/podinfo", "--source-kind", "HelmRepository"}, "requires --source-kind GitRepository"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetFormVariables(t)
+			_, err := parseFlags(tt.args, io.Discard)
+			assert.ErrorContains(t, err, tt.message)
+		})
+	}
+}
+
+func TestRunValidate_GitRepository(t *testing.T) {
+	resetFormVariables(t)
+	require.NoError(t, loadTemplates())
+	root := newLocalChartRepository(t)
+	config := &models.AppConfig{
+		AppName:      "podinfo",
+		Namespace:    "apps",
+		HelmRepoName: "fleet",
+		SourceKind:   models.SourceKindGitRepository,
+		GitURL:       "ssh://git@github.com/acme/fleet.git",
+		GitBranch:    "main",
+		ChartPath:    "./charts/podinfo",
+		ChartName:    "podinfo",
+		Interval:     "5m",
+		Values:       map[string]interface{}{models.RawValuesKey: "image:\n  repository: ghcr.io/stefanprodan/podinfo\n"},
+	}
+	outputDir := filepath.Join(root, "apps")
+	require.NoError(t, generator.GenerateFluxStructureWithOptions(config, generator.Options{OutputDir: outputDir}))
+
+	// The chart is read from the working tree holding the app
+	var out bytes.Buffer
+	assert.Equal(t, 0, runValidate(context.Background(), []string{filepath.Join(outputDir, "podinfo")}, &out))
+	assert.Contains(t, out.String(), "matches the values schema of podinfo 6.9.0")
+}
@@ -2,17 +2,25 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"embed"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/charmbracelet/huh"
 	"github.com/charmbracelet/lipgloss"
 
+	"github.com/EffectiveSloth/flux-app-generator/internal/filesystem"
 	"github.com/EffectiveSloth/flux-app-generator/internal/generator"
 	"github.com/EffectiveSloth/flux-app-generator/internal/helm"
 	"github.com/EffectiveSloth/flux-app-generator/internal/kubernetes"
@@ -20,6 +28,9 @@ import (
 	"github.com/EffectiveSloth/flux-app-generator/internal/plugins"
 )
 
+// exitCodeDiff is the exit status used by --diff when generation would change files.
+const exitCodeDiff = 2
+
 //go:embed templates
 var templatesFS embed.FS
 
@@ -42,6 +53,7 @@ var (
 	selectedVersion string
 	interval        string
 	valuesPrefill   string
+	sourceKind      string
 	versionFetcher  = helm.NewVersionFetcher()
 
 	// Kubernetes auto-completion.
@@ -61,122 +73,609 @@ func main() {
 		log.Fatal(err)
 	}
 
-	// Show Kubernetes connection splash screen
-	showKubernetesSplashScreen()
+	// An interrupt cancels the requests in flight
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if len(os.Args) > 1 && os.Args[1] == "upgrade" {
+		os.Exit(runUpgrade(ctx, os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(runValidate(ctx, os.Args[2:], os.Stdout))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "cache" {
+		os.Exit(runCache(os.Args[2:], os.Stdout))
+	}
+
+	opts, err := parseFlags(os.Args[1:], os.Stderr)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		log.Fatal(err)
+	}
+	// Keep stdout for the manifests or the diff when previewing
+	preview := opts.dryRun || opts.diff
+	var info io.Writer = os.Stdout
+	if preview {
+		info = os.Stderr
+	}
+	if err := configureCache(opts.cache); err != nil {
+		log.Fatal(err)
+	}
+	configureNetwork(opts.network)
+	if err := configureVerification(opts.verify); err != nil {
+		log.Fatal(err)
+	}
+
+	if opts.fromFile != "" {
+		spec, err := models.LoadSpec(opts.fromFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		applySpec(spec, opts)
+	}
+	resolveNamedRepository(loadHelmRepositories())
+	if err := resolveLocalChart(); err != nil {
+		log.Fatal(err)
+	}
+
+	if opts.noInput {
+		// Fail fast instead of prompting for anything
+		if missing := missingFields(); len(missing) > 0 {
+			fmt.Fprintf(os.Stderr, "❌ Cannot run with --no-input, %s\n", formatMissingFields(missing))
+			os.Exit(1)
+		}
+	} else {
+		// Show Kubernetes connection splash screen
+		showKubernetesSplashScreen()
+	}
 
 	// Initialize plugin registry with Kubernetes client (after splash screen)
 	pluginRegistry = plugins.NewRegistry(k8sClient)
 
-	// Set default values
-	namespace = ""
-	interval = "5m"
-	valuesPrefill = "default"
+	// Credentials of a repository given on the command line are needed to list its charts
+	if err := resolveRepoAuth(helmRepoURL, opts.auth); err != nil {
+		log.Fatal(err)
+	}
 
-	// Step 1: Basic Application Info
-	appInfoForm := huh.NewForm(
+	if !opts.noInput {
+		if err := runWizard(ctx, opts, info); err != nil {
+			exitIfCancelled(err)
+			log.Fatal(err)
+		}
+	}
+	if err := resolveVersionConstraint(ctx, info); err != nil {
+		exitIfCancelled(err)
+		log.Fatal(err)
+	}
+
+	// Validate all required fields are filled
+	if missing := missingFields(); len(missing) > 0 {
+		fmt.Fprintf(info, "❌ Missing required information, %s\n", formatMissingFields(missing))
+		os.Exit(1)
+	}
+	if sourceKind == models.SourceKindOCIRepository && !helm.IsOCI(helmRepoURL) {
+		fmt.Fprintf(info, "❌ Source kind %s requires an oci:// repository URL\n", sourceKind)
+		os.Exit(1)
+	}
+	config := buildConfig()
+	if err := config.Validate(); err != nil {
+		// Without an app spec, the settings come from flags and the wizard
+		if opts.fromFile == "" {
+			err = flagError(err)
+		}
+		fmt.Fprintf(info, "❌ %s\n", err)
+		os.Exit(1)
+	}
+	if err := inspectChart(ctx, info); err != nil {
+		exitIfCancelled(err)
+		log.Fatal(err)
+	}
+	if len(subchartToggles) > 0 {
+		if chartInfo == nil {
+			fmt.Fprintf(info, "⚠️  Warning: chart was not inspected, subcharts are toggled with <key>.enabled\n")
+		} else if err := checkSubchartToggles(chartInfo); err != nil {
+			fmt.Fprintf(info, "❌ %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Step 4: Interactive Plugin Menu (plugins from an app spec are used as-is)
+	if !opts.noInput && opts.fromFile == "" {
+		if err := runInteractivePluginMenu(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// The configuration was built before the plugin menu
+	config.Plugins = buildPlugins()
+
+	// Show the files that would be overwritten before anything is written
+	genOpts := generator.Options{OutputDir: opts.outputDir, Force: opts.force}
+	if config.FluxKustomization != nil {
+		if config.FluxKustomization.AppPath, err = appRepositoryPath(genOpts.AppDir(config)); err != nil {
+			log.Fatal(err)
+		}
+	}
+	var archive *filesystem.ArchiveFS
+	var archiveBuf bytes.Buffer
+	if opts.archive != "" {
+		// The app is generated into a fresh in-memory archive, only the archive file itself can be overwritten
+		if _, err := os.Stat(opts.archive); err == nil && !opts.force {
+			log.Fatalf("refusing to overwrite %s, use --force to overwrite it", opts.archive)
+		}
+		format, err := filesystem.ArchiveFormatFromPath(opts.archive)
+		if err != nil {
+			log.Fatal(err)
+		}
+		archive = filesystem.NewArchiveFS(&archiveBuf, format)
+		genOpts.FS = archive
+	} else if !preview {
+		if err := confirmOverwrite(config, genOpts, opts.noInput); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Handle values prefill
+	if valuesPrefill == models.ValuesPrefillDefault || valuesPrefill == models.ValuesPrefillOverrides {
+		// Download and extract default values.yaml from the chart tarball
+		fmt.Fprintln(info, "📦 Downloading chart and extracting default values...")
+		defaults, err := chartDefaultValues(ctx)
+		switch {
+		case isCancelled(err):
+			exitIfCancelled(err)
+		case errors.Is(err, helm.ErrVerification):
+			fmt.Fprintf(info, "❌ %s\n", err)
+			os.Exit(1)
+		case err != nil:
+			fmt.Fprintf(info, "⚠️  Warning: Failed to download default values: %s\n", err.Error())
+			fmt.Fprintln(info, "📝 Creating empty values file instead...")
+			config.Values[models.RawValuesKey] = "# Failed to download default values for " + selectedChart + "\n# Error: " + err.Error() + "\n"
+		case valuesPrefill == models.ValuesPrefillOverrides:
+			fmt.Fprintln(info, "✅ Successfully extracted default values from chart")
+			if err := prefillOverrides(config, defaults, opts); err != nil {
+				log.Fatal(err)
+			}
+		default:
+			fmt.Fprintln(info, "✅ Successfully extracted default values from chart")
+			config.Values[models.RawValuesKey] = defaults
+		}
+	}
+
+	// Save the collected answers, the downloaded chart values are left out of the spec
+	specPath := opts.saveSpec
+	if specPath == "" && !opts.noInput && opts.fromFile == "" {
+		if specPath, err = promptSaveSpec(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if specPath != "" {
+		if err := models.SaveSpec(specPath, models.NewAppSpec(config)); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintf(info, "💾 Saved app spec to %s\n", specPath)
+	}
+
+	// The subchart conditions are kept out of the spec, which records the toggles themselves
+	if err := applySubchartToggles(config, chartInfo); err != nil {
+		log.Fatal(err)
+	}
+
+	// Like the toggles, the spec keeps the Namespace, which is only skipped for this cluster
+	if skipExistingNamespace(ctx, clusterNamespaces(), config) {
+		fmt.Fprintf(info, "ℹ️  Namespace %s already exists in the cluster, its manifest is not generated\n", config.Namespace)
+	}
+
+	// Catch values Flux would fail the HelmRelease with
+	if !opts.skipSchemaValidation {
+		if err := checkValuesSchema(info, config); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Render in memory only when previewing
+	if preview {
+		changed, err := previewFluxStructure(os.Stdout, config, genOpts, opts.diff)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if changed {
+			os.Exit(exitCodeDiff)
+		}
+		return
+	}
+
+	// Generate the Flux structure
+	if err := generator.GenerateFluxStructureWithOptions(config, genOpts); err != nil {
+		log.Fatal(err)
+	}
+	if archive != nil {
+		if err := archive.Close(); err != nil {
+			log.Fatal(err)
+		}
+		if err := os.WriteFile(opts.archive, archiveBuf.Bytes(), 0o600); err != nil {
+			log.Fatalf("failed to write archive %s: %v", opts.archive, err)
+		}
+		fmt.Printf("🗜️  Wrote archive %s\n", opts.archive)
+	}
+
+	// Success message
+	appDir := genOpts.AppDir(config)
+	fmt.Printf("\n🎉 Successfully generated Flux GitOps structure!\n")
+	fmt.Printf("📁 Application: %s\n", appName)
+	fmt.Printf("🏷️  Namespace: %s\n", namespace)
+	fmt.Printf("📦 Chart: %s@%s\n", selectedChart, selectedVersion)
+	if trackConstraint {
+		fmt.Printf("🔁 Tracking versions: %s\n", versionConstraint)
+	}
+	fmt.Printf("🔄 Sync Interval: %s\n", interval)
+	if cosign.verify {
+		fmt.Printf("🔏 Signature verification: cosign\n")
+	}
+	if config.NamespaceManifest != nil {
+		fmt.Printf("📂 Namespace manifest: dependencies/namespace.yaml\n")
+	}
+	if config.ValuesKind != "" || len(config.ValuesSources) > 0 {
+		delivery := config.ValuesObjectKind()
+		if config.UsesInlineValues() {
+			delivery = models.ValuesKindInline
+		}
+		if len(config.ValuesSources) > 0 {
+			delivery += ", layered with " + formatValuesSources(config.ValuesSources)
+		}
+		fmt.Printf("📝 Values: %s\n", delivery)
+	}
+	if len(config.Subcharts) > 0 {
+		fmt.Printf("🧩 Subcharts: %s\n", formatSubchartToggles(config.Subcharts))
+	}
+	if config.HasEnvironments() {
+		printEnvironments(config, appDir)
+	}
+	var kustomizations []string
+	if config.FluxKustomization != nil {
+		if kustomizations, err = fluxKustomizationFiles(config); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("🔁 Flux Kustomization: %s\n", strings.Join(kustomizations, ", "))
+	}
+
+	if len(pluginInstances) > 0 {
+		fmt.Printf("🔌 Plugin Instances: %d\n", len(pluginInstances))
+		for i, instance := range pluginInstances {
+			fmt.Printf("   %d. %s\n", i+1, instance.PluginName)
+		}
+	}
+
+	baseDir, applyDir := appDir, appDir
+	if config.HasEnvironments() {
+		baseDir = filepath.Join(appDir, "base")
+		applyDir = filepath.Join(appDir, filepath.FromSlash(generator.OverlayDir(config.Environments[0].Name)))
+	}
+	fmt.Printf("\n💡 Next steps:\n")
+	fmt.Printf("   1. Review the generated files in the '%s/' directory\n", appDir)
+	switch config.ValuesKind {
+	case models.ValuesKindInline:
+		fmt.Printf("   2. Customize the values in '%s'\n", filepath.Join(baseDir, "release", "helm-release.yaml"))
+	case models.ValuesKindSecret:
+		fmt.Printf("   2. Customize the values in '%s' and encrypt it with SOPS\n", filepath.Join(baseDir, "release", "helm-values.yaml"))
+	default:
+		fmt.Printf("   2. Customize the values in '%s'\n", filepath.Join(baseDir, "release", "helm-values.yaml"))
+	}
+	if len(kustomizations) > 0 {
+		fmt.Printf("   3. Commit '%s/' and '%s' to your Git repository, Flux then reconciles the app\n", appDir, strings.Join(kustomizations, "', '"))
+		return
+	}
+	fmt.Printf("   3. Commit to your Git repository\n")
+	fmt.Printf("   4. Apply to your cluster: kubectl apply -k %s/\n", applyDir)
+}
+
+// confirmOverwrite lists the existing files generation would overwrite. Without --force generation is
+// refused; with it the user is asked to confirm unless prompting is disabled.
+func confirmOverwrite(config *models.AppConfig, genOpts generator.Options, noInput bool) error {
+	if err := generator.CheckOverwrite(config, genOpts); err != nil {
+		var overwriteErr *generator.OverwriteError
+		if errors.As(err, &overwriteErr) {
+			fmt.Printf("❌ App directory '%s/' already exists.\n", overwriteErr.Dir)
+			printFileList("Files that would be overwritten:", overwriteErr.Files)
+			return fmt.Errorf("refusing to overwrite %s, use --force to overwrite it", overwriteErr.Dir)
+		}
+		return err
+	}
+
+	existing, err := generator.ExistingFiles(config, genOpts)
+	if err != nil {
+		return err
+	}
+	if len(existing) == 0 {
+		return nil
+	}
+
+	printFileList(fmt.Sprintf("⚠️  The following files in '%s/' will be overwritten:", genOpts.AppDir(config)), existing)
+	if noInput {
+		return nil
+	}
+
+	var overwrite bool
+	confirmForm := huh.NewForm(
 		huh.NewGroup(
-			huh.NewInput().
-				Title("Application Name").
-				Description("Enter a name for your Flux application").
-				Placeholder("my-app").
-				Value(&appName).
-				Validate(func(s string) error {
-					if s == "" {
-						return fmt.Errorf("application name is required")
-					}
-					return nil
-				}),
-
-			func() huh.Field {
-				if k8sConnected && k8sTUIProvider != nil {
-					return k8sTUIProvider.NamespaceInput(
-						"Namespace",
-						"Kubernetes namespace for the application",
-						"default",
-						&namespace,
-					)
+			huh.NewConfirm().
+				Title("Overwrite Files").
+				Description(fmt.Sprintf("Overwrite %d existing file(s)?", len(existing))).
+				Value(&overwrite),
+		).Title("⚠️  Existing Application"),
+	).WithTheme(huh.ThemeCharm())
+
+	if err := confirmForm.Run(); err != nil {
+		return err
+	}
+	if !overwrite {
+		return fmt.Errorf("generation cancelled, no files were written")
+	}
+	return nil
+}
+
+// previewFluxStructure generates the Flux structure in memory and prints either the full manifests
+// or, in diff mode, a unified diff against the app directory and the Flux Kustomizations of the app.
+// It reports whether the diff is non-empty.
+func previewFluxStructure(w io.Writer, config *models.AppConfig, genOpts generator.Options, diff bool) (bool, error) {
+	rendered := filesystem.NewMemFS()
+	renderOpts := genOpts
+	renderOpts.FS, renderOpts.Log = rendered, io.Discard
+	if err := generator.GenerateFluxStructureWithOptions(config, renderOpts); err != nil {
+		return false, err
+	}
+
+	appDir := genOpts.AppDir(config)
+	var files []generator.RenderedFile
+	for _, name := range rendered.Files() {
+		rel, err := filepath.Rel(appDir, name)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		content, err := rendered.ReadFile(name)
+		if err != nil {
+			return false, err
+		}
+		files = append(files, generator.RenderedFile{Path: filepath.ToSlash(rel), Content: string(content)})
+	}
+	changed, err := previewFiles(w, genOpts.FS, files, appDir, diff)
+	if err != nil || config.FluxKustomization == nil {
+		return changed, err
+	}
+
+	// The Flux Kustomizations are written outside of the app directory
+	kustomizations, err := generator.FluxKustomizationFiles(config)
+	if err != nil {
+		return false, err
+	}
+	clustersDir := config.FluxKustomization.ClustersDir
+	for i, file := range kustomizations {
+		content, err := rendered.ReadFile(filepath.Join(clustersDir, filepath.FromSlash(file.Path)))
+		if err != nil {
+			return false, err
+		}
+		kustomizations[i].Content = string(content)
+	}
+	kustomizationsChanged, err := previewFiles(w, genOpts.FS, kustomizations, clustersDir, diff)
+	return changed || kustomizationsChanged, err
+}
+
+// previewFiles prints rendered files, or in diff mode a unified diff against the files of appDir.
+// It reports whether the diff is non-empty.
+func previewFiles(w io.Writer, fsys filesystem.FS, files []generator.RenderedFile, appDir string, diff bool) (bool, error) {
+	if diff {
+		out, err := generator.DiffFluxStructure(fsys, files, appDir)
+		if err != nil {
+			return false, err
+		}
+		_, err = io.WriteString(w, out)
+		return out != "", err
+	}
+
+	for _, file := range files {
+		if _, err := fmt.Fprintf(w, "---\n# Source: %s\n%s", path.Join(filepath.ToSlash(appDir), file.Path), file.Content); err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// printFileList prints a title followed by one file per line.
+func printFileList(title string, files []string) {
+	fmt.Println(title)
+	for _, file := range files {
+		fmt.Printf("   - %s\n", file)
+	}
+}
+
+// runWizard prompts for every configuration field that was not provided through flags. Status
+// lines are written to info.
+func runWizard(ctx context.Context, opts *cliOptions, info io.Writer) error {
+	// Step 1: Basic Application Info
+	var appInfoFields []huh.Field
+	if appName == "" {
+		appInfoFields = append(appInfoFields, huh.NewInput().
+			Title("Application Name").
+			Description("Enter a name for your Flux application").
+			Placeholder("my-app").
+			Value(&appName).
+			Validate(func(s string) error {
+				if s == "" {
+					return fmt.Errorf("application name is required")
 				}
-				// Fallback to regular input if Kubernetes client is not available
-				return huh.NewInput().
-					Title("Namespace").
-					Description("Kubernetes namespace for the application").
-					Placeholder("default").
-					Value(&namespace).
-					Validate(func(s string) error {
-						if s == "" {
-							return fmt.Errorf("namespace is required")
-						}
-						return nil
-					})
-			}(),
-
-			huh.NewInput().
-				Title("Helm Repository Name").
-				Description("Name for the Helm repository resource").
-				Placeholder("my-helm-repo").
-				Value(&helmRepoName).
-				Validate(func(s string) error {
-					if s == "" {
-						return fmt.Errorf("helm repository name is required")
-					}
-					return nil
-				}),
+				return nil
+			}))
+	}
 
-			huh.NewInput().
-				Title("Helm Repository URL").
-				Description("URL of the Helm repository").
-				Placeholder("https://helm.example.com").
-				Value(&helmRepoURL).
+	if namespace == "" {
+		appInfoFields = append(appInfoFields, func() huh.Field {
+			if k8sConnected && k8sTUIProvider != nil {
+				return k8sTUIProvider.NamespaceInput(
+					"Namespace",
+					"Kubernetes namespace for the application",
+					"default",
+					&namespace,
+				)
+			}
+			// Fallback to regular input if Kubernetes client is not available
+			return huh.NewInput().
+				Title("Namespace").
+				Description("Kubernetes namespace for the application").
+				Placeholder("default").
+				Value(&namespace).
 				Validate(func(s string) error {
 					if s == "" {
-						return fmt.Errorf("helm repository URL is required")
+						return fmt.Errorf("namespace is required")
 					}
 					return nil
-				}),
-		).Title("📝 Application Configuration"),
-	).WithTheme(huh.ThemeCharm())
+				})
+		}())
+	}
 
-	if err := appInfoForm.Run(); err != nil {
-		log.Fatal(err)
+	// Repositories configured with the Helm CLI are offered instead of entering a new one
+	var picker *repositoryPicker
+	var repoFields []huh.Field
+	if helmRepoName == "" {
+		repoFields = append(repoFields, repoNameField())
+	}
+	if helmRepoURL == "" && sourceKind != models.SourceKindGitRepository {
+		repoFields = append(repoFields, repoURLField())
+	}
+	if len(repoFields) == 2 {
+		if repositories := loadHelmRepositories(); len(repositories) > 0 {
+			picker = &repositoryPicker{repositories: repositories}
+		}
 	}
 
-	// Step 2: Chart Selection
-	chartForm := huh.NewForm(
-		huh.NewGroup(
-			huh.NewSelect[string]().
-				Title("Select Chart").
-				Description("Choose a chart from the Helm repository").
-				OptionsFunc(func() []huh.Option[string] {
-					if helmRepoURL == "" {
-						return []huh.Option[string]{huh.NewOption("Please enter repository URL first", "")}
-					}
+	if picker == nil {
+		appInfoFields = append(appInfoFields, repoFields...)
+	}
+	var appInfoGroups []*huh.Group
+	if len(appInfoFields) > 0 {
+		appInfoGroups = append(appInfoGroups, huh.NewGroup(appInfoFields...).Title("📝 Application Configuration"))
+	}
+	if picker != nil {
+		appInfoGroups = append(appInfoGroups, picker.groups(ctx, repoFields...)...)
+	}
 
-					// Fetch charts from repository
-					charts, err := versionFetcher.ListCharts(helmRepoURL)
-					if err != nil {
-						return []huh.Option[string]{huh.NewOption(fmt.Sprintf("Error: %s", err.Error()), "")}
-					}
+	if len(appInfoGroups) > 0 {
+		appInfoForm := huh.NewForm(appInfoGroups...).WithTheme(huh.ThemeCharm())
+
+		if err := appInfoForm.Run(); err != nil {
+			return err
+		}
+		if picker != nil {
+			picker.apply()
+		}
+		if isLocalChart(helmRepoURL) {
+			localChartPath = helmRepoURL
+			if err := resolveLocalChart(); err != nil {
+				return err
+			}
+		}
+		if err := resolveRepoAuth(helmRepoURL, opts.auth); err != nil {
+			return err
+		}
+	}
 
-					options := make([]huh.Option[string], len(charts))
-					for i, chart := range charts {
-						displayName := chart.Name
-						if chart.Description != "" {
-							displayName = fmt.Sprintf("%s - %s", chart.Name, chart.Description)
+	// Step 1.5: Git repository of a local chart, when it cannot be detected from the working tree
+	if sourceKind == models.SourceKindGitRepository {
+		if fields := gitRepositoryFields(); len(fields) > 0 {
+			gitForm := huh.NewForm(
+				huh.NewGroup(fields...).Title("🌿 Git Repository"),
+			).WithTheme(huh.ThemeCharm())
+			if err := gitForm.Run(); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Step 2: Chart Selection (OCI registries cannot list their charts, the name is entered instead)
+	if selectedChart == "" && helm.IsOCI(helmRepoURL) {
+		chartCtx, cancel := context.WithCancel(ctx)
+		chartForm := huh.NewForm(
+			huh.NewGroup(
+				huh.NewInput().
+					Title("Chart Name").
+					Description(fmt.Sprintf("Name of the chart in %s", helmRepoURL)).
+					Placeholder("my-chart").
+					Value(&selectedChart).
+					Validate(func(s string) error {
+						if s == "" {
+							return fmt.Errorf("chart name is required")
 						}
-						options[i] = huh.NewOption(displayName, chart.Name)
-					}
-					return options
-				}, &helmRepoURL).
-				Value(&selectedChart),
-		).Title("📦 Chart Selection"),
-	).WithTheme(huh.ThemeCharm())
+						return versionFetcher.ValidateChartExists(chartCtx, helmRepoURL, s)
+					}),
+			).Title("📦 Chart Selection"),
+		).WithTheme(huh.ThemeCharm())
 
-	if err := chartForm.Run(); err != nil {
-		log.Fatal(err)
+		if err := runForm(chartCtx, cancel, chartForm); err != nil {
+			return err
+		}
+	}
+	if selectedChart == "" {
+		chartCtx, cancel := context.WithCancel(ctx)
+		// The index is fetched once, the search only filters it
+		var charts []helm.ChartSummary
+		var chartsErr error
+		listed := false
+		listCharts := func() ([]helm.ChartSummary, error) {
+			if !listed && helmRepoURL != "" {
+				charts, chartsErr = versionFetcher.ListCharts(chartCtx, helmRepoURL)
+				listed = true
+			}
+			return charts, chartsErr
+		}
+
+		var chartQuery string
+		chartForm := huh.NewForm(
+			huh.NewGroup(
+				huh.NewInput().
+					Title("Search Charts").
+					Description("Words to look for in the chart names, keywords and descriptions, leave empty to list every chart").
+					Value(&chartQuery),
+				huh.NewSelect[string]().
+					Title("Select Chart").
+					DescriptionFunc(func() string {
+						charts, _ := listCharts()
+						return chartDescription(charts, selectedChart)
+					}, &selectedChart).
+					OptionsFunc(func() []huh.Option[string] {
+						if helmRepoURL == "" {
+							return []huh.Option[string]{huh.NewOption("Please enter repository URL first", "")}
+						}
+
+						// Fetch charts from repository
+						charts, err := listCharts()
+						if err != nil {
+							return []huh.Option[string]{huh.NewOption(fmt.Sprintf("Error: %s", err.Error()), "")}
+						}
+						return chartOptions(charts, chartQuery)
+					}, &chartQuery).
+					Height(chartSelectorHeight).
+					Value(&selectedChart).
+					Validate(func(s string) error {
+						if s == "" {
+							return fmt.Errorf("no chart selected, change the search")
+						}
+						return nil
+					}),
+			).Title("📦 Chart Selection"),
+		).WithTheme(huh.ThemeCharm())
+
+		if err := runForm(chartCtx, cancel, chartForm); err != nil {
+			return err
+		}
 	}
 
-	// Step 2.5: Version Selection (only if chart is selected)
-	if selectedChart != "" {
+	// Step 2.5: Version Selection (only if chart is selected), skipped when a version constraint resolves it
+	if err := resolveVersionConstraint(ctx, info); err != nil {
+		return err
+	}
+	// Older versions are offered a page at a time, choosing "show more" asks again with the next page
+	for limit := versionPageSize; selectedChart != "" && selectedVersion == ""; limit += versionPageSize {
+		versionCtx, cancel := context.WithCancel(ctx)
 		versionForm := huh.NewForm(
 			huh.NewGroup(
 				huh.NewSelect[string]().
@@ -184,124 +683,150 @@ func main() {
 					Description("Choose a version of the selected chart").
 					OptionsFunc(func() []huh.Option[string] {
 						// Fetch versions for the selected chart
-						versions, err := versionFetcher.FetchChartVersions(helmRepoURL, selectedChart)
+						versions, err := versionFetcher.FetchChartVersions(versionCtx, helmRepoURL, selectedChart)
+						if err == nil {
+							versions, err = helm.FilterVersions(versions, versionFilter())
+						}
 						if err != nil {
 							return []huh.Option[string]{huh.NewOption(fmt.Sprintf("Error: %s", err.Error()), "")}
 						}
-
-						options := make([]huh.Option[string], len(versions))
-						for i, version := range versions {
-							displayName := fmt.Sprintf("%s (App: %s)", version.ChartVersion, version.AppVersion)
-							if version.Description != "" {
-								displayName = fmt.Sprintf("%s - %s", displayName, version.Description)
-							}
-							options[i] = huh.NewOption(displayName, version.ChartVersion)
-						}
-						return options
+						return versionOptions(versions, limit)
 					}, &selectedChart).
+					Height(chartSelectorHeight).
 					Value(&selectedVersion),
 			).Title("📦 Version Selection"),
 		).WithTheme(huh.ThemeCharm())
 
-		if err := versionForm.Run(); err != nil {
-			log.Fatal(err)
+		if err := runForm(versionCtx, cancel, versionForm); err != nil {
+			return err
+		}
+		if selectedVersion != showMoreVersions {
+			break
 		}
+		selectedVersion = ""
 	}
 
-	// Step 3: Final Configuration
-	finalForm := huh.NewForm(
-		huh.NewGroup(
-			huh.NewSelect[string]().
-				Title("Sync Interval").
-				Description("How often Flux should check for changes").
-				Options(
-					huh.NewOption("1 minute", "1m"),
-					huh.NewOption("5 minutes", "5m"),
-					huh.NewOption("10 minutes", "10m"),
-					huh.NewOption("30 minutes", "30m"),
-					huh.NewOption("1 hour", "1h"),
-				).
-				Value(&interval),
-
-			huh.NewSelect[string]().
-				Title("Values Configuration").
-				Description("How to initialize the Helm values file").
-				Options(
-					huh.NewOption("Use default values from chart", "default"),
-					huh.NewOption("Create empty values file", "empty"),
-				).
-				Value(&valuesPrefill),
-		).Title("⚙️  Configuration"),
-	).WithTheme(huh.ThemeCharm())
-
-	if err := finalForm.Run(); err != nil {
-		log.Fatal(err)
+	// Step 2.6: Chart details, including kubeVersion and deprecation warnings
+	if selectedChart != "" && selectedVersion != "" {
+		if err := inspectChart(ctx, info); err != nil {
+			return err
+		}
+		if err := showChartInfo(); err != nil {
+			return err
+		}
+		if err := promptSubcharts(opts); err != nil {
+			return err
+		}
 	}
 
-	// Validate all required fields are filled
-	if appName == "" || helmRepoName == "" || helmRepoURL == "" || selectedChart == "" || selectedVersion == "" {
-		fmt.Println("❌ Missing required information. Please run the application again.")
-		os.Exit(1)
+	// Step 3: Final Configuration
+	var finalFields []huh.Field
+	if !opts.isSet("interval") {
+		finalFields = append(finalFields, huh.NewSelect[string]().
+			Title("Sync Interval").
+			Description("How often Flux should check for changes").
+			Options(
+				huh.NewOption("1 minute", "1m"),
+				huh.NewOption("5 minutes", "5m"),
+				huh.NewOption("10 minutes", "10m"),
+				huh.NewOption("30 minutes", "30m"),
+				huh.NewOption("1 hour", "1h"),
+			).
+			Value(&interval))
 	}
 
-	// Step 4: Interactive Plugin Menu
-	if err := runInteractivePluginMenu(); err != nil {
-		log.Fatal(err)
+	if !opts.isSet("source-kind") && helm.IsOCI(helmRepoURL) {
+		finalFields = append(finalFields, huh.NewSelect[string]().
+			Title("OCI Source").
+			Description("Flux source the chart is pulled from").
+			Options(
+				huh.NewOption("HelmRepository of type oci", models.SourceKindHelmRepository),
+				huh.NewOption("OCIRepository referenced by the HelmRelease chartRef", models.SourceKindOCIRepository),
+			).
+			Value(&sourceKind))
 	}
 
-	// Create configuration
-	config := &models.AppConfig{
-		AppName:      appName,
-		Namespace:    namespace,
-		HelmRepoName: helmRepoName,
-		HelmRepoURL:  helmRepoURL,
-		ChartName:    selectedChart,
-		ChartVersion: selectedVersion,
-		Interval:     interval,
-		Values:       make(map[string]interface{}),
-		Plugins:      pluginInstances, // Use the new plugin instances list
-		PluginFiles:  []string{},      // Will be populated by generatePluginFiles
+	if !opts.isSet("values-prefill") {
+		finalFields = append(finalFields, huh.NewSelect[string]().
+			Title("Values Configuration").
+			Description("How to initialize the Helm values file").
+			Options(
+				huh.NewOption("Use default values from chart", "default"),
+				huh.NewOption("Only overrides of selected keys, defaults kept as reference", "overrides"),
+				huh.NewOption("Create empty values file", "empty"),
+			).
+			Value(&valuesPrefill))
 	}
 
-	// Handle values prefill
-	if valuesPrefill == "default" {
-		// Download and extract default values.yaml from the chart tarball
-		fmt.Println("📦 Downloading chart and extracting default values...")
-		values, err := helm.DownloadAndExtractValuesYAML(helmRepoURL, selectedChart, selectedVersion)
-		if err != nil {
-			fmt.Printf("⚠️  Warning: Failed to download default values: %s\n", err.Error())
-			fmt.Println("📝 Creating empty values file instead...")
-			config.Values["__raw_yaml__"] = "# Failed to download default values for " + selectedChart + "\n# Error: " + err.Error() + "\n"
-		} else {
-			fmt.Println("✅ Successfully extracted default values from chart")
-			config.Values["__raw_yaml__"] = values
-		}
+	trackingField := versionTrackingField(opts)
+	if trackingField != nil {
+		finalFields = append(finalFields, trackingField)
 	}
 
-	// Generate the Flux structure
-	if err := generator.GenerateFluxStructure(config); err != nil {
-		log.Fatal(err)
-	}
+	finalFields = append(finalFields, repoSecretFields(opts)...)
 
-	// Success message
-	fmt.Printf("\n🎉 Successfully generated Flux GitOps structure!\n")
-	fmt.Printf("📁 Application: %s\n", appName)
-	fmt.Printf("🏷️  Namespace: %s\n", namespace)
-	fmt.Printf("📦 Chart: %s@%s\n", selectedChart, selectedVersion)
-	fmt.Printf("🔄 Sync Interval: %s\n", interval)
+	if len(finalFields) > 0 {
+		finalForm := huh.NewForm(
+			huh.NewGroup(finalFields...).Title("⚙️  Configuration"),
+		).WithTheme(huh.ThemeCharm())
 
-	if len(pluginInstances) > 0 {
-		fmt.Printf("🔌 Plugin Instances: %d\n", len(pluginInstances))
-		for i, instance := range pluginInstances {
-			fmt.Printf("   %d. %s\n", i+1, instance.PluginName)
+		if err := finalForm.Run(); err != nil {
+			return err
 		}
 	}
+	if trackingField != nil {
+		trackConstraint = versionConstraint != ""
+	}
 
-	fmt.Printf("\n💡 Next steps:\n")
-	fmt.Printf("   1. Review the generated files in the '%s/' directory\n", appName)
-	fmt.Printf("   2. Customize the values in '%s/release/helm-values.yaml'\n", appName)
-	fmt.Printf("   3. Commit to your Git repository\n")
-	fmt.Printf("   4. Apply to your cluster: kubectl apply -k %s/\n", appName)
+	if err := promptRepoExternalSecret(opts); err != nil {
+		return err
+	}
+	if err := promptCosignVerify(opts); err != nil {
+		return err
+	}
+	if err := promptValuesFrom(opts); err != nil {
+		return err
+	}
+	if err := promptRelease(opts); err != nil {
+		return err
+	}
+	if err := promptNamespaceManifest(ctx, opts); err != nil {
+		return err
+	}
+	if err := promptEnvironments(opts); err != nil {
+		return err
+	}
+	return promptFluxKustomization(opts)
+}
+
+// repoNameField asks for the name of the Helm repository resource.
+func repoNameField() huh.Field {
+	return huh.NewInput().
+		Title("Helm Repository Name").
+		Description("Name for the Helm repository resource").
+		Placeholder("my-helm-repo").
+		Value(&helmRepoName).
+		Validate(func(s string) error {
+			if s == "" {
+				return fmt.Errorf("helm repository name is required")
+			}
+			return nil
+		})
+}
+
+// repoURLField asks for the URL of the Helm repository, or the path of a local chart.
+func repoURLField() huh.Field {
+	return huh.NewInput().
+		Title("Helm Repository URL").
+		Description("URL of the Helm repository, oci:// for charts stored in an OCI registry, or the path of a local chart").
+		Placeholder("https://helm.example.com").
+		Value(&helmRepoURL).
+		Validate(func(s string) error {
+			if s == "" {
+				return fmt.Errorf("helm repository URL is required")
+			}
+			return nil
+		})
 }
 
 // showKubernetesSplashScreen displays a styled splash and tests Kubernetes connection.
@@ -381,8 +906,17 @@ func clearTerminal() {
 func loadTemplates() error {
 	templates := map[string]*string{
 		"helm-repository.yaml.tmpl": &generator.HelmRepositoryTemplate,
+		"oci-repository.yaml.tmpl":  &generator.OCIRepositoryTemplate,
+		"git-repository.yaml.tmpl":  &generator.GitRepositoryTemplate,
 		"helm-release.yaml.tmpl":    &generator.HelmReleaseTemplate,
 		"kustomization.yaml.tmpl":   &generator.KustomizationTemplate,
+		"namespace.yaml.tmpl":       &generator.NamespaceTemplate,
+
+		"overlay-kustomization.yaml.tmpl": &generator.OverlayKustomizationTemplate,
+		"helm-release-patch.yaml.tmpl":    &generator.HelmReleasePatchTemplate,
+		"oci-repository-patch.yaml.tmpl":  &generator.OCIRepositoryPatchTemplate,
+
+		"flux-kustomization.yaml.tmpl": &generator.FluxKustomizationTemplate,
 	}
 
 	for filename, target := range templates {
@@ -1,13 +1,18 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"io"
 	"os"
 	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/EffectiveSloth/flux-app-generator/internal/filesystem"
+	"github.com/EffectiveSloth/flux-app-generator/internal/generator"
 	"github.com/EffectiveSloth/flux-app-generator/internal/kubernetes"
+	"github.com/EffectiveSloth/flux-app-generator/internal/models"
 	"github.com/EffectiveSloth/flux-app-generator/internal/plugins"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -250,8 +255,14 @@ func TestEmbeddedTemplates(t *testing.T) {
 	// Test that embedded templates are accessible
 	templates := []string{
 		"helm-repository.yaml.tmpl",
+		"oci-repository.yaml.tmpl",
 		"helm-release.yaml.tmpl",
 		"kustomization.yaml.tmpl",
+		"overlay-kustomization.yaml.tmpl",
+		"helm-release-patch.yaml.tmpl",
+		"oci-repository-patch.yaml.tmpl",
+		"flux-kustomization.yaml.tmpl",
+		"namespace.yaml.tmpl",
 	}
 
 	for _, template := range templates {
@@ -504,3 +515,35 @@ func TestEnvironmentVariableHandling(t *testing.T) {
 	nonExistentValue := os.Getenv("NON_EXISTENT_VAR")
 	assert.Equal(t, "", nonExistentValue)
 }
+
+func TestPreviewFluxStructure(t *testing.T) {
+	require.NoError(t, loadTemplates())
+	config := &models.AppConfig{
+		AppName:           "podinfo",
+		Namespace:         "apps",
+		HelmRepoName:      "podinfo",
+		HelmRepoURL:       "https://stefanprodan.github.io/podinfo",
+		ChartName:         "podinfo",
+		ChartVersion:      "6.9.0",
+		Interval:          "5m",
+		FluxKustomization: &models.FluxKustomization{ClustersDir: "clusters", Prune: true},
+	}
+	fsys := filesystem.NewMemFS()
+	genOpts := generator.Options{OutputDir: "apps", FS: fsys, Log: io.Discard}
+
+	var out bytes.Buffer
+	changed, err := previewFluxStructure(&out, config, genOpts, false)
+	require.NoError(t, err)
+	assert.False(t, changed)
+	assert.Empty(t, fsys.Files(), "previewing must not write files")
+	assert.Contains(t, out.String(), "# Source: apps/podinfo/release/helm-release.yaml\n")
+	assert.Contains(t, out.String(), "# Source: clusters/podinfo.yaml\n")
+
+	// The preview of a generated app has no diff
+	require.NoError(t, generator.GenerateFluxStructureWithOptions(config, genOpts))
+	out.Reset()
+	changed, err = previewFluxStructure(&out, config, genOpts, true)
+	require.NoError(t, err)
+	assert.False(t, changed)
+	assert.Empty(t, out.String())
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/huh"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/models"
+)
+
+// namespaceManifest holds the Namespace generated into dependencies/, when generate is set.
+var namespaceManifest struct {
+	generate      bool
+	labels        map[string]string
+	annotations   map[string]string
+	podSecurity   string
+	kustomization bool
+}
+
+// fluxKustomizationNameLabel is the label Flux sets on the objects applied by a Kustomization.
+const fluxKustomizationNameLabel = "kustomize.toolkit.fluxcd.io/name"
+
+// namespaceChecker returns the labels of a namespace of the cluster, and whether it exists.
+type namespaceChecker interface {
+	NamespaceLabels(ctx context.Context, name string) (map[string]string, bool, error)
+}
+
+// addNamespaceFlags adds the flags generating the Namespace of the app.
+func addNamespaceFlags(fs *flag.FlagSet) {
+	namespaceManifest.labels, namespaceManifest.annotations = nil, nil
+	fs.BoolVar(&namespaceManifest.generate, "namespace-manifest", false, "generate the Namespace of the app into dependencies/, unless the connected cluster already has it")
+	fs.Func("namespace-labels", "comma-separated labels of the generated Namespace (e.g. team=web,env=prod)", func(s string) error {
+		var err error
+		namespaceManifest.labels, err = parseKeyValues(s)
+		return err
+	})
+	fs.Func("namespace-annotations", "comma-separated annotations of the generated Namespace (e.g. owner=web-team)", func(s string) error {
+		var err error
+		namespaceManifest.annotations, err = parseKeyValues(s)
+		return err
+	})
+	fs.StringVar(&namespaceManifest.podSecurity, "pod-security", "", "Pod Security Admission level enforced on the generated Namespace (privileged|baseline|restricted)")
+	fs.BoolVar(&namespaceManifest.kustomization, "namespace-in-kustomization", true, "list the generated Namespace in kustomization.yaml, so that Flux applies and prunes it with the app")
+}
+
+// applyNamespaceFlags turns on the Namespace generation when one of its settings is given.
+func applyNamespaceFlags(opts *cliOptions) error {
+	if !opts.set["namespace-labels"] && !opts.set["namespace-annotations"] && !opts.set["pod-security"] && !opts.set["namespace-in-kustomization"] {
+		return nil
+	}
+	if opts.set["namespace-manifest"] && !namespaceManifest.generate {
+		return fmt.Errorf("--namespace-labels, --namespace-annotations, --pod-security and --namespace-in-kustomization require --namespace-manifest")
+	}
+	namespaceManifest.generate = true
+	opts.set["namespace-manifest"] = true
+	return nil
+}
+
+// parseKeyValues parses a comma-separated list of key=value labels or annotations.
+func parseKeyValues(s string) (map[string]string, error) {
+	pairs := make(map[string]string)
+	for _, item := range splitList(s) {
+		key, value, ok := strings.Cut(item, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%q is not key=value", item)
+		}
+		pairs[key] = strings.TrimSpace(value)
+	}
+	return pairs, nil
+}
+
+// formatKeyValues formats labels or annotations the way --namespace-labels takes them.
+func formatKeyValues(pairs map[string]string) string {
+	items := make([]string, 0, len(pairs))
+	for key, value := range pairs {
+		items = append(items, key+"="+value)
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+// applyNamespaceSpec fills the Namespace from an app spec, unless --namespace-manifest is set. An
+// app spec without one generates no Namespace.
+func applyNamespaceSpec(n *models.NamespaceManifest, opts *cliOptions) {
+	if opts.isSet("namespace-manifest") {
+		return
+	}
+	opts.set["namespace-manifest"] = true
+	namespaceManifest.generate = n != nil
+	if n == nil {
+		return
+	}
+	namespaceManifest.labels = n.Labels
+	namespaceManifest.annotations = n.Annotations
+	namespaceManifest.podSecurity = n.PodSecurity
+	namespaceManifest.kustomization = n.Kustomization
+}
+
+// buildNamespaceManifest returns the Namespace of the app, nil when none is generated.
+func buildNamespaceManifest() *models.NamespaceManifest {
+	if !namespaceManifest.generate {
+		return nil
+	}
+	n := &models.NamespaceManifest{
+		PodSecurity:   namespaceManifest.podSecurity,
+		Kustomization: namespaceManifest.kustomization,
+	}
+	if len(namespaceManifest.labels) > 0 {
+		n.Labels = make(map[string]string, len(namespaceManifest.labels))
+		for key, value := range namespaceManifest.labels {
+			n.Labels[key] = value
+		}
+	}
+	if len(namespaceManifest.annotations) > 0 {
+		n.Annotations = make(map[string]string, len(namespaceManifest.annotations))
+		for key, value := range namespaceManifest.annotations {
+			n.Annotations[key] = value
+		}
+	}
+	return n
+}
+
+// namespaceCreatedElsewhere reports whether the connected cluster has the namespace, and it was
+// not applied by Flux. A namespace applied by a Flux Kustomization may be the one generated by a
+// previous run, which dropping would have Flux prune along with everything in it. It is false when
+// there is no cluster or it cannot tell.
+func namespaceCreatedElsewhere(ctx context.Context, checker namespaceChecker, name string) bool {
+	if checker == nil || name == "" {
+		return false
+	}
+	labels, exists, err := checker.NamespaceLabels(ctx, name)
+	return err == nil && exists && labels[fluxKustomizationNameLabel] == ""
+}
+
+// skipExistingNamespace drops the Namespace of the app when the cluster already has it, created
+// outside of Flux, and reports whether it did.
+func skipExistingNamespace(ctx context.Context, checker namespaceChecker, config *models.AppConfig) bool {
+	if config.NamespaceManifest == nil || !namespaceCreatedElsewhere(ctx, checker, config.Namespace) {
+		return false
+	}
+	config.NamespaceManifest = nil
+	return true
+}
+
+// clusterNamespaces returns the namespace checker of the connected cluster, nil when not connected.
+func clusterNamespaces() namespaceChecker {
+	if !k8sConnected || k8sClient == nil {
+		return nil
+	}
+	return k8sClient
+}
+
+// promptNamespaceManifest asks whether to generate the Namespace of the app, and with which
+// labels, annotations and Pod Security Admission level. It is not asked when the connected
+// cluster already has the namespace, created outside of Flux.
+func promptNamespaceManifest(ctx context.Context, opts *cliOptions) error {
+	if opts.isSet("namespace-manifest") || namespaceCreatedElsewhere(ctx, clusterNamespaces(), namespace) {
+		return nil
+	}
+
+	confirm := huh.NewForm(
+		huh.NewGroup(
+			huh.NewConfirm().
+				Title("Generate Namespace?").
+				Description(fmt.Sprintf("Generate the %s Namespace into dependencies/, for namespaces not created elsewhere", namespace)).
+				Value(&namespaceManifest.generate),
+		),
+	).WithTheme(huh.ThemeCharm())
+	if err := confirm.Run(); err != nil {
+		return err
+	}
+	if !namespaceManifest.generate {
+		return nil
+	}
+
+	labels := formatKeyValues(namespaceManifest.labels)
+	annotations := formatKeyValues(namespaceManifest.annotations)
+	validKeyValues := func(s string) error {
+		_, err := parseKeyValues(s)
+		return err
+	}
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Labels").
+				Description("Comma-separated labels of the Namespace").
+				Placeholder("team=web,env=prod").
+				Value(&labels).
+				Validate(validKeyValues),
+			huh.NewInput().
+				Title("Annotations").
+				Description("Comma-separated annotations of the Namespace").
+				Placeholder("owner=web-team").
+				Value(&annotations).
+				Validate(validKeyValues),
+			huh.NewSelect[string]().
+				Title("Pod Security").
+				Description("Pod Security Admission level enforced on the pods of the namespace").
+				Options(
+					huh.NewOption("None (cluster default)", ""),
+					huh.NewOption("Privileged", models.PodSecurityPrivileged),
+					huh.NewOption("Baseline", models.PodSecurityBaseline),
+					huh.NewOption("Restricted", models.PodSecurityRestricted),
+				).
+				Value(&namespaceManifest.podSecurity),
+			huh.NewConfirm().
+				Title("Add to kustomization.yaml?").
+				Description("Have Flux apply the Namespace with the app, and delete it with the app when pruning").
+				Value(&namespaceManifest.kustomization),
+		).Title("📂 Namespace"),
+	).WithTheme(huh.ThemeCharm())
+	if err := form.Run(); err != nil {
+		return err
+	}
+
+	var err error
+	if namespaceManifest.labels, err = parseKeyValues(labels); err != nil {
+		return err
+	}
+	namespaceManifest.annotations, err = parseKeyValues(annotations)
+	return err
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/models"
+)
+
+// fakeNamespaces is a cluster holding the namespaces of its map, with their labels.
+type fakeNamespaces struct {
+	namespaces map[string]map[string]string
+	err        error
+}
+
+func (f fakeNamespaces) NamespaceLabels(_ context.Context, name string) (map[string]string, bool, error) {
+	labels, exists := f.namespaces[name]
+	return labels, exists, f.err
+}
+
+func TestParseFlags_Namespace(t *testing.T) {
+	resetFormVariables(t)
+
+	_, err := parseFlags([]string{
+		"--namespace", "web",
+		"--namespace-labels", "team=web, env=prod",
+		"--namespace-annotations", "owner=web-team",
+		"--pod-security", "baseline",
+	}, io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, &models.NamespaceManifest{
+		Labels:        map[string]string{"team": "web", "env": "prod"},
+		Annotations:   map[string]string{"owner": "web-team"},
+		PodSecurity:   models.PodSecurityBaseline,
+		Kustomization: true,
+	}, buildConfig().NamespaceManifest)
+
+	// No Namespace is generated by default
+	resetFormVariables(t)
+	opts, err := parseFlags(nil, io.Discard)
+	require.NoError(t, err)
+	assert.Nil(t, buildConfig().NamespaceManifest)
+	assert.False(t, opts.isSet("namespace-manifest"))
+
+	resetFormVariables(t)
+	_, err = parseFlags([]string{"--namespace-manifest", "--namespace-in-kustomization=false"}, io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, &models.NamespaceManifest{}, buildConfig().NamespaceManifest)
+
+	resetFormVariables(t)
+	_, err = parseFlags([]string{"--namespace-manifest=false", "--pod-security", "restricted"}, io.Discard)
+	assert.Error(t, err)
+
+	resetFormVariables(t)
+	_, err = parseFlags([]string{"--namespace-labels", "team"}, io.Discard)
+	assert.Error(t, err)
+}
+
+func TestApplySpec_Namespace(t *testing.T) {
+	resetFormVariables(t)
+
+	opts, err := parseFlags(nil, io.Discard)
+	require.NoError(t, err)
+	n := &models.NamespaceManifest{PodSecurity: models.PodSecurityRestricted, Kustomization: true}
+	applySpec(models.NewAppSpec(&models.AppConfig{NamespaceManifest: n}), opts)
+	assert.Equal(t, n, buildConfig().NamespaceManifest)
+	assert.True(t, opts.isSet("namespace-manifest"))
+
+	// Flags override the spec
+	resetFormVariables(t)
+	opts, err = parseFlags([]string{"--namespace-manifest=false"}, io.Discard)
+	require.NoError(t, err)
+	applySpec(models.NewAppSpec(&models.AppConfig{NamespaceManifest: n}), opts)
+	assert.Nil(t, buildConfig().NamespaceManifest)
+}
+
+func TestSkipExistingNamespace(t *testing.T) {
+	ctx := context.Background()
+	cluster := fakeNamespaces{namespaces: map[string]map[string]string{
+		"apps": {"team": "web"},
+		"shop": {fluxKustomizationNameLabel: "shop"},
+	}}
+
+	config := &models.AppConfig{Namespace: "apps", NamespaceManifest: &models.NamespaceManifest{}}
+	assert.True(t, skipExistingNamespace(ctx, cluster, config))
+	assert.Nil(t, config.NamespaceManifest)
+
+	config = &models.AppConfig{Namespace: "web", NamespaceManifest: &models.NamespaceManifest{}}
+	assert.False(t, skipExistingNamespace(ctx, cluster, config))
+	assert.NotNil(t, config.NamespaceManifest)
+
+	// A Namespace applied by Flux, such as one generated before, is kept so that it is not pruned
+	config = &models.AppConfig{Namespace: "shop", NamespaceManifest: &models.NamespaceManifest{Kustomization: true}}
+	assert.False(t, skipExistingNamespace(ctx, cluster, config))
+	assert.NotNil(t, config.NamespaceManifest)
+
+	// Without a cluster, or one that cannot tell, the Namespace is kept
+	config = &models.AppConfig{Namespace: "apps", NamespaceManifest: &models.NamespaceManifest{}}
+	assert.False(t, skipExistingNamespace(ctx, nil, config))
+	assert.False(t, skipExistingNamespace(ctx, fakeNamespaces{err: errors.New("forbidden")}, config))
+	assert.NotNil(t, config.NamespaceManifest)
+}
+
+func TestParseKeyValues(t *testing.T) {
+	pairs, err := parseKeyValues("")
+	require.NoError(t, err)
+	assert.Empty(t, pairs)
+
+	pairs, err = parseKeyValues("team=web,app.kubernetes.io/part-of=shop,empty=")
+	require.NoError(t, err)
+	assert.Equal(t, "app.kubernetes.io/part-of=shop,empty=,team=web", formatKeyValues(pairs))
+
+	_, err = parseKeyValues("=web")
+	assert.Error(t, err)
+}
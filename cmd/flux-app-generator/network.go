@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/charmbracelet/huh"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/helm"
+)
+
+// networkOptions holds the flags controlling the requests to repositories and registries.
+type networkOptions struct {
+	timeout time.Duration
+	retries int
+}
+
+// addNetworkFlags adds the flags controlling the requests to repositories and registries.
+func addNetworkFlags(fs *flag.FlagSet, opts *networkOptions) {
+	fs.DurationVar(&opts.timeout, "timeout", helm.DefaultTimeout, "timeout of each request to the Helm repository, 0 to wait forever")
+	fs.IntVar(&opts.retries, "retries", helm.DefaultRetries, "how many times a failed request to the Helm repository is retried")
+}
+
+// validateNetworkFlags checks the network flags.
+func validateNetworkFlags(opts networkOptions) error {
+	if opts.timeout < 0 {
+		return fmt.Errorf("invalid --timeout %s: must not be negative", opts.timeout)
+	}
+	if opts.retries < 0 {
+		return fmt.Errorf("invalid --retries %d: must not be negative", opts.retries)
+	}
+	return nil
+}
+
+// configureNetwork sets the timeout and retries of the requests to repositories and registries.
+func configureNetwork(opts networkOptions) {
+	helm.SetNetwork(helm.Network{Timeout: opts.timeout, Retries: opts.retries, Backoff: helm.DefaultBackoff})
+}
+
+// exitCodeCancelled is the exit status when the user cancels, the one of a process killed by SIGINT.
+const exitCodeCancelled = 130
+
+// isCancelled reports whether an error comes from the user leaving a form or interrupting a request.
+func isCancelled(err error) bool {
+	return errors.Is(err, huh.ErrUserAborted) || errors.Is(err, context.Canceled)
+}
+
+// exitIfCancelled exits quietly when err comes from the user cancelling.
+func exitIfCancelled(err error) {
+	if isCancelled(err) {
+		fmt.Fprintln(os.Stderr, "👋 Cancelled")
+		os.Exit(exitCodeCancelled)
+	}
+}
+
+// runForm runs a form whose fields fetch from the repository with ctx. Leaving the form, e.g.
+// with Ctrl-C while its options load, cancels the requests still in flight.
+func runForm(ctx context.Context, cancel context.CancelFunc, form *huh.Form) error {
+	defer cancel()
+	err := form.RunWithContext(ctx)
+	// The form is killed when ctx is cancelled from outside, e.g. by an interrupt signal
+	if errors.Is(err, huh.ErrTimeout) && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/huh"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/models"
+)
+
+// release holds the optional settings of the HelmRelease, only the ones that are set are written.
+var release models.ReleaseOptions
+
+// releaseFlags are the flags setting the options of the HelmRelease.
+var releaseFlags = []string{
+	"release-name", "release-target-namespace", "release-service-account", "release-timeout",
+	"release-max-history", "release-depends-on", "install-retries", "install-crds",
+	"upgrade-retries", "remediate-last-failure", "upgrade-crds", "release-test", "drift-detection",
+}
+
+// addReleaseFlags adds the flags setting the options of the HelmRelease.
+func addReleaseFlags(fs *flag.FlagSet) {
+	// Like the defaults of the other flags, the options set through functions start unset
+	release.MaxHistory, release.DependsOn, release.RemediateLastFailure = nil, nil, nil
+	fs.StringVar(&release.ReleaseName, "release-name", "", "Helm release name, the app name prefixed with the target namespace by default")
+	fs.StringVar(&release.TargetNamespace, "release-target-namespace", "", "namespace the Helm release is installed in, the namespace of the HelmRelease by default")
+	fs.StringVar(&release.ServiceAccountName, "release-service-account", "", "service account the Helm release is installed with")
+	fs.StringVar(&release.Timeout, "release-timeout", "", "timeout of the Helm actions of the HelmRelease (e.g. 10m)")
+	fs.Func("release-max-history", "revisions kept in the Helm release history, 0 for all", func(s string) error {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		release.MaxHistory = &n
+		return nil
+	})
+	fs.Func("release-depends-on", "comma-separated HelmReleases installed before the app, as name or namespace/name", func(s string) error {
+		release.DependsOn = splitList(s)
+		return nil
+	})
+	fs.IntVar(&release.InstallRetries, "install-retries", 0, "remediation retries of a failed install, -1 for unlimited")
+	fs.StringVar(&release.InstallCRDs, "install-crds", "", "CRD policy of the install (Skip|Create|CreateReplace)")
+	fs.IntVar(&release.UpgradeRetries, "upgrade-retries", 0, "remediation retries of a failed upgrade, -1 for unlimited")
+	fs.Func("remediate-last-failure", "remediate the last failed upgrade once the retries are exhausted (true|false)", func(s string) error {
+		remediate, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		release.RemediateLastFailure = &remediate
+		return nil
+	})
+	fs.StringVar(&release.UpgradeCRDs, "upgrade-crds", "", "CRD policy of the upgrades (Skip|Create|CreateReplace)")
+	fs.BoolVar(&release.Test, "release-test", false, "run the Helm tests of the chart after install and upgrade")
+	fs.StringVar(&release.DriftDetection, "drift-detection", "", "drift detection mode of the HelmRelease (enabled|warn|disabled)")
+}
+
+// applyReleaseSpec fills the options of the HelmRelease from an app spec, keeping the ones of
+// flags given on the command line.
+func applyReleaseSpec(r *models.ReleaseOptions, opts *cliOptions) {
+	var spec models.ReleaseOptions
+	if r != nil {
+		spec = *r
+	}
+	fields := map[string]func(){
+		"release-name":             func() { release.ReleaseName = spec.ReleaseName },
+		"release-target-namespace": func() { release.TargetNamespace = spec.TargetNamespace },
+		"release-service-account":  func() { release.ServiceAccountName = spec.ServiceAccountName },
+		"release-timeout":          func() { release.Timeout = spec.Timeout },
+		"release-max-history":      func() { release.MaxHistory = spec.MaxHistory },
+		"release-depends-on":       func() { release.DependsOn = append([]string(nil), spec.DependsOn...) },
+		"install-retries":          func() { release.InstallRetries = spec.InstallRetries },
+		"install-crds":             func() { release.InstallCRDs = spec.InstallCRDs },
+		"upgrade-retries":          func() { release.UpgradeRetries = spec.UpgradeRetries },
+		"remediate-last-failure":   func() { release.RemediateLastFailure = spec.RemediateLastFailure },
+		"upgrade-crds":             func() { release.UpgradeCRDs = spec.UpgradeCRDs },
+		"release-test":             func() { release.Test = spec.Test },
+		"drift-detection":          func() { release.DriftDetection = spec.DriftDetection },
+	}
+	for name, apply := range fields {
+		if opts.isSet(name) {
+			continue
+		}
+		apply()
+		opts.set[name] = true
+	}
+}
+
+// buildRelease returns the options of the HelmRelease, nil when none is set.
+func buildRelease() *models.ReleaseOptions {
+	if release.IsZero() {
+		return nil
+	}
+	r := release
+	r.DependsOn = append([]string(nil), release.DependsOn...)
+	return &r
+}
+
+// promptRelease asks whether to set options of the HelmRelease, then for the options. It is
+// skipped when any of them was given on the command line or in an app spec.
+func promptRelease(opts *cliOptions) error {
+	for _, name := range releaseFlags {
+		if opts.isSet(name) {
+			return nil
+		}
+	}
+
+	var configure bool
+	confirm := huh.NewForm(
+		huh.NewGroup(
+			huh.NewConfirm().
+				Title("Configure HelmRelease Options?").
+				Description("Set the release name, timeout, dependencies, remediation, CRD policies or drift detection of the HelmRelease").
+				Value(&configure),
+		),
+	).WithTheme(huh.ThemeCharm())
+	if err := confirm.Run(); err != nil {
+		return err
+	}
+	if !configure {
+		return nil
+	}
+
+	maxHistory := formatOptionalInt(release.MaxHistory)
+	dependsOn := strings.Join(release.DependsOn, ",")
+	installRetries := formatRetries(release.InstallRetries)
+	upgradeRetries := formatRetries(release.UpgradeRetries)
+	remediateLastFailure := ""
+	if release.RemediateLastFailure != nil {
+		remediateLastFailure = strconv.FormatBool(*release.RemediateLastFailure)
+	}
+	crdPolicies := []huh.Option[string]{
+		huh.NewOption("Flux default", ""),
+		huh.NewOption(models.CRDPolicySkip, models.CRDPolicySkip),
+		huh.NewOption(models.CRDPolicyCreate, models.CRDPolicyCreate),
+		huh.NewOption(models.CRDPolicyCreateReplace, models.CRDPolicyCreateReplace),
+	}
+
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Release Name").
+				Description("Helm release name (leave empty for the app name, prefixed with the target namespace)").
+				Value(&release.ReleaseName),
+			huh.NewInput().
+				Title("Target Namespace").
+				Description("Namespace the release is installed in (leave empty for the namespace of the HelmRelease)").
+				Value(&release.TargetNamespace),
+			huh.NewInput().
+				Title("Service Account").
+				Description("Service account the release is installed with (leave empty for the controller's)").
+				Value(&release.ServiceAccountName),
+			huh.NewInput().
+				Title("Timeout").
+				Description("Timeout of the Helm actions (leave empty for 5m)").
+				Placeholder("10m").
+				Value(&release.Timeout).
+				Validate(func(s string) error {
+					if s == "" {
+						return nil
+					}
+					_, err := time.ParseDuration(s)
+					return err
+				}),
+			huh.NewInput().
+				Title("Max History").
+				Description("Revisions kept in the release history, 0 for all (leave empty for 5)").
+				Value(&maxHistory).
+				Validate(func(s string) error {
+					_, err := parseOptionalInt(s, 0)
+					return err
+				}),
+			huh.NewInput().
+				Title("Depends On").
+				Description("Comma-separated HelmReleases installed before the app, as name or namespace/name").
+				Value(&dependsOn),
+		).Title("⚙️  HelmRelease"),
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Install Retries").
+				Description("Remediation retries of a failed install, -1 for unlimited (leave empty for none)").
+				Value(&installRetries).
+				Validate(func(s string) error {
+					_, err := parseOptionalInt(s, -1)
+					return err
+				}),
+			huh.NewSelect[string]().
+				Title("Install CRDs").
+				Description("What the install does with the CRDs of the chart").
+				Options(crdPolicies...).
+				Value(&release.InstallCRDs),
+			huh.NewInput().
+				Title("Upgrade Retries").
+				Description("Remediation retries of a failed upgrade, -1 for unlimited (leave empty for none)").
+				Value(&upgradeRetries).
+				Validate(func(s string) error {
+					_, err := parseOptionalInt(s, -1)
+					return err
+				}),
+			huh.NewSelect[string]().
+				Title("Remediate Last Failure").
+				Description("Remediate the last failed upgrade once the retries are exhausted").
+				Options(
+					huh.NewOption("Flux default", ""),
+					huh.NewOption("Yes", "true"),
+					huh.NewOption("No", "false"),
+				).
+				Value(&remediateLastFailure),
+			huh.NewSelect[string]().
+				Title("Upgrade CRDs").
+				Description("What the upgrades do with the CRDs of the chart").
+				Options(crdPolicies...).
+				Value(&release.UpgradeCRDs),
+		).Title("⚙️  Install and Upgrade"),
+		huh.NewGroup(
+			huh.NewConfirm().
+				Title("Helm Tests").
+				Description("Run the Helm tests of the chart after install and upgrade").
+				Value(&release.Test),
+			huh.NewSelect[string]().
+				Title("Drift Detection").
+				Description("Whether Flux detects and corrects changes made to the release in the cluster").
+				Options(
+					huh.NewOption("Flux default", ""),
+					huh.NewOption("Enabled, correct drift", models.DriftDetectionEnabled),
+					huh.NewOption("Warn about drift", models.DriftDetectionWarn),
+					huh.NewOption("Disabled", models.DriftDetectionDisabled),
+				).
+				Value(&release.DriftDetection),
+		).Title("⚙️  Tests and Drift Detection"),
+	).WithTheme(huh.ThemeCharm())
+	if err := form.Run(); err != nil {
+		return err
+	}
+
+	var err error
+	if release.MaxHistory, err = parseOptionalInt(maxHistory, 0); err != nil {
+		return err
+	}
+	release.DependsOn = splitList(dependsOn)
+	for _, retries := range []struct {
+		text   string
+		target *int
+	}{{installRetries, &release.InstallRetries}, {upgradeRetries, &release.UpgradeRetries}} {
+		n, err := parseOptionalInt(retries.text, -1)
+		if err != nil {
+			return err
+		}
+		*retries.target = 0
+		if n != nil {
+			*retries.target = *n
+		}
+	}
+	release.RemediateLastFailure = nil
+	if remediateLastFailure != "" {
+		remediate := remediateLastFailure == "true"
+		release.RemediateLastFailure = &remediate
+	}
+	return nil
+}
+
+// parseOptionalInt parses a wizard input holding an integer of at least minimum, nil when empty.
+func parseOptionalInt(s string, minimum int) (*int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not a number", s)
+	}
+	if n < minimum {
+		return nil, fmt.Errorf("must be %d or more", minimum)
+	}
+	return &n, nil
+}
+
+// formatOptionalInt formats an optional integer for a wizard input.
+func formatOptionalInt(n *int) string {
+	if n == nil {
+		return ""
+	}
+	return strconv.Itoa(*n)
+}
+
+// formatRetries formats a number of retries for a wizard input, empty when there are none.
+func formatRetries(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
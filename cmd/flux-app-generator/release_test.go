@@ -0,0 +1,76 @@
+package main
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/models"
+)
+
+func TestParseFlags_Release(t *testing.T) {
+	resetFormVariables(t)
+
+	_, err := parseFlags([]string{
+		"--release-name", "web",
+		"--release-max-history", "0",
+		"--release-depends-on", "cert-manager,infra/ingress-nginx",
+		"--upgrade-retries", "3",
+		"--remediate-last-failure=false",
+		"--install-crds", "CreateReplace",
+		"--drift-detection", "warn",
+	}, io.Discard)
+	require.NoError(t, err)
+	zero, remediate := 0, false
+	assert.Equal(t, &models.ReleaseOptions{
+		ReleaseName:          "web",
+		MaxHistory:           &zero,
+		DependsOn:            []string{"cert-manager", "infra/ingress-nginx"},
+		UpgradeRetries:       3,
+		RemediateLastFailure: &remediate,
+		InstallCRDs:          "CreateReplace",
+		DriftDetection:       "warn",
+	}, buildConfig().Release)
+
+	// No options are written by default
+	resetFormVariables(t)
+	_, err = parseFlags(nil, io.Discard)
+	require.NoError(t, err)
+	assert.Nil(t, buildConfig().Release)
+
+	resetFormVariables(t)
+	_, err = parseFlags([]string{"--remediate-last-failure", "maybe"}, io.Discard)
+	assert.Error(t, err)
+}
+
+func TestApplySpec_Release(t *testing.T) {
+	resetFormVariables(t)
+
+	opts, err := parseFlags([]string{"--release-timeout", "15m"}, io.Discard)
+	require.NoError(t, err)
+	spec := models.NewAppSpec(&models.AppConfig{Release: &models.ReleaseOptions{Timeout: "5m", Test: true}})
+	applySpec(spec, opts)
+
+	// Flags override the options of the spec
+	assert.Equal(t, &models.ReleaseOptions{Timeout: "15m", Test: true}, buildConfig().Release)
+	for _, name := range releaseFlags {
+		assert.True(t, opts.isSet(name), name)
+	}
+}
+
+func TestParseOptionalInt(t *testing.T) {
+	n, err := parseOptionalInt(" ", 0)
+	require.NoError(t, err)
+	assert.Nil(t, n)
+
+	n, err = parseOptionalInt("-1", -1)
+	require.NoError(t, err)
+	assert.Equal(t, "-1", formatOptionalInt(n))
+
+	_, err = parseOptionalInt("-2", -1)
+	assert.Error(t, err)
+	_, err = parseOptionalInt("many", 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/charmbracelet/huh"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/helm"
+	"github.com/EffectiveSloth/flux-app-generator/internal/models"
+)
+
+// Choices of the repository picker besides the configured repositories, which are chosen by URL.
+const (
+	repoChoiceSearch = "search"
+	repoChoiceManual = "manual"
+)
+
+// loadHelmRepositories returns the repositories configured with the Helm CLI. Failing to read
+// them only means none are offered.
+func loadHelmRepositories() []helm.Repository {
+	repositories, err := helm.LoadRepositories(helm.DefaultRepositoriesFile())
+	if err != nil {
+		return nil
+	}
+	return repositories
+}
+
+// resolveNamedRepository fills the repository URL from the Helm CLI repository named by
+// --repo-name, like `helm install name/chart` does.
+func resolveNamedRepository(repositories []helm.Repository) {
+	if helmRepoName == "" || helmRepoURL != "" || sourceKind == models.SourceKindGitRepository {
+		return
+	}
+	if repository, ok := helm.FindRepositoryByName(repositories, helmRepoName); ok {
+		helmRepoURL = repository.URL
+	}
+}
+
+// repositoryPicker lets the wizard reuse a repository of the Helm CLI, or find a chart across all
+// of them, instead of entering the repository name and URL.
+type repositoryPicker struct {
+	repositories []helm.Repository
+	choice       string
+	query        string
+	match        helm.ChartMatch
+}
+
+// groups returns the form groups of the picker: the repository choice, then the chart search or
+// the repository name and URL depending on the choice.
+func (p *repositoryPicker) groups(ctx context.Context, manualFields ...huh.Field) []*huh.Group {
+	options := make([]huh.Option[string], 0, len(p.repositories)+2)
+	for _, repository := range p.repositories {
+		options = append(options, huh.NewOption(fmt.Sprintf("%s (%s)", repository.Name, repository.URL), repository.URL))
+	}
+	options = append(options,
+		huh.NewOption("🔎 Search a chart in all these repositories", repoChoiceSearch),
+		huh.NewOption("✏️  Enter another repository", repoChoiceManual),
+	)
+
+	return []*huh.Group{
+		huh.NewGroup(
+			huh.NewSelect[string]().
+				Title("Helm Repository").
+				Description("Repositories configured with the Helm CLI, credentials included").
+				Options(options...).
+				Value(&p.choice),
+		).Title("📚 Helm Repository"),
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Chart Name").
+				Description("Part of the chart name to look for in the repository indexes").
+				Placeholder("nginx").
+				Value(&p.query).
+				Validate(func(s string) error {
+					if s == "" {
+						return fmt.Errorf("chart name is required")
+					}
+					return nil
+				}),
+			huh.NewSelect[helm.ChartMatch]().
+				Title("Select Chart").
+				OptionsFunc(func() []huh.Option[helm.ChartMatch] {
+					return p.searchOptions(ctx)
+				}, &p.query).
+				Value(&p.match).
+				Validate(func(match helm.ChartMatch) error {
+					if match.Name == "" {
+						return fmt.Errorf("no chart selected, change the chart name")
+					}
+					return nil
+				}),
+		).Title("🔎 Chart Search").WithHideFunc(func() bool {
+			return p.choice != repoChoiceSearch
+		}),
+		huh.NewGroup(manualFields...).Title("📝 Application Configuration").WithHideFunc(func() bool {
+			return p.choice != repoChoiceManual
+		}),
+	}
+}
+
+// searchOptions lists the charts matching the query, as "repository/chart version - description".
+func (p *repositoryPicker) searchOptions(ctx context.Context) []huh.Option[helm.ChartMatch] {
+	if p.query == "" {
+		return []huh.Option[helm.ChartMatch]{huh.NewOption("Please enter a chart name first", helm.ChartMatch{})}
+	}
+	matches, err := versionFetcher.SearchCharts(ctx, p.repositories, p.query)
+	if err != nil {
+		return []huh.Option[helm.ChartMatch]{huh.NewOption(fmt.Sprintf("Error: %s", err.Error()), helm.ChartMatch{})}
+	}
+	if len(matches) == 0 {
+		return []huh.Option[helm.ChartMatch]{huh.NewOption(fmt.Sprintf("No chart matches %q", p.query), helm.ChartMatch{})}
+	}
+
+	options := make([]huh.Option[helm.ChartMatch], len(matches))
+	for i, match := range matches {
+		displayName := fmt.Sprintf("%s/%s %s", match.Repository.Name, match.Name, match.Version)
+		if match.Description != "" {
+			displayName = fmt.Sprintf("%s - %s", displayName, match.Description)
+		}
+		options[i] = huh.NewOption(displayName, match)
+	}
+	return options
+}
+
+// apply fills the repository, and the chart found by a search, from the choice. Manually entered
+// repositories are already in the form variables.
+func (p *repositoryPicker) apply() {
+	switch p.choice {
+	case repoChoiceManual:
+	case repoChoiceSearch:
+		helmRepoName, helmRepoURL = p.match.Repository.Name, p.match.Repository.URL
+		selectedChart = p.match.Name
+	default:
+		if repository, ok := helm.FindRepository(p.repositories, p.choice); ok {
+			helmRepoName, helmRepoURL = repository.Name, repository.URL
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/helm"
+)
+
+// setHelmRepositories configures the Helm CLI repositories of the test with a cached podinfo index.
+func setHelmRepositories(t *testing.T) []helm.Repository {
+	t.Helper()
+	dir := t.TempDir()
+	config := filepath.Join(dir, "repositories.yaml")
+	require.NoError(t, os.WriteFile(config, []byte(`repositories:
+- name: podinfo
+  url: https://stefanprodan.github.io/podinfo
+- name: bitnami
+  url: https://charts.bitnami.com/bitnami
+`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "podinfo-index.yaml"),
+		[]byte("entries:\n  podinfo:\n    - version: 6.9.0\n      description: Podinfo Helm chart for Kubernetes\n"), 0o600))
+	t.Setenv("HELM_REPOSITORY_CONFIG", config)
+	t.Setenv("HELM_REPOSITORY_CACHE", dir)
+	return loadHelmRepositories()
+}
+
+func TestResolveNamedRepository(t *testing.T) {
+	resetFormVariables(t)
+	repositories := setHelmRepositories(t)
+	require.Len(t, repositories, 2)
+
+	helmRepoName = "bitnami"
+	resolveNamedRepository(repositories)
+	assert.Equal(t, "https://charts.bitnami.com/bitnami", helmRepoURL)
+
+	// Explicit URLs and unknown names are left alone
+	helmRepoName, helmRepoURL = "bitnami", "https://mirror.example.com/bitnami"
+	resolveNamedRepository(repositories)
+	assert.Equal(t, "https://mirror.example.com/bitnami", helmRepoURL)
+	helmRepoName, helmRepoURL = "stable", ""
+	resolveNamedRepository(repositories)
+	assert.Empty(t, helmRepoURL)
+}
+
+func TestRepositoryPicker(t *testing.T) {
+	resetFormVariables(t)
+	picker := &repositoryPicker{repositories: setHelmRepositories(t), choice: "https://charts.bitnami.com/bitnami"}
+	picker.apply()
+	assert.Equal(t, "bitnami", helmRepoName)
+	assert.Equal(t, "https://charts.bitnami.com/bitnami", helmRepoURL)
+	assert.Empty(t, selectedChart)
+
+	// The search reads the indexes cached by the Helm CLI, bitnami is skipped as unreachable
+	previous := versionFetcher
+	versionFetcher = helm.NewMockVersionFetcher(func(context.Context, string) (*helm.IndexYAML, error) {
+		return nil, os.ErrNotExist
+	})
+	t.Cleanup(func() { versionFetcher = previous })
+
+	picker.choice, picker.query = repoChoiceSearch, "info"
+	options := picker.searchOptions(context.Background())
+	require.Len(t, options, 1)
+	assert.Equal(t, "podinfo/podinfo 6.9.0 - Podinfo Helm chart for Kubernetes", options[0].Key)
+
+	picker.match = options[0].Value
+	picker.apply()
+	assert.Equal(t, "podinfo", helmRepoName)
+	assert.Equal(t, "https://stefanprodan.github.io/podinfo", helmRepoURL)
+	assert.Equal(t, "podinfo", selectedChart)
+
+	picker.query = "redis"
+	options = picker.searchOptions(context.Background())
+	require.Len(t, options, 1)
+	assert.Equal(t, `No chart matches "redis"`, options[0].Key)
+}
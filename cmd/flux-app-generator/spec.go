@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/huh"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/models"
+	"github.com/EffectiveSloth/flux-app-generator/internal/plugins"
+)
+
+var (
+	// specValues holds the explicit Helm values read from an app spec file.
+	specValues map[string]interface{}
+	// valuesKeys holds the keys of the chart values written to an "overrides" values file.
+	valuesKeys []string
+)
+
+// applySpec fills the form variables from an app spec, keeping the values of flags given on the command line.
+// Fields taken from the spec are marked as provided so the wizard does not ask for them again.
+func applySpec(spec *models.AppSpec, opts *cliOptions) {
+	fields := map[string]struct {
+		target *string
+		value  string
+	}{
+		"app-name":             {&appName, spec.Spec.AppName},
+		"namespace":            {&namespace, spec.Spec.Namespace},
+		"repo-name":            {&helmRepoName, spec.Spec.HelmRepoName},
+		"repo-url":             {&helmRepoURL, spec.Spec.HelmRepoURL},
+		"chart":                {&selectedChart, spec.Spec.ChartName},
+		"chart-version":        {&selectedVersion, spec.Spec.ChartVersion},
+		"version-constraint":   {&versionConstraint, spec.Spec.VersionConstraint},
+		"interval":             {&interval, spec.Spec.Interval},
+		"values-prefill":       {&valuesPrefill, spec.Spec.ValuesPrefill},
+		"values-kind":          {&valuesKind, spec.Spec.ValuesKind},
+		"source-kind":          {&sourceKind, spec.Spec.SourceKind},
+		"repo-secret-ref":      {&repoSecretRef, spec.Spec.RepoSecretRef},
+		"repo-cert-secret-ref": {&repoCertSecretRef, spec.Spec.RepoCertSecretRef},
+		"git-url":              {&gitURL, spec.Spec.GitURL},
+		"git-branch":           {&gitBranch, spec.Spec.GitBranch},
+		"git-path":             {&gitChartPath, spec.Spec.ChartPath},
+		"cosign-secret-ref":    {&cosign.secretRef, spec.Spec.CosignSecretRef},
+		"cosign-oidc-issuer":   {&cosign.oidcIssuer, spec.Spec.CosignOIDCIssuer},
+		"cosign-oidc-subject":  {&cosign.oidcSubject, spec.Spec.CosignOIDCSubject},
+	}
+
+	for name, field := range fields {
+		if opts.isSet(name) {
+			continue
+		}
+		*field.target = field.value
+		opts.set[name] = true
+	}
+
+	if !opts.isSet("values-keys") {
+		valuesKeys = spec.Spec.ValuesKeys
+	}
+	if !opts.isSet("subcharts") && len(spec.Spec.Subcharts) > 0 {
+		subchartToggles = make(map[string]bool, len(spec.Spec.Subcharts))
+		for key, enabled := range spec.Spec.Subcharts {
+			subchartToggles[key] = enabled
+		}
+		opts.set["subcharts"] = true
+	}
+	if !opts.isSet("track-constraint") {
+		trackConstraint = spec.Spec.TrackConstraint
+		opts.set["track-constraint"] = true
+	}
+	if !opts.isSet("cosign-verify") {
+		cosign.verify = spec.Spec.CosignVerify
+		opts.set["cosign-verify"] = true
+	}
+	if !opts.isSet("environments") {
+		environments = append([]models.Environment(nil), spec.Spec.Environments...)
+		opts.set["environments"] = true
+	}
+	applyValuesSourcesSpec(spec.Spec.ValuesSources, opts)
+	applyReleaseSpec(spec.Spec.Release, opts)
+	applyNamespaceSpec(spec.Spec.NamespaceManifest, opts)
+	applyKustomizationSpec(spec.Spec.FluxKustomization, opts)
+	specValues = spec.Spec.Values
+	pluginInstances = append([]plugins.PluginConfig(nil), spec.Spec.Plugins...)
+}
+
+// buildPlugins returns the plugins of the app: the configured plugin instances, and the
+// ExternalSecret of the repository credentials when there is one.
+func buildPlugins() []plugins.PluginConfig {
+	if plugin, ok := repoExternalSecretPlugin(); ok {
+		return append(append([]plugins.PluginConfig(nil), pluginInstances...), plugin)
+	}
+	return pluginInstances
+}
+
+// buildConfig creates the application configuration from the collected answers.
+func buildConfig() *models.AppConfig {
+	values := make(map[string]interface{}, len(specValues))
+	for k, v := range specValues {
+		values[k] = v
+	}
+
+	var subcharts map[string]bool
+	if len(subchartToggles) > 0 {
+		subcharts = make(map[string]bool, len(subchartToggles))
+		for key, enabled := range subchartToggles {
+			subcharts[key] = enabled
+		}
+	}
+
+	return &models.AppConfig{
+		AppName:           appName,
+		Namespace:         namespace,
+		HelmRepoName:      helmRepoName,
+		HelmRepoURL:       helmRepoURL,
+		ChartName:         selectedChart,
+		ChartVersion:      selectedVersion,
+		VersionConstraint: versionConstraint,
+		TrackConstraint:   trackConstraint,
+		Interval:          interval,
+		SourceKind:        sourceKind,
+		RepoSecretRef:     repoSecretRef,
+		RepoCertSecretRef: repoCertSecretRef,
+		GitURL:            gitURL,
+		GitBranch:         gitBranch,
+		ChartPath:         gitChartPath,
+		CosignVerify:      cosign.verify,
+		CosignSecretRef:   cosign.secretRef,
+		CosignOIDCIssuer:  cosign.oidcIssuer,
+		CosignOIDCSubject: cosign.oidcSubject,
+		ValuesPrefill:     valuesPrefill,
+		ValuesKeys:        valuesKeys,
+		Subcharts:         subcharts,
+		Environments:      append([]models.Environment(nil), environments...),
+		FluxKustomization: buildFluxKustomization(),
+		Values:            values,
+		ValuesKind:        valuesKind,
+		ValuesSources:     append([]models.ValuesSource(nil), valuesSources...),
+		Release:           buildRelease(),
+		NamespaceManifest: buildNamespaceManifest(),
+		Plugins:           buildPlugins(),
+		PluginFiles:       []string{}, // Will be populated by generatePluginFiles
+	}
+}
+
+// promptSaveSpec asks whether the collected answers should be saved and returns the chosen path.
+// An empty path means the answers are not saved.
+func promptSaveSpec() (string, error) {
+	var save bool
+	confirmForm := huh.NewForm(
+		huh.NewGroup(
+			huh.NewConfirm().
+				Title("Save Answers").
+				Description("Save these answers to an app spec file to replay this run later with --from-file").
+				Value(&save),
+		).Title("💾 App Spec"),
+	).WithTheme(huh.ThemeCharm())
+
+	if err := confirmForm.Run(); err != nil {
+		return "", err
+	}
+	if !save {
+		return "", nil
+	}
+
+	path := appName + ".yaml"
+	pathForm := huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().
+				Title("App Spec File").
+				Description("Path of the YAML (or .json) file to write").
+				Value(&path).
+				Validate(func(s string) error {
+					if s == "" {
+						return fmt.Errorf("file path is required")
+					}
+					return nil
+				}),
+		).Title("💾 App Spec"),
+	).WithTheme(huh.ThemeCharm())
+
+	if err := pathForm.Run(); err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/models"
+	"github.com/EffectiveSloth/flux-app-generator/internal/plugins"
+)
+
+func TestApplySpec_FlagsOverrideSpec(t *testing.T) {
+	resetFormVariables(t)
+
+	opts, err := parseFlags([]string{"--chart-version", "2.0.0", "--interval", "1m"}, io.Discard)
+	require.NoError(t, err)
+
+	spec := models.NewAppSpec(&models.AppConfig{
+		AppName:       "spec-app",
+		Namespace:     "apps",
+		HelmRepoName:  "repo",
+		HelmRepoURL:   "https://example.com/repo",
+		ChartName:     "chart",
+		ChartVersion:  "1.0.0",
+		Interval:      "5m",
+		ValuesPrefill: models.ValuesPrefillEmpty,
+		Values:        map[string]interface{}{"replicaCount": 2},
+		Plugins:       []plugins.PluginConfig{{PluginName: "imageupdate", Values: map[string]interface{}{"automation_name": "auto"}}},
+	})
+	applySpec(spec, opts)
+
+	assert.Equal(t, "spec-app", appName)
+	assert.Equal(t, "apps", namespace)
+	assert.Equal(t, "chart", selectedChart)
+	assert.Equal(t, "2.0.0", selectedVersion)
+	assert.Equal(t, "1m", interval)
+	assert.Equal(t, models.ValuesPrefillEmpty, valuesPrefill)
+	assert.True(t, opts.isSet("values-prefill"))
+	assert.Empty(t, missingFields())
+
+	config := buildConfig()
+	assert.Equal(t, "2.0.0", config.ChartVersion)
+	assert.Equal(t, 2, config.Values["replicaCount"])
+	require.Len(t, config.Plugins, 1)
+	assert.Equal(t, "imageupdate", config.Plugins[0].PluginName)
+
+	// Subchart toggles of the spec apply unless given on the command line
+	applySpec(models.NewAppSpec(&models.AppConfig{Subcharts: map[string]bool{"redis": true}}), opts)
+	assert.Equal(t, map[string]bool{"redis": true}, subchartToggles)
+	assert.True(t, opts.isSet("subcharts"))
+	config = buildConfig()
+
+	// Prefilled values must not leak back into the spec values
+	config.Values[models.RawValuesKey] = "raw"
+	assert.NotContains(t, specValues, models.RawValuesKey)
+}
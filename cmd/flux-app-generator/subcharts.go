@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/huh"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/helm"
+	"github.com/EffectiveSloth/flux-app-generator/internal/models"
+	"github.com/EffectiveSloth/flux-app-generator/internal/values"
+)
+
+// subchartToggles holds the subcharts enabled or disabled with --subcharts or in the wizard, by
+// dependency key. Subcharts that are not listed keep the state of the chart defaults.
+var subchartToggles map[string]bool
+
+// parseSubchartToggles parses a comma-separated list of key=true|false subchart toggles.
+func parseSubchartToggles(s string) (map[string]bool, error) {
+	toggles := make(map[string]bool)
+	for _, item := range splitList(s) {
+		key, value, ok := strings.Cut(item, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("%q is not key=true|false", item)
+		}
+		enabled, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("%q is not key=true|false", item)
+		}
+		toggles[strings.TrimSpace(key)] = enabled
+	}
+	return toggles, nil
+}
+
+// toggleableSubcharts returns the dependencies of a chart that a condition enables or disables.
+func toggleableSubcharts(chart *helm.Chart) []helm.ChartDependency {
+	var dependencies []helm.ChartDependency
+	for _, dependency := range chart.Metadata.Dependencies {
+		if dependency.ConditionPath() != nil {
+			dependencies = append(dependencies, dependency)
+		}
+	}
+	return dependencies
+}
+
+// checkSubchartToggles checks that every toggled subchart is a dependency of the chart with a condition.
+func checkSubchartToggles(chart *helm.Chart) error {
+	toggleable := make(map[string]bool)
+	for _, dependency := range toggleableSubcharts(chart) {
+		toggleable[dependency.Key()] = true
+	}
+	for _, key := range sortedToggleKeys(subchartToggles) {
+		if !toggleable[key] {
+			return fmt.Errorf("chart %s has no subchart %q with a condition to toggle", chart.Metadata.Name, key)
+		}
+	}
+	return nil
+}
+
+// promptSubcharts asks which of the toggleable subcharts of the inspected chart to enable.
+func promptSubcharts(opts *cliOptions) error {
+	if chartInfo == nil || opts.isSet("subcharts") {
+		return nil
+	}
+	dependencies := toggleableSubcharts(chartInfo)
+	if len(dependencies) == 0 {
+		return nil
+	}
+
+	options := make([]huh.Option[string], len(dependencies))
+	var selected []string
+	for i, dependency := range dependencies {
+		options[i] = huh.NewOption(fmt.Sprintf("%s %s (%s)", dependency.Key(), dependency.Version, strings.Join(dependency.ConditionPath(), ".")), dependency.Key())
+		if enabled, ok := subchartToggles[dependency.Key()]; ok && enabled || !ok && chartInfo.DependencyEnabled(dependency) {
+			selected = append(selected, dependency.Key())
+		}
+	}
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewMultiSelect[string]().
+				Title("Enabled Subcharts").
+				Description("The condition of each subchart is written to the values, with the defaults of the enabled ones").
+				Options(options...).
+				Value(&selected),
+		).Title("🧩 Subcharts"),
+	).WithTheme(huh.ThemeCharm())
+	if err := form.Run(); err != nil {
+		return err
+	}
+
+	subchartToggles = make(map[string]bool, len(dependencies))
+	for _, dependency := range dependencies {
+		subchartToggles[dependency.Key()] = false
+	}
+	for _, key := range selected {
+		subchartToggles[key] = true
+	}
+	return nil
+}
+
+// applySubchartToggles writes the condition of each toggled subchart to the values of the app.
+// Without the chart, the conditions are assumed to follow the <key>.enabled convention.
+func applySubchartToggles(config *models.AppConfig, chart *helm.Chart) error {
+	if len(config.Subcharts) == 0 {
+		return nil
+	}
+	var conditions map[string][]string
+	if chart != nil {
+		conditions = make(map[string][]string)
+		for _, dependency := range toggleableSubcharts(chart) {
+			conditions[dependency.Key()] = dependency.ConditionPath()
+		}
+	}
+	if config.Values == nil {
+		config.Values = make(map[string]interface{})
+	}
+
+	for _, key := range sortedToggleKeys(config.Subcharts) {
+		path := []string{key, "enabled"}
+		if conditions != nil {
+			var ok bool
+			if path, ok = conditions[key]; !ok {
+				return fmt.Errorf("chart %s has no subchart %q with a condition to toggle", chart.Metadata.Name, key)
+			}
+		}
+		enabled := config.Subcharts[key]
+		if raw, ok := config.Values[models.RawValuesKey].(string); ok {
+			updated, err := values.Set([]byte(raw), path, enabled)
+			if err != nil {
+				return err
+			}
+			config.Values[models.RawValuesKey] = string(updated)
+			continue
+		}
+		setNestedValue(config.Values, path, enabled)
+	}
+	return nil
+}
+
+// setNestedValue sets a value at a key path of explicit values. The mappings along the path are
+// copied, as they may be shared with the app spec the values come from.
+func setNestedValue(values map[string]interface{}, path []string, value interface{}) {
+	for _, key := range path[:len(path)-1] {
+		child := make(map[string]interface{})
+		if existing, ok := values[key].(map[string]interface{}); ok {
+			for k, v := range existing {
+				child[k] = v
+			}
+		}
+		values[key] = child
+		values = child
+	}
+	values[path[len(path)-1]] = value
+}
+
+// sortedToggleKeys returns the subchart keys of toggles in a stable order.
+func sortedToggleKeys(toggles map[string]bool) []string {
+	keys := make([]string, 0, len(toggles))
+	for key := range toggles {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formatSubchartToggles describes toggles as "key (enabled), key (disabled)".
+func formatSubchartToggles(toggles map[string]bool) string {
+	parts := make([]string, 0, len(toggles))
+	for _, key := range sortedToggleKeys(toggles) {
+		state := "disabled"
+		if toggles[key] {
+			state = "enabled"
+		}
+		parts = append(parts, fmt.Sprintf("%s (%s)", key, state))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/helm"
+	"github.com/EffectiveSloth/flux-app-generator/internal/models"
+)
+
+// umbrellaChart returns a chart with a redis subchart toggled by redis.enabled, a database
+// toggled by db.enabled and an always enabled library chart.
+func umbrellaChart() *helm.Chart {
+	return &helm.Chart{Metadata: helm.ChartMetadata{
+		Name:    "app",
+		Version: "1.0.0",
+		Dependencies: []helm.ChartDependency{
+			{Name: "redis", Version: "17.x.x", Condition: "redis.enabled"},
+			{Name: "postgresql", Alias: "db", Version: "12.x.x", Condition: "db.enabled,global.db.enabled"},
+			{Name: "common", Version: "2.x.x"},
+		},
+	}}
+}
+
+func TestParseFlags_Subcharts(t *testing.T) {
+	resetFormVariables(t)
+
+	opts, err := parseFlags([]string{"--subcharts", "redis=true, db=false"}, io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"redis": true, "db": false}, subchartToggles)
+	assert.True(t, opts.isSet("subcharts"))
+	assert.Equal(t, map[string]bool{"redis": true, "db": false}, buildConfig().Subcharts)
+
+	for _, invalid := range []string{"redis", "=true", "redis=maybe"} {
+		_, err := parseSubchartToggles(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestCheckSubchartToggles(t *testing.T) {
+	resetFormVariables(t)
+	chart := umbrellaChart()
+
+	subchartToggles = map[string]bool{"redis": true, "db": false}
+	assert.NoError(t, checkSubchartToggles(chart))
+
+	// Only subcharts with a condition can be toggled, by alias
+	for _, key := range []string{"common", "postgresql", "mongodb"} {
+		subchartToggles = map[string]bool{key: true}
+		err := checkSubchartToggles(chart)
+		require.Error(t, err, key)
+		assert.Contains(t, err.Error(), "no subchart")
+	}
+}
+
+func TestApplySubchartToggles(t *testing.T) {
+	chart := umbrellaChart()
+
+	// The first condition of each subchart is set in the raw values, comments kept
+	config := &models.AppConfig{
+		Subcharts: map[string]bool{"redis": true, "db": false},
+		Values:    map[string]interface{}{models.RawValuesKey: "# Redis cache\nredis:\n  enabled: false # off by default\n"},
+	}
+	require.NoError(t, applySubchartToggles(config, chart))
+	assert.Equal(t, "# Redis cache\nredis:\n  enabled: true # off by default\ndb:\n  enabled: false\n", config.Values[models.RawValuesKey])
+
+	// Explicit values are nested without changing the values they were copied from
+	specDB := map[string]interface{}{"auth": "secret"}
+	config = &models.AppConfig{
+		Subcharts: map[string]bool{"db": true},
+		Values:    map[string]interface{}{"db": specDB},
+	}
+	require.NoError(t, applySubchartToggles(config, chart))
+	assert.Equal(t, map[string]interface{}{"db": map[string]interface{}{"auth": "secret", "enabled": true}}, config.Values)
+	assert.Equal(t, map[string]interface{}{"auth": "secret"}, specDB)
+
+	// Without the chart, the <key>.enabled convention is used
+	config = &models.AppConfig{Subcharts: map[string]bool{"cache": false}}
+	require.NoError(t, applySubchartToggles(config, nil))
+	assert.Equal(t, map[string]interface{}{"cache": map[string]interface{}{"enabled": false}}, config.Values)
+
+	config = &models.AppConfig{Subcharts: map[string]bool{"common": true}}
+	assert.Error(t, applySubchartToggles(config, chart))
+}
+
+func TestFormatSubchartToggles(t *testing.T) {
+	assert.Equal(t, "db (disabled), redis (enabled)", formatSubchartToggles(map[string]bool{"redis": true, "db": false}))
+}
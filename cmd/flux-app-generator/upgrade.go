@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/charmbracelet/huh"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/generator"
+	"github.com/EffectiveSloth/flux-app-generator/internal/helm"
+	"github.com/EffectiveSloth/flux-app-generator/internal/plugins"
+	"github.com/EffectiveSloth/flux-app-generator/internal/semver"
+	"github.com/EffectiveSloth/flux-app-generator/internal/values"
+)
+
+// upgradeOptions holds the parsed flags of the upgrade command.
+type upgradeOptions struct {
+	appDir             string
+	chartVersion       string
+	latest             bool
+	versionConstraint  string
+	trackConstraint    bool
+	includePrereleases bool
+	noInput            bool
+	dryRun             bool
+	diff               bool
+	mergeValues        bool
+	auth               helm.Auth
+	cache              cacheOptions
+	network            networkOptions
+	verify             verifyOptions
+}
+
+// parseUpgradeFlags parses the arguments of the upgrade command.
+func parseUpgradeFlags(args []string, output io.Writer) (*upgradeOptions, error) {
+	opts := &upgradeOptions{}
+	fs := flag.NewFlagSet("flux-app-generator upgrade", flag.ContinueOnError)
+	fs.SetOutput(output)
+
+	fs.StringVar(&opts.chartVersion, "chart-version", "", "chart version to upgrade to")
+	fs.BoolVar(&opts.latest, "latest", false, "upgrade to the latest chart version of the repository")
+	fs.StringVar(&opts.versionConstraint, "version-constraint", "", "upgrade to the newest chart version matching this semver constraint (e.g. ~1.4)")
+	fs.BoolVar(&opts.trackConstraint, "track-constraint", false, "write --version-constraint into the app so Flux follows new matching versions")
+	fs.BoolVar(&opts.includePrereleases, "include-prereleases", false, "offer prerelease chart versions, hidden by default")
+	fs.BoolVar(&opts.noInput, "no-input", false, "never prompt; only apply the changes given through flags")
+	fs.BoolVar(&opts.dryRun, "dry-run", false, "print the changed manifests instead of writing them")
+	fs.BoolVar(&opts.diff, "diff", false, "print a unified diff of the changes instead of writing them; exits with status 2 when there are differences")
+	fs.BoolVar(&opts.mergeValues, "merge-values", true, "three-way merge the values file with the default values of both chart versions")
+	addRepoAuthFlags(fs, &opts.auth)
+	addCacheFlags(fs, &opts.cache)
+	addNetworkFlags(fs, &opts.network)
+	addVerifyFlags(fs, &opts.verify)
+
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(fs.Output(), "Usage: flux-app-generator upgrade [flags] <app-dir>\n\n")
+		_, _ = fmt.Fprintf(fs.Output(), "Updates an existing app in place, keeping hand edits and comments.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return nil, fmt.Errorf("expected exactly one app directory")
+	}
+	// The HelmRelease and values of apps generated with environments are upgraded in their base
+	opts.appDir = generator.BaseDir(nil, fs.Arg(0))
+
+	if opts.chartVersion != "" && opts.latest {
+		return nil, fmt.Errorf("--chart-version and --latest cannot be used together")
+	}
+	if opts.versionConstraint != "" {
+		if opts.chartVersion != "" || opts.latest {
+			return nil, fmt.Errorf("--version-constraint cannot be used with --chart-version or --latest")
+		}
+		if _, err := semver.ParseConstraint(opts.versionConstraint); err != nil {
+			return nil, fmt.Errorf("invalid --version-constraint: %w", err)
+		}
+	} else if opts.trackConstraint {
+		return nil, fmt.Errorf("--track-constraint requires --version-constraint")
+	}
+	if opts.dryRun && opts.diff {
+		return nil, fmt.Errorf("--dry-run and --diff cannot be used together")
+	}
+	if err := validateCacheFlags(opts.cache); err != nil {
+		return nil, err
+	}
+	if err := validateNetworkFlags(opts.network); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+// runUpgrade runs the upgrade command and returns the process exit code.
+func runUpgrade(ctx context.Context, args []string) int {
+	opts, err := parseUpgradeFlags(args, os.Stderr)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		return 1
+	}
+
+	app, err := generator.LoadApp(nil, opts.appDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Cannot read app in %s: %s\n", opts.appDir, err)
+		return 1
+	}
+	if app.UsesGitRepository() {
+		fmt.Fprintf(os.Stderr, "❌ %s deploys the chart at %s of %s, upgrade the chart in the Git repository instead\n", app.AppName, app.ChartPath, app.GitURL)
+		return 1
+	}
+	// Keep stdout for the manifests or the diff when previewing
+	var info io.Writer = os.Stdout
+	if opts.dryRun || opts.diff {
+		info = os.Stderr
+	}
+	if err := resolveRepoAuth(app.HelmRepoURL, opts.auth); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		return 1
+	}
+	if err := configureCache(opts.cache); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		return 1
+	}
+	configureNetwork(opts.network)
+	if err := configureVerification(opts.verify); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		return 1
+	}
+	if app.VersionConstraint != "" {
+		// The deployed version is the newest one matching the tracked constraint
+		_, _ = fmt.Fprintf(info, "📦 %s: chart %s@%s from %s\n", app.AppName, app.ChartName, app.VersionConstraint, app.HelmRepoURL)
+		if current, err := versionFetcher.FetchMatchingVersion(ctx, app.HelmRepoURL, app.ChartName, helm.VersionFilter{Constraint: app.VersionConstraint}); err == nil {
+			app.ChartVersion = current.ChartVersion
+			_, _ = fmt.Fprintf(info, "🔎 Version constraint %s resolves to %s\n", app.VersionConstraint, app.ChartVersion)
+		}
+	} else {
+		_, _ = fmt.Fprintf(info, "📦 %s: chart %s@%s from %s\n", app.AppName, app.ChartName, app.ChartVersion, app.HelmRepoURL)
+	}
+
+	filter := helm.VersionFilter{IncludePrerelease: opts.includePrereleases}
+	upgrade := generator.UpgradeOptions{ChartVersion: opts.chartVersion}
+	switch {
+	case opts.versionConstraint != "":
+		filter.Constraint = opts.versionConstraint
+		matching, err := versionFetcher.FetchMatchingVersion(ctx, app.HelmRepoURL, app.ChartName, filter)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+			return 1
+		}
+		upgrade.ChartVersion = matching.ChartVersion
+		if opts.trackConstraint {
+			upgrade.VersionConstraint = opts.versionConstraint
+		}
+	case opts.latest:
+		latest, err := versionFetcher.FetchMatchingVersion(ctx, app.HelmRepoURL, app.ChartName, filter)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+			return 1
+		}
+		upgrade.ChartVersion = latest.ChartVersion
+	case opts.chartVersion == "" && !opts.noInput:
+		if upgrade.ChartVersion, err = selectUpgradeVersion(ctx, info, versionFetcher, app, filter); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+			return 1
+		}
+	}
+
+	if !opts.noInput {
+		if upgrade.Plugins, err = selectUpgradePlugins(app); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+			return 1
+		}
+	}
+
+	if opts.mergeValues && upgrade.ChartVersion != "" && upgrade.ChartVersion != app.ChartVersion {
+		if app.InlineValues {
+			_, _ = fmt.Fprintln(info, "ℹ️  Inline values of the HelmRelease are not merged, review them against the new chart version")
+		} else if upgrade.DefaultValues, err = fetchDefaultValues(ctx, info, app, upgrade.ChartVersion); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+			return 1
+		}
+	}
+
+	if opts.dryRun || opts.diff {
+		result, err := generator.RenderUpgrade(nil, opts.appDir, upgrade)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+			return 1
+		}
+		printValuesReport(os.Stderr, result.ValuesReport)
+		changed, err := previewFiles(os.Stdout, nil, result.Files, opts.appDir, opts.diff)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+			return 1
+		}
+		if changed {
+			return exitCodeDiff
+		}
+		return 0
+	}
+
+	result, err := generator.UpgradeApp(nil, opts.appDir, upgrade)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		return 1
+	}
+	changed := result.Paths()
+	if len(changed) == 0 {
+		fmt.Println("✅ Nothing to upgrade")
+		return 0
+	}
+	if upgrade.ChartVersion != "" && upgrade.ChartVersion != app.ChartVersion {
+		fmt.Printf("⬆️  Upgraded %s from %s to %s\n", app.ChartName, app.ChartVersion, upgrade.ChartVersion)
+	}
+	if upgrade.VersionConstraint != "" {
+		fmt.Printf("🔁 Tracking versions: %s\n", upgrade.VersionConstraint)
+	}
+	printFileList(fmt.Sprintf("📝 Updated files in '%s/':", opts.appDir), changed)
+	printValuesReport(os.Stdout, result.ValuesReport)
+	return 0
+}
+
+// fetchDefaultValues downloads the default values of the current and the new chart version.
+// The values file is not merged when either download fails; charts that fail verification are
+// an error.
+func fetchDefaultValues(ctx context.Context, w io.Writer, app *generator.App, version string) (*generator.DefaultValues, error) {
+	_, _ = fmt.Fprintln(w, "📦 Downloading default values of both chart versions...")
+	downloaded := make([]string, 0, 2)
+	for _, v := range []string{app.ChartVersion, version} {
+		values, err := helm.DownloadAndExtractValuesYAML(ctx, app.HelmRepoURL, app.ChartName, v)
+		if errors.Is(err, helm.ErrVerification) {
+			return nil, err
+		}
+		if err != nil {
+			_, _ = fmt.Fprintf(w, "⚠️  Warning: values are not merged, failed to download the values of %s: %s\n", v, err)
+			return nil, nil
+		}
+		downloaded = append(downloaded, values)
+	}
+	return &generator.DefaultValues{Old: downloaded[0], New: downloaded[1]}, nil
+}
+
+// printValuesReport prints the changes and conflicts of the values merge.
+func printValuesReport(w io.Writer, report *values.Report) {
+	if report == nil {
+		return
+	}
+	if len(report.Changes) == 0 {
+		_, _ = fmt.Fprintln(w, "✅ Values need no changes for the new chart version")
+		return
+	}
+	_, _ = fmt.Fprintf(w, "\n🔀 Values merge:\n%s", report)
+	if len(report.Conflicts()) > 0 {
+		_, _ = fmt.Fprintln(w, "⚠️  Conflicting keys keep our value and are marked with '# CONFLICT' comments in the values file")
+	}
+}
+
+// selectUpgradeVersion asks for the version to upgrade to among the versions newer than the current one.
+// An empty version keeps the current one. Status lines are written to info.
+func selectUpgradeVersion(ctx context.Context, info io.Writer, fetcher *helm.VersionFetcher, app *generator.App, filter helm.VersionFilter) (string, error) {
+	versions, err := fetcher.FetchChartVersions(ctx, app.HelmRepoURL, app.ChartName)
+	if err != nil {
+		return "", err
+	}
+	if versions, err = helm.FilterVersions(versions, filter); err != nil {
+		return "", err
+	}
+
+	// Versions are sorted newest first
+	current, currentErr := semver.Parse(app.ChartVersion)
+	var options []huh.Option[string]
+	for _, version := range versions {
+		if version.ChartVersion == app.ChartVersion {
+			break
+		}
+		if v, err := semver.Parse(version.ChartVersion); err == nil && currentErr == nil && v.Compare(current) <= 0 {
+			break
+		}
+		label := version.ChartVersion
+		if version.AppVersion != "" {
+			label += fmt.Sprintf(" (app %s)", version.AppVersion)
+		}
+		options = append(options, huh.NewOption(label, version.ChartVersion))
+	}
+	if len(options) == 0 {
+		_, _ = fmt.Fprintf(info, "✅ %s@%s is the newest version available\n", app.ChartName, app.ChartVersion)
+		return "", nil
+	}
+	options = append(options, huh.NewOption(fmt.Sprintf("Keep %s", app.ChartVersion), ""))
+
+	var selected string
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[string]().
+				Title("Chart Version").
+				Description(fmt.Sprintf("%s is currently at %s", app.ChartName, app.ChartVersion)).
+				Options(options...).
+				Value(&selected),
+		).Title("⬆️ Upgrade"),
+	).WithTheme(huh.ThemeCharm())
+	if err := form.Run(); err != nil {
+		return "", err
+	}
+	return selected, nil
+}
+
+// selectUpgradePlugins offers to add plugin instances to the app.
+func selectUpgradePlugins(app *generator.App) ([]plugins.PluginConfig, error) {
+	var addPlugins bool
+	confirm := huh.NewForm(
+		huh.NewGroup(
+			huh.NewConfirm().
+				Title("Add plugins to this app?").
+				Value(&addPlugins),
+		),
+	).WithTheme(huh.ThemeCharm())
+	if err := confirm.Run(); err != nil {
+		return nil, err
+	}
+	if !addPlugins {
+		return nil, nil
+	}
+
+	// Plugins are configured for the namespace of the app
+	showKubernetesSplashScreen()
+	namespace = app.Namespace
+	pluginRegistry = plugins.NewRegistry(k8sClient)
+	if err := runInteractivePluginMenu(); err != nil {
+		return nil, err
+	}
+	return pluginInstances, nil
+}
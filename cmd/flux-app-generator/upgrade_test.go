@@ -0,0 +1,39 @@
+package main
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseUpgradeFlags(t *testing.T) {
+	opts, err := parseUpgradeFlags([]string{"--chart-version", "1.2.3", "--diff", "apps/podinfo"}, io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, "apps/podinfo", opts.appDir)
+	assert.Equal(t, "1.2.3", opts.chartVersion)
+	assert.True(t, opts.diff)
+
+	tests := []struct {
+		name    string
+		args    []string
+		message string
+	}{
+		{"missing app directory", []string{"--latest"}, "expected exactly one app directory"},
+		{"version and latest", []string{"--latest", "--chart-version", "1.0.0", "app"}, "cannot be used together"},
+		{"dry run and diff", []string{"--dry-run", "--diff", "app"}, "cannot be used together"},
+		{"constraint and version", []string{"--version-constraint", "~1.0", "--chart-version", "1.0.0", "app"}, "cannot be used with"},
+		{"invalid constraint", []string{"--version-constraint", "~latest", "app"}, "invalid --version-constraint"},
+		{"tracking without constraint", []string{"--track-constraint", "app"}, "requires --version-constraint"},
+		{"negative cache ttl", []string{"--cache-ttl", "-5m", "app"}, "invalid --cache-ttl"},
+		{"negative retries", []string{"--retries", "-2", "app"}, "invalid --retries"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseUpgradeFlags(tt.args, io.Discard)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.message)
+		})
+	}
+}
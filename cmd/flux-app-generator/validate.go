@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/generator"
+	"github.com/EffectiveSloth/flux-app-generator/internal/helm"
+	"github.com/EffectiveSloth/flux-app-generator/internal/models"
+	"github.com/EffectiveSloth/flux-app-generator/internal/schema"
+	"github.com/EffectiveSloth/flux-app-generator/internal/values"
+)
+
+// valuesFile is the values file validated against the values schema, as named in reports.
+const valuesFile = "release/helm-values.yaml"
+
+// validateOptions holds the parsed flags of the validate command.
+type validateOptions struct {
+	appDir  string
+	auth    helm.Auth
+	cache   cacheOptions
+	network networkOptions
+	verify  verifyOptions
+}
+
+// parseValidateFlags parses the arguments of the validate command.
+func parseValidateFlags(args []string, output io.Writer) (*validateOptions, error) {
+	opts := &validateOptions{}
+	fs := flag.NewFlagSet("flux-app-generator validate", flag.ContinueOnError)
+	fs.SetOutput(output)
+	addRepoAuthFlags(fs, &opts.auth)
+	addCacheFlags(fs, &opts.cache)
+	addNetworkFlags(fs, &opts.network)
+	addVerifyFlags(fs, &opts.verify)
+
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(fs.Output(), "Usage: flux-app-generator validate [flags] <app-dir>\n\n")
+		_, _ = fmt.Fprintf(fs.Output(), "Validates the values file of an existing app against the values.schema.json of its chart.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return nil, fmt.Errorf("expected exactly one app directory")
+	}
+	// The HelmRelease and values of apps generated with environments are validated in their base
+	opts.appDir = generator.BaseDir(nil, fs.Arg(0))
+
+	if err := validateCacheFlags(opts.cache); err != nil {
+		return nil, err
+	}
+	if err := validateNetworkFlags(opts.network); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+// runValidate runs the validate command and returns the process exit code: 1 when the values
+// break the schema or cannot be validated.
+func runValidate(ctx context.Context, args []string, w io.Writer) int {
+	opts, err := parseValidateFlags(args, os.Stderr)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		return 1
+	}
+
+	app, err := generator.LoadApp(nil, opts.appDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Cannot read app in %s: %s\n", opts.appDir, err)
+		return 1
+	}
+	data, err := generator.ReadValues(nil, opts.appDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		return 1
+	}
+	if err := resolveRepoAuth(app.HelmRepoURL, opts.auth); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		return 1
+	}
+	if err := configureCache(opts.cache); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		return 1
+	}
+	configureNetwork(opts.network)
+	if err := configureVerification(opts.verify); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		return 1
+	}
+
+	var chart *helm.Chart
+	version := app.ChartVersion
+	if app.UsesGitRepository() {
+		if chart, err = loadGitChart(opts.appDir, app.ChartPath); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Cannot load chart %s: %s\n", app.ChartPath, err)
+			return 1
+		}
+		version = chart.Metadata.Version
+	} else {
+		if app.VersionConstraint != "" {
+			matching, err := versionFetcher.FetchMatchingVersion(ctx, app.HelmRepoURL, app.ChartName, helm.VersionFilter{Constraint: app.VersionConstraint})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+				return 1
+			}
+			version = matching.ChartVersion
+		}
+		if chart, err = helm.InspectChart(ctx, app.HelmRepoURL, app.ChartName, version); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Cannot inspect chart %s@%s: %s\n", app.ChartName, version, err)
+			return 1
+		}
+	}
+	if chart.Schema == nil {
+		_, _ = fmt.Fprintf(w, "ℹ️  Chart %s %s has no values.schema.json, nothing to validate\n", app.ChartName, version)
+		return 0
+	}
+
+	violations, err := validateValues(chart, data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s\n", err)
+		return 1
+	}
+	if len(violations) > 0 {
+		printViolations(w, chart, violations)
+		return 1
+	}
+	_, _ = fmt.Fprintf(w, "✅ %s matches the values schema of %s %s\n", valuesFile, app.ChartName, version)
+	return 0
+}
+
+// checkValuesSchema validates the values file of the generated app against the values schema of
+// the inspected chart, if it has one.
+func checkValuesSchema(w io.Writer, config *models.AppConfig) error {
+	if chartInfo == nil || chartInfo.Schema == nil {
+		return nil
+	}
+	content, err := generator.RenderHelmValues(config)
+	if err != nil {
+		return err
+	}
+	violations, err := validateValues(chartInfo, []byte(content))
+	if err != nil {
+		return err
+	}
+	if len(violations) > 0 {
+		printViolations(w, chartInfo, violations)
+		return fmt.Errorf("%s does not match the values schema of chart %s %s, fix the values or use --skip-schema-validation",
+			valuesFile, chartInfo.Metadata.Name, chartInfo.Metadata.Version)
+	}
+	_, _ = fmt.Fprintln(w, "✅ Values match the values schema of the chart")
+	return nil
+}
+
+// validateValues validates a values file against the values schema of a chart. Like Helm, the
+// values are validated once coalesced with the chart defaults.
+func validateValues(chart *helm.Chart, data []byte) ([]schema.Violation, error) {
+	valuesSchema, err := schema.Compile(chart.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("chart %s %s: values.schema.json: %w", chart.Metadata.Name, chart.Metadata.Version, err)
+	}
+	coalesced, err := values.Coalesce([]byte(chart.Values), data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", valuesFile, err)
+	}
+	return valuesSchema.Validate(coalesced), nil
+}
+
+// printViolations lists the schema violations of the values file, by line.
+func printViolations(w io.Writer, chart *helm.Chart, violations []schema.Violation) {
+	_, _ = fmt.Fprintf(w, "❌ %s does not match the values schema of %s %s:\n", valuesFile, chart.Metadata.Name, chart.Metadata.Version)
+	for _, violation := range violations {
+		pointer := violation.Pointer
+		if pointer == "" {
+			pointer = "/"
+		}
+		if violation.Line > 0 {
+			_, _ = fmt.Fprintf(w, "   %s:%d: %s: %s\n", valuesFile, violation.Line, pointer, violation.Message)
+		} else {
+			_, _ = fmt.Fprintf(w, "   %s: %s (chart default)\n", pointer, violation.Message)
+		}
+	}
+}
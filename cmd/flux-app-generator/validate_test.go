@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/generator"
+	"github.com/EffectiveSloth/flux-app-generator/internal/helm"
+	"github.com/EffectiveSloth/flux-app-generator/internal/models"
+)
+
+const validateTestSchema = `{
+  "type": "object",
+  "required": ["image"],
+  "properties": {
+    "replicaCount": {"type": "integer", "minimum": 1},
+    "image": {"type": "object", "required": ["repository"]}
+  }
+}`
+
+// newSchemaChartRepository serves the podinfo chart with a values schema.
+func newSchemaChartRepository(t *testing.T) string {
+	t.Helper()
+	return newTestChartRepository(t, map[string]string{
+		"Chart.yaml":         "apiVersion: v2\nname: podinfo\nversion: 6.9.0\n",
+		"values.yaml":        "replicaCount: 1\nimage:\n  repository: ghcr.io/stefanprodan/podinfo\n",
+		"values.schema.json": validateTestSchema,
+	})
+}
+
+func TestCheckValuesSchema(t *testing.T) {
+	resetFormVariables(t)
+	helmRepoURL = newSchemaChartRepository(t)
+	appName, namespace, helmRepoName, selectedChart, selectedVersion = "podinfo", "apps", "podinfo", "podinfo", "6.9.0"
+	require.NoError(t, inspectChart(context.Background(), io.Discard))
+
+	// Overrides are validated once coalesced with the chart defaults
+	config := buildConfig()
+	config.Values[models.RawValuesKey] = "replicaCount: 2\n"
+	var out bytes.Buffer
+	require.NoError(t, checkValuesSchema(&out, config))
+	assert.Contains(t, out.String(), "Values match the values schema")
+
+	config.Values[models.RawValuesKey] = "# Overrides\nreplicaCount: 0\nimage: ~\n"
+	out.Reset()
+	err := checkValuesSchema(&out, config)
+	assert.ErrorContains(t, err, "--skip-schema-validation")
+	assert.Contains(t, out.String(), "release/helm-values.yaml:2: /replicaCount: minimum: got 0, want 1")
+	assert.Contains(t, out.String(), "/: missing property 'image'")
+
+	// Charts without a schema are not validated
+	chartInfo.Schema = nil
+	require.NoError(t, checkValuesSchema(&out, config))
+}
+
+func TestRunValidate(t *testing.T) {
+	resetFormVariables(t)
+	require.NoError(t, loadTemplates())
+	t.Setenv(helm.CacheDirEnv, t.TempDir())
+	t.Cleanup(func() {
+		helm.SetCache(nil)
+	})
+	outputDir := t.TempDir()
+	config := &models.AppConfig{
+		AppName:      "podinfo",
+		Namespace:    "apps",
+		HelmRepoName: "podinfo",
+		HelmRepoURL:  newSchemaChartRepository(t),
+		ChartName:    "podinfo",
+		ChartVersion: "6.9.0",
+		Interval:     "5m",
+		Values:       map[string]interface{}{models.RawValuesKey: "replicaCount: 2\n"},
+	}
+	require.NoError(t, generator.GenerateFluxStructureWithOptions(config, generator.Options{OutputDir: outputDir}))
+	appDir := filepath.Join(outputDir, "podinfo")
+
+	var out bytes.Buffer
+	assert.Equal(t, 0, runValidate(context.Background(), []string{appDir}, &out))
+	assert.Contains(t, out.String(), "✅ release/helm-values.yaml matches the values schema of podinfo 6.9.0")
+
+	valuesPath := filepath.Join(appDir, "release", "helm-values.yaml")
+	// A null value removes the default, like with Helm
+	require.NoError(t, os.WriteFile(valuesPath, []byte("image: ~\nreplicaCount: \"2\"\n"), 0o600))
+	out.Reset()
+	assert.Equal(t, 1, runValidate(context.Background(), []string{appDir}, &out))
+	assert.Contains(t, out.String(), "release/helm-values.yaml:1: /: missing property 'image'")
+	assert.Contains(t, out.String(), "release/helm-values.yaml:2: /replicaCount: got string, want integer")
+
+	assert.Equal(t, 1, runValidate(context.Background(), []string{filepath.Join(outputDir, "missing")}, &out))
+}
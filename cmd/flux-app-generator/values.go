@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/huh"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/generator"
+	"github.com/EffectiveSloth/flux-app-generator/internal/models"
+	"github.com/EffectiveSloth/flux-app-generator/internal/values"
+)
+
+// valuesKeysDepth is the number of nesting levels offered by the values key selector.
+const valuesKeysDepth = 2
+
+// prefillOverrides writes the selected keys of the chart defaults to the values file, and the
+// whole defaults, commented out, to a reference file that is not applied.
+func prefillOverrides(config *models.AppConfig, defaults string, opts *cliOptions) error {
+	keys := valuesKeys
+	if len(keys) == 0 {
+		var err error
+		if keys, err = values.DefaultKeys([]byte(defaults)); err != nil {
+			return err
+		}
+		if !opts.noInput {
+			if keys, err = selectValuesKeys(defaults, keys); err != nil {
+				return err
+			}
+		}
+	}
+
+	overrides, err := values.Overrides([]byte(defaults), keys)
+	if err != nil {
+		return err
+	}
+	header := fmt.Sprintf("# Overrides of the %s %s default values.\n# All defaults are listed in helm-values.defaults.yaml.\n", config.ChartName, config.ChartVersion)
+	config.Values[models.RawValuesKey] = header + string(overrides)
+	config.ValuesKeys = keys
+	config.DefaultValues = generator.ValuesDefaultsReference(config.ChartName, config.ChartVersion, defaults)
+	return nil
+}
+
+// selectValuesKeys asks which keys of the chart defaults should be overridden.
+func selectValuesKeys(defaults string, preselected []string) ([]string, error) {
+	keys, err := values.Keys([]byte(defaults), valuesKeysDepth)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	options := make([]huh.Option[string], 0, len(keys))
+	for _, key := range keys {
+		label := strings.Repeat("  ", key.Depth) + key.Path
+		options = append(options, huh.NewOption(label, key.Path))
+	}
+
+	selected := preselected
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewMultiSelect[string]().
+				Title("Values To Override").
+				Description("Selected keys are copied with their defaults to helm-values.yaml; selecting a key includes its children").
+				Options(options...).
+				Height(15).
+				Value(&selected),
+		).Title("📝 Helm Values"),
+	).WithTheme(huh.ThemeCharm())
+	if err := form.Run(); err != nil {
+		return nil, err
+	}
+	return selected, nil
+}
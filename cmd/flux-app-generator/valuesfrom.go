@@ -0,0 +1,143 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/huh"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/models"
+)
+
+// valuesSourceFormat is the format of the sources taken by --values-sources.
+const valuesSourceFormat = "Kind/name[/valuesKey][=targetPath|@file][?]"
+
+var (
+	// valuesKind is how the values are delivered to the HelmRelease, a ConfigMap when empty.
+	valuesKind string
+	// valuesSources are the ConfigMaps and Secrets layered over the values of the app.
+	valuesSources []models.ValuesSource
+)
+
+// addValuesFromFlags adds the flags choosing how the values are delivered to the HelmRelease.
+func addValuesFromFlags(fs *flag.FlagSet) {
+	valuesSources = nil
+	fs.StringVar(&valuesKind, "values-kind", "", "how the values are delivered to the HelmRelease (ConfigMap|Secret|inline), ConfigMap by default")
+	fs.Func("values-sources", "comma-separated ConfigMaps and Secrets layered over the values, as "+valuesSourceFormat+" (e.g. Secret/db/password=postgresql.auth.password)", func(s string) error {
+		sources, err := parseValuesSources(s)
+		if err != nil {
+			return err
+		}
+		valuesSources = sources
+		return nil
+	})
+}
+
+// parseValuesSources parses a comma-separated list of values sources. A source is an existing
+// ConfigMap or Secret, its values merged or set at =targetPath, or one generated from @file;
+// a trailing ? makes it optional.
+func parseValuesSources(s string) ([]models.ValuesSource, error) {
+	var sources []models.ValuesSource
+	for _, item := range splitList(s) {
+		var source models.ValuesSource
+		ref := item
+		if trimmed, ok := strings.CutSuffix(ref, "?"); ok {
+			ref, source.Optional = trimmed, true
+		}
+		if name, file, ok := strings.Cut(ref, "@"); ok {
+			ref, source.File = name, file
+		} else if name, targetPath, ok := strings.Cut(ref, "="); ok {
+			ref, source.TargetPath = name, targetPath
+		}
+		parts := strings.Split(ref, "/")
+		if len(parts) < 2 || len(parts) > 3 || parts[1] == "" {
+			return nil, fmt.Errorf("%q is not %s", item, valuesSourceFormat)
+		}
+		source.Kind, source.Name = parts[0], parts[1]
+		if len(parts) == 3 {
+			source.ValuesKey = parts[2]
+		}
+		if source.Kind != models.ValuesKindConfigMap && source.Kind != models.ValuesKindSecret {
+			return nil, fmt.Errorf("%q is not a %s or %s", item, models.ValuesKindConfigMap, models.ValuesKindSecret)
+		}
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
+
+// formatValuesSources formats values sources the way --values-sources takes them.
+func formatValuesSources(sources []models.ValuesSource) string {
+	items := make([]string, len(sources))
+	for i, source := range sources {
+		items[i] = source.Kind + "/" + source.Name
+		if source.ValuesKey != "" {
+			items[i] += "/" + source.ValuesKey
+		}
+		switch {
+		case source.File != "":
+			items[i] += "@" + source.File
+		case source.TargetPath != "":
+			items[i] += "=" + source.TargetPath
+		}
+		if source.Optional {
+			items[i] += "?"
+		}
+	}
+	return strings.Join(items, ",")
+}
+
+// applyValuesSourcesSpec fills the values sources from an app spec, unless --values-sources is set.
+func applyValuesSourcesSpec(sources []models.ValuesSource, opts *cliOptions) {
+	if opts.isSet("values-sources") {
+		return
+	}
+	valuesSources = append([]models.ValuesSource(nil), sources...)
+	opts.set["values-sources"] = true
+}
+
+// promptValuesFrom asks how the values are delivered to the HelmRelease, and for the sources
+// layered over them. Each is skipped when given on the command line or in an app spec.
+func promptValuesFrom(opts *cliOptions) error {
+	var fields []huh.Field
+	if !opts.isSet("values-kind") {
+		if valuesKind == "" {
+			valuesKind = models.ValuesKindConfigMap
+		}
+		fields = append(fields, huh.NewSelect[string]().
+			Title("Values Delivery").
+			Description("How the values of helm-values.yaml reach the HelmRelease").
+			Options(
+				huh.NewOption("ConfigMap generated from helm-values.yaml", models.ValuesKindConfigMap),
+				huh.NewOption("Secret generated from helm-values.yaml, to encrypt with SOPS", models.ValuesKindSecret),
+				huh.NewOption("Inline in the HelmRelease, as spec.values", models.ValuesKindInline),
+			).
+			Value(&valuesKind))
+	}
+	sources := formatValuesSources(valuesSources)
+	if !opts.isSet("values-sources") {
+		fields = append(fields, huh.NewInput().
+			Title("Layered Values Sources").
+			Description("Comma-separated ConfigMaps and Secrets merged over the values, as "+valuesSourceFormat+" (leave empty for none)").
+			Placeholder("Secret/db-credentials/password=postgresql.auth.password").
+			Value(&sources).
+			Validate(func(s string) error {
+				_, err := parseValuesSources(s)
+				return err
+			}))
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	form := huh.NewForm(
+		huh.NewGroup(fields...).Title("📝 Values Sources"),
+	).WithTheme(huh.ThemeCharm())
+	if err := form.Run(); err != nil {
+		return err
+	}
+
+	var err error
+	valuesSources, err = parseValuesSources(sources)
+	return err
+}
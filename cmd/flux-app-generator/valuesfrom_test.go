@@ -0,0 +1,60 @@
+package main
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/models"
+)
+
+func TestParseValuesSources(t *testing.T) {
+	sources, err := parseValuesSources("ConfigMap/shared-values?, Secret/db/password=postgresql.auth.password, Secret/podinfo-secrets@secrets.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, []models.ValuesSource{
+		{Kind: models.ValuesKindConfigMap, Name: "shared-values", Optional: true},
+		{Kind: models.ValuesKindSecret, Name: "db", ValuesKey: "password", TargetPath: "postgresql.auth.password"},
+		{Kind: models.ValuesKindSecret, Name: "podinfo-secrets", File: "secrets.yaml"},
+	}, sources)
+	assert.Equal(t, "ConfigMap/shared-values?,Secret/db/password=postgresql.auth.password,Secret/podinfo-secrets@secrets.yaml", formatValuesSources(sources))
+
+	sources, err = parseValuesSources("")
+	require.NoError(t, err)
+	assert.Empty(t, sources)
+
+	for _, invalid := range []string{"shared-values", "ConfigMap/", "ConfigMap/a/b/c", "Vault/db"} {
+		_, err := parseValuesSources(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestParseFlags_ValuesFrom(t *testing.T) {
+	resetFormVariables(t)
+
+	_, err := parseFlags([]string{"--values-kind", "Secret", "--values-sources", "ConfigMap/shared-values?"}, io.Discard)
+	require.NoError(t, err)
+	config := buildConfig()
+	assert.Equal(t, models.ValuesKindSecret, config.ValuesKind)
+	assert.Equal(t, []models.ValuesSource{{Kind: models.ValuesKindConfigMap, Name: "shared-values", Optional: true}}, config.ValuesSources)
+
+	resetFormVariables(t)
+	_, err = parseFlags([]string{"--values-sources", "shared-values"}, io.Discard)
+	assert.Error(t, err)
+}
+
+func TestApplySpec_ValuesFrom(t *testing.T) {
+	resetFormVariables(t)
+
+	opts, err := parseFlags([]string{"--values-kind", "inline"}, io.Discard)
+	require.NoError(t, err)
+	sources := []models.ValuesSource{{Kind: models.ValuesKindSecret, Name: "db", ValuesKey: "password", TargetPath: "auth.password"}}
+	applySpec(models.NewAppSpec(&models.AppConfig{ValuesKind: models.ValuesKindSecret, ValuesSources: sources}), opts)
+
+	// Flags override the spec
+	config := buildConfig()
+	assert.Equal(t, models.ValuesKindInline, config.ValuesKind)
+	assert.Equal(t, sources, config.ValuesSources)
+	assert.True(t, opts.isSet("values-sources"))
+}
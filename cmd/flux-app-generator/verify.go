@@ -0,0 +1,115 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+
+	"github.com/charmbracelet/huh"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/helm"
+	"github.com/EffectiveSloth/flux-app-generator/internal/models"
+)
+
+// verifyOptions holds the flags controlling the verification of downloaded charts.
+type verifyOptions struct {
+	keyring string
+}
+
+// addVerifyFlags adds the flags controlling the verification of downloaded charts.
+func addVerifyFlags(fs *flag.FlagSet, opts *verifyOptions) {
+	fs.StringVar(&opts.keyring, "keyring", "", "public keyring (gpg --export) verifying the provenance files of downloaded charts, like helm --verify")
+}
+
+// configureVerification sets the keyring verifying the provenance files of downloaded charts.
+// Chart digests listed in repository indexes are always checked.
+func configureVerification(opts verifyOptions) error {
+	if opts.keyring == "" {
+		helm.SetKeyring(nil)
+		return nil
+	}
+	keyring, err := helm.LoadKeyring(opts.keyring)
+	if err != nil {
+		return err
+	}
+	helm.SetKeyring(keyring)
+	return nil
+}
+
+// cosign holds the cosign verification of the chart written into the generated source.
+var cosign struct {
+	verify      bool
+	secretRef   string
+	oidcIssuer  string
+	oidcSubject string
+}
+
+// addCosignFlags adds the flags writing cosign verification into the generated source.
+func addCosignFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&cosign.verify, "cosign-verify", false, "have Flux verify the cosign signature of the OCI chart, keyless unless --cosign-secret-ref is set")
+	fs.StringVar(&cosign.secretRef, "cosign-secret-ref", "", "Secret holding the cosign public keys the OCI chart is verified with")
+	fs.StringVar(&cosign.oidcIssuer, "cosign-oidc-issuer", "", "regexp of the OIDC issuer of keyless signatures (OCIRepository only)")
+	fs.StringVar(&cosign.oidcSubject, "cosign-oidc-subject", "", "regexp of the OIDC subject of keyless signatures (OCIRepository only)")
+}
+
+// promptCosignVerify asks whether Flux should verify the cosign signature of an OCI chart, and
+// how: with the public keys of a Secret or keyless, optionally matching the signer identity.
+func promptCosignVerify(opts *cliOptions) error {
+	if !helm.IsOCI(helmRepoURL) || opts.isSet("cosign-verify") {
+		return nil
+	}
+
+	confirm := huh.NewForm(
+		huh.NewGroup(
+			huh.NewConfirm().
+				Title("Verify Chart Signature?").
+				Description("Have Flux verify the cosign signature of the chart before using it").
+				Value(&cosign.verify),
+		),
+	).WithTheme(huh.ThemeCharm())
+	if err := confirm.Run(); err != nil {
+		return err
+	}
+	if !cosign.verify {
+		return nil
+	}
+
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Cosign Public Keys Secret").
+				Description("Secret holding the cosign public keys (*.pub), empty for keyless verification").
+				Value(&cosign.secretRef),
+		).Title("🔏 Chart Signature"),
+		huh.NewGroup(
+			huh.NewInput().
+				Title("OIDC Issuer").
+				Description("Regexp of the issuer of the keyless signatures, empty to accept any").
+				Placeholder("^https://token.actions.githubusercontent.com$").
+				Value(&cosign.oidcIssuer).
+				Validate(validRegexp),
+			huh.NewInput().
+				Title("OIDC Subject").
+				Description("Regexp of the identity that signed the chart, required with an issuer").
+				Placeholder("^https://github.com/acme/charts/.*$").
+				Value(&cosign.oidcSubject).
+				Validate(func(s string) error {
+					if s == "" && cosign.oidcIssuer != "" {
+						return fmt.Errorf("subject is required with an issuer")
+					}
+					return validRegexp(s)
+				}),
+		).Title("🔏 Keyless Signature").WithHideFunc(func() bool {
+			return cosign.secretRef != "" || sourceKind != models.SourceKindOCIRepository
+		}),
+	).WithTheme(huh.ThemeCharm())
+	return form.Run()
+}
+
+// validRegexp checks that a wizard input is a valid regexp.
+func validRegexp(s string) error {
+	if _, err := regexp.Compile(s); err != nil {
+		return fmt.Errorf("invalid regexp: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/helm"
+)
+
+// signerPublicKey is an Ed25519 public key, as exported with gpg --armor --export.
+const signerPublicKey = `-----BEGIN PGP PUBLIC KEY BLOCK-----
+
+mDMEatHN1BYJKwYBBAHaRw8BAQdA+MoWQdbuvp/3uHznZD9o+A9oZrh7nsijmpRv
+TYbjZR20IUNoYXJ0IFNpZ25lciA8Y2hhcnRzQGV4YW1wbGUuY29tPoiQBBMWCAA4
+FiEEIPPdaMbSKY+u8WTuWrmxY56s/+oFAmrRzdQCGwMFCwkIBwIGFQoJCAsCBBYC
+AwECHgECF4AACgkQWrmxY56s/+qpXAEApdCeZP9OAIGW2+Sujy8axQOdOCx42p6W
+ILSE4Yn65VIA/Rs2hvvPyV/hrkNbSaTkO6CVw/qoe8pe1vU1Ij9+aZcM
+=LBOL
+-----END PGP PUBLIC KEY BLOCK-----
+`
+
+func TestConfigureVerification(t *testing.T) {
+	t.Cleanup(func() {
+		helm.SetKeyring(nil)
+	})
+	dir := t.TempDir()
+	keyring := filepath.Join(dir, "pubring.asc")
+	require.NoError(t, os.WriteFile(keyring, []byte(signerPublicKey), 0o600))
+	require.NoError(t, configureVerification(verifyOptions{keyring: keyring}))
+	require.NoError(t, configureVerification(verifyOptions{}))
+
+	invalid := filepath.Join(dir, "invalid.gpg")
+	require.NoError(t, os.WriteFile(invalid, []byte("not a keyring"), 0o600))
+	assert.Error(t, configureVerification(verifyOptions{keyring: invalid}))
+	assert.ErrorContains(t, configureVerification(verifyOptions{keyring: filepath.Join(dir, "missing.gpg")}), "failed to read keyring")
+}
+
+func TestParseFlags_Cosign(t *testing.T) {
+	resetFormVariables(t)
+	opts, err := parseFlags([]string{"--cosign-secret-ref", "cosign-pub", "--keyring", "pubring.gpg"}, io.Discard)
+	require.NoError(t, err)
+	assert.True(t, cosign.verify)
+	assert.True(t, opts.isSet("cosign-verify"))
+	assert.Equal(t, "pubring.gpg", opts.verify.keyring)
+
+	helmRepoURL, selectedChart, sourceKind = "oci://ghcr.io/stefanprodan/charts", "podinfo", "OCIRepository"
+	config := buildConfig()
+	assert.True(t, config.CosignVerify)
+	assert.Equal(t, "cosign-pub", config.CosignSecretRef)
+	assert.NoError(t, config.ValidateVerification())
+
+	resetFormVariables(t)
+	_, err = parseFlags([]string{"--cosign-verify=false", "--cosign-oidc-issuer", ".*"}, io.Discard)
+	assert.ErrorContains(t, err, "require --cosign-verify")
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/charmbracelet/huh"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/helm"
+	"github.com/EffectiveSloth/flux-app-generator/internal/models"
+	"github.com/EffectiveSloth/flux-app-generator/internal/semver"
+)
+
+var (
+	// versionConstraint is the semver constraint the chart version is resolved from.
+	versionConstraint string
+	// trackConstraint writes versionConstraint into the generated manifests instead of the resolved version.
+	trackConstraint bool
+	// includePrereleases offers and resolves prerelease chart versions, which are hidden by default.
+	includePrereleases bool
+)
+
+// versionPageSize is the number of versions the version selector offers before "show more".
+const versionPageSize = 15
+
+// showMoreVersions is the value of the version selector option offering more versions.
+const showMoreVersions = "…"
+
+// versionOptions returns the version selector options for the first limit versions, followed by
+// an option to show more when some are left out.
+func versionOptions(versions []helm.ChartVersion, limit int) []huh.Option[string] {
+	shown := versions
+	if len(shown) > limit {
+		shown = shown[:limit]
+	}
+	options := make([]huh.Option[string], 0, len(shown)+1)
+	for _, version := range shown {
+		displayName := version.ChartVersion
+		if version.AppVersion != "" {
+			displayName = fmt.Sprintf("%s (App: %s)", version.ChartVersion, version.AppVersion)
+		}
+		if version.Description != "" {
+			displayName = fmt.Sprintf("%s - %s", displayName, version.Description)
+		}
+		options = append(options, huh.NewOption(displayName, version.ChartVersion))
+	}
+	if len(versions) > len(shown) {
+		more := min(versionPageSize, len(versions)-len(shown))
+		options = append(options, huh.NewOption(fmt.Sprintf("⬇️  Show %d more of %d older versions", more, len(versions)-len(shown)), showMoreVersions))
+	}
+	return options
+}
+
+// versionFilter returns the filter selecting the chart versions to offer or resolve.
+func versionFilter() helm.VersionFilter {
+	return helm.VersionFilter{Constraint: versionConstraint, IncludePrerelease: includePrereleases}
+}
+
+// validateVersionConstraint checks the version constraint flags.
+func validateVersionConstraint() error {
+	if versionConstraint != "" {
+		if _, err := semver.ParseConstraint(versionConstraint); err != nil {
+			return fmt.Errorf("invalid --version-constraint: %w", err)
+		}
+	} else if trackConstraint {
+		return fmt.Errorf("--track-constraint requires --version-constraint")
+	}
+	return nil
+}
+
+// resolveVersionConstraint sets the chart version to the newest version matching the version
+// constraint, reporting it to w. A version chosen already must match the constraint.
+func resolveVersionConstraint(ctx context.Context, w io.Writer) error {
+	if versionConstraint == "" || selectedChart == "" {
+		return nil
+	}
+	if selectedVersion != "" {
+		constraint, err := semver.ParseConstraint(versionConstraint)
+		if err != nil {
+			return err
+		}
+		if version, err := semver.Parse(selectedVersion); err != nil || !constraint.Check(version) {
+			return fmt.Errorf("chart version %s does not match the version constraint %s", selectedVersion, versionConstraint)
+		}
+		return nil
+	}
+	version, err := versionFetcher.FetchMatchingVersion(ctx, helmRepoURL, selectedChart, versionFilter())
+	if err != nil {
+		return err
+	}
+	selectedVersion = version.ChartVersion
+	fmt.Fprintf(w, "🔎 Version constraint %s resolves to %s@%s\n", versionConstraint, selectedChart, selectedVersion)
+	return nil
+}
+
+// versionTrackingField returns the wizard field choosing between pinning the selected version
+// and tracking new patch or minor releases, or nil when the choice was made through flags.
+func versionTrackingField(opts *cliOptions) huh.Field {
+	if opts.isSet("version-constraint") || opts.isSet("track-constraint") || sourceKind == models.SourceKindGitRepository {
+		return nil
+	}
+	version, err := semver.Parse(selectedVersion)
+	if err != nil {
+		return nil
+	}
+	patch := fmt.Sprintf("~%s", version)
+	minor := fmt.Sprintf("^%s", version)
+	return huh.NewSelect[string]().
+		Title("Version Updates").
+		Description("Pin the chart version, or let Flux upgrade to newer matching versions").
+		Options(
+			huh.NewOption(fmt.Sprintf("Pin %s", selectedVersion), ""),
+			huh.NewOption(fmt.Sprintf("Follow patch releases (%s)", patch), patch),
+			huh.NewOption(fmt.Sprintf("Follow minor releases (%s)", minor), minor),
+		).
+		Value(&versionConstraint)
+}
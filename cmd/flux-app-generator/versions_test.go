@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/helm"
+)
+
+// newTestRepository serves a Helm repository index listing versions of the podinfo chart.
+func newTestRepository(t *testing.T, versions ...string) string {
+	t.Helper()
+	index := "apiVersion: v1\nentries:\n  podinfo:\n"
+	for _, version := range versions {
+		index += "    - version: " + version + "\n"
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, index)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestResolveVersionConstraint(t *testing.T) {
+	resetFormVariables(t)
+	repoURL := newTestRepository(t, "1.9.0", "1.10.0", "1.10.1", "2.0.0-rc.1", "2.0.0")
+
+	_, err := parseFlags([]string{"--repo-url", repoURL, "--chart", "podinfo", "--version-constraint", "~1.10", "--track-constraint"}, io.Discard)
+	require.NoError(t, err)
+	// The chart version is not required with a constraint
+	for _, field := range missingFields() {
+		assert.NotEqual(t, "chart-version", field.flag)
+	}
+
+	require.NoError(t, resolveVersionConstraint(context.Background(), io.Discard))
+	assert.Equal(t, "1.10.1", selectedVersion)
+	config := buildConfig()
+	assert.Equal(t, "~1.10", config.ReleaseVersion())
+	assert.Equal(t, "1.10.1", config.ChartVersion)
+
+	// A given version must match the constraint
+	selectedVersion = "1.9.0"
+	assert.ErrorContains(t, resolveVersionConstraint(context.Background(), io.Discard), "does not match the version constraint")
+
+	// Prereleases only match constraints naming one
+	selectedVersion, versionConstraint, includePrereleases = "", ">=2.0.0-0", false
+	require.NoError(t, resolveVersionConstraint(context.Background(), io.Discard))
+	assert.Equal(t, "2.0.0", selectedVersion)
+
+	selectedVersion, versionConstraint = "", "^3"
+	assert.ErrorContains(t, resolveVersionConstraint(context.Background(), io.Discard), "no version of chart 'podinfo' matches ^3")
+}
+
+func TestVersionTrackingField(t *testing.T) {
+	resetFormVariables(t)
+
+	opts, err := parseFlags(nil, io.Discard)
+	require.NoError(t, err)
+	selectedVersion = "1.4.2"
+	assert.NotNil(t, versionTrackingField(opts))
+
+	selectedVersion = "main"
+	assert.Nil(t, versionTrackingField(opts), "versions that are not semver can only be pinned")
+
+	opts, err = parseFlags([]string{"--version-constraint", "~1.4"}, io.Discard)
+	require.NoError(t, err)
+	selectedVersion = "1.4.2"
+	assert.Nil(t, versionTrackingField(opts))
+}
+
+func TestVersionOptions(t *testing.T) {
+	var versions []helm.ChartVersion
+	for i := 40; i > 0; i-- {
+		versions = append(versions, helm.ChartVersion{ChartVersion: fmt.Sprintf("1.%d.0", i)})
+	}
+	versions[0].AppVersion, versions[0].Description = "2.0.0", "Latest"
+
+	options := versionOptions(versions, versionPageSize)
+	require.Len(t, options, versionPageSize+1)
+	assert.Equal(t, "1.40.0 (App: 2.0.0) - Latest", options[0].Key)
+	assert.Equal(t, "1.26.0", options[versionPageSize-1].Value)
+	assert.Equal(t, showMoreVersions, options[versionPageSize].Value)
+	assert.Contains(t, options[versionPageSize].Key, "Show 15 more of 25 older versions")
+
+	options = versionOptions(versions, 3*versionPageSize)
+	require.Len(t, options, 40, "every version fits, nothing more to show")
+	assert.Equal(t, "1.1.0", options[39].Value)
+}
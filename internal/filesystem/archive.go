@@ -0,0 +1,185 @@
+package filesystem
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ArchiveFormat is the format an ArchiveFS is written in.
+type ArchiveFormat string
+
+const (
+	// ArchiveTar writes an uncompressed tar archive.
+	ArchiveTar ArchiveFormat = "tar"
+	// ArchiveTarGz writes a gzip-compressed tar archive.
+	ArchiveTarGz ArchiveFormat = "tar.gz"
+	// ArchiveZip writes a zip archive.
+	ArchiveZip ArchiveFormat = "zip"
+)
+
+// ArchiveFormatFromPath returns the archive format matching the extension of path.
+func ArchiveFormatFromPath(path string) (ArchiveFormat, error) {
+	name := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return ArchiveTarGz, nil
+	case strings.HasSuffix(name, ".tar"):
+		return ArchiveTar, nil
+	case strings.HasSuffix(name, ".zip"):
+		return ArchiveZip, nil
+	default:
+		return "", fmt.Errorf("unsupported archive %s, expected a .tar, .tar.gz, .tgz or .zip file", path)
+	}
+}
+
+// ArchiveFS collects writes in memory and writes them as a single archive on Close,
+// so the archive only ever contains complete generations.
+type ArchiveFS struct {
+	*MemFS
+	w       io.Writer
+	format  ArchiveFormat
+	modTime time.Time
+}
+
+// NewArchiveFS creates an archive filesystem written to w in the given format when closed.
+func NewArchiveFS(w io.Writer, format ArchiveFormat) *ArchiveFS {
+	return &ArchiveFS{MemFS: NewMemFS(), w: w, format: format, modTime: time.Now()}
+}
+
+// Close writes the archive. Entries are sorted and directories precede their contents.
+func (a *ArchiveFS) Close() error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	entries, err := a.entries()
+	if err != nil {
+		return err
+	}
+	switch a.format {
+	case ArchiveTar:
+		return a.writeTar(a.w, entries)
+	case ArchiveTarGz:
+		gz := gzip.NewWriter(a.w)
+		if err := a.writeTar(gz, entries); err != nil {
+			return err
+		}
+		return gz.Close()
+	case ArchiveZip:
+		return a.writeZip(entries)
+	default:
+		return fmt.Errorf("unsupported archive format %q", a.format)
+	}
+}
+
+// archiveEntry is a file or directory of the archive, named with a slash-separated relative path.
+type archiveEntry struct {
+	name string
+	file *memFile
+	dir  bool
+}
+
+func (a *ArchiveFS) entries() ([]archiveEntry, error) {
+	dirs := make(map[string]bool)
+	for dir := range a.dirs {
+		name, err := archiveName(dir)
+		if err != nil {
+			return nil, err
+		}
+		dirs[name] = true
+	}
+	for file := range a.files {
+		for dir := filepath.Dir(file); dir != "." && dir != string(filepath.Separator); dir = filepath.Dir(dir) {
+			name, err := archiveName(dir)
+			if err != nil {
+				return nil, err
+			}
+			dirs[name] = true
+		}
+	}
+	delete(dirs, "")
+
+	entries := make([]archiveEntry, 0, len(dirs)+len(a.files))
+	for dir := range dirs {
+		entries = append(entries, archiveEntry{name: dir + "/", dir: true})
+	}
+	for file, data := range a.files {
+		data := data
+		name, err := archiveName(file)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, archiveEntry{name: name, file: &data})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+	return entries, nil
+}
+
+// archiveName converts a path to a relative, slash-separated archive entry name. Paths leading
+// outside of the archive, through "..", are rejected so that extracting it cannot write elsewhere.
+func archiveName(file string) (string, error) {
+	name := path.Clean(filepath.ToSlash(strings.TrimPrefix(file, filepath.VolumeName(file))))
+	name = strings.TrimLeft(name, "/")
+	if name == "." {
+		return "", nil
+	}
+	if name == ".." || strings.HasPrefix(name, "../") {
+		return "", fmt.Errorf("%s is outside of the archive", file)
+	}
+	return name, nil
+}
+
+func (a *ArchiveFS) writeTar(w io.Writer, entries []archiveEntry) error {
+	tw := tar.NewWriter(w)
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.name, ModTime: a.modTime}
+		if entry.dir {
+			header.Typeflag = tar.TypeDir
+			header.Mode = 0o755
+		} else {
+			header.Typeflag = tar.TypeReg
+			header.Mode = int64(entry.file.perm.Perm())
+			header.Size = int64(len(entry.file.data))
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return fmt.Errorf("failed to write %s to archive: %w", entry.name, err)
+		}
+		if !entry.dir {
+			if _, err := tw.Write(entry.file.data); err != nil {
+				return fmt.Errorf("failed to write %s to archive: %w", entry.name, err)
+			}
+		}
+	}
+	return tw.Close()
+}
+
+func (a *ArchiveFS) writeZip(entries []archiveEntry) error {
+	zw := zip.NewWriter(a.w)
+	for _, entry := range entries {
+		header := &zip.FileHeader{Name: entry.name, Method: zip.Deflate, Modified: a.modTime}
+		if entry.dir {
+			header.Method = zip.Store
+			header.SetMode(os.ModeDir | 0o755)
+		} else {
+			header.SetMode(entry.file.perm.Perm())
+		}
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			return fmt.Errorf("failed to write %s to archive: %w", entry.name, err)
+		}
+		if !entry.dir {
+			if _, err := w.Write(entry.file.data); err != nil {
+				return fmt.Errorf("failed to write %s to archive: %w", entry.name, err)
+			}
+		}
+	}
+	return zw.Close()
+}
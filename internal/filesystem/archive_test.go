@@ -0,0 +1,133 @@
+package filesystem
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchiveFormatFromPath(t *testing.T) {
+	tests := map[string]ArchiveFormat{
+		"app.tar":    ArchiveTar,
+		"app.tar.gz": ArchiveTarGz,
+		"APP.TGZ":    ArchiveTarGz,
+		"app.zip":    ArchiveZip,
+	}
+	for path, expected := range tests {
+		format, err := ArchiveFormatFromPath(path)
+		require.NoError(t, err, path)
+		assert.Equal(t, expected, format, path)
+	}
+
+	_, err := ArchiveFormatFromPath("app.rar")
+	assert.Error(t, err)
+}
+
+// writeArchive generates a small app into an archive of the given format.
+func writeArchive(t *testing.T, format ArchiveFormat) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	fsys := NewArchiveFS(&buf, format)
+	require.NoError(t, fsys.MkdirAll(filepath.Join("app", "dependencies"), 0o755))
+	require.NoError(t, fsys.WriteFile(filepath.Join("app", "release", "values.yaml"), []byte("a: 1\n"), 0o600))
+	require.NoError(t, fsys.WriteFile(filepath.Join("app", "kustomization.yaml"), []byte("b: 2\n"), 0o600))
+	assert.Zero(t, buf.Len(), "nothing is written before Close")
+	require.NoError(t, fsys.Close())
+	return buf.Bytes()
+}
+
+var expectedArchiveEntries = []string{
+	"app/",
+	"app/dependencies/",
+	"app/kustomization.yaml",
+	"app/release/",
+	"app/release/values.yaml",
+}
+
+func TestArchiveFS_Tar(t *testing.T) {
+	for _, format := range []ArchiveFormat{ArchiveTar, ArchiveTarGz} {
+		t.Run(string(format), func(t *testing.T) {
+			var r io.Reader = bytes.NewReader(writeArchive(t, format))
+			if format == ArchiveTarGz {
+				gz, err := gzip.NewReader(r)
+				require.NoError(t, err)
+				r = gz
+			}
+
+			var names []string
+			contents := make(map[string]string)
+			tr := tar.NewReader(r)
+			for {
+				header, err := tr.Next()
+				if err == io.EOF {
+					break
+				}
+				require.NoError(t, err)
+				names = append(names, header.Name)
+				data, err := io.ReadAll(tr)
+				require.NoError(t, err)
+				contents[header.Name] = string(data)
+			}
+
+			assert.Equal(t, expectedArchiveEntries, names)
+			assert.Equal(t, "a: 1\n", contents["app/release/values.yaml"])
+		})
+	}
+}
+
+func TestArchiveFS_Zip(t *testing.T) {
+	data := writeArchive(t, ArchiveZip)
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	var names []string
+	for _, file := range zr.File {
+		names = append(names, file.Name)
+	}
+	assert.Equal(t, expectedArchiveEntries, names)
+
+	rc, err := zr.Open("app/kustomization.yaml")
+	require.NoError(t, err)
+	defer func() { _ = rc.Close() }()
+	content, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, "b: 2\n", string(content))
+}
+
+func TestArchiveName(t *testing.T) {
+	tests := map[string]string{
+		"app/kustomization.yaml":          "app/kustomization.yaml",
+		"./app/release":                   "app/release",
+		"/app/release":                    "app/release",
+		"clusters/../app/release":         "app/release",
+		".":                               "",
+		"/../app/kustomization.yaml":      "app/kustomization.yaml",
+		"app/release/../../kustomization": "kustomization",
+	}
+	for path, expected := range tests {
+		name, err := archiveName(filepath.FromSlash(path))
+		require.NoError(t, err, path)
+		assert.Equal(t, expected, name, path)
+	}
+
+	for _, path := range []string{"..", "../fleet/app", "app/../../fleet"} {
+		_, err := archiveName(filepath.FromSlash(path))
+		assert.Error(t, err, path)
+	}
+}
+
+func TestArchiveFS_RejectsPathsOutsideArchive(t *testing.T) {
+	var buf bytes.Buffer
+	fsys := NewArchiveFS(&buf, ArchiveTar)
+	require.NoError(t, fsys.WriteFile(filepath.Join("..", "fleet", "kustomization.yaml"), []byte("a: 1\n"), 0o600))
+	assert.Error(t, fsys.Close())
+	assert.Zero(t, buf.Len())
+}
@@ -0,0 +1,59 @@
+// Package filesystem provides the pluggable filesystems generated files are written to:
+// the OS filesystem, an in-memory filesystem, tar/zip archives and a staging layer that
+// makes a batch of writes atomic.
+package filesystem
+
+import (
+	"os"
+)
+
+// FS is the set of filesystem operations used to generate an application.
+// Paths use the OS separator, as produced by filepath.Join.
+type FS interface {
+	// MkdirAll creates a directory along with any necessary parents.
+	MkdirAll(path string, perm os.FileMode) error
+	// WriteFile writes data to the named file, creating or truncating it.
+	WriteFile(path string, data []byte, perm os.FileMode) error
+	// ReadFile returns the contents of the named file.
+	ReadFile(path string) ([]byte, error)
+	// Stat returns file information; missing files yield an error satisfying os.IsNotExist.
+	Stat(path string) (os.FileInfo, error)
+	// Remove removes the named file or empty directory.
+	Remove(path string) error
+}
+
+// OS is the FS backed by the operating system filesystem.
+type OS struct{}
+
+// MkdirAll implements FS.
+func (OS) MkdirAll(path string, perm os.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+// WriteFile implements FS.
+func (OS) WriteFile(path string, data []byte, perm os.FileMode) error {
+	return os.WriteFile(path, data, perm)
+}
+
+// ReadFile implements FS.
+func (OS) ReadFile(path string) ([]byte, error) {
+	return os.ReadFile(path) // #nosec G304 -- reading generated files is the purpose of this function
+}
+
+// Stat implements FS.
+func (OS) Stat(path string) (os.FileInfo, error) {
+	return os.Stat(path)
+}
+
+// Remove implements FS.
+func (OS) Remove(path string) error {
+	return os.Remove(path)
+}
+
+// Default returns fsys, or the OS filesystem when fsys is nil.
+func Default(fsys FS) FS {
+	if fsys == nil {
+		return OS{}
+	}
+	return fsys
+}
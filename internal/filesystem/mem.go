@@ -0,0 +1,147 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemFS is an in-memory FS, used for previews and tests.
+type MemFS struct {
+	mu    sync.RWMutex
+	files map[string]memFile
+	dirs  map[string]os.FileMode
+}
+
+type memFile struct {
+	data []byte
+	perm os.FileMode
+}
+
+// NewMemFS creates an empty in-memory filesystem.
+func NewMemFS() *MemFS {
+	return &MemFS{
+		files: make(map[string]memFile),
+		dirs:  make(map[string]os.FileMode),
+	}
+}
+
+// MkdirAll implements FS.
+func (m *MemFS) MkdirAll(path string, perm os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for dir := filepath.Clean(path); dir != "." && dir != string(filepath.Separator); dir = filepath.Dir(dir) {
+		if _, ok := m.files[dir]; ok {
+			return &os.PathError{Op: "mkdir", Path: dir, Err: os.ErrExist}
+		}
+		if _, ok := m.dirs[dir]; !ok {
+			m.dirs[dir] = perm
+		}
+	}
+	return nil
+}
+
+// WriteFile implements FS. Parent directories are created implicitly.
+func (m *MemFS) WriteFile(path string, data []byte, perm os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	path = filepath.Clean(path)
+	if _, ok := m.dirs[path]; ok {
+		return &os.PathError{Op: "write", Path: path, Err: os.ErrExist}
+	}
+	m.files[path] = memFile{data: append([]byte(nil), data...), perm: perm}
+	return nil
+}
+
+// ReadFile implements FS.
+func (m *MemFS) ReadFile(path string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	file, ok := m.files[filepath.Clean(path)]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+	}
+	return append([]byte(nil), file.data...), nil
+}
+
+// Stat implements FS. Directories exist when created explicitly or when they contain a file.
+func (m *MemFS) Stat(path string) (os.FileInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	path = filepath.Clean(path)
+	if file, ok := m.files[path]; ok {
+		return memFileInfo{name: filepath.Base(path), size: int64(len(file.data)), mode: file.perm}, nil
+	}
+	if perm, ok := m.dirs[path]; ok {
+		return memFileInfo{name: filepath.Base(path), mode: os.ModeDir | perm}, nil
+	}
+	prefix := path + string(filepath.Separator)
+	for name := range m.files {
+		if strings.HasPrefix(name, prefix) {
+			return memFileInfo{name: filepath.Base(path), mode: os.ModeDir | 0o755}, nil
+		}
+	}
+	return nil, &os.PathError{Op: "stat", Path: path, Err: os.ErrNotExist}
+}
+
+// Remove implements FS.
+func (m *MemFS) Remove(path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	path = filepath.Clean(path)
+	if _, ok := m.files[path]; ok {
+		delete(m.files, path)
+		return nil
+	}
+	if _, ok := m.dirs[path]; ok {
+		prefix := path + string(filepath.Separator)
+		for name := range m.files {
+			if strings.HasPrefix(name, prefix) {
+				return &os.PathError{Op: "remove", Path: path, Err: os.ErrExist}
+			}
+		}
+		for name := range m.dirs {
+			if strings.HasPrefix(name, prefix) {
+				return &os.PathError{Op: "remove", Path: path, Err: os.ErrExist}
+			}
+		}
+		delete(m.dirs, path)
+		return nil
+	}
+	return &os.PathError{Op: "remove", Path: path, Err: os.ErrNotExist}
+}
+
+// Files returns the paths of all files, sorted.
+func (m *MemFS) Files() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	paths := make([]string, 0, len(m.files))
+	for path := range m.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// memFileInfo implements os.FileInfo for MemFS entries.
+type memFileInfo struct {
+	name string
+	size int64
+	mode os.FileMode
+}
+
+func (i memFileInfo) Name() string       { return i.name }
+func (i memFileInfo) Size() int64        { return i.size }
+func (i memFileInfo) Mode() os.FileMode  { return i.mode }
+func (i memFileInfo) ModTime() time.Time { return time.Time{} }
+func (i memFileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i memFileInfo) Sys() interface{}   { return nil }
@@ -0,0 +1,45 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemFS(t *testing.T) {
+	fsys := NewMemFS()
+
+	require.NoError(t, fsys.MkdirAll(filepath.Join("app", "release"), 0o755))
+	require.NoError(t, fsys.WriteFile(filepath.Join("app", "release", "values.yaml"), []byte("a: 1\n"), 0o600))
+	require.NoError(t, fsys.WriteFile(filepath.Join("app", "dependencies", "repo.yaml"), []byte("b: 2\n"), 0o600))
+
+	data, err := fsys.ReadFile(filepath.Join("app", "release", "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "a: 1\n", string(data))
+
+	info, err := fsys.Stat(filepath.Join("app", "dependencies"))
+	require.NoError(t, err)
+	assert.True(t, info.IsDir(), "directories holding files exist implicitly")
+
+	info, err = fsys.Stat(filepath.Join("app", "release", "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), info.Size())
+	assert.Equal(t, os.FileMode(0o600), info.Mode())
+
+	_, err = fsys.ReadFile("missing.yaml")
+	assert.True(t, os.IsNotExist(err))
+
+	assert.Equal(t, []string{
+		filepath.Join("app", "dependencies", "repo.yaml"),
+		filepath.Join("app", "release", "values.yaml"),
+	}, fsys.Files())
+
+	assert.Error(t, fsys.Remove(filepath.Join("app", "release")), "non-empty directories cannot be removed")
+	require.NoError(t, fsys.Remove(filepath.Join("app", "release", "values.yaml")))
+	require.NoError(t, fsys.Remove(filepath.Join("app", "release")))
+	_, err = fsys.Stat(filepath.Join("app", "release"))
+	assert.True(t, os.IsNotExist(err))
+}
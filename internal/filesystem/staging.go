@@ -0,0 +1,134 @@
+package filesystem
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Staging buffers writes in memory and applies them to a target FS on Commit,
+// so that either every file lands or none do. Reads see staged writes first.
+type Staging struct {
+	target FS
+	staged *MemFS
+}
+
+// NewStaging creates a staging layer on top of target.
+func NewStaging(target FS) *Staging {
+	return &Staging{target: Default(target), staged: NewMemFS()}
+}
+
+// MkdirAll implements FS.
+func (s *Staging) MkdirAll(path string, perm os.FileMode) error {
+	return s.staged.MkdirAll(path, perm)
+}
+
+// WriteFile implements FS.
+func (s *Staging) WriteFile(path string, data []byte, perm os.FileMode) error {
+	return s.staged.WriteFile(path, data, perm)
+}
+
+// ReadFile implements FS.
+func (s *Staging) ReadFile(path string) ([]byte, error) {
+	if data, err := s.staged.ReadFile(path); err == nil {
+		return data, nil
+	}
+	return s.target.ReadFile(path)
+}
+
+// Stat implements FS.
+func (s *Staging) Stat(path string) (os.FileInfo, error) {
+	if info, err := s.staged.Stat(path); err == nil {
+		return info, nil
+	}
+	return s.target.Stat(path)
+}
+
+// Remove is not supported while staging: generation only ever adds files.
+func (s *Staging) Remove(path string) error {
+	return &os.PathError{Op: "remove", Path: path, Err: errors.ErrUnsupported}
+}
+
+// Files returns the staged file paths, sorted.
+func (s *Staging) Files() []string {
+	return s.staged.Files()
+}
+
+// Commit writes the staged directories and files to the target. When a write fails,
+// the files written so far are restored to their previous content (or removed) and
+// the directories created by the commit are removed again.
+func (s *Staging) Commit() error {
+	var createdDirs []string
+	restore := make(map[string][]byte)
+	var written []string
+
+	rollback := func() {
+		for i := len(written) - 1; i >= 0; i-- {
+			path := written[i]
+			if previous, ok := restore[path]; ok {
+				_ = s.target.WriteFile(path, previous, s.staged.files[path].perm)
+			} else {
+				_ = s.target.Remove(path)
+			}
+		}
+		// Deepest directories first; Remove fails harmlessly on non-empty directories
+		sort.Sort(sort.Reverse(sort.StringSlice(createdDirs)))
+		for _, dir := range createdDirs {
+			_ = s.target.Remove(dir)
+		}
+	}
+
+	dirs := make([]string, 0, len(s.staged.dirs))
+	for dir := range s.staged.dirs {
+		dirs = append(dirs, dir)
+	}
+	for _, path := range s.staged.Files() {
+		dirs = append(dirs, filepath.Dir(path))
+	}
+	sort.Strings(dirs)
+
+	for _, dir := range dirs {
+		missing := s.missingDirs(dir)
+		if len(missing) == 0 {
+			continue
+		}
+		perm, ok := s.staged.dirs[dir]
+		if !ok {
+			perm = 0o755
+		}
+		if err := s.target.MkdirAll(dir, perm); err != nil {
+			createdDirs = append(createdDirs, missing...)
+			rollback()
+			return fmt.Errorf("failed to create directory %s: %w", dir, err)
+		}
+		createdDirs = append(createdDirs, missing...)
+	}
+
+	for _, path := range s.staged.Files() {
+		if previous, err := s.target.ReadFile(path); err == nil {
+			restore[path] = previous
+		}
+		file := s.staged.files[path]
+		written = append(written, path)
+		if err := s.target.WriteFile(path, file.data, file.perm); err != nil {
+			rollback()
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// missingDirs returns dir and those of its parents that do not exist in the target yet.
+func (s *Staging) missingDirs(dir string) []string {
+	var missing []string
+	for ; dir != "." && dir != string(filepath.Separator) && dir != filepath.VolumeName(dir); dir = filepath.Dir(dir) {
+		if _, err := s.target.Stat(dir); err == nil {
+			break
+		}
+		missing = append(missing, dir)
+	}
+	return missing
+}
@@ -0,0 +1,71 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaging_Commit(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing.yaml")
+	require.NoError(t, os.WriteFile(existing, []byte("old\n"), 0o600))
+
+	staging := NewStaging(OS{})
+	require.NoError(t, staging.WriteFile(existing, []byte("new\n"), 0o600))
+	require.NoError(t, staging.WriteFile(filepath.Join(dir, "app", "release", "values.yaml"), []byte("a: 1\n"), 0o600))
+
+	// Reads see the staged content, the target is untouched until commit
+	data, err := staging.ReadFile(existing)
+	require.NoError(t, err)
+	assert.Equal(t, "new\n", string(data))
+	_, err = os.Stat(filepath.Join(dir, "app"))
+	assert.True(t, os.IsNotExist(err))
+
+	require.NoError(t, staging.Commit())
+
+	data, err = os.ReadFile(existing)
+	require.NoError(t, err)
+	assert.Equal(t, "new\n", string(data))
+	data, err = os.ReadFile(filepath.Join(dir, "app", "release", "values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "a: 1\n", string(data))
+}
+
+// failingFS fails writes to files with a given name.
+type failingFS struct {
+	*MemFS
+	failOn string
+}
+
+func (f failingFS) WriteFile(path string, data []byte, perm os.FileMode) error {
+	if filepath.Base(path) == f.failOn {
+		return os.ErrPermission
+	}
+	return f.MemFS.WriteFile(path, data, perm)
+}
+
+func TestStaging_CommitRollback(t *testing.T) {
+	target := failingFS{MemFS: NewMemFS(), failOn: "z.yaml"}
+	require.NoError(t, target.WriteFile(filepath.Join("app", "a.yaml"), []byte("old\n"), 0o600))
+
+	staging := NewStaging(target)
+	require.NoError(t, staging.WriteFile(filepath.Join("app", "a.yaml"), []byte("new\n"), 0o600))
+	require.NoError(t, staging.WriteFile(filepath.Join("app", "release", "b.yaml"), []byte("b\n"), 0o600))
+	require.NoError(t, staging.WriteFile(filepath.Join("app", "release", "z.yaml"), []byte("z\n"), 0o600))
+
+	err := staging.Commit()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "z.yaml")
+
+	// Previous content is restored and new files and directories are removed
+	data, err := target.ReadFile(filepath.Join("app", "a.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "old\n", string(data))
+	assert.Equal(t, []string{filepath.Join("app", "a.yaml")}, target.Files())
+	_, err = target.Stat(filepath.Join("app", "release"))
+	assert.True(t, os.IsNotExist(err))
+}
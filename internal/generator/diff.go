@@ -0,0 +1,48 @@
+package generator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/filesystem"
+)
+
+// DiffFluxStructure compares rendered files with the ones in the app directory of fsys and returns
+// a unified diff of the changes generation would make. An empty diff means the app is up to date.
+func DiffFluxStructure(fsys filesystem.FS, files []RenderedFile, appDir string) (string, error) {
+	fsys = filesystem.Default(fsys)
+	var out strings.Builder
+
+	for _, file := range files {
+		fromFile := "a/" + file.Path
+		existing, err := fsys.ReadFile(filepath.Join(appDir, filepath.FromSlash(file.Path)))
+		if err != nil {
+			if !os.IsNotExist(err) {
+				return "", fmt.Errorf("failed to read %s: %w", file.Path, err)
+			}
+			fromFile = "/dev/null"
+		}
+
+		if string(existing) == file.Content {
+			continue
+		}
+
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(string(existing)),
+			B:        difflib.SplitLines(file.Content),
+			FromFile: fromFile,
+			ToFile:   "b/" + file.Path,
+			Context:  3,
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to diff %s: %w", file.Path, err)
+		}
+		out.WriteString(diff)
+	}
+
+	return out.String(), nil
+}
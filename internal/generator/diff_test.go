@@ -0,0 +1,55 @@
+package generator
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/filesystem"
+)
+
+// Test the unified diff against an existing app directory.
+func TestDiffFluxStructure(t *testing.T) {
+	fsys := filesystem.NewMemFS()
+	files := []RenderedFile{
+		{Path: "unchanged.yaml", Content: "a: 1\n"},
+		{Path: "release/changed.yaml", Content: "a: 1\nb: 3\n"},
+		{Path: "new.yaml", Content: "c: 1\n"},
+	}
+	if err := fsys.WriteFile(filepath.Join("app", "unchanged.yaml"), []byte("a: 1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.WriteFile(filepath.Join("app", "release", "changed.yaml"), []byte("a: 1\nb: 2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	diff, err := DiffFluxStructure(fsys, files, "app")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if strings.Contains(diff, "unchanged.yaml") {
+		t.Error("expected unchanged file to be left out of the diff")
+	}
+	for _, want := range []string{
+		"--- a/release/changed.yaml",
+		"+++ b/release/changed.yaml",
+		"-b: 2",
+		"+b: 3",
+		"--- /dev/null",
+		"+++ b/new.yaml",
+		"+c: 1",
+	} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("expected diff to contain %q, got:\n%s", want, diff)
+		}
+	}
+
+	diff, err = DiffFluxStructure(fsys, files[:1], "app")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff != "" {
+		t.Errorf("expected empty diff, got:\n%s", diff)
+	}
+}
@@ -0,0 +1,123 @@
+package generator
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/filesystem"
+	"github.com/EffectiveSloth/flux-app-generator/internal/models"
+)
+
+// FluxKustomizationTemplate is the template of the Flux Kustomization reconciling the app.
+var FluxKustomizationTemplate string
+
+// fluxKustomization is the template data of a Flux Kustomization.
+type fluxKustomization struct {
+	*models.FluxKustomization
+	Name         string
+	Interval     string
+	Path         string               // Slash-separated path of the reconciled directory in the Git repository
+	HealthChecks []models.HealthCheck // Health checks with their namespace resolved
+}
+
+// FluxKustomizationFiles returns the Flux Kustomizations of the app, with paths relative to the
+// clusters directory: <app>.yaml reconciling the app directory, or with environments one
+// <env>/<app>.yaml per environment reconciling its overlay. Those are named <app>-<env>, so that
+// they do not replace each other when the clusters directory is reconciled as a whole. It returns
+// nil when the app has no Flux Kustomization.
+func FluxKustomizationFiles(config *models.AppConfig) ([]RenderedFile, error) {
+	k := config.FluxKustomization
+	if k == nil {
+		return nil, nil
+	}
+	appPath := k.AppPath
+	if appPath == "" {
+		appPath = "./" + config.AppName
+	}
+
+	data := fluxKustomization{
+		FluxKustomization: k,
+		Name:              config.AppName,
+		Interval:          config.Interval,
+		Path:              appPath,
+	}
+	for _, check := range k.HealthChecks {
+		if check.Namespace == "" {
+			// Resources of the app are in its namespace, unless the Kustomization moves them
+			check.Namespace = config.Namespace
+			if k.TargetNamespace != "" {
+				check.Namespace = k.TargetNamespace
+			}
+		}
+		data.HealthChecks = append(data.HealthChecks, check)
+	}
+
+	file := config.AppName + ".yaml"
+	if !config.HasEnvironments() {
+		content, err := renderTemplateString(FluxKustomizationTemplate, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render %s: %w", file, err)
+		}
+		return []RenderedFile{{Path: file, Content: content}}, nil
+	}
+
+	files := make([]RenderedFile, 0, len(config.Environments))
+	for _, env := range config.Environments {
+		data.Name = config.AppName + "-" + env.Name
+		data.Path = path.Join(appPath, OverlayDir(env.Name))
+		if strings.HasPrefix(appPath, "./") {
+			// path.Join drops the leading ./ Flux paths are written with
+			data.Path = "./" + data.Path
+		}
+		envFile := path.Join(env.Name, file)
+		content, err := renderTemplateString(FluxKustomizationTemplate, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render %s: %w", envFile, err)
+		}
+		files = append(files, RenderedFile{Path: envFile, Content: content})
+	}
+	return files, nil
+}
+
+// checkFluxKustomizationOverwrite refuses to overwrite existing Flux Kustomizations of the app,
+// unless opts.Force is set.
+func checkFluxKustomizationOverwrite(config *models.AppConfig, opts Options) error {
+	if config.FluxKustomization == nil || opts.Force {
+		return nil
+	}
+	files, err := FluxKustomizationFiles(config)
+	if err != nil {
+		return err
+	}
+	fsys := filesystem.Default(opts.FS)
+	for _, file := range files {
+		target := filepath.Join(config.FluxKustomization.ClustersDir, filepath.FromSlash(file.Path))
+		if _, err := fsys.Stat(target); err == nil {
+			return fmt.Errorf("flux kustomization %s already exists, use --force to overwrite it", target)
+		} else if !os.IsNotExist(err) {
+			return fmt.Errorf("failed to check flux kustomization %s: %w", target, err)
+		}
+	}
+	return nil
+}
+
+// generateFluxKustomizations writes the Flux Kustomizations of the app into its clusters directory.
+func generateFluxKustomizations(fsys filesystem.FS, config *models.AppConfig) error {
+	files, err := FluxKustomizationFiles(config)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		target := filepath.Join(config.FluxKustomization.ClustersDir, filepath.FromSlash(file.Path))
+		if err := fsys.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", filepath.Dir(target), err)
+		}
+		if err := fsys.WriteFile(target, []byte(file.Content), 0o600); err != nil {
+			return fmt.Errorf("failed to create %s: %w", target, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package generator
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/filesystem"
+	"github.com/EffectiveSloth/flux-app-generator/internal/models"
+)
+
+// setFluxKustomizationTemplate sets a minimal Flux Kustomization template for the duration of a test.
+func setFluxKustomizationTemplate(t *testing.T) {
+	t.Helper()
+	previous := FluxKustomizationTemplate
+	t.Cleanup(func() { FluxKustomizationTemplate = previous })
+	FluxKustomizationTemplate = "name: {{.Name}}\npath: {{.Path}}\n" +
+		"{{range .Dependencies}}dependsOn: {{.Namespace}}/{{.Name}}\n{{end}}" +
+		"{{range .HealthChecks}}healthCheck: {{.Kind}}/{{.Namespace}}/{{.Name}}\n{{end}}"
+}
+
+func newFluxKustomizationConfig() *models.AppConfig {
+	config := newEnvironmentsConfig()
+	config.Environments = nil
+	config.FluxKustomization = &models.FluxKustomization{
+		ClustersDir: filepath.Join("clusters", "production"),
+		DependsOn:   []string{"infrastructure", "flux-system/crds"},
+		HealthChecks: []models.HealthCheck{
+			{APIVersion: "helm.toolkit.fluxcd.io/v2", Kind: "HelmRelease", Name: "test-app"},
+			{APIVersion: "apps/v1", Kind: "Deployment", Name: "db", Namespace: "data"},
+		},
+		AppPath: "./apps/test-app",
+	}
+	return config
+}
+
+func TestFluxKustomizationFiles(t *testing.T) {
+	setFluxKustomizationTemplate(t)
+	config := newFluxKustomizationConfig()
+
+	files, err := FluxKustomizationFiles(config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "name: test-app\npath: ./apps/test-app\n" +
+		"dependsOn: /infrastructure\ndependsOn: flux-system/crds\n" +
+		"healthCheck: HelmRelease/default/test-app\nhealthCheck: Deployment/data/db\n\n"
+	if len(files) != 1 || files[0].Path != "test-app.yaml" || files[0].Content != expected {
+		t.Errorf("unexpected Flux Kustomization %+v", files)
+	}
+
+	// Health checks follow the resources moved to the target namespace
+	config.FluxKustomization.TargetNamespace = "moved"
+	files, err = FluxKustomizationFiles(config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(files[0].Content, "healthCheck: HelmRelease/moved/test-app\n") {
+		t.Errorf("expected the health check in the target namespace, got:\n%s", files[0].Content)
+	}
+
+	// Each environment is reconciled from its overlay, in its own cluster directory
+	config.Environments = []models.Environment{{Name: "staging"}, {Name: "production"}}
+	files, err = FluxKustomizationFiles(config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(files) != 2 || files[1].Path != "production/test-app.yaml" ||
+		!strings.HasPrefix(files[1].Content, "name: test-app-production\npath: ./apps/test-app/overlays/production\n") {
+		t.Errorf("unexpected Flux Kustomizations %+v", files)
+	}
+
+	config.FluxKustomization = nil
+	if files, err := FluxKustomizationFiles(config); err != nil || files != nil {
+		t.Errorf("expected no Flux Kustomization, got %v (%v)", files, err)
+	}
+}
+
+func TestGenerateFluxStructureWithOptions_FluxKustomization(t *testing.T) {
+	setFluxKustomizationTemplate(t)
+	config := newFluxKustomizationConfig()
+	config.FluxKustomization.AppPath = ""
+
+	fsys := filesystem.NewMemFS()
+	if err := GenerateFluxStructureWithOptions(config, Options{OutputDir: "apps", FS: fsys}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	target := filepath.Join("clusters", "production", "test-app.yaml")
+	content, err := fsys.ReadFile(target)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(string(content), "name: test-app\npath: ./test-app\n") {
+		t.Errorf("unexpected Flux Kustomization:\n%s", content)
+	}
+
+	// An existing Flux Kustomization is only overwritten with Force
+	err = GenerateFluxStructureWithOptions(config, Options{OutputDir: "other", FS: fsys})
+	if err == nil || !strings.Contains(err.Error(), target) {
+		t.Errorf("expected the existing Flux Kustomization to be refused, got %v", err)
+	}
+	if err := GenerateFluxStructureWithOptions(config, Options{OutputDir: "other", FS: fsys, Force: true}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
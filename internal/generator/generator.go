@@ -3,135 +3,516 @@ package generator
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"text/template"
 
+	"gopkg.in/yaml.v3"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/filesystem"
 	"github.com/EffectiveSloth/flux-app-generator/internal/kubernetes"
 	"github.com/EffectiveSloth/flux-app-generator/internal/models"
 	"github.com/EffectiveSloth/flux-app-generator/internal/plugins"
+	"github.com/EffectiveSloth/flux-app-generator/internal/values"
 )
 
 // Import embedded templates from main package.
 var (
 	HelmRepositoryTemplate string
+	OCIRepositoryTemplate  string
+	GitRepositoryTemplate  string
 	HelmReleaseTemplate    string
 	HelmValuesTemplate     string
 	KustomizationTemplate  string
+	NamespaceTemplate      string
+
+	// Templates of the overlays of apps generated with environments.
+	OverlayKustomizationTemplate string
+	HelmReleasePatchTemplate     string
+	OCIRepositoryPatchTemplate   string
+)
+
+// Paths of the generated files, relative to the app directory.
+const (
+	helmRepositoryPath = "dependencies/helm-repository.yaml"
+	ociRepositoryPath  = "dependencies/oci-repository.yaml"
+	gitRepositoryPath  = "dependencies/git-repository.yaml"
+	namespacePath      = "dependencies/namespace.yaml"
+	helmReleasePath    = "release/helm-release.yaml"
+	helmValuesPath     = "release/helm-values.yaml"
+	// helmValuesDefaultsPath holds the commented chart defaults of "overrides" values files.
+	// It is not listed in the kustomization and never applied.
+	helmValuesDefaultsPath = "release/helm-values.defaults.yaml"
+	kustomizationPath      = "kustomization.yaml"
 )
 
-func generateFromTemplateString(templateStr, outputPath string, data interface{}) error {
-	tmpl, err := template.New("template").Parse(templateStr)
+// RenderedFile is a generated file held in memory.
+type RenderedFile struct {
+	Path    string // Slash-separated path relative to the app directory
+	Content string
+}
+
+// templateFuncs are the functions of the templates, rendering inline values.
+var templateFuncs = template.FuncMap{
+	"inlineValues":            inlineValues,
+	"inlineEnvironmentValues": inlineEnvironmentValues,
+}
+
+func renderTemplateString(templateStr string, data interface{}) (string, error) {
+	tmpl, err := template.New("template").Funcs(templateFuncs).Parse(templateStr)
 	if err != nil {
-		return fmt.Errorf("failed to parse template: %w", err)
+		return "", fmt.Errorf("failed to parse template: %w", err)
 	}
 
-	file, err := os.Create(outputPath)
-	if err != nil {
-		return fmt.Errorf("failed to create %s: %w", outputPath, err)
+	var content strings.Builder
+	if err := tmpl.Execute(&content, data); err != nil {
+		return "", err
 	}
-	defer func() {
-		if closeErr := file.Close(); closeErr != nil {
-			// Log the error but don't return it as the main operation succeeded
-			fmt.Printf("Warning: failed to close file %s: %v\n", outputPath, closeErr)
-		}
-	}()
 
-	err = tmpl.Execute(file, data)
+	// Ensure file ends with a newline
+	content.WriteString("\n")
+	return content.String(), nil
+}
+
+func generateFromTemplateString(fsys filesystem.FS, templateStr, outputPath string, data interface{}) error {
+	content, err := renderTemplateString(templateStr, data)
 	if err != nil {
 		return err
 	}
+	if err := fsys.WriteFile(outputPath, []byte(content), 0o600); err != nil {
+		return fmt.Errorf("failed to create %s: %w", outputPath, err)
+	}
+	return nil
+}
+
+// sourceTemplate returns the path and template of the Flux source the chart is pulled from.
+func sourceTemplate(config *models.AppConfig) (path, tmpl string) {
+	if config.UsesOCIRepository() {
+		return ociRepositoryPath, OCIRepositoryTemplate
+	}
+	if config.UsesGitRepository() {
+		return gitRepositoryPath, GitRepositoryTemplate
+	}
+	return helmRepositoryPath, HelmRepositoryTemplate
+}
 
-	// Ensure file ends with a newline
-	_, err = file.WriteString("\n")
-	return err
+func generateHelmRepository(fsys filesystem.FS, config *models.AppConfig, appDir string) error {
+	path, tmpl := sourceTemplate(config)
+	return generateFromTemplateString(
+		fsys,
+		tmpl,
+		filepath.Join(appDir, filepath.FromSlash(path)),
+		config,
+	)
 }
 
-func generateHelmRepository(config *models.AppConfig, appDir string) error {
+// generateNamespace writes the Namespace of the app, when it is generated.
+func generateNamespace(fsys filesystem.FS, config *models.AppConfig, appDir string) error {
+	if config.NamespaceManifest == nil {
+		return nil
+	}
 	return generateFromTemplateString(
-		HelmRepositoryTemplate,
-		filepath.Join(appDir, "dependencies", "helm-repository.yaml"),
+		fsys,
+		NamespaceTemplate,
+		filepath.Join(appDir, filepath.FromSlash(namespacePath)),
 		config,
 	)
 }
 
-func generateHelmRelease(config *models.AppConfig, appDir string) error {
+func generateHelmRelease(fsys filesystem.FS, config *models.AppConfig, appDir string) error {
 	return generateFromTemplateString(
+		fsys,
 		HelmReleaseTemplate,
-		filepath.Join(appDir, "release", "helm-release.yaml"),
+		filepath.Join(appDir, filepath.FromSlash(helmReleasePath)),
 		config,
 	)
 }
 
-func generateHelmValues(config *models.AppConfig, appDir string) error {
-	outputPath := filepath.Join(appDir, "release", "helm-values.yaml")
-	if raw, ok := config.Values["__raw_yaml__"]; ok {
-		// Write raw YAML directly, ensuring it ends with a newline
+// RenderHelmValues returns the content of release/helm-values.yaml.
+func RenderHelmValues(config *models.AppConfig) (string, error) {
+	if raw, ok := config.Values[models.RawValuesKey]; ok {
+		// Use raw YAML directly, ensuring it ends with a newline
 		content := raw.(string)
 		if content != "" && content[len(content)-1] != '\n' {
 			content += "\n"
 		}
-		return os.WriteFile(outputPath, []byte(content), 0o600)
+		return content, nil
+	}
+	if len(config.Values) > 0 {
+		// Encode explicit values, e.g. from an app spec file
+		content, err := yaml.Marshal(config.Values)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode helm values: %w", err)
+		}
+		return string(content), nil
 	}
 	// Create an empty file with just a newline
-	return os.WriteFile(outputPath, []byte("\n"), 0o600)
+	return "\n", nil
+}
+
+// inlineValues returns the values of the app indented as spec.values of the HelmRelease, empty
+// when there are none.
+func inlineValues(config *models.AppConfig) (string, error) {
+	content, err := RenderHelmValues(config)
+	if err != nil {
+		return "", err
+	}
+	return indentValues(content), nil
+}
+
+// inlineEnvironmentValues returns the values of an environment indented as spec.values of the
+// HelmRelease patch, empty when there are none.
+func inlineEnvironmentValues(env models.Environment) (string, error) {
+	if len(env.Values) == 0 {
+		return "", nil
+	}
+	content, err := yaml.Marshal(env.Values)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode helm values: %w", err)
+	}
+	return indentValues(string(content)), nil
+}
+
+// indentValues indents a values file under spec.values, keeping its comments. Files without values
+// are empty.
+func indentValues(content string) string {
+	if trimmed := strings.TrimSpace(content); trimmed == "" || trimmed == "{}" {
+		return ""
+	}
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			lines[i] = "    " + line
+		}
+	}
+	return strings.Join(lines, "\n")
 }
 
-func generateKustomization(config *models.AppConfig, appDir string) error {
+// valuesFilePaths returns the values files written into release/: helm-values.yaml, unless the
+// values are inline, and the files of the layered sources.
+func valuesFilePaths(config *models.AppConfig) []string {
+	var paths []string
+	if !config.UsesInlineValues() {
+		paths = append(paths, helmValuesPath)
+	}
+	for _, source := range config.ValuesSources {
+		if source.File != "" {
+			paths = append(paths, path.Join("release", source.File))
+		}
+	}
+	return paths
+}
+
+// renderValuesFiles renders the values files written into release/.
+func renderValuesFiles(config *models.AppConfig) ([]RenderedFile, error) {
+	var files []RenderedFile
+	if !config.UsesInlineValues() {
+		content, err := RenderHelmValues(config)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, RenderedFile{Path: helmValuesPath, Content: content})
+	}
+	for _, source := range config.ValuesSources {
+		if source.File == "" {
+			continue
+		}
+		content := fmt.Sprintf("# Values of the %s %s, layered in the order of valuesFrom in helm-release.yaml\n", source.Name, source.Kind)
+		files = append(files, RenderedFile{Path: path.Join("release", source.File), Content: content})
+	}
+	return files, nil
+}
+
+func generateHelmValues(fsys filesystem.FS, config *models.AppConfig, appDir string) error {
+	files, err := renderValuesFiles(config)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err := fsys.WriteFile(filepath.Join(appDir, filepath.FromSlash(file.Path)), []byte(file.Content), 0o600); err != nil {
+			return fmt.Errorf("failed to create %s: %w", file.Path, err)
+		}
+	}
+	return nil
+}
+
+// ValuesDefaultsReference returns the content of the reference file listing the chart defaults
+// next to an "overrides" values file.
+func ValuesDefaultsReference(chartName, chartVersion, defaults string) string {
+	return values.CommentedReference([]byte(defaults),
+		fmt.Sprintf("Default values of chart %s version %s.", chartName, chartVersion),
+		"Reference only: this file is not applied, set overrides in helm-values.yaml.",
+	)
+}
+
+func generateHelmValuesDefaults(fsys filesystem.FS, config *models.AppConfig, appDir string) error {
+	if config.DefaultValues == "" {
+		return nil
+	}
+	return fsys.WriteFile(filepath.Join(appDir, filepath.FromSlash(helmValuesDefaultsPath)), []byte(config.DefaultValues), 0o600)
+}
+
+func generateKustomization(fsys filesystem.FS, config *models.AppConfig, appDir string) error {
 	return generateFromTemplateString(
+		fsys,
 		KustomizationTemplate,
-		filepath.Join(appDir, "kustomization.yaml"),
+		filepath.Join(appDir, kustomizationPath),
 		config,
 	)
 }
 
-// GenerateFluxStructure is the main entrypoint for generating the Flux structure.
+// renderPluginFiles renders the files of all configured plugins in memory.
+func renderPluginFiles(config *models.AppConfig) ([]RenderedFile, error) {
+	pluginRegistry := plugins.NewRegistry(&kubernetes.MockKubeLister{})
+	var files []RenderedFile
+
+	for _, pluginConfig := range config.Plugins {
+		plugin, exists := pluginRegistry.Get(pluginConfig.PluginName)
+		if !exists {
+			return nil, fmt.Errorf("plugin '%s' not found in registry", pluginConfig.PluginName)
+		}
+
+		// Validate plugin configuration
+		if err := plugin.Validate(pluginConfig.Values); err != nil {
+			return nil, fmt.Errorf("validation failed for plugin '%s': %w", pluginConfig.PluginName, err)
+		}
+
+		paths, err := pluginFilePaths(plugin, pluginConfig, config.Namespace)
+		if err != nil {
+			return nil, err
+		}
+
+		rendered, err := plugin.RenderFiles(pluginConfig.Values, config.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render file for plugin '%s': %w", pluginConfig.PluginName, err)
+		}
+
+		for _, path := range paths {
+			content, ok := rendered[path]
+			if !ok {
+				return nil, fmt.Errorf("plugin '%s' did not render %s", pluginConfig.PluginName, path)
+			}
+			files = append(files, RenderedFile{Path: path, Content: content})
+		}
+	}
+
+	return files, nil
+}
+
+// Options controls where the Flux structure is written.
+type Options struct {
+	// OutputDir is the root directory the app directory is created in, e.g. "apps/staging".
+	// Defaults to the current working directory.
+	OutputDir string
+	// Force allows overwriting the files of an existing app directory.
+	Force bool
+	// FS is the filesystem the app is written to. Defaults to the OS filesystem.
+	FS filesystem.FS
+	// Log receives the progress messages of generation. Defaults to os.Stdout.
+	Log io.Writer
+}
+
+// AppDir returns the directory the application is generated in.
+func (o Options) AppDir(config *models.AppConfig) string {
+	return filepath.Join(o.OutputDir, config.AppName)
+}
+
+// OverwriteError is returned when the app directory already exists and overwriting was not forced.
+type OverwriteError struct {
+	Dir   string
+	Files []string // Files that would be overwritten, relative to Dir
+}
+
+func (e *OverwriteError) Error() string {
+	if len(e.Files) == 0 {
+		return fmt.Sprintf("app directory %s already exists, use --force to generate into it", e.Dir)
+	}
+	return fmt.Sprintf("app directory %s already exists, use --force to overwrite: %s", e.Dir, strings.Join(e.Files, ", "))
+}
+
+// PlannedFiles returns the paths of all files generation writes, relative to the app directory.
+// The files of apps generated with environments are in base/, followed by the overlays.
+func PlannedFiles(config *models.AppConfig) ([]string, error) {
+	sourcePath, _ := sourceTemplate(config)
+	files := []string{filepath.FromSlash(sourcePath)}
+	if config.NamespaceManifest != nil {
+		files = append(files, filepath.FromSlash(namespacePath))
+	}
+	files = append(files, filepath.FromSlash(helmReleasePath))
+	for _, file := range valuesFilePaths(config) {
+		files = append(files, filepath.FromSlash(file))
+	}
+	if config.DefaultValues != "" {
+		files = append(files, filepath.FromSlash(helmValuesDefaultsPath))
+	}
+
+	pluginRegistry := plugins.NewRegistry(&kubernetes.MockKubeLister{})
+	for _, pluginConfig := range config.Plugins {
+		plugin, exists := pluginRegistry.Get(pluginConfig.PluginName)
+		if !exists {
+			return nil, fmt.Errorf("plugin '%s' not found in registry", pluginConfig.PluginName)
+		}
+		paths, err := pluginFilePaths(plugin, pluginConfig, config.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			files = append(files, filepath.FromSlash(path))
+		}
+	}
+
+	files = append(files, kustomizationPath)
+
+	if config.HasEnvironments() {
+		for i := range files {
+			files[i] = filepath.Join(baseDirName, files[i])
+		}
+		for _, env := range config.Environments {
+			for _, file := range overlayPaths(config, env) {
+				files = append(files, filepath.FromSlash(file))
+			}
+		}
+	}
+	return files, nil
+}
+
+// ExistingFiles returns the planned files that already exist in the app directory and would be overwritten.
+func ExistingFiles(config *models.AppConfig, opts Options) ([]string, error) {
+	files, err := PlannedFiles(config)
+	if err != nil {
+		return nil, err
+	}
+
+	appDir := opts.AppDir(config)
+	fsys := filesystem.Default(opts.FS)
+	var existing []string
+	for _, file := range files {
+		if _, err := fsys.Stat(filepath.Join(appDir, file)); err == nil {
+			existing = append(existing, file)
+		}
+	}
+	return existing, nil
+}
+
+// CheckOverwrite returns an *OverwriteError listing the files that would be overwritten
+// when the app directory already exists and opts.Force is not set. Existing Flux Kustomizations
+// of the app are refused too.
+func CheckOverwrite(config *models.AppConfig, opts Options) error {
+	if err := checkFluxKustomizationOverwrite(config, opts); err != nil {
+		return err
+	}
+	appDir := opts.AppDir(config)
+	info, err := filesystem.Default(opts.FS).Stat(appDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to check app directory %s: %w", appDir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("app directory %s exists and is not a directory", appDir)
+	}
+	if opts.Force {
+		return nil
+	}
+
+	existing, err := ExistingFiles(config, opts)
+	if err != nil {
+		return err
+	}
+	return &OverwriteError{Dir: appDir, Files: existing}
+}
+
+// GenerateFluxStructure is the main entrypoint for generating the Flux structure in the current directory.
 func GenerateFluxStructure(config *models.AppConfig) error {
+	return GenerateFluxStructureWithOptions(config, Options{})
+}
+
+// GenerateFluxStructureWithOptions generates the Flux structure under the configured output directory.
+// It refuses to write into an existing app directory unless opts.Force is set. Generation is atomic:
+// files are staged in memory and only written to opts.FS once every file rendered successfully.
+func GenerateFluxStructureWithOptions(config *models.AppConfig, opts Options) error {
+	if config.AppName == "" {
+		return fmt.Errorf("application name is required")
+	}
+
+	if err := CheckOverwrite(config, opts); err != nil {
+		return err
+	}
+
+	appDir := opts.AppDir(config)
+	staging := filesystem.NewStaging(opts.FS)
+	// The app directory holds a base and its overlays when there are environments
+	baseDir := appDir
+	if config.HasEnvironments() {
+		baseDir = filepath.Join(appDir, baseDirName)
+	}
 	// Create app directory
-	appDir := config.AppName
-	if err := os.MkdirAll(appDir, 0o755); err != nil {
+	if err := staging.MkdirAll(appDir, 0o755); err != nil {
 		return fmt.Errorf("failed to create app directory %s: %w", appDir, err)
 	}
-
 	// Create subdirectories
-	dirs := []string{filepath.Join(appDir, "dependencies"), filepath.Join(appDir, "release")}
+	dirs := []string{filepath.Join(baseDir, "dependencies"), filepath.Join(baseDir, "release")}
 	for _, dir := range dirs {
-		if err := os.MkdirAll(dir, 0o755); err != nil {
+		if err := staging.MkdirAll(dir, 0o755); err != nil {
 			return fmt.Errorf("failed to create directory %s: %w", dir, err)
 		}
 	}
 
-	if err := generateHelmRepository(config, appDir); err != nil {
+	if err := generateHelmRepository(staging, config, baseDir); err != nil {
+		return err
+	}
+	if err := generateNamespace(staging, config, baseDir); err != nil {
+		return err
+	}
+	if err := generateHelmRelease(staging, config, baseDir); err != nil {
 		return err
 	}
-	if err := generateHelmRelease(config, appDir); err != nil {
+	if err := generateHelmValues(staging, config, baseDir); err != nil {
 		return err
 	}
-	if err := generateHelmValues(config, appDir); err != nil {
+	if err := generateHelmValuesDefaults(staging, config, baseDir); err != nil {
 		return err
 	}
 
+	log := opts.Log
+	if log == nil {
+		log = os.Stdout
+	}
+
 	// Generate plugin files first
-	pluginFiles, err := generatePluginFiles(config, appDir)
+	pluginFiles, err := generatePluginFiles(staging, config, baseDir, log)
 	if err != nil {
 		return err
 	}
 	config.PluginFiles = pluginFiles
 
 	// Generate kustomization.yaml after plugin files are generated
-	if err := generateKustomization(config, appDir); err != nil {
+	if err := generateKustomization(staging, config, baseDir); err != nil {
+		return err
+	}
+	if err := generateOverlays(staging, config, appDir); err != nil {
+		return err
+	}
+	if err := generateFluxKustomizations(staging, config); err != nil {
 		return err
 	}
 
-	fmt.Printf("\n✅ Generated Flux structure for '%s' in namespace '%s'\n", config.AppName, config.Namespace)
-	fmt.Printf("📁 Files created in directory: %s/\n", appDir)
+	if err := staging.Commit(); err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(log, "\n✅ Generated Flux structure for '%s' in namespace '%s'\n", config.AppName, config.Namespace)
+	_, _ = fmt.Fprintf(log, "📁 Files created in directory: %s/\n", appDir)
 
 	// Print plugin summary if any plugins were generated
 	if len(config.Plugins) > 0 {
-		fmt.Printf("🔌 Generated %d plugin file(s):\n", len(config.Plugins))
+		_, _ = fmt.Fprintf(log, "🔌 Generated %d plugin file(s):\n", len(config.Plugins))
 		for _, pluginConfig := range config.Plugins {
-			fmt.Printf("   - %s\n", pluginConfig.PluginName)
+			_, _ = fmt.Fprintf(log, "   - %s\n", pluginConfig.PluginName)
 		}
 	}
 
@@ -139,7 +520,7 @@ func GenerateFluxStructure(config *models.AppConfig) error {
 }
 
 // generatePluginFiles generates files for all configured plugins and returns their paths.
-func generatePluginFiles(config *models.AppConfig, appDir string) ([]string, error) {
+func generatePluginFiles(fsys filesystem.FS, config *models.AppConfig, appDir string, log io.Writer) ([]string, error) {
 	if len(config.Plugins) == 0 {
 		return nil, nil // No plugins to generate
 	}
@@ -159,54 +540,56 @@ func generatePluginFiles(config *models.AppConfig, appDir string) ([]string, err
 			return nil, fmt.Errorf("validation failed for plugin '%s': %w", pluginConfig.PluginName, err)
 		}
 
-		// Special handling for imageupdate plugin which generates multiple files
-		if pluginConfig.PluginName == "imageupdate" {
-			// Generate the plugin files
-			if err := plugin.GenerateFile(pluginConfig.Values, appDir, config.Namespace); err != nil {
-				return nil, fmt.Errorf("failed to generate file for plugin '%s': %w", pluginConfig.PluginName, err)
-			}
-
-			// Add all three imageupdate files to kustomization
-			imageUpdateFiles := []string{
-				"image-repository.yaml",
-				"image-policy.yaml",
-				"image-update-automation.yaml",
-			}
-			pluginFiles = append(pluginFiles, imageUpdateFiles...)
-
-			fmt.Printf("✅ Generated %s plugin files\n", pluginConfig.PluginName)
-			continue
+		paths, err := pluginFilePaths(plugin, pluginConfig, config.Namespace)
+		if err != nil {
+			return nil, err
 		}
+		pluginFiles = append(pluginFiles, paths...)
 
-		// Regular plugin handling
-		// Get the file path that will be generated
-		templateData := make(map[string]interface{})
-		for k, v := range pluginConfig.Values {
-			templateData[k] = v
+		// Generate the plugin file(s)
+		if err := plugin.GenerateFile(fsys, pluginConfig.Values, appDir, config.Namespace); err != nil {
+			return nil, fmt.Errorf("failed to generate file for plugin '%s': %w", pluginConfig.PluginName, err)
 		}
-		templateData["Namespace"] = config.Namespace
 
-		// Parse the file path template to get the actual path
-		pathTmpl, err := template.New("filepath").Parse(plugin.FilePath())
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse file path template for plugin '%s': %w", pluginConfig.PluginName, err)
+		if len(paths) > 1 {
+			_, _ = fmt.Fprintf(log, "✅ Generated %s plugin files\n", pluginConfig.PluginName)
+		} else {
+			_, _ = fmt.Fprintf(log, "✅ Generated %s plugin file\n", pluginConfig.PluginName)
 		}
+	}
 
-		var pathBuf strings.Builder
-		if err := pathTmpl.Execute(&pathBuf, templateData); err != nil {
-			return nil, fmt.Errorf("failed to execute file path template for plugin '%s': %w", pluginConfig.PluginName, err)
-		}
+	return pluginFiles, nil
+}
 
-		filePath := pathBuf.String()
-		pluginFiles = append(pluginFiles, filePath)
+// pluginFilePaths returns the paths of the files a plugin instance generates, relative to the app directory.
+func pluginFilePaths(plugin plugins.Plugin, pluginConfig plugins.PluginConfig, namespace string) ([]string, error) {
+	// Special handling for imageupdate plugin which generates multiple files
+	if pluginConfig.PluginName == "imageupdate" {
+		// Add all three imageupdate files to kustomization
+		return []string{
+			"image-repository.yaml",
+			"image-policy.yaml",
+			"image-update-automation.yaml",
+		}, nil
+	}
 
-		// Generate the plugin file
-		if err := plugin.GenerateFile(pluginConfig.Values, appDir, config.Namespace); err != nil {
-			return nil, fmt.Errorf("failed to generate file for plugin '%s': %w", pluginConfig.PluginName, err)
-		}
+	// Regular plugin handling
+	templateData := make(map[string]interface{})
+	for k, v := range pluginConfig.Values {
+		templateData[k] = v
+	}
+	templateData["Namespace"] = namespace
 
-		fmt.Printf("✅ Generated %s plugin file\n", pluginConfig.PluginName)
+	// Parse the file path template to get the actual path
+	pathTmpl, err := template.New("filepath").Parse(plugin.FilePath())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse file path template for plugin '%s': %w", pluginConfig.PluginName, err)
 	}
 
-	return pluginFiles, nil
+	var pathBuf strings.Builder
+	if err := pathTmpl.Execute(&pathBuf, templateData); err != nil {
+		return nil, fmt.Errorf("failed to execute file path template for plugin '%s': %w", pluginConfig.PluginName, err)
+	}
+
+	return []string{pathBuf.String()}, nil
 }
@@ -1,10 +1,13 @@
 package generator
 
 import (
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/EffectiveSloth/flux-app-generator/internal/filesystem"
 	"github.com/EffectiveSloth/flux-app-generator/internal/models"
 	"github.com/EffectiveSloth/flux-app-generator/internal/plugins"
 )
@@ -86,7 +89,7 @@ configMapGenerator:
 			tempDir := t.TempDir()
 			outputPath := filepath.Join(tempDir, "test.txt")
 
-			err := generateFromTemplateString(tt.templateStr, outputPath, tt.data)
+			err := generateFromTemplateString(filesystem.OS{}, tt.templateStr, outputPath, tt.data)
 			if tt.expectError && err == nil {
 				t.Errorf("expected error but got none")
 			}
@@ -124,7 +127,7 @@ func TestGenerateHelmRepository(t *testing.T) {
 		t.Fatalf("failed to create dependencies directory: %v", err)
 	}
 
-	err := generateHelmRepository(config, appDir)
+	err := generateHelmRepository(filesystem.OS{}, config, appDir)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
@@ -158,7 +161,7 @@ func TestGenerateHelmRelease(t *testing.T) {
 		t.Fatalf("failed to create release directory: %v", err)
 	}
 
-	err := generateHelmRelease(config, appDir)
+	err := generateHelmRelease(filesystem.OS{}, config, appDir)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
@@ -194,7 +197,7 @@ func TestGenerateHelmValues(t *testing.T) {
 		t.Fatalf("failed to create release directory: %v", err)
 	}
 
-	err := generateHelmValues(config, appDir)
+	err := generateHelmValues(filesystem.OS{}, config, appDir)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
@@ -217,7 +220,7 @@ func TestGenerateKustomization(t *testing.T) {
 		t.Fatalf("failed to create test directory: %v", err)
 	}
 
-	err := generateKustomization(config, appDir)
+	err := generateKustomization(filesystem.OS{}, config, appDir)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
@@ -419,7 +422,7 @@ func TestGeneratePluginFiles(t *testing.T) {
 				t.Fatalf("failed to create app directory: %v", err)
 			}
 
-			pluginFiles, err := generatePluginFiles(tt.config, appDir)
+			pluginFiles, err := generatePluginFiles(filesystem.OS{}, tt.config, appDir, io.Discard)
 
 			if tt.expectError {
 				if err == nil {
@@ -613,7 +616,7 @@ func TestGeneratePluginFiles_MultiplePlugins(t *testing.T) {
 		t.Fatalf("failed to create app directory: %v", err)
 	}
 
-	pluginFiles, err := generatePluginFiles(config, appDir)
+	pluginFiles, err := generatePluginFiles(filesystem.OS{}, config, appDir, io.Discard)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
@@ -651,3 +654,276 @@ func TestGeneratePluginFiles_MultiplePlugins(t *testing.T) {
 		}
 	}
 }
+
+// Test generation into a custom output directory and overwrite protection.
+func TestGenerateFluxStructureWithOptions_OutputDirAndOverwrite(t *testing.T) {
+	config := &models.AppConfig{
+		AppName:      "test-app",
+		Namespace:    "default",
+		HelmRepoName: "test-repo",
+		HelmRepoURL:  "https://example.com/repo",
+		ChartName:    "test-chart",
+		ChartVersion: "1.0.0",
+		Interval:     "5m",
+		Values:       map[string]interface{}{},
+	}
+	opts := Options{OutputDir: filepath.Join(t.TempDir(), "apps", "staging")}
+
+	if err := GenerateFluxStructureWithOptions(config, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	appDir := filepath.Join(opts.OutputDir, "test-app")
+	if opts.AppDir(config) != appDir {
+		t.Errorf("expected app directory %s, got %s", appDir, opts.AppDir(config))
+	}
+	if _, err := os.Stat(filepath.Join(appDir, "kustomization.yaml")); err != nil {
+		t.Errorf("expected kustomization.yaml in output directory: %v", err)
+	}
+
+	// Mark a file to detect whether it gets overwritten
+	valuesPath := filepath.Join(appDir, "release", "helm-values.yaml")
+	if err := os.WriteFile(valuesPath, []byte("edited: true\n"), 0o600); err != nil {
+		t.Fatalf("failed to edit values file: %v", err)
+	}
+
+	err := GenerateFluxStructureWithOptions(config, opts)
+	overwriteErr, ok := err.(*OverwriteError)
+	if !ok {
+		t.Fatalf("expected *OverwriteError, got %v", err)
+	}
+	if overwriteErr.Dir != appDir || len(overwriteErr.Files) != 4 {
+		t.Errorf("unexpected overwrite error: %+v", overwriteErr)
+	}
+	content, _ := os.ReadFile(valuesPath)
+	if string(content) != "edited: true\n" {
+		t.Errorf("expected existing file to be left untouched, got %q", content)
+	}
+
+	opts.Force = true
+	if err := CheckOverwrite(config, opts); err != nil {
+		t.Errorf("expected no overwrite error with force, got %v", err)
+	}
+	if err := GenerateFluxStructureWithOptions(config, opts); err != nil {
+		t.Fatalf("unexpected error with force: %v", err)
+	}
+	content, _ = os.ReadFile(valuesPath)
+	if string(content) == "edited: true\n" {
+		t.Error("expected file to be overwritten with force")
+	}
+}
+
+// Test the list of planned and existing files.
+func TestPlannedAndExistingFiles(t *testing.T) {
+	config := &models.AppConfig{
+		AppName:   "test-app",
+		Namespace: "default",
+		Plugins: []plugins.PluginConfig{
+			{
+				PluginName: "externalsecret",
+				Values: map[string]interface{}{
+					"name":               "test-secret",
+					"secret_store_type":  "ClusterSecretStore",
+					"secret_store_name":  "vault-backend",
+					"secret_key":         "secret/myapp",
+					"target_secret_name": "test-target",
+				},
+			},
+		},
+	}
+
+	files, err := PlannedFiles(config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{
+		filepath.Join("dependencies", "helm-repository.yaml"),
+		filepath.Join("release", "helm-release.yaml"),
+		filepath.Join("release", "helm-values.yaml"),
+		filepath.Join("dependencies", "external-secret-test-target.yaml"),
+		"kustomization.yaml",
+	}
+	if len(files) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, files)
+	}
+	for i := range expected {
+		if files[i] != expected[i] {
+			t.Errorf("expected file %d to be %s, got %s", i, expected[i], files[i])
+		}
+	}
+
+	opts := Options{OutputDir: t.TempDir()}
+	if err := os.MkdirAll(filepath.Join(opts.AppDir(config), "release"), 0o755); err != nil {
+		t.Fatalf("failed to create app directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(opts.AppDir(config), "release", "helm-release.yaml"), []byte("\n"), 0o600); err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+
+	existing, err := ExistingFiles(config, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(existing) != 1 || existing[0] != filepath.Join("release", "helm-release.yaml") {
+		t.Errorf("expected only the release file to exist, got %v", existing)
+	}
+
+	config.Plugins[0].PluginName = "nonexistent"
+	if _, err := PlannedFiles(config); err == nil {
+		t.Error("expected error for unknown plugin")
+	}
+}
+
+// failingFS fails writes to one file to simulate a partially failing generation.
+type failingFS struct {
+	*filesystem.MemFS
+	failOn string
+}
+
+func (f failingFS) WriteFile(path string, data []byte, perm os.FileMode) error {
+	if filepath.Base(path) == f.failOn {
+		return os.ErrPermission
+	}
+	return f.MemFS.WriteFile(path, data, perm)
+}
+
+// Test generation into an in-memory filesystem, which either writes every file or none.
+func TestGenerateFluxStructureWithOptions_FS(t *testing.T) {
+	config := &models.AppConfig{
+		AppName:      "test-app",
+		Namespace:    "default",
+		HelmRepoName: "test-repo",
+		HelmRepoURL:  "https://example.com/repo",
+		ChartName:    "test-chart",
+		ChartVersion: "1.0.0",
+		Interval:     "5m",
+		Values:       map[string]interface{}{},
+	}
+
+	fsys := filesystem.NewMemFS()
+	if err := GenerateFluxStructureWithOptions(config, Options{OutputDir: "apps", FS: fsys}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{
+		filepath.Join("apps", "test-app", "dependencies", "helm-repository.yaml"),
+		filepath.Join("apps", "test-app", "kustomization.yaml"),
+		filepath.Join("apps", "test-app", "release", "helm-release.yaml"),
+		filepath.Join("apps", "test-app", "release", "helm-values.yaml"),
+	}
+	files := fsys.Files()
+	if len(files) != len(expected) {
+		t.Fatalf("expected files %v, got %v", expected, files)
+	}
+	for i := range expected {
+		if files[i] != expected[i] {
+			t.Errorf("expected file %s, got %s", expected[i], files[i])
+		}
+	}
+
+	failing := failingFS{MemFS: filesystem.NewMemFS(), failOn: "kustomization.yaml"}
+	if err := GenerateFluxStructureWithOptions(config, Options{OutputDir: "apps", FS: failing}); err == nil {
+		t.Fatal("expected error when a write fails")
+	}
+	if files := failing.Files(); len(files) != 0 {
+		t.Errorf("expected no files after a failed generation, got %v", files)
+	}
+	if _, err := failing.Stat(filepath.Join("apps", "test-app")); !os.IsNotExist(err) {
+		t.Errorf("expected app directory to be removed after a failed generation, got %v", err)
+	}
+}
+
+// Test that the commented defaults of an overrides values file are generated but not listed in the kustomization.
+func TestGenerateFluxStructureWithOptions_ValuesDefaults(t *testing.T) {
+	config := &models.AppConfig{
+		AppName:       "test-app",
+		Namespace:     "default",
+		HelmRepoName:  "test-repo",
+		HelmRepoURL:   "https://example.com/repo",
+		ChartName:     "test-chart",
+		ChartVersion:  "1.0.0",
+		Interval:      "5m",
+		ValuesPrefill: models.ValuesPrefillOverrides,
+		Values:        map[string]interface{}{models.RawValuesKey: "replicaCount: 1\n"},
+		DefaultValues: "# replicaCount: 1\n# debug: false\n",
+	}
+
+	planned, err := PlannedFiles(config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if planned[3] != filepath.Join("release", "helm-values.defaults.yaml") {
+		t.Errorf("expected the defaults file to be planned, got %v", planned)
+	}
+
+	fsys := filesystem.NewMemFS()
+	if err := GenerateFluxStructureWithOptions(config, Options{OutputDir: "apps", FS: fsys}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defaults, err := fsys.ReadFile(filepath.Join("apps", "test-app", "release", "helm-values.defaults.yaml"))
+	if err != nil {
+		t.Fatalf("expected the defaults file to be written: %v", err)
+	}
+	if string(defaults) != config.DefaultValues {
+		t.Errorf("expected defaults %q, got %q", config.DefaultValues, defaults)
+	}
+	kustomization, err := fsys.ReadFile(filepath.Join("apps", "test-app", "kustomization.yaml"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(string(kustomization), "helm-values.defaults.yaml") {
+		t.Errorf("expected the defaults file not to be listed in the kustomization:\n%s", kustomization)
+	}
+
+	// Without defaults, such as when the chart could not be downloaded, no defaults file is written
+	config.DefaultValues = ""
+	if planned, err = PlannedFiles(config); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, file := range planned {
+		if file == filepath.Join("release", "helm-values.defaults.yaml") {
+			t.Errorf("expected the defaults file not to be planned without defaults, got %v", planned)
+		}
+	}
+}
+
+func TestPlannedFiles_ValuesSources(t *testing.T) {
+	config := &models.AppConfig{
+		AppName:    "test-app",
+		Namespace:  "default",
+		ValuesKind: models.ValuesKindInline,
+		ValuesSources: []models.ValuesSource{
+			{Kind: models.ValuesKindSecret, Name: "test-app-secrets", File: "secrets.yaml"},
+			{Kind: models.ValuesKindConfigMap, Name: "shared-values"},
+		},
+	}
+
+	// Inline values have no values file, only the generated sources do
+	files, err := PlannedFiles(config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{
+		filepath.Join("dependencies", "helm-repository.yaml"),
+		filepath.Join("release", "helm-release.yaml"),
+		filepath.Join("release", "secrets.yaml"),
+		"kustomization.yaml",
+	}
+	if strings.Join(files, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected %v, got %v", expected, files)
+	}
+
+	fsys := filesystem.NewMemFS()
+	if err := generateHelmValues(fsys, config, "test-app"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	content, err := fsys.ReadFile(filepath.Join("test-app", "release", "secrets.yaml"))
+	if err != nil {
+		t.Fatalf("expected the values file of the source: %v", err)
+	}
+	if !strings.HasPrefix(string(content), "# Values of the test-app-secrets Secret") {
+		t.Errorf("unexpected values file %q", content)
+	}
+	if _, err := fsys.Stat(filepath.Join("test-app", "release", "helm-values.yaml")); err == nil {
+		t.Error("expected no helm-values.yaml with inline values")
+	}
+}
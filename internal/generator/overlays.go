@@ -0,0 +1,117 @@
+package generator
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/filesystem"
+	"github.com/EffectiveSloth/flux-app-generator/internal/models"
+)
+
+// Layout of apps generated with environments: the app files in base/, patched by one Kustomize
+// overlay per environment in overlays/<env>/.
+const (
+	baseDirName            = "base"
+	overlaysDirName        = "overlays"
+	helmReleasePatchFile   = "helm-release-patch.yaml"
+	ociRepositoryPatchFile = "oci-repository-patch.yaml"
+	overlayValuesFile      = "helm-values.yaml"
+)
+
+// overlay is the template data of the files of an environment overlay.
+type overlay struct {
+	*models.AppConfig
+	Environment models.Environment
+}
+
+// BaseDir returns the directory holding the HelmRelease and the values of the app in appDir: its
+// base/ directory for apps generated with environments, appDir itself otherwise.
+func BaseDir(fsys filesystem.FS, appDir string) string {
+	base := filepath.Join(appDir, baseDirName)
+	if _, err := filesystem.Default(fsys).Stat(filepath.Join(base, kustomizationPath)); err == nil {
+		return base
+	}
+	return appDir
+}
+
+// OverlayDir returns the directory of the overlay of an environment, relative to the app directory.
+func OverlayDir(env string) string {
+	return path.Join(overlaysDirName, env)
+}
+
+// overlayPaths returns the slash-separated paths of the files of an environment overlay, relative
+// to the app directory.
+func overlayPaths(config *models.AppConfig, env models.Environment) []string {
+	dir := OverlayDir(env.Name)
+	paths := []string{path.Join(dir, helmReleasePatchFile)}
+	if config.UsesOCIRepository() && env.ChartVersion != "" {
+		paths = append(paths, path.Join(dir, ociRepositoryPatchFile))
+	}
+	// Inline values of the environment are written into the patch
+	if !config.UsesInlineValues() {
+		paths = append(paths, path.Join(dir, overlayValuesFile))
+	}
+	return append(paths, path.Join(dir, kustomizationPath))
+}
+
+// renderOverlays renders the files of every environment overlay, relative to the app directory.
+func renderOverlays(config *models.AppConfig) ([]RenderedFile, error) {
+	var files []RenderedFile
+	for _, env := range config.Environments {
+		data := overlay{AppConfig: config, Environment: env}
+		for _, file := range overlayPaths(config, env) {
+			var content string
+			var err error
+			switch path.Base(file) {
+			case helmReleasePatchFile:
+				content, err = renderTemplateString(HelmReleasePatchTemplate, data)
+			case ociRepositoryPatchFile:
+				content, err = renderTemplateString(OCIRepositoryPatchTemplate, data)
+			case overlayValuesFile:
+				content, err = renderOverlayValues(env)
+			default:
+				content, err = renderTemplateString(OverlayKustomizationTemplate, data)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to render %s: %w", file, err)
+			}
+			files = append(files, RenderedFile{Path: file, Content: content})
+		}
+	}
+	return files, nil
+}
+
+// renderOverlayValues returns the values file of an environment, merged over the base values by
+// the HelmRelease.
+func renderOverlayValues(env models.Environment) (string, error) {
+	header := fmt.Sprintf("# Values of the %s environment, merged over the values of the base\n", env.Name)
+	if len(env.Values) == 0 {
+		return header, nil
+	}
+	content, err := yaml.Marshal(env.Values)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode helm values: %w", err)
+	}
+	return header + string(content), nil
+}
+
+// generateOverlays writes the overlays of the environments of the app.
+func generateOverlays(fsys filesystem.FS, config *models.AppConfig, appDir string) error {
+	overlays, err := renderOverlays(config)
+	if err != nil {
+		return err
+	}
+	for _, file := range overlays {
+		target := filepath.Join(appDir, filepath.FromSlash(file.Path))
+		if err := fsys.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", filepath.Dir(target), err)
+		}
+		if err := fsys.WriteFile(target, []byte(file.Content), 0o600); err != nil {
+			return fmt.Errorf("failed to create %s: %w", target, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package generator
+
+import (
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/filesystem"
+	"github.com/EffectiveSloth/flux-app-generator/internal/models"
+)
+
+// setOverlayTemplates sets minimal overlay templates for the duration of a test.
+func setOverlayTemplates(t *testing.T) {
+	t.Helper()
+	kustomization, release, oci := OverlayKustomizationTemplate, HelmReleasePatchTemplate, OCIRepositoryPatchTemplate
+	t.Cleanup(func() {
+		OverlayKustomizationTemplate, HelmReleasePatchTemplate, OCIRepositoryPatchTemplate = kustomization, release, oci
+	})
+	OverlayKustomizationTemplate = "resources:\n  - ../../base\nname: {{.AppName}}-{{.Environment.Name}}-values"
+	HelmReleasePatchTemplate = "name: {{.AppName}}\nversion: '{{.Environment.ChartVersion}}'\ninterval: {{.Environment.Interval}}"
+	OCIRepositoryPatchTemplate = "tag: '{{.Environment.OCIChartTag}}'"
+}
+
+func newEnvironmentsConfig() *models.AppConfig {
+	return &models.AppConfig{
+		AppName:      "test-app",
+		Namespace:    "default",
+		HelmRepoName: "test-repo",
+		HelmRepoURL:  "https://example.com/repo",
+		ChartName:    "test-chart",
+		ChartVersion: "1.0.0",
+		Interval:     "5m",
+		Values:       map[string]interface{}{},
+		Environments: []models.Environment{
+			{Name: "staging"},
+			{Name: "production", ChartVersion: "0.9.0", Interval: "30m", Values: map[string]interface{}{"replicaCount": 3}},
+		},
+	}
+}
+
+func TestPlannedFiles_Environments(t *testing.T) {
+	config := newEnvironmentsConfig()
+
+	files, err := PlannedFiles(config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{
+		filepath.Join("base", "dependencies", "helm-repository.yaml"),
+		filepath.Join("base", "release", "helm-release.yaml"),
+		filepath.Join("base", "release", "helm-values.yaml"),
+		filepath.Join("base", "kustomization.yaml"),
+		filepath.Join("overlays", "staging", "helm-release-patch.yaml"),
+		filepath.Join("overlays", "staging", "helm-values.yaml"),
+		filepath.Join("overlays", "staging", "kustomization.yaml"),
+		filepath.Join("overlays", "production", "helm-release-patch.yaml"),
+		filepath.Join("overlays", "production", "helm-values.yaml"),
+		filepath.Join("overlays", "production", "kustomization.yaml"),
+	}
+	if strings.Join(files, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected %v, got %v", expected, files)
+	}
+
+	// The OCIRepository is patched with the chart version of the environment
+	config.SourceKind = models.SourceKindOCIRepository
+	files, err = PlannedFiles(config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(strings.Join(files, "\n"), filepath.Join("overlays", "production", "oci-repository-patch.yaml")) ||
+		strings.Contains(strings.Join(files, "\n"), filepath.Join("overlays", "staging", "oci-repository-patch.yaml")) {
+		t.Errorf("expected an OCIRepository patch for production only, got %v", files)
+	}
+}
+
+func TestGenerateFluxStructureWithOptions_Environments(t *testing.T) {
+	setOverlayTemplates(t)
+	config := newEnvironmentsConfig()
+
+	fsys := filesystem.NewMemFS()
+	if err := GenerateFluxStructureWithOptions(config, Options{OutputDir: "apps", FS: fsys}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	planned, err := PlannedFiles(config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := fsys.Files(); len(got) != len(planned) {
+		t.Fatalf("expected the %d planned files to be written, got %v", len(planned), got)
+	}
+
+	patch, err := fsys.ReadFile(filepath.Join("apps", "test-app", "overlays", "production", "helm-release-patch.yaml"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(patch) != "name: test-app\nversion: '0.9.0'\ninterval: 30m\n" {
+		t.Errorf("unexpected patch:\n%s", patch)
+	}
+	values, err := fsys.ReadFile(filepath.Join("apps", "test-app", "overlays", "production", "helm-values.yaml"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(values) != "# Values of the production environment, merged over the values of the base\nreplicaCount: 3\n" {
+		t.Errorf("unexpected values:\n%s", values)
+	}
+	values, err = fsys.ReadFile(filepath.Join("apps", "test-app", "overlays", "staging", "helm-values.yaml"))
+	if err != nil || strings.Count(string(values), "\n") != 1 {
+		t.Errorf("expected only a header in the staging values, got %q (%v)", values, err)
+	}
+
+	// Upgrades and validation read the base of the app
+	if dir := BaseDir(fsys, filepath.Join("apps", "test-app")); dir != filepath.Join("apps", "test-app", "base") {
+		t.Errorf("expected the base directory, got %s", dir)
+	}
+	if dir := BaseDir(fsys, filepath.Join("apps", "other")); dir != filepath.Join("apps", "other") {
+		t.Errorf("expected the app directory itself, got %s", dir)
+	}
+}
+
+func TestGenerateFluxStructureWithOptions_EnvironmentsOCI(t *testing.T) {
+	setOverlayTemplates(t)
+	config := newEnvironmentsConfig()
+	config.SourceKind = models.SourceKindOCIRepository
+	config.HelmRepoURL = "oci://ghcr.io/acme/charts"
+	config.Environments[1].ChartVersion = "1.0.0+build.1"
+
+	fsys := filesystem.NewMemFS()
+	if err := GenerateFluxStructureWithOptions(config, Options{FS: fsys, Log: io.Discard}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	read := func(name string) string {
+		content, _ := fsys.ReadFile(filepath.Join("test-app", filepath.FromSlash(name)))
+		return string(content)
+	}
+	if _, err := fsys.Stat(filepath.Join("test-app", "base", "release", "helm-release.yaml")); err != nil {
+		t.Errorf("expected the release in base/: %v", err)
+	}
+	if patch := read("overlays/production/oci-repository-patch.yaml"); patch != "tag: '1.0.0_build.1'\n" {
+		t.Errorf("unexpected OCIRepository patch %q", patch)
+	}
+	if kustomization := read("overlays/staging/kustomization.yaml"); !strings.Contains(kustomization, "name: test-app-staging-values") {
+		t.Errorf("unexpected overlay kustomization:\n%s", kustomization)
+	}
+}
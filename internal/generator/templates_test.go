@@ -0,0 +1,446 @@
+package generator
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/filesystem"
+	"github.com/EffectiveSloth/flux-app-generator/internal/models"
+)
+
+// useTemplates sets the templates of the generator to the ones embedded in the binary, for the
+// duration of a test.
+func useTemplates(t *testing.T) {
+	t.Helper()
+	templates := map[string]*string{
+		"helm-repository.yaml.tmpl":       &HelmRepositoryTemplate,
+		"oci-repository.yaml.tmpl":        &OCIRepositoryTemplate,
+		"git-repository.yaml.tmpl":        &GitRepositoryTemplate,
+		"helm-release.yaml.tmpl":          &HelmReleaseTemplate,
+		"kustomization.yaml.tmpl":         &KustomizationTemplate,
+		"namespace.yaml.tmpl":             &NamespaceTemplate,
+		"overlay-kustomization.yaml.tmpl": &OverlayKustomizationTemplate,
+		"helm-release-patch.yaml.tmpl":    &HelmReleasePatchTemplate,
+		"oci-repository-patch.yaml.tmpl":  &OCIRepositoryPatchTemplate,
+		"flux-kustomization.yaml.tmpl":    &FluxKustomizationTemplate,
+	}
+	for name, target := range templates {
+		content, err := os.ReadFile(filepath.Join("..", "..", "cmd", "flux-app-generator", "templates", name))
+		require.NoError(t, err)
+		previous := *target
+		t.Cleanup(func() { *target = previous })
+		*target = string(content)
+	}
+}
+
+// newPodinfoConfig returns the podinfo app the template tests start from.
+func newPodinfoConfig() *models.AppConfig {
+	return &models.AppConfig{
+		AppName:      "podinfo",
+		Namespace:    "apps",
+		HelmRepoName: "podinfo",
+		HelmRepoURL:  "https://stefanprodan.github.io/podinfo",
+		ChartName:    "podinfo",
+		ChartVersion: "6.9.0",
+		Interval:     "5m",
+		Values:       map[string]interface{}{},
+	}
+}
+
+// renderFiles generates the app in memory and returns its files by path, relative to the app
+// directory.
+func renderFiles(t *testing.T, config *models.AppConfig) map[string]string {
+	t.Helper()
+	fsys := filesystem.NewMemFS()
+	require.NoError(t, GenerateFluxStructureWithOptions(config, Options{FS: fsys, Log: io.Discard}))
+	files := make(map[string]string)
+	for _, name := range fsys.Files() {
+		rel, err := filepath.Rel(config.AppName, name)
+		require.NoError(t, err)
+		content, err := fsys.ReadFile(name)
+		require.NoError(t, err)
+		files[filepath.ToSlash(rel)] = string(content)
+	}
+	return files
+}
+
+func TestTemplates_Sources(t *testing.T) {
+	useTemplates(t)
+
+	newConfig := func(sourceKind string) *models.AppConfig {
+		config := newPodinfoConfig()
+		config.HelmRepoURL, config.SourceKind = "oci://ghcr.io/stefanprodan/charts", sourceKind
+		return config
+	}
+
+	// HelmRepository of type oci
+	files := renderFiles(t, newConfig(""))
+	assert.Contains(t, files["dependencies/helm-repository.yaml"], "  type: oci\n  url: oci://ghcr.io/stefanprodan/charts\n")
+	assert.Contains(t, files["release/helm-release.yaml"], "        kind: HelmRepository\n")
+	assert.Contains(t, files["kustomization.yaml"], "  - dependencies/helm-repository.yaml\n")
+
+	// OCIRepository referenced through chartRef
+	files = renderFiles(t, newConfig(models.SourceKindOCIRepository))
+	assert.NotContains(t, files, "dependencies/helm-repository.yaml")
+	assert.Contains(t, files["dependencies/oci-repository.yaml"], "kind: OCIRepository\n")
+	assert.Contains(t, files["dependencies/oci-repository.yaml"], "  url: oci://ghcr.io/stefanprodan/charts/podinfo\n  ref:\n    tag: '6.9.0'\n")
+	assert.Contains(t, files["release/helm-release.yaml"], "  chartRef:\n    kind: OCIRepository\n    name: podinfo\n  valuesFrom:\n")
+	assert.NotContains(t, files["release/helm-release.yaml"], "sourceRef")
+	assert.Contains(t, files["kustomization.yaml"], "  - dependencies/oci-repository.yaml\n")
+
+	// Tracked version constraints are written instead of the pinned version
+	config := newConfig(models.SourceKindOCIRepository)
+	config.VersionConstraint, config.TrackConstraint = ">=6.9 <7", true
+	files = renderFiles(t, config)
+	assert.Contains(t, files["dependencies/oci-repository.yaml"], "  ref:\n    semver: '>=6.9 <7'\n")
+	config.SourceKind = ""
+	files = renderFiles(t, config)
+	assert.Contains(t, files["release/helm-release.yaml"], "      version: '>=6.9 <7'\n")
+
+	// Secrets of private repositories are referenced by both sources
+	config = newConfig(models.SourceKindOCIRepository)
+	config.RepoSecretRef = "ghcr-auth"
+	config.RepoCertSecretRef = "ghcr-tls"
+	files = renderFiles(t, config)
+	assert.Contains(t, files["dependencies/oci-repository.yaml"], "    operation: copy\n  secretRef:\n    name: ghcr-auth\n  certSecretRef:\n    name: ghcr-tls\n")
+	config.SourceKind = ""
+	files = renderFiles(t, config)
+	assert.Contains(t, files["dependencies/helm-repository.yaml"], "  secretRef:\n    name: ghcr-auth\n  certSecretRef:\n    name: ghcr-tls\n")
+
+	// Cosign verification is written into the source pulling the OCI chart
+	config = newConfig(models.SourceKindOCIRepository)
+	config.CosignVerify = true
+	config.CosignOIDCIssuer, config.CosignOIDCSubject = "^https://token.actions.githubusercontent.com$", "^https://github.com/stefanprodan/podinfo.*$"
+	files = renderFiles(t, config)
+	assert.Contains(t, files["dependencies/oci-repository.yaml"], "    operation: copy\n  verify:\n    provider: cosign\n    matchOIDCIdentity:\n"+
+		"      - issuer: '^https://token.actions.githubusercontent.com$'\n        subject: '^https://github.com/stefanprodan/podinfo.*$'\n")
+	config = newConfig("")
+	config.CosignVerify, config.CosignSecretRef = true, "cosign-pub"
+	files = renderFiles(t, config)
+	assert.Contains(t, files["release/helm-release.yaml"], "      interval: 5m\n      verify:\n        provider: cosign\n        secretRef:\n          name: cosign-pub\n  valuesFrom:\n")
+	config.CosignVerify, config.CosignSecretRef = false, ""
+	files = renderFiles(t, config)
+	assert.NotContains(t, files["release/helm-release.yaml"], "verify:")
+
+	// Charts of a Git repository are referenced by path through a GitRepository
+	config = newConfig(models.SourceKindGitRepository)
+	config.HelmRepoName, config.HelmRepoURL = "fleet", ""
+	config.GitURL, config.GitBranch, config.ChartPath = "ssh://git@github.com/acme/fleet.git", "main", "./charts/podinfo"
+	config.RepoSecretRef = "fleet-auth"
+	files = renderFiles(t, config)
+	assert.NotContains(t, files, "dependencies/helm-repository.yaml")
+	assert.Contains(t, files["dependencies/git-repository.yaml"], "kind: GitRepository\n")
+	assert.Contains(t, files["dependencies/git-repository.yaml"], "  url: ssh://git@github.com/acme/fleet.git\n  ref:\n    branch: main\n  secretRef:\n    name: fleet-auth\n")
+	assert.Contains(t, files["release/helm-release.yaml"], "      chart: ./charts/podinfo\n      sourceRef:\n        kind: GitRepository\n        name: fleet\n")
+	assert.NotContains(t, files["release/helm-release.yaml"], "version:")
+	assert.Contains(t, files["kustomization.yaml"], "  - dependencies/git-repository.yaml\n")
+
+	// Plain HTTP repositories are unchanged
+	config = newConfig("")
+	config.HelmRepoURL = "https://stefanprodan.github.io/podinfo"
+	files = renderFiles(t, config)
+	assert.NotContains(t, files["dependencies/helm-repository.yaml"], "type:")
+	assert.NotContains(t, files["dependencies/helm-repository.yaml"], "secretRef")
+}
+
+func TestTemplates_Environments(t *testing.T) {
+	useTemplates(t)
+
+	config := newPodinfoConfig()
+	config.Environments = []models.Environment{
+		{Name: "staging"},
+		{Name: "production", ChartVersion: "6.8.0", Interval: "30m"},
+	}
+
+	// The base is the app itself, each overlay patches the HelmRelease and adds its values
+	files := renderFiles(t, config)
+	assert.Contains(t, files["base/kustomization.yaml"], "  - dependencies/helm-repository.yaml\n")
+	assert.Contains(t, files["base/release/helm-release.yaml"], "      version: '6.9.0'\n")
+	assert.Equal(t, `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+
+resources:
+  - ../../base
+
+patches:
+  - path: helm-release-patch.yaml
+
+configMapGenerator:
+  - name: podinfo-staging-values
+    files:
+      - values.yaml=helm-values.yaml
+    options:
+      disableNameSuffixHash: true
+
+`, files["overlays/staging/kustomization.yaml"])
+	assert.Equal(t, `apiVersion: helm.toolkit.fluxcd.io/v2
+kind: HelmRelease
+metadata:
+  name: podinfo
+  namespace: apps
+spec:
+  valuesFrom:
+    - kind: ConfigMap
+      name: podinfo-values
+      valuesKey: values.yaml
+    - kind: ConfigMap
+      name: podinfo-staging-values
+      valuesKey: values.yaml
+
+`, files["overlays/staging/helm-release-patch.yaml"])
+	assert.Contains(t, files["overlays/production/helm-release-patch.yaml"], "spec:\n  interval: 30m\n  chart:\n    spec:\n      version: '6.8.0'\n  valuesFrom:\n")
+
+	// OCIRepository sources are patched with the tag of the environment, replacing a tracked constraint
+	config.HelmRepoURL, config.SourceKind = "oci://ghcr.io/stefanprodan/charts", models.SourceKindOCIRepository
+	config.VersionConstraint, config.TrackConstraint = ">=6.8 <7", true
+	files = renderFiles(t, config)
+	assert.NotContains(t, files["overlays/production/helm-release-patch.yaml"], "version:")
+	assert.Contains(t, files["overlays/production/kustomization.yaml"], "  - path: helm-release-patch.yaml\n  - path: oci-repository-patch.yaml\n")
+	assert.Contains(t, files["overlays/production/oci-repository-patch.yaml"], "kind: OCIRepository\nmetadata:\n  name: podinfo\n  namespace: apps\nspec:\n  ref:\n    semver: null\n    tag: '6.8.0'\n")
+	assert.NotContains(t, files, "overlays/staging/oci-repository-patch.yaml")
+}
+
+func TestTemplates_Release(t *testing.T) {
+	useTemplates(t)
+
+	config := newPodinfoConfig()
+
+	// Options are only written when set
+	config.Release = &models.ReleaseOptions{}
+	assert.Contains(t, renderFiles(t, config)["release/helm-release.yaml"], "        name: podinfo\n      interval: 5m\n  valuesFrom:\n")
+
+	maxHistory, remediate := 0, false
+	config.Release = &models.ReleaseOptions{
+		ReleaseName:          "web",
+		TargetNamespace:      "web",
+		ServiceAccountName:   "deployer",
+		Timeout:              "10m",
+		MaxHistory:           &maxHistory,
+		DependsOn:            []string{"cert-manager", "infra/ingress-nginx"},
+		InstallRetries:       -1,
+		InstallCRDs:          models.CRDPolicyCreateReplace,
+		UpgradeRetries:       3,
+		RemediateLastFailure: &remediate,
+		Test:                 true,
+		DriftDetection:       models.DriftDetectionEnabled,
+	}
+	assert.Contains(t, renderFiles(t, config)["release/helm-release.yaml"], `      interval: 5m
+  releaseName: web
+  targetNamespace: web
+  serviceAccountName: deployer
+  timeout: 10m
+  maxHistory: 0
+  dependsOn:
+    - name: cert-manager
+    - name: ingress-nginx
+      namespace: infra
+  install:
+    crds: CreateReplace
+    remediation:
+      retries: -1
+  upgrade:
+    remediation:
+      retries: 3
+      remediateLastFailure: false
+  test:
+    enable: true
+  driftDetection:
+    mode: enabled
+  valuesFrom:
+`)
+
+	config.Release = &models.ReleaseOptions{UpgradeCRDs: models.CRDPolicySkip}
+	assert.Contains(t, renderFiles(t, config)["release/helm-release.yaml"], "  upgrade:\n    crds: Skip\n  valuesFrom:\n")
+}
+
+func TestTemplates_FluxKustomization(t *testing.T) {
+	useTemplates(t)
+
+	config := newPodinfoConfig()
+	config.FluxKustomization = &models.FluxKustomization{
+		ClustersDir: "clusters/production",
+		Namespace:   "flux-system",
+		SourceRef:   "flux-system",
+		Prune:       true,
+		AppPath:     "./apps/podinfo",
+	}
+	render := func() string {
+		files, err := FluxKustomizationFiles(config)
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+		return files[0].Content
+	}
+
+	assert.Equal(t, `apiVersion: kustomize.toolkit.fluxcd.io/v1
+kind: Kustomization
+metadata:
+  name: podinfo
+  namespace: flux-system
+spec:
+  interval: 5m
+  path: ./apps/podinfo
+  prune: true
+  sourceRef:
+    kind: GitRepository
+    name: flux-system
+
+`, render())
+
+	config.FluxKustomization.Prune = false
+	config.FluxKustomization.Wait = true
+	config.FluxKustomization.Timeout = "3m"
+	config.FluxKustomization.TargetNamespace = "podinfo"
+	config.FluxKustomization.DependsOn = []string{"infrastructure", "fleet/crds"}
+	config.FluxKustomization.HealthChecks = []models.HealthCheck{{APIVersion: "helm.toolkit.fluxcd.io/v2", Kind: "HelmRelease", Name: "podinfo"}}
+	assert.Equal(t, `apiVersion: kustomize.toolkit.fluxcd.io/v1
+kind: Kustomization
+metadata:
+  name: podinfo
+  namespace: flux-system
+spec:
+  interval: 5m
+  path: ./apps/podinfo
+  prune: false
+  sourceRef:
+    kind: GitRepository
+    name: flux-system
+  targetNamespace: podinfo
+  wait: true
+  timeout: 3m
+  dependsOn:
+    - name: infrastructure
+    - name: crds
+      namespace: fleet
+  healthChecks:
+    - apiVersion: helm.toolkit.fluxcd.io/v2
+      kind: HelmRelease
+      name: podinfo
+      namespace: podinfo
+
+`, render())
+}
+
+func TestTemplates_Namespace(t *testing.T) {
+	useTemplates(t)
+
+	config := newPodinfoConfig()
+	config.NamespaceManifest = &models.NamespaceManifest{
+		Labels:        map[string]string{"team": "web", "env": "prod"},
+		Annotations:   map[string]string{"owner": "web-team"},
+		PodSecurity:   models.PodSecurityRestricted,
+		Kustomization: true,
+	}
+
+	files := renderFiles(t, config)
+	assert.Equal(t, `apiVersion: v1
+kind: Namespace
+metadata:
+  name: apps
+  labels:
+    env: "prod"
+    pod-security.kubernetes.io/enforce: "restricted"
+    team: "web"
+  annotations:
+    owner: "web-team"
+`+"\n", files["dependencies/namespace.yaml"])
+	assert.Contains(t, files["kustomization.yaml"], "resources:\n  - dependencies/namespace.yaml\n  - dependencies/helm-repository.yaml\n")
+
+	// The Namespace can be left out of kustomization.yaml, to be applied by hand
+	config.NamespaceManifest = &models.NamespaceManifest{}
+	files = renderFiles(t, config)
+	assert.Equal(t, "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: apps\n\n", files["dependencies/namespace.yaml"])
+	assert.NotContains(t, files["kustomization.yaml"], "namespace.yaml")
+
+	config.NamespaceManifest = nil
+	assert.NotContains(t, renderFiles(t, config), "dependencies/namespace.yaml")
+}
+
+func TestTemplates_ValuesFrom(t *testing.T) {
+	useTemplates(t)
+
+	config := newPodinfoConfig()
+	config.Values = map[string]interface{}{"replicaCount": 2}
+	config.ValuesKind = models.ValuesKindSecret
+	config.ValuesSources = []models.ValuesSource{
+		{Kind: models.ValuesKindConfigMap, Name: "podinfo-common", File: "common.yaml"},
+		{Kind: models.ValuesKindSecret, Name: "db-credentials", ValuesKey: "password", TargetPath: "postgresql.auth.password", Optional: true},
+	}
+
+	// Sources are layered over the values of the app, generated ones from their own file
+	files := renderFiles(t, config)
+	assert.Contains(t, files["release/helm-release.yaml"], `  valuesFrom:
+    - kind: Secret
+      name: podinfo-values
+      valuesKey: values.yaml
+    - kind: ConfigMap
+      name: podinfo-common
+    - kind: Secret
+      name: db-credentials
+      valuesKey: password
+      targetPath: postgresql.auth.password
+      optional: true
+
+`)
+	assert.Equal(t, "replicaCount: 2\n", files["release/helm-values.yaml"])
+	assert.Contains(t, files["release/common.yaml"], "# Values of the podinfo-common ConfigMap")
+	assert.Contains(t, files["kustomization.yaml"], `  - release/helm-release.yaml
+
+configMapGenerator:
+  - name: podinfo-common
+    files:
+      - values.yaml=release/common.yaml
+    options:
+      disableNameSuffixHash: true
+
+secretGenerator:
+  - name: podinfo-values
+    files:
+      - values.yaml=release/helm-values.yaml
+    options:
+      disableNameSuffixHash: true
+
+`)
+
+	// Inline values are written into the HelmRelease, without a values file
+	config.ValuesKind = models.ValuesKindInline
+	config.ValuesSources = nil
+	config.Values = map[string]interface{}{models.RawValuesKey: "# Replicas\nreplicaCount: 2\n\nimage:\n  tag: 6.9.0\n"}
+	files = renderFiles(t, config)
+	assert.Contains(t, files["release/helm-release.yaml"], "        name: podinfo\n      interval: 5m\n  values:\n    # Replicas\n    replicaCount: 2\n\n    image:\n      tag: 6.9.0\n\n")
+	assert.NotContains(t, files["release/helm-release.yaml"], "valuesFrom")
+	assert.NotContains(t, files, "release/helm-values.yaml")
+	assert.NotContains(t, files["kustomization.yaml"], "Generator")
+
+	config.Values = map[string]interface{}{}
+	assert.Contains(t, renderFiles(t, config)["release/helm-release.yaml"], "  values: {}\n")
+
+	// The overlays of inline values patch spec.values
+	config.Environments = []models.Environment{{Name: "production", Values: map[string]interface{}{"replicaCount": 3}}, {Name: "staging"}}
+	files = renderFiles(t, config)
+	assert.Contains(t, files["overlays/production/helm-release-patch.yaml"], "spec:\n  values:\n    replicaCount: 3\n")
+	assert.Contains(t, files["overlays/staging/helm-release-patch.yaml"], "spec:\n  values: {}\n")
+	assert.NotContains(t, files, "overlays/production/helm-values.yaml")
+	assert.NotContains(t, files["overlays/production/kustomization.yaml"], "Generator")
+
+	// The overlays of Secret values generate a Secret, after the layered sources
+	config.ValuesKind = models.ValuesKindSecret
+	config.ValuesSources = []models.ValuesSource{{Kind: models.ValuesKindConfigMap, Name: "shared-values", Optional: true}}
+	files = renderFiles(t, config)
+	assert.Contains(t, files["overlays/production/helm-release-patch.yaml"], `  valuesFrom:
+    - kind: Secret
+      name: podinfo-values
+      valuesKey: values.yaml
+    - kind: ConfigMap
+      name: shared-values
+      optional: true
+    - kind: Secret
+      name: podinfo-production-values
+      valuesKey: values.yaml
+`)
+	assert.Contains(t, files["overlays/production/kustomization.yaml"], "\nsecretGenerator:\n  - name: podinfo-production-values\n")
+}
@@ -0,0 +1,368 @@
+package generator
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/filesystem"
+	"github.com/EffectiveSloth/flux-app-generator/internal/models"
+	"github.com/EffectiveSloth/flux-app-generator/internal/plugins"
+	"github.com/EffectiveSloth/flux-app-generator/internal/semver"
+	"github.com/EffectiveSloth/flux-app-generator/internal/values"
+)
+
+// App is the configuration of an existing app, parsed from its generated files.
+type App struct {
+	AppName      string
+	Namespace    string
+	HelmRepoName string
+	HelmRepoURL  string
+	ChartName    string
+	ChartVersion string
+	// VersionConstraint is the semver constraint the app tracks instead of a pinned version.
+	// ChartVersion is then empty, as the version depends on the repository.
+	VersionConstraint string
+	Interval          string
+	SourceKind        string   // Kind of the Flux source of the chart
+	GitURL            string   // URL of the GitRepository of a chart stored in Git
+	GitBranch         string   // Branch of the GitRepository of a chart stored in Git
+	ChartPath         string   // Path of a chart stored in Git, relative to the repository root
+	Resources         []string // Resources listed in kustomization.yaml
+	InlineValues      bool     // Values are written into the HelmRelease instead of helm-values.yaml
+}
+
+// UpgradeOptions describes the changes applied to an existing app.
+type UpgradeOptions struct {
+	// ChartVersion is the chart version to upgrade to. Empty keeps the current version.
+	ChartVersion string
+	// VersionConstraint is a semver constraint written instead of ChartVersion, which is then the
+	// version it resolves to. Empty pins ChartVersion, replacing a tracked constraint.
+	VersionConstraint string
+	// Plugins are new plugin instances whose files are added to the app.
+	Plugins []plugins.PluginConfig
+	// DefaultValues holds the default values of the current and the new chart version. When set,
+	// the values file is three-way merged between them on a version change.
+	DefaultValues *DefaultValues
+}
+
+// DefaultValues holds the default values.yaml of two chart versions.
+type DefaultValues struct {
+	Old string
+	New string
+}
+
+// UpgradeResult lists the files changed by an upgrade.
+type UpgradeResult struct {
+	Files []RenderedFile
+	// ValuesReport lists the changes and conflicts of the values merge, nil when values were not merged.
+	ValuesReport *values.Report
+}
+
+// Paths returns the paths of the changed files.
+func (r *UpgradeResult) Paths() []string {
+	paths := make([]string, 0, len(r.Files))
+	for _, file := range r.Files {
+		paths = append(paths, file.Path)
+	}
+	return paths
+}
+
+// LoadApp parses the HelmRelease, HelmRepository and Kustomization of the app in appDir.
+func LoadApp(fsys filesystem.FS, appDir string) (*App, error) {
+	fsys = filesystem.Default(fsys)
+
+	release, err := readYAMLMapping(fsys, appDir, helmReleasePath)
+	if err != nil {
+		return nil, err
+	}
+	kustomization, err := readYAMLMapping(fsys, appDir, kustomizationPath)
+	if err != nil {
+		return nil, err
+	}
+
+	app := &App{
+		AppName:   lookupString(release, "metadata", "name"),
+		Namespace: lookupString(release, "metadata", "namespace"),
+		Interval:  lookupString(release, "spec", "interval"),
+	}
+	app.InlineValues = lookupNode(release, "spec", "values") != nil
+
+	switch {
+	case lookupString(release, "spec", "chartRef", "kind") == models.SourceKindOCIRepository:
+		repository, err := readYAMLMapping(fsys, appDir, ociRepositoryPath)
+		if err != nil {
+			return nil, err
+		}
+		// The OCIRepository URL is the one of the chart itself
+		chartURL := lookupString(repository, "spec", "url")
+		if i := strings.LastIndex(chartURL, "/"); i >= 0 {
+			app.HelmRepoURL, app.ChartName = chartURL[:i], chartURL[i+1:]
+		}
+		app.HelmRepoName = lookupString(release, "spec", "chartRef", "name")
+		app.ChartVersion = strings.ReplaceAll(lookupString(repository, "spec", "ref", "tag"), "_", "+")
+		app.VersionConstraint = lookupString(repository, "spec", "ref", "semver")
+		app.SourceKind = models.SourceKindOCIRepository
+		if app.ChartName == "" {
+			return nil, fmt.Errorf("%s: spec.url is not set", ociRepositoryPath)
+		}
+	case lookupString(release, "spec", "chart", "spec", "sourceRef", "kind") == models.SourceKindGitRepository:
+		repository, err := readYAMLMapping(fsys, appDir, gitRepositoryPath)
+		if err != nil {
+			return nil, err
+		}
+		app.HelmRepoName = lookupString(release, "spec", "chart", "spec", "sourceRef", "name")
+		app.GitURL = lookupString(repository, "spec", "url")
+		app.GitBranch = lookupString(repository, "spec", "ref", "branch")
+		app.ChartPath = lookupString(release, "spec", "chart", "spec", "chart")
+		app.SourceKind = models.SourceKindGitRepository
+		// The chart is named after its directory or package
+		app.ChartName = strings.TrimSuffix(path.Base(app.ChartPath), ".tgz")
+		if app.ChartPath == "" {
+			return nil, fmt.Errorf("%s: spec.chart.spec.chart is not set", helmReleasePath)
+		}
+		if app.GitURL == "" {
+			return nil, fmt.Errorf("%s: spec.url is not set", gitRepositoryPath)
+		}
+	default:
+		repository, err := readYAMLMapping(fsys, appDir, helmRepositoryPath)
+		if err != nil {
+			return nil, err
+		}
+		app.HelmRepoName = lookupString(release, "spec", "chart", "spec", "sourceRef", "name")
+		app.HelmRepoURL = lookupString(repository, "spec", "url")
+		app.ChartName = lookupString(release, "spec", "chart", "spec", "chart")
+		app.ChartVersion = lookupString(release, "spec", "chart", "spec", "version")
+		if app.ChartVersion != "" && !semver.IsExact(app.ChartVersion) {
+			app.ChartVersion, app.VersionConstraint = "", app.ChartVersion
+		}
+	}
+
+	if resources := lookupNode(kustomization, "resources"); resources != nil {
+		for _, item := range resources.Content {
+			app.Resources = append(app.Resources, item.Value)
+		}
+	}
+
+	if app.ChartName == "" {
+		return nil, fmt.Errorf("%s: spec.chart.spec.chart is not set", helmReleasePath)
+	}
+	if app.HelmRepoURL == "" && !app.UsesGitRepository() {
+		return nil, fmt.Errorf("%s: spec.url is not set", helmRepositoryPath)
+	}
+	return app, nil
+}
+
+// ReadValues returns the content of release/helm-values.yaml of the app in appDir, or the
+// spec.values of its HelmRelease when the values are inline.
+func ReadValues(fsys filesystem.FS, appDir string) ([]byte, error) {
+	fsys = filesystem.Default(fsys)
+	data, err := fsys.ReadFile(filepath.Join(appDir, filepath.FromSlash(helmValuesPath)))
+	if err == nil {
+		return data, nil
+	}
+	if release, releaseErr := readYAMLMapping(fsys, appDir, helmReleasePath); releaseErr == nil {
+		if inline := lookupNode(release, "spec", "values"); inline != nil {
+			return yaml.Marshal(inline)
+		}
+	}
+	return nil, fmt.Errorf("failed to read %s: %w", helmValuesPath, err)
+}
+
+// Config returns the app configuration matching the parsed app.
+func (a *App) Config() *models.AppConfig {
+	return &models.AppConfig{
+		AppName:           a.AppName,
+		Namespace:         a.Namespace,
+		HelmRepoName:      a.HelmRepoName,
+		HelmRepoURL:       a.HelmRepoURL,
+		ChartName:         a.ChartName,
+		ChartVersion:      a.ChartVersion,
+		VersionConstraint: a.VersionConstraint,
+		TrackConstraint:   a.VersionConstraint != "",
+		Interval:          a.Interval,
+		SourceKind:        a.SourceKind,
+		GitURL:            a.GitURL,
+		GitBranch:         a.GitBranch,
+		ChartPath:         a.ChartPath,
+	}
+}
+
+// UsesGitRepository reports whether the chart of the app is stored in a Git repository.
+func (a *App) UsesGitRepository() bool {
+	return a.SourceKind == models.SourceKindGitRepository
+}
+
+// RenderUpgrade renders the files of appDir changed by the upgrade. Only the edited fields are
+// rewritten; the rest of each file, including comments and hand edits, is kept byte for byte.
+// Merged values files are the exception: they are re-encoded when the merge changes them.
+func RenderUpgrade(fsys filesystem.FS, appDir string, opts UpgradeOptions) (*UpgradeResult, error) {
+	fsys = filesystem.Default(fsys)
+
+	app, err := LoadApp(fsys, appDir)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &UpgradeResult{}
+	var files []RenderedFile
+
+	// The written version is the tracked constraint, or else the pinned version
+	current, target := app.VersionConstraint, opts.VersionConstraint
+	if current == "" {
+		current = app.ChartVersion
+	}
+	if target == "" {
+		target = opts.ChartVersion
+	}
+
+	if target != "" && target != current {
+		// The version is set in the HelmRelease, or as a tag or semver range in the OCIRepository
+		path, field, version := helmReleasePath, []string{"spec", "chart", "spec", "version"}, target
+		rename := ""
+		if app.SourceKind == models.SourceKindOCIRepository {
+			key, newKey := "tag", "tag"
+			if app.VersionConstraint != "" {
+				key = "semver"
+			}
+			if opts.VersionConstraint != "" {
+				newKey = "semver"
+			} else {
+				version = strings.ReplaceAll(opts.ChartVersion, "+", "_")
+			}
+			path, field = ociRepositoryPath, []string{"spec", "ref", key}
+			if newKey != key {
+				rename = newKey
+			}
+		}
+		src, err := fsys.ReadFile(filepath.Join(appDir, filepath.FromSlash(path)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		doc, err := parseYAMLMapping(src)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		node := lookupNode(doc, field...)
+		if node == nil {
+			return nil, fmt.Errorf("%s: %s is not set", path, strings.Join(field, "."))
+		}
+		content, err := replaceScalar(src, node, version)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update %s: %w", path, err)
+		}
+		// Switching between a tag and a semver range renames the key, which comes before its value
+		if rename != "" {
+			if content, err = renameKey(content, lookupKey(doc, field...), rename); err != nil {
+				return nil, fmt.Errorf("failed to update %s: %w", path, err)
+			}
+		}
+		files = append(files, RenderedFile{Path: path, Content: string(content)})
+
+		// Inline values are left to be merged by hand, with the rest of the HelmRelease
+		if opts.DefaultValues != nil && !app.InlineValues {
+			ours, err := fsys.ReadFile(filepath.Join(appDir, filepath.FromSlash(helmValuesPath)))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", helmValuesPath, err)
+			}
+			merged, err := values.Merge([]byte(opts.DefaultValues.Old), []byte(opts.DefaultValues.New), ours)
+			if err != nil {
+				return nil, fmt.Errorf("failed to merge %s: %w", helmValuesPath, err)
+			}
+			result.ValuesReport = &merged.Report
+			if merged.Changed() {
+				files = append(files, RenderedFile{Path: helmValuesPath, Content: string(merged.Values)})
+			}
+
+			// Keep the reference defaults of an overrides values file in line with the new version
+			if _, err := fsys.Stat(filepath.Join(appDir, filepath.FromSlash(helmValuesDefaultsPath))); err == nil {
+				reference := ValuesDefaultsReference(app.ChartName, opts.ChartVersion, opts.DefaultValues.New)
+				files = append(files, RenderedFile{Path: helmValuesDefaultsPath, Content: reference})
+			}
+		}
+	}
+
+	if len(opts.Plugins) > 0 {
+		config := app.Config()
+		config.Plugins = opts.Plugins
+		pluginFiles, err := renderPluginFiles(config)
+		if err != nil {
+			return nil, err
+		}
+
+		src, err := fsys.ReadFile(filepath.Join(appDir, kustomizationPath))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", kustomizationPath, err)
+		}
+		listed := make(map[string]bool, len(app.Resources))
+		for _, resource := range app.Resources {
+			listed[resource] = true
+		}
+
+		for _, file := range pluginFiles {
+			if _, err := fsys.Stat(filepath.Join(appDir, filepath.FromSlash(file.Path))); err == nil {
+				return nil, fmt.Errorf("plugin file %s already exists in %s", file.Path, appDir)
+			}
+			files = append(files, file)
+
+			if listed[file.Path] {
+				continue
+			}
+			kustomization, err := parseYAMLMapping(src)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", kustomizationPath, err)
+			}
+			resources := lookupNode(kustomization, "resources")
+			if resources == nil {
+				return nil, fmt.Errorf("%s: resources is not set", kustomizationPath)
+			}
+			if src, err = appendSequenceItem(src, resources, file.Path); err != nil {
+				return nil, fmt.Errorf("failed to update %s: %w", kustomizationPath, err)
+			}
+			listed[file.Path] = true
+		}
+		files = append(files, RenderedFile{Path: kustomizationPath, Content: string(src)})
+	}
+
+	result.Files = files
+	return result, nil
+}
+
+// UpgradeApp applies the upgrade to appDir atomically.
+func UpgradeApp(fsys filesystem.FS, appDir string, opts UpgradeOptions) (*UpgradeResult, error) {
+	result, err := RenderUpgrade(fsys, appDir, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	staging := filesystem.NewStaging(fsys)
+	for _, file := range result.Files {
+		path := filepath.Join(appDir, filepath.FromSlash(file.Path))
+		if err := staging.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, err
+		}
+		if err := staging.WriteFile(path, []byte(file.Content), 0o600); err != nil {
+			return nil, err
+		}
+	}
+	if err := staging.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// readYAMLMapping reads and parses one of the generated files of appDir.
+func readYAMLMapping(fsys filesystem.FS, appDir, path string) (*yaml.Node, error) {
+	data, err := fsys.ReadFile(filepath.Join(appDir, filepath.FromSlash(path)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	node, err := parseYAMLMapping(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return node, nil
+}
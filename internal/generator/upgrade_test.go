@@ -0,0 +1,340 @@
+package generator
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/filesystem"
+	"github.com/EffectiveSloth/flux-app-generator/internal/plugins"
+)
+
+const upgradeRelease = `apiVersion: helm.toolkit.fluxcd.io/v2
+kind: HelmRelease
+metadata:
+  name: podinfo
+  namespace: apps
+spec:
+  interval: 5m
+  chart:
+    spec:
+      chart: podinfo
+      # Pinned until the next maintenance window
+      version: '6.5.0' # chart version
+      sourceRef:
+        kind: HelmRepository
+        name: podinfo
+      interval: 5m
+  install:
+    remediation:
+      retries: 3
+`
+
+const upgradeRepository = `apiVersion: source.toolkit.fluxcd.io/v1
+kind: HelmRepository
+metadata:
+  name: podinfo
+  namespace: apps
+spec:
+  interval: 5m
+  url: https://stefanprodan.github.io/podinfo
+`
+
+const upgradeKustomization = `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+
+resources:
+  - dependencies/helm-repository.yaml
+  - release/helm-release.yaml # the release
+
+configMapGenerator:
+  - name: podinfo-values
+    files:
+      - values.yaml=release/helm-values.yaml
+`
+
+// newUpgradeFS returns an in-memory filesystem holding a hand-edited app in "podinfo".
+func newUpgradeFS(t *testing.T) *filesystem.MemFS {
+	t.Helper()
+	fsys := filesystem.NewMemFS()
+	files := map[string]string{
+		helmReleasePath:    upgradeRelease,
+		helmRepositoryPath: upgradeRepository,
+		kustomizationPath:  upgradeKustomization,
+	}
+	for path, content := range files {
+		require.NoError(t, fsys.WriteFile(filepath.Join("podinfo", filepath.FromSlash(path)), []byte(content), 0o600))
+	}
+	return fsys
+}
+
+func TestLoadApp(t *testing.T) {
+	app, err := LoadApp(newUpgradeFS(t), "podinfo")
+	require.NoError(t, err)
+
+	assert.Equal(t, &App{
+		AppName:      "podinfo",
+		Namespace:    "apps",
+		HelmRepoName: "podinfo",
+		HelmRepoURL:  "https://stefanprodan.github.io/podinfo",
+		ChartName:    "podinfo",
+		ChartVersion: "6.5.0",
+		Interval:     "5m",
+		Resources:    []string{"dependencies/helm-repository.yaml", "release/helm-release.yaml"},
+	}, app)
+
+	_, err = LoadApp(filesystem.NewMemFS(), "podinfo")
+	assert.Error(t, err)
+}
+
+func TestRenderUpgrade_ChartVersion(t *testing.T) {
+	fsys := newUpgradeFS(t)
+
+	result, err := RenderUpgrade(fsys, "podinfo", UpgradeOptions{ChartVersion: "6.9.0"})
+	require.NoError(t, err)
+	files := result.Files
+	require.Len(t, files, 1)
+	assert.Equal(t, helmReleasePath, files[0].Path)
+	// Only the version changes, comments and hand edits are kept
+	assert.Equal(t, strings.Replace(upgradeRelease, "'6.5.0'", "'6.9.0'", 1), files[0].Content)
+
+	result, err = RenderUpgrade(fsys, "podinfo", UpgradeOptions{ChartVersion: "6.5.0"})
+	require.NoError(t, err)
+	assert.Empty(t, result.Files, "upgrading to the current version changes nothing")
+	assert.Nil(t, result.ValuesReport)
+}
+
+func TestUpgradeApp_AddPlugin(t *testing.T) {
+	fsys := newUpgradeFS(t)
+
+	secretPlugin := []plugins.PluginConfig{{
+		PluginName: "externalsecret",
+		Values: map[string]interface{}{
+			"name":               "podinfo-secret",
+			"secret_store_type":  "ClusterSecretStore",
+			"secret_store_name":  "vault-backend",
+			"secret_key":         "podinfo",
+			"target_secret_name": "podinfo",
+			"refresh_interval":   "60m",
+		},
+	}}
+
+	result, err := UpgradeApp(fsys, "podinfo", UpgradeOptions{ChartVersion: "6.9.0", Plugins: secretPlugin})
+	require.NoError(t, err)
+	assert.Equal(t, []string{helmReleasePath, "dependencies/external-secret-podinfo.yaml", kustomizationPath}, result.Paths())
+
+	kustomization, err := fsys.ReadFile(filepath.Join("podinfo", kustomizationPath))
+	require.NoError(t, err)
+	assert.Equal(t, strings.Replace(upgradeKustomization,
+		"  - release/helm-release.yaml # the release\n",
+		"  - release/helm-release.yaml # the release\n  - dependencies/external-secret-podinfo.yaml\n", 1),
+		string(kustomization))
+
+	secret, err := fsys.ReadFile(filepath.Join("podinfo", "dependencies", "external-secret-podinfo.yaml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(secret), "namespace: apps")
+
+	// Adding the same plugin instance again would overwrite its file
+	_, err = UpgradeApp(fsys, "podinfo", UpgradeOptions{Plugins: secretPlugin})
+	assert.ErrorContains(t, err, "already exists")
+}
+
+func TestUpgradeApp_MergeValues(t *testing.T) {
+	fsys := newUpgradeFS(t)
+	valuesPath := filepath.Join("podinfo", filepath.FromSlash(helmValuesPath))
+	require.NoError(t, fsys.WriteFile(valuesPath, []byte("replicaCount: 2\nimage:\n  tag: 6.5.0\n"), 0o600))
+
+	result, err := UpgradeApp(fsys, "podinfo", UpgradeOptions{
+		ChartVersion: "6.9.0",
+		DefaultValues: &DefaultValues{
+			Old: "replicaCount: 1\nimage:\n  tag: 6.5.0\n",
+			New: "replicaCount: 1\nimage:\n  tag: 6.9.0\n",
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{helmReleasePath, helmValuesPath}, result.Paths())
+	require.NotNil(t, result.ValuesReport)
+	assert.Len(t, result.ValuesReport.Changes, 1)
+	assert.Empty(t, result.ValuesReport.Conflicts())
+
+	merged, err := fsys.ReadFile(valuesPath)
+	require.NoError(t, err)
+	assert.Equal(t, "replicaCount: 2\nimage:\n  tag: 6.9.0\n", string(merged))
+}
+
+func TestUpgradeApp_ValuesDefaultsReference(t *testing.T) {
+	fsys := newUpgradeFS(t)
+	valuesPath := filepath.Join("podinfo", filepath.FromSlash(helmValuesPath))
+	defaultsPath := filepath.Join("podinfo", filepath.FromSlash(helmValuesDefaultsPath))
+	require.NoError(t, fsys.WriteFile(valuesPath, []byte("replicaCount: 2\n"), 0o600))
+	require.NoError(t, fsys.WriteFile(defaultsPath, []byte(ValuesDefaultsReference("podinfo", "6.5.0", "replicaCount: 1\n")), 0o600))
+
+	result, err := UpgradeApp(fsys, "podinfo", UpgradeOptions{
+		ChartVersion: "6.9.0",
+		DefaultValues: &DefaultValues{
+			Old: "replicaCount: 1\ndebug: false\n",
+			New: "replicaCount: 1\ndebug: false\nlogLevel: info\n",
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{helmReleasePath, helmValuesDefaultsPath}, result.Paths())
+
+	reference, err := fsys.ReadFile(defaultsPath)
+	require.NoError(t, err)
+	assert.Equal(t, ValuesDefaultsReference("podinfo", "6.9.0", "replicaCount: 1\ndebug: false\nlogLevel: info\n"), string(reference))
+	assert.Contains(t, string(reference), "# logLevel: info\n")
+}
+
+func TestUpgradeApp_OCIRepository(t *testing.T) {
+	fsys := filesystem.NewMemFS()
+	files := map[string]string{
+		helmReleasePath: `apiVersion: helm.toolkit.fluxcd.io/v2
+kind: HelmRelease
+metadata:
+  name: podinfo
+  namespace: apps
+spec:
+  interval: 5m
+  chartRef:
+    kind: OCIRepository
+    name: podinfo-oci
+`,
+		ociRepositoryPath: `apiVersion: source.toolkit.fluxcd.io/v1
+kind: OCIRepository
+metadata:
+  name: podinfo-oci
+  namespace: apps
+spec:
+  interval: 5m
+  url: oci://ghcr.io/stefanprodan/charts/podinfo
+  ref:
+    tag: '6.5.0' # pinned
+`,
+		kustomizationPath: strings.Replace(upgradeKustomization, "helm-repository", "oci-repository", 1),
+	}
+	for path, content := range files {
+		require.NoError(t, fsys.WriteFile(filepath.Join("podinfo", filepath.FromSlash(path)), []byte(content), 0o600))
+	}
+
+	app, err := LoadApp(fsys, "podinfo")
+	require.NoError(t, err)
+	assert.Equal(t, "oci://ghcr.io/stefanprodan/charts", app.HelmRepoURL)
+	assert.Equal(t, "podinfo", app.ChartName)
+	assert.Equal(t, "6.5.0", app.ChartVersion)
+	assert.Equal(t, "podinfo-oci", app.HelmRepoName)
+	assert.True(t, app.Config().UsesOCIRepository())
+
+	result, err := UpgradeApp(fsys, "podinfo", UpgradeOptions{ChartVersion: "6.9.0+build.1"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{ociRepositoryPath}, result.Paths())
+
+	upgraded, err := fsys.ReadFile(filepath.Join("podinfo", filepath.FromSlash(ociRepositoryPath)))
+	require.NoError(t, err)
+	assert.Equal(t, strings.Replace(files[ociRepositoryPath], "'6.5.0'", "'6.9.0_build.1'", 1), string(upgraded))
+
+	// Tracking a constraint switches the tag to a semver range, and pinning switches it back
+	_, err = UpgradeApp(fsys, "podinfo", UpgradeOptions{ChartVersion: "6.9.1", VersionConstraint: "~6.9"})
+	require.NoError(t, err)
+	upgraded, err = fsys.ReadFile(filepath.Join("podinfo", filepath.FromSlash(ociRepositoryPath)))
+	require.NoError(t, err)
+	assert.Equal(t, strings.Replace(files[ociRepositoryPath], "tag: '6.5.0'", "semver: '~6.9'", 1), string(upgraded))
+
+	app, err = LoadApp(fsys, "podinfo")
+	require.NoError(t, err)
+	assert.Equal(t, "~6.9", app.VersionConstraint)
+	assert.Empty(t, app.ChartVersion)
+
+	_, err = UpgradeApp(fsys, "podinfo", UpgradeOptions{ChartVersion: "6.9.2"})
+	require.NoError(t, err)
+	upgraded, err = fsys.ReadFile(filepath.Join("podinfo", filepath.FromSlash(ociRepositoryPath)))
+	require.NoError(t, err)
+	assert.Equal(t, strings.Replace(files[ociRepositoryPath], "'6.5.0'", "'6.9.2'", 1), string(upgraded))
+}
+
+func TestLoadApp_GitRepository(t *testing.T) {
+	fsys := filesystem.NewMemFS()
+	files := map[string]string{
+		helmReleasePath: strings.Replace(strings.Replace(upgradeRelease,
+			"chart: podinfo", "chart: ./charts/podinfo", 1),
+			"kind: HelmRepository\n        name: podinfo", "kind: GitRepository\n        name: fleet", 1),
+		gitRepositoryPath: `apiVersion: source.toolkit.fluxcd.io/v1
+kind: GitRepository
+metadata:
+  name: fleet
+  namespace: apps
+spec:
+  interval: 5m
+  url: ssh://git@github.com/acme/fleet.git
+  ref:
+    branch: main
+`,
+		kustomizationPath: strings.Replace(upgradeKustomization, "helm-repository", "git-repository", 1),
+	}
+	for path, content := range files {
+		require.NoError(t, fsys.WriteFile(filepath.Join("podinfo", filepath.FromSlash(path)), []byte(content), 0o600))
+	}
+
+	app, err := LoadApp(fsys, "podinfo")
+	require.NoError(t, err)
+	assert.True(t, app.UsesGitRepository())
+	assert.Equal(t, "fleet", app.HelmRepoName)
+	assert.Equal(t, "ssh://git@github.com/acme/fleet.git", app.GitURL)
+	assert.Equal(t, "main", app.GitBranch)
+	assert.Equal(t, "./charts/podinfo", app.ChartPath)
+	assert.Equal(t, "podinfo", app.ChartName)
+	assert.Empty(t, app.HelmRepoURL)
+
+	config := app.Config()
+	assert.Equal(t, "GitRepository", config.SourceKind)
+	assert.Equal(t, "./charts/podinfo", config.ChartPath)
+}
+
+func TestUpgradeApp_VersionConstraint(t *testing.T) {
+	fsys := newUpgradeFS(t)
+
+	result, err := UpgradeApp(fsys, "podinfo", UpgradeOptions{ChartVersion: "6.9.1", VersionConstraint: ">=6.9.0 <7.0.0"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{helmReleasePath}, result.Paths())
+	upgraded, err := fsys.ReadFile(filepath.Join("podinfo", filepath.FromSlash(helmReleasePath)))
+	require.NoError(t, err)
+	assert.Equal(t, strings.Replace(upgradeRelease, "'6.5.0'", "'>=6.9.0 <7.0.0'", 1), string(upgraded))
+
+	app, err := LoadApp(fsys, "podinfo")
+	require.NoError(t, err)
+	assert.Equal(t, ">=6.9.0 <7.0.0", app.VersionConstraint)
+	assert.Empty(t, app.ChartVersion)
+	assert.Equal(t, ">=6.9.0 <7.0.0", app.Config().ReleaseVersion())
+
+	// The same constraint needs no change, whatever it resolves to
+	result, err = RenderUpgrade(fsys, "podinfo", UpgradeOptions{ChartVersion: "6.9.2", VersionConstraint: ">=6.9.0 <7.0.0"})
+	require.NoError(t, err)
+	assert.Empty(t, result.Files)
+}
+
+func TestUpgradeApp_InlineValues(t *testing.T) {
+	fsys := newUpgradeFS(t)
+	release := upgradeRelease + "  values:\n    # Two replicas\n    replicaCount: 2\n"
+	require.NoError(t, fsys.WriteFile(filepath.Join("podinfo", filepath.FromSlash(helmReleasePath)), []byte(release), 0o600))
+
+	app, err := LoadApp(fsys, "podinfo")
+	require.NoError(t, err)
+	assert.True(t, app.InlineValues)
+	values, err := ReadValues(fsys, "podinfo")
+	require.NoError(t, err)
+	assert.Equal(t, "# Two replicas\nreplicaCount: 2\n", string(values))
+
+	// Inline values are not merged
+	result, err := UpgradeApp(fsys, "podinfo", UpgradeOptions{
+		ChartVersion:  "6.9.0",
+		DefaultValues: &DefaultValues{Old: "replicaCount: 1\n", New: "replicaCount: 1\nimage: {}\n"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{helmReleasePath}, result.Paths())
+	assert.Nil(t, result.ValuesReport)
+
+	_, err = ReadValues(newUpgradeFS(t), "podinfo")
+	assert.Error(t, err)
+}
@@ -0,0 +1,234 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The helpers below edit YAML documents in place: nodes are located with yaml.v3 and only the bytes
+// of the edited value are rewritten, so comments, blank lines and formatting survive untouched.
+
+// parseYAMLMapping parses a single YAML document and returns its top-level mapping node.
+func parseYAMLMapping(data []byte) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("expected a YAML mapping")
+	}
+	return doc.Content[0], nil
+}
+
+// lookupNode follows a path of mapping keys and returns the value node, or nil when a key is missing.
+func lookupNode(node *yaml.Node, path ...string) *yaml.Node {
+	for _, key := range path {
+		if node == nil || node.Kind != yaml.MappingNode {
+			return nil
+		}
+		var next *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				next = node.Content[i+1]
+				break
+			}
+		}
+		node = next
+	}
+	return node
+}
+
+// lookupKey returns the key node of the last key of path, or nil when a key is missing.
+func lookupKey(node *yaml.Node, path ...string) *yaml.Node {
+	if len(path) == 0 {
+		return nil
+	}
+	parent := lookupNode(node, path[:len(path)-1]...)
+	if parent == nil || parent.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(parent.Content); i += 2 {
+		if parent.Content[i].Value == path[len(path)-1] {
+			return parent.Content[i]
+		}
+	}
+	return nil
+}
+
+// lookupString returns the value of a scalar at path, or an empty string.
+func lookupString(node *yaml.Node, path ...string) string {
+	if n := lookupNode(node, path...); n != nil && n.Kind == yaml.ScalarNode {
+		return n.Value
+	}
+	return ""
+}
+
+// lineOffsets returns the byte offset each line of src starts at.
+func lineOffsets(src []byte) []int {
+	offsets := []int{0}
+	for i, b := range src {
+		if b == '\n' {
+			offsets = append(offsets, i+1)
+		}
+	}
+	return offsets
+}
+
+// nodeOffset converts the 1-based line and column of node to a byte offset in src.
+func nodeOffset(src []byte, offsets []int, node *yaml.Node) (int, error) {
+	if node.Line < 1 || node.Line > len(offsets) {
+		return 0, fmt.Errorf("line %d out of range", node.Line)
+	}
+	start := offsets[node.Line-1]
+	line := src[start:]
+	if end := bytes.IndexByte(line, '\n'); end >= 0 {
+		line = line[:end]
+	}
+	// Columns count characters, not bytes
+	column := 1
+	for i := range string(line) {
+		if column == node.Column {
+			return start + i, nil
+		}
+		column++
+	}
+	return 0, fmt.Errorf("column %d out of range on line %d", node.Column, node.Line)
+}
+
+// scalarEnd returns the offset right after the scalar token starting at start.
+func scalarEnd(src []byte, start int, style yaml.Style) (int, error) {
+	switch {
+	case style&yaml.SingleQuotedStyle != 0:
+		for i := start + 1; i < len(src); i++ {
+			if src[i] == '\'' {
+				if i+1 < len(src) && src[i+1] == '\'' {
+					i++
+					continue
+				}
+				return i + 1, nil
+			}
+		}
+		return 0, fmt.Errorf("unterminated single-quoted scalar")
+	case style&yaml.DoubleQuotedStyle != 0:
+		for i := start + 1; i < len(src); i++ {
+			switch src[i] {
+			case '\\':
+				i++
+			case '"':
+				return i + 1, nil
+			}
+		}
+		return 0, fmt.Errorf("unterminated double-quoted scalar")
+	case style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0:
+		return 0, fmt.Errorf("block scalars are not supported")
+	default:
+		end := start
+		for end < len(src) && src[end] != '\n' {
+			if src[end] == '#' && end > start && (src[end-1] == ' ' || src[end-1] == '\t') {
+				break
+			}
+			end++
+		}
+		return start + len(strings.TrimRight(string(src[start:end]), " \t\r")), nil
+	}
+}
+
+// formatScalar renders value as a string scalar in the given style. Plain values that would not be
+// read back as strings, such as 1.10, are single-quoted.
+func formatScalar(value string, style yaml.Style) string {
+	switch {
+	case style&yaml.DoubleQuotedStyle != 0:
+		return strconv.Quote(value)
+	case style&yaml.SingleQuotedStyle != 0:
+		return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	}
+	var decoded interface{}
+	if err := yaml.Unmarshal([]byte(value), &decoded); err != nil || decoded != value {
+		return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	}
+	return value
+}
+
+// replaceScalar rewrites the value of a scalar node, keeping its quoting style.
+func replaceScalar(src []byte, node *yaml.Node, value string) ([]byte, error) {
+	if node.Kind != yaml.ScalarNode {
+		return nil, fmt.Errorf("line %d: expected a scalar value", node.Line)
+	}
+	offsets := lineOffsets(src)
+	start, err := nodeOffset(src, offsets, node)
+	if err != nil {
+		return nil, err
+	}
+	end, err := scalarEnd(src, start, node.Style)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %w", node.Line, err)
+	}
+
+	var out bytes.Buffer
+	out.Write(src[:start])
+	out.WriteString(formatScalar(value, node.Style))
+	out.Write(src[end:])
+	return out.Bytes(), nil
+}
+
+// renameKey rewrites a plain mapping key. Unlike values, plain keys end at their ": " separator.
+func renameKey(src []byte, key *yaml.Node, name string) ([]byte, error) {
+	if key.Kind != yaml.ScalarNode || key.Style != 0 {
+		return nil, fmt.Errorf("line %d: expected a plain key", key.Line)
+	}
+	start, err := nodeOffset(src, lineOffsets(src), key)
+	if err != nil {
+		return nil, err
+	}
+	end := start + len(key.Value)
+	if end > len(src) || string(src[start:end]) != key.Value {
+		return nil, fmt.Errorf("line %d: key %q not found", key.Line, key.Value)
+	}
+
+	var out bytes.Buffer
+	out.Write(src[:start])
+	out.WriteString(name)
+	out.Write(src[end:])
+	return out.Bytes(), nil
+}
+
+// appendSequenceItem adds a scalar item after the last item of a non-empty block sequence,
+// using the same indentation as that item.
+func appendSequenceItem(src []byte, seq *yaml.Node, value string) ([]byte, error) {
+	if seq.Kind != yaml.SequenceNode || seq.Style&yaml.FlowStyle != 0 || len(seq.Content) == 0 {
+		return nil, fmt.Errorf("line %d: expected a non-empty block sequence", seq.Line)
+	}
+	last := seq.Content[len(seq.Content)-1]
+	if last.Kind != yaml.ScalarNode {
+		return nil, fmt.Errorf("line %d: expected a sequence of scalars", last.Line)
+	}
+
+	offsets := lineOffsets(src)
+	itemStart, err := nodeOffset(src, offsets, last)
+	if err != nil {
+		return nil, err
+	}
+	lineStart := offsets[last.Line-1]
+	prefix := string(src[lineStart:itemStart])
+	dash := strings.LastIndex(prefix, "-")
+	if dash < 0 {
+		return nil, fmt.Errorf("line %d: expected a sequence item", last.Line)
+	}
+	// Keep the indentation before the dash and the spacing after it
+	indent := prefix[:dash] + "-" + strings.Repeat(" ", len(prefix)-dash-1)
+
+	lineEnd := len(src)
+	if end := bytes.IndexByte(src[itemStart:], '\n'); end >= 0 {
+		lineEnd = itemStart + end
+	}
+
+	var out bytes.Buffer
+	out.Write(src[:lineEnd])
+	out.WriteString("\n" + indent + formatScalar(value, last.Style))
+	out.Write(src[lineEnd:])
+	return out.Bytes(), nil
+}
@@ -0,0 +1,69 @@
+package generator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplaceScalar(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		value    string
+		expected string
+	}{
+		{"single quoted", "version: '1.0.0' # pinned\n", "2.0.0", "version: '2.0.0' # pinned\n"},
+		{"double quoted", "version: \"1.0.0\"\n", "2.0.0", "version: \"2.0.0\"\n"},
+		{"plain", "version: 1.0.0   # pinned\n", "2.0.0", "version: 2.0.0   # pinned\n"},
+		{"plain stays a string", "version: 1.0.0\n", "1.10", "version: '1.10'\n"},
+		{"escaped quote", "version: 'it''s'\nnext: true\n", "v2", "version: 'v2'\nnext: true\n"},
+		{"unicode before value", "é: 1.0.0\n", "2.0.0", "é: 2.0.0\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, err := parseYAMLMapping([]byte(tt.src))
+			require.NoError(t, err)
+			node := root.Content[1]
+
+			out, err := replaceScalar([]byte(tt.src), node, tt.value)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, string(out))
+		})
+	}
+}
+
+func TestRenameKey(t *testing.T) {
+	src := "ref:\n  tag: '1.0.0' # pinned\n"
+	root, err := parseYAMLMapping([]byte(src))
+	require.NoError(t, err)
+
+	out, err := renameKey([]byte(src), lookupKey(root, "ref", "tag"), "semver")
+	require.NoError(t, err)
+	assert.Equal(t, "ref:\n  semver: '1.0.0' # pinned\n", string(out))
+
+	assert.Nil(t, lookupKey(root, "ref", "missing"))
+
+	quoted, err := parseYAMLMapping([]byte("'tag': v1\n"))
+	require.NoError(t, err)
+	_, err = renameKey([]byte("'tag': v1\n"), quoted.Content[0], "semver")
+	assert.Error(t, err)
+}
+
+func TestAppendSequenceItem(t *testing.T) {
+	src := "resources:\n    -   a.yaml\n    -   b.yaml # last\nother: true\n"
+	root, err := parseYAMLMapping([]byte(src))
+	require.NoError(t, err)
+
+	out, err := appendSequenceItem([]byte(src), lookupNode(root, "resources"), "c.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, "resources:\n    -   a.yaml\n    -   b.yaml # last\n    -   c.yaml\nother: true\n", string(out))
+
+	flow := "resources: [a.yaml]\n"
+	root, err = parseYAMLMapping([]byte(flow))
+	require.NoError(t, err)
+	_, err = appendSequenceItem([]byte(flow), lookupNode(root, "resources"), "c.yaml")
+	assert.Error(t, err, "flow sequences are not supported")
+}
@@ -0,0 +1,166 @@
+// Package git reads the settings of local Git working trees, without running git.
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotRepository is returned when a path is not inside a Git working tree.
+var ErrNotRepository = errors.New("not inside a Git repository")
+
+// Repository is a local Git working tree.
+type Repository struct {
+	// Root is the absolute path of the working tree.
+	Root string
+	// RemoteURL is the URL of the origin remote, empty when there is none.
+	RemoteURL string
+	// Branch is the checked out branch, empty when HEAD is detached.
+	Branch string
+}
+
+// Discover returns the working tree a path is in, looking for .git in the path and its parents.
+func Discover(path string) (*Repository, error) {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+	for {
+		gitDir, err := resolveGitDir(filepath.Join(dir, ".git"))
+		if err == nil {
+			return open(dir, gitDir)
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, fmt.Errorf("%s: %w", path, ErrNotRepository)
+		}
+		dir = parent
+	}
+}
+
+// resolveGitDir returns the Git directory of a .git entry, which is a "gitdir:" file in
+// linked worktrees and submodules.
+func resolveGitDir(dotGit string) (string, error) {
+	info, err := os.Stat(dotGit)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return dotGit, nil
+	}
+	data, err := os.ReadFile(dotGit) // #nosec G304 -- .git of a directory given by the user
+	if err != nil {
+		return "", err
+	}
+	gitDir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:")
+	if !ok {
+		return "", fmt.Errorf("invalid %s file", dotGit)
+	}
+	gitDir = strings.TrimSpace(gitDir)
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(filepath.Dir(dotGit), gitDir)
+	}
+	return gitDir, nil
+}
+
+// open reads the origin remote and the branch of a working tree.
+func open(root, gitDir string) (*Repository, error) {
+	repo := &Repository{Root: root}
+
+	head, err := os.ReadFile(filepath.Join(gitDir, "HEAD")) // #nosec G304 -- inside the Git directory
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HEAD: %w", err)
+	}
+	if ref, ok := strings.CutPrefix(strings.TrimSpace(string(head)), "ref: refs/heads/"); ok {
+		repo.Branch = ref
+	}
+
+	// Linked worktrees share the configuration of the main repository
+	configDir := gitDir
+	if common, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil { // #nosec G304 -- inside the Git directory
+		configDir = strings.TrimSpace(string(common))
+		if !filepath.IsAbs(configDir) {
+			configDir = filepath.Join(gitDir, configDir)
+		}
+	}
+	config, err := os.ReadFile(filepath.Join(configDir, "config")) // #nosec G304 -- inside the Git directory
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read the Git configuration: %w", err)
+	}
+	repo.RemoteURL = remoteURL(config, "origin")
+	return repo, nil
+}
+
+// remoteURL returns the URL of a remote in a Git configuration file.
+func remoteURL(config []byte, remote string) string {
+	section := fmt.Sprintf(`remote "%s"`, remote)
+	inSection := false
+	scanner := bufio.NewScanner(bytes.NewReader(config))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			inSection = strings.TrimSpace(strings.Trim(line, "[]")) == section
+			continue
+		}
+		if !inSection {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if ok && strings.TrimSpace(key) == "url" {
+			return strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+	return ""
+}
+
+// RelativePath returns the slash-separated path of a file of the working tree relative to its
+// root, prefixed with "./" like the chart paths of Flux.
+func (r *Repository) RelativePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(r.Root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is not inside the Git repository %s", path, r.Root)
+	}
+	if rel == "." {
+		return "./", nil
+	}
+	return "./" + filepath.ToSlash(rel), nil
+}
+
+// FluxURL returns a remote URL in the form expected by a Flux GitRepository, which does not
+// accept the scp-like syntax of SSH remotes: git@github.com:org/repo.git becomes
+// ssh://git@github.com/org/repo.git.
+func FluxURL(remote string) string {
+	if strings.Contains(remote, "://") {
+		return remote
+	}
+	host, path, ok := strings.Cut(remote, ":")
+	if !ok || strings.Contains(host, "/") {
+		return remote
+	}
+	return "ssh://" + host + "/" + strings.TrimPrefix(path, "/")
+}
+
+// Name returns the name of a repository from its URL, such as "fleet" for
+// https://github.com/org/fleet.git.
+func Name(remote string) string {
+	remote = strings.TrimSuffix(strings.TrimRight(remote, "/"), ".git")
+	if i := strings.LastIndexAny(remote, "/:"); i >= 0 {
+		remote = remote[i+1:]
+	}
+	return remote
+}
@@ -0,0 +1,86 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testConfig = `[core]
+	bare = false
+[remote "upstream"]
+	url = https://github.com/upstream/fleet.git
+[remote "origin"]
+	url = git@github.com:acme/fleet.git
+	fetch = +refs/heads/*:refs/remotes/origin/*
+[branch "main"]
+	remote = origin
+`
+
+// newTestRepository creates a working tree with a chart directory.
+func newTestRepository(t *testing.T, head string) string {
+	t.Helper()
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, ".git"), 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(root, ".git", "HEAD"), []byte(head), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(root, ".git", "config"), []byte(testConfig), 0o600))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "charts", "foo"), 0o750))
+	return root
+}
+
+func TestDiscover(t *testing.T) {
+	root := newTestRepository(t, "ref: refs/heads/main\n")
+
+	repo, err := Discover(filepath.Join(root, "charts", "foo"))
+	require.NoError(t, err)
+	assert.Equal(t, root, repo.Root)
+	assert.Equal(t, "git@github.com:acme/fleet.git", repo.RemoteURL)
+	assert.Equal(t, "main", repo.Branch)
+
+	path, err := repo.RelativePath(filepath.Join(root, "charts", "foo"))
+	require.NoError(t, err)
+	assert.Equal(t, "./charts/foo", path)
+	_, err = repo.RelativePath(t.TempDir())
+	assert.ErrorContains(t, err, "is not inside the Git repository")
+
+	// A detached HEAD has no branch
+	require.NoError(t, os.WriteFile(filepath.Join(root, ".git", "HEAD"), []byte("0123456789abcdef0123456789abcdef01234567\n"), 0o600))
+	repo, err = Discover(root)
+	require.NoError(t, err)
+	assert.Empty(t, repo.Branch)
+
+	_, err = Discover(t.TempDir())
+	assert.ErrorIs(t, err, ErrNotRepository)
+}
+
+func TestDiscover_Worktree(t *testing.T) {
+	main := newTestRepository(t, "ref: refs/heads/main\n")
+	gitDir := filepath.Join(main, ".git", "worktrees", "feature")
+	require.NoError(t, os.MkdirAll(gitDir, 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(gitDir, "HEAD"), []byte("ref: refs/heads/feature\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(gitDir, "commondir"), []byte("../..\n"), 0o600))
+
+	worktree := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(worktree, ".git"), []byte("gitdir: "+gitDir+"\n"), 0o600))
+
+	repo, err := Discover(worktree)
+	require.NoError(t, err)
+	assert.Equal(t, "feature", repo.Branch)
+	assert.Equal(t, "git@github.com:acme/fleet.git", repo.RemoteURL)
+}
+
+func TestFluxURL(t *testing.T) {
+	assert.Equal(t, "ssh://git@github.com/acme/fleet.git", FluxURL("git@github.com:acme/fleet.git"))
+	assert.Equal(t, "https://github.com/acme/fleet.git", FluxURL("https://github.com/acme/fleet.git"))
+	assert.Equal(t, "ssh://git@gitlab.example.com:2222/acme/fleet.git", FluxURL("ssh://git@gitlab.example.com:2222/acme/fleet.git"))
+	assert.Equal(t, "/srv/git/fleet.git", FluxURL("/srv/git/fleet.git"))
+}
+
+func TestName(t *testing.T) {
+	assert.Equal(t, "fleet", Name("git@github.com:acme/fleet.git"))
+	assert.Equal(t, "fleet", Name("https://github.com/acme/fleet/"))
+	assert.Equal(t, "fleet", Name("fleet"))
+}
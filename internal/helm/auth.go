@@ -0,0 +1,173 @@
+package helm
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Auth holds the credentials and TLS settings used to reach a chart repository.
+type Auth struct {
+	Username string
+	Password string
+	// Token is a bearer token, sent instead of the username and password.
+	Token                 string
+	CAFile                string
+	CertFile              string
+	KeyFile               string
+	InsecureSkipTLSVerify bool
+}
+
+// HasCredentials reports whether a username or a token is set.
+func (a Auth) HasCredentials() bool {
+	return a.Username != "" || a.Token != ""
+}
+
+// HasTLS reports whether a custom CA, a client certificate or insecure TLS is configured.
+func (a Auth) HasTLS() bool {
+	return a.CAFile != "" || a.CertFile != "" || a.KeyFile != "" || a.InsecureSkipTLSVerify
+}
+
+// IsZero reports whether no setting is configured.
+func (a Auth) IsZero() bool {
+	return a == Auth{}
+}
+
+// WithDefaults fills the empty fields of a from fallback. The credentials are taken as a whole,
+// so that a username is never combined with the password of another source.
+func (a Auth) WithDefaults(fallback Auth) Auth {
+	if !a.HasCredentials() {
+		a.Username, a.Password, a.Token = fallback.Username, fallback.Password, fallback.Token
+	}
+	if a.CAFile == "" {
+		a.CAFile = fallback.CAFile
+	}
+	if a.CertFile == "" && a.KeyFile == "" {
+		a.CertFile, a.KeyFile = fallback.CertFile, fallback.KeyFile
+	}
+	a.InsecureSkipTLSVerify = a.InsecureSkipTLSVerify || fallback.InsecureSkipTLSVerify
+	return a
+}
+
+// authorize adds the credentials to a request.
+func (a Auth) authorize(req *http.Request) {
+	switch {
+	case a.Token != "":
+		req.Header.Set("Authorization", "Bearer "+a.Token)
+	case a.Username != "":
+		req.SetBasicAuth(a.Username, a.Password)
+	}
+}
+
+// httpClient returns an HTTP client using the TLS settings.
+func (a Auth) httpClient() (*http.Client, error) {
+	if !a.HasTLS() {
+		return defaultClient, nil
+	}
+
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: a.InsecureSkipTLSVerify, // #nosec G402 -- explicitly requested by the user
+	}
+	if a.CAFile != "" {
+		pem, err := os.ReadFile(a.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in CA file %s", a.CAFile)
+		}
+		config.RootCAs = pool
+	}
+	if a.CertFile != "" || a.KeyFile != "" {
+		if a.CertFile == "" || a.KeyFile == "" {
+			return nil, fmt.Errorf("a client certificate requires both a certificate and a key file")
+		}
+		cert, err := tls.LoadX509KeyPair(a.CertFile, a.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	transport := newTransport()
+	transport.TLSClientConfig = config
+	return &http.Client{Transport: transport}, nil
+}
+
+// repositoryAuth holds the settings of the repositories registered with SetAuth, by repository
+// location, the host and path of the repository URL.
+var repositoryAuth = struct {
+	sync.Mutex
+	byLocation map[string]Auth
+	clients    map[string]*http.Client
+}{byLocation: make(map[string]Auth), clients: make(map[string]*http.Client)}
+
+// SetAuth registers the settings used for every request under repoURL, such as the index, the
+// chart tarballs it serves and, for oci:// URLs, the registry API of its charts. Repositories
+// sharing a host, such as ghcr.io/org-a and ghcr.io/org-b, keep their own settings.
+// Credentials are not sent to other hosts, e.g. when charts are served from a CDN.
+func SetAuth(repoURL string, auth Auth) error {
+	location, err := repositoryLocation(repoURL)
+	if err != nil {
+		return err
+	}
+	client, err := auth.httpClient()
+	if err != nil {
+		return err
+	}
+
+	repositoryAuth.Lock()
+	defer repositoryAuth.Unlock()
+	repositoryAuth.byLocation[location] = auth
+	repositoryAuth.clients[location] = client
+	return nil
+}
+
+// authFor returns the settings of the registered repository with the longest location containing
+// location, the host and path of a request, and the HTTP client using them. The fallback client is
+// returned for locations outside of every registered repository.
+func authFor(location string, fallback *http.Client) (Auth, *http.Client) {
+	repositoryAuth.Lock()
+	defer repositoryAuth.Unlock()
+	match, found := "", false
+	for prefix := range repositoryAuth.byLocation {
+		if (location == prefix || strings.HasPrefix(location, prefix+"/")) && (!found || len(prefix) > len(match)) {
+			match, found = prefix, true
+		}
+	}
+	if !found {
+		return Auth{}, fallback
+	}
+	return repositoryAuth.byLocation[match], repositoryAuth.clients[match]
+}
+
+// urlLocation returns the host, with its port, and the path of a URL, without trailing slash.
+func urlLocation(u *url.URL) string {
+	return u.Host + strings.TrimSuffix(u.Path, "/")
+}
+
+// repositoryLocation returns the location of a http(s):// or oci:// repository URL, its host, with
+// its port, and its path.
+func repositoryLocation(repoURL string) (string, error) {
+	if IsOCI(repoURL) {
+		repoURL = "https://" + strings.TrimPrefix(repoURL, ociScheme)
+	}
+	parsed, err := url.Parse(repoURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid repository URL: %w", err)
+	}
+	if parsed.Host == "" {
+		return "", fmt.Errorf("invalid repository URL %q: missing host", repoURL)
+	}
+	return urlLocation(parsed), nil
+}
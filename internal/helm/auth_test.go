@@ -0,0 +1,181 @@
+package helm
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newPrivateRepository starts a chart repository behind basic auth, with a certificate of its own CA,
+// serving chart URLs relative to the repository as ChartMuseum does. It returns the repository URL
+// and the path of its CA certificate.
+func newPrivateRepository(t *testing.T) (string, string) {
+	t.Helper()
+	chart := chartTarball(t, map[string]string{"app/Chart.yaml": "name: app\nversion: 1.0.0\n", "app/values.yaml": "replicaCount: 1\n"})
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "robot" || password != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/charts/index.yaml":
+			_, _ = w.Write([]byte("entries:\n  app:\n    - version: 1.0.0\n      urls: [charts/app-1.0.0.tgz]\n"))
+		case "/charts/charts/app-1.0.0.tgz":
+			_, _ = w.Write(chart)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	require.NoError(t, os.WriteFile(caFile, certPEM, 0o600))
+
+	repoURL := server.URL + "/charts"
+	t.Cleanup(func() {
+		location, _ := repositoryLocation(repoURL)
+		repositoryAuth.Lock()
+		delete(repositoryAuth.byLocation, location)
+		delete(repositoryAuth.clients, location)
+		repositoryAuth.Unlock()
+	})
+	return repoURL, caFile
+}
+
+func TestSetAuth_PrivateRepository(t *testing.T) {
+	repoURL, caFile := newPrivateRepository(t)
+
+	// The certificate of the private CA is not trusted by default
+	_, err := fetchIndexYAML(context.Background(), repoURL)
+	require.Error(t, err)
+
+	require.NoError(t, SetAuth(repoURL, Auth{CAFile: caFile}))
+	_, err = fetchIndexYAML(context.Background(), repoURL)
+	assert.ErrorContains(t, err, "status 401")
+
+	require.NoError(t, SetAuth(repoURL, Auth{Username: "robot", Password: "s3cret", CAFile: caFile}))
+	idx, err := fetchIndexYAML(context.Background(), repoURL)
+	require.NoError(t, err)
+	assert.Contains(t, idx.Entries, "app")
+
+	values, err := DownloadAndExtractValuesYAML(context.Background(), repoURL, "app", "1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, "replicaCount: 1\n", values)
+}
+
+func TestSetAuth_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	emptyCA := filepath.Join(dir, "empty.crt")
+	require.NoError(t, os.WriteFile(emptyCA, []byte("not a certificate"), 0o600))
+
+	tests := []struct {
+		name    string
+		repoURL string
+		auth    Auth
+		message string
+	}{
+		{"missing host", "charts", Auth{}, "missing host"},
+		{"missing CA file", "https://charts.example.com", Auth{CAFile: filepath.Join(dir, "missing.crt")}, "failed to read CA file"},
+		{"CA file without certificate", "https://charts.example.com", Auth{CAFile: emptyCA}, "no certificate found"},
+		{"certificate without key", "https://charts.example.com", Auth{CertFile: emptyCA}, "requires both a certificate and a key file"},
+		{"invalid client certificate", "https://charts.example.com", Auth{CertFile: emptyCA, KeyFile: emptyCA}, "failed to load client certificate"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := SetAuth(tt.repoURL, tt.auth)
+			assert.ErrorContains(t, err, tt.message)
+		})
+	}
+}
+
+func TestAuth_WithDefaults(t *testing.T) {
+	fallback := Auth{Username: "helm", Password: "from-helm", CAFile: "helm-ca.crt", CertFile: "tls.crt", KeyFile: "tls.key"}
+
+	// A token replaces the fallback username and password as a whole
+	auth := Auth{Token: "t0ken"}.WithDefaults(fallback)
+	assert.Equal(t, Auth{Token: "t0ken", CAFile: "helm-ca.crt", CertFile: "tls.crt", KeyFile: "tls.key"}, auth)
+
+	auth = Auth{CAFile: "custom-ca.crt"}.WithDefaults(fallback)
+	assert.Equal(t, "helm", auth.Username)
+	assert.Equal(t, "from-helm", auth.Password)
+	assert.Equal(t, "custom-ca.crt", auth.CAFile)
+
+	assert.True(t, Auth{}.IsZero())
+	assert.False(t, auth.IsZero())
+}
+
+func TestAuth_Authorize(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "https://charts.example.com/index.yaml", http.NoBody)
+	Auth{Username: "robot", Password: "s3cret"}.authorize(req)
+	username, password, ok := req.BasicAuth()
+	assert.True(t, ok)
+	assert.Equal(t, "robot", username)
+	assert.Equal(t, "s3cret", password)
+
+	req = httptest.NewRequest(http.MethodGet, "https://charts.example.com/index.yaml", http.NoBody)
+	Auth{Username: "robot", Token: "t0ken"}.authorize(req)
+	assert.True(t, strings.HasPrefix(req.Header.Get("Authorization"), "Bearer t0ken"))
+}
+
+func TestRepositoryLocation(t *testing.T) {
+	location, err := repositoryLocation("oci://registry.example.com:5000/charts")
+	require.NoError(t, err)
+	assert.Equal(t, "registry.example.com:5000/charts", location)
+
+	location, err = repositoryLocation("https://charts.example.com/stable/")
+	require.NoError(t, err)
+	assert.Equal(t, "charts.example.com/stable", location)
+
+	location, err = repositoryLocation("https://charts.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "charts.example.com", location)
+}
+
+func TestAuthFor(t *testing.T) {
+	repositories := map[string]Auth{
+		"oci://ghcr.io/org-a":                    {Username: "a"},
+		"oci://ghcr.io/org-b":                    {Username: "b"},
+		"https://charts.example.com":             {Username: "root"},
+		"https://charts.example.com/tenant-a":    {Username: "tenant-a"},
+		"https://charts.example.com/tenant-a/v2": {Username: "tenant-a-v2"},
+	}
+	for repoURL, auth := range repositories {
+		require.NoError(t, SetAuth(repoURL, auth))
+	}
+	t.Cleanup(func() {
+		repositoryAuth.Lock()
+		defer repositoryAuth.Unlock()
+		for repoURL := range repositories {
+			location, _ := repositoryLocation(repoURL)
+			delete(repositoryAuth.byLocation, location)
+			delete(repositoryAuth.clients, location)
+		}
+	})
+
+	tests := map[string]string{
+		"ghcr.io/org-a/charts/podinfo":               "a",
+		"ghcr.io/org-b/podinfo":                      "b",
+		"ghcr.io/org-ab/podinfo":                     "",
+		"charts.example.com/index.yaml":              "root",
+		"charts.example.com/tenant-a":                "tenant-a",
+		"charts.example.com/tenant-a/charts/app.tgz": "tenant-a",
+		"charts.example.com/tenant-a/v2/index.yaml":  "tenant-a-v2",
+		"charts.example.com/tenant-b/index.yaml":     "root",
+		"cdn.example.com/tenant-a/charts/app.tgz":    "",
+	}
+	for location, username := range tests {
+		auth, client := authFor(location, defaultClient)
+		assert.Equal(t, username, auth.Username, location)
+		assert.Equal(t, defaultClient, client, location)
+	}
+}
@@ -0,0 +1,278 @@
+package helm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultCacheTTL is how long a cached repository index is used before it is revalidated.
+const DefaultCacheTTL = time.Hour
+
+// CacheDirEnv overrides the cache directory.
+const CacheDirEnv = "FLUX_APP_GENERATOR_CACHE_DIR"
+
+// ErrNotCached is returned in offline mode for indexes and charts that are not in the cache.
+var ErrNotCached = errors.New("not in the cache")
+
+// Cache stores repository indexes and chart tarballs on disk so that they are downloaded once.
+// Indexes are revalidated with their ETag or Last-Modified date once older than the TTL; chart
+// versions never change, so their tarballs are kept until the cache is cleaned.
+// Indexes are also kept parsed in memory for the lifetime of the process.
+type Cache struct {
+	dir     string // Empty for a memory-only cache
+	ttl     time.Duration
+	offline bool
+	now     func() time.Time
+
+	mu      sync.Mutex
+	indexes map[string]*IndexYAML
+	loads   singleflight.Group // Index loads in flight, by repository URL
+}
+
+// indexMeta holds the revalidation data of a cached index.
+type indexMeta struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	FetchedAt    time.Time `json:"fetchedAt"`
+}
+
+// indexDownload fetches an index, conditionally when meta holds validators. notModified is set
+// when the cached copy is still current.
+type indexDownload func(meta indexMeta) (body []byte, fresh indexMeta, notModified bool, err error)
+
+// NewCache returns a cache storing its files in dir, or only in memory when dir is empty.
+// In offline mode nothing is downloaded and only cached indexes and charts are available.
+func NewCache(dir string, ttl time.Duration, offline bool) *Cache {
+	return &Cache{dir: dir, ttl: ttl, offline: offline, now: time.Now, indexes: make(map[string]*IndexYAML)}
+}
+
+// DefaultCacheDir returns the cache directory: $FLUX_APP_GENERATOR_CACHE_DIR, or
+// flux-app-generator in the user cache directory.
+func DefaultCacheDir() (string, error) {
+	if dir := os.Getenv(CacheDirEnv); dir != "" {
+		return dir, nil
+	}
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate the cache directory: %w", err)
+	}
+	return filepath.Join(base, "flux-app-generator"), nil
+}
+
+// Dir returns the directory of the cache.
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// Clean removes every cached file.
+func (c *Cache) Clean() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.indexes = make(map[string]*IndexYAML)
+	if c.dir == "" {
+		return nil
+	}
+	if err := os.RemoveAll(c.dir); err != nil {
+		return fmt.Errorf("failed to clean cache %s: %w", c.dir, err)
+	}
+	return nil
+}
+
+// isOffline reports whether downloads are disabled. A nil cache is online.
+func (c *Cache) isOffline() bool {
+	return c != nil && c.offline
+}
+
+// index returns the index of a repository, downloading it only when the cached copy is missing
+// or expired and no longer current. A nil cache always downloads.
+func (c *Cache) index(repoURL string, download indexDownload) (*IndexYAML, error) {
+	if c == nil {
+		body, _, _, err := download(indexMeta{})
+		if err != nil {
+			return nil, err
+		}
+		return parseIndex(body)
+	}
+
+	if idx, ok := c.loaded(repoURL); ok {
+		return idx, nil
+	}
+	// Concurrent fetches of an index share its download, which does not hold up other repositories
+	idx, err, _ := c.loads.Do(repoURL, func() (interface{}, error) {
+		return c.load(repoURL, download)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return idx.(*IndexYAML), nil
+}
+
+// loaded returns the index of a repository already parsed by this process.
+func (c *Cache) loaded(repoURL string) (*IndexYAML, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	idx, ok := c.indexes[repoURL]
+	return idx, ok
+}
+
+// load reads the index of a repository from disk, downloading it when missing or expired, and
+// keeps it parsed in memory.
+func (c *Cache) load(repoURL string, download indexDownload) (*IndexYAML, error) {
+	if idx, ok := c.loaded(repoURL); ok {
+		// Loaded by a fetch that completed in the meantime
+		return idx, nil
+	}
+
+	path := c.path("indexes", repoURL, ".yaml")
+	var meta indexMeta
+	var body []byte
+	cached := false
+	if path != "" {
+		if data, err := os.ReadFile(path + ".json"); err == nil && json.Unmarshal(data, &meta) == nil {
+			body, err = os.ReadFile(path) // #nosec G304 -- path in the cache directory
+			cached = err == nil
+		}
+	}
+
+	switch {
+	case c.offline:
+		if !cached {
+			return nil, fmt.Errorf("index of %s: %w, run once without --offline", repoURL, ErrNotCached)
+		}
+	case cached && c.now().Sub(meta.FetchedAt) < c.ttl:
+	default:
+		validators := indexMeta{URL: repoURL}
+		if cached {
+			validators = meta
+		}
+		fresh, freshMeta, notModified, err := download(validators)
+		if err != nil {
+			return nil, err
+		}
+		if !notModified {
+			body, meta = fresh, freshMeta
+		}
+		meta.URL, meta.FetchedAt = repoURL, c.now()
+		c.store(path, body, meta, !notModified)
+	}
+
+	idx, err := parseIndex(body)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.indexes[repoURL] = idx
+	return idx, nil
+}
+
+// store writes an index and its metadata. The cache is an optimization, so failing to write it
+// is not an error.
+func (c *Cache) store(path string, body []byte, meta indexMeta, writeBody bool) {
+	if path == "" {
+		return
+	}
+	if writeBody {
+		if err := writeFileAtomic(path, body); err != nil {
+			return
+		}
+	}
+	if data, err := json.Marshal(meta); err == nil {
+		_ = writeFileAtomic(path+".json", data)
+	}
+}
+
+// chart returns a chart tarball, downloading it only when it is not cached. A nil cache always downloads.
+func (c *Cache) chart(key string, download func() ([]byte, error)) ([]byte, error) {
+	if c == nil {
+		return download()
+	}
+	path := c.path("charts", key, ".tgz")
+	if path != "" {
+		if data, err := os.ReadFile(path); err == nil { // #nosec G304 -- path in the cache directory
+			return data, nil
+		}
+	}
+	if c.offline {
+		return nil, fmt.Errorf("chart %s: %w, run once without --offline", key, ErrNotCached)
+	}
+	data, err := download()
+	if err != nil {
+		return nil, err
+	}
+	if path != "" {
+		_ = writeFileAtomic(path, data)
+	}
+	return data, nil
+}
+
+// path returns the file caching key in a subdirectory, or "" for a memory-only cache.
+func (c *Cache) path(kind, key, ext string) string {
+	if c.dir == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, kind, hex.EncodeToString(sum[:])+ext)
+}
+
+// writeFileAtomic writes a file through a temporary file, so that readers never see a partial file.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// parseIndex parses a repository index.yaml.
+func parseIndex(body []byte) (*IndexYAML, error) {
+	var idx IndexYAML
+	if err := yaml.Unmarshal(body, &idx); err != nil {
+		return nil, fmt.Errorf("failed to parse index.yaml: %w", err)
+	}
+	return &idx, nil
+}
+
+// activeCache is the cache used by the package functions, nil when caching is disabled.
+var activeCache struct {
+	sync.Mutex
+	cache *Cache
+}
+
+// SetCache sets the cache used to fetch indexes and charts. A nil cache disables caching.
+func SetCache(cache *Cache) {
+	activeCache.Lock()
+	defer activeCache.Unlock()
+	activeCache.cache = cache
+}
+
+// currentCache returns the cache set with SetCache.
+func currentCache() *Cache {
+	activeCache.Lock()
+	defer activeCache.Unlock()
+	return activeCache.cache
+}
@@ -0,0 +1,218 @@
+package helm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const cacheTestIndex = `apiVersion: v1
+entries:
+  podinfo:
+    - name: podinfo
+      version: 6.9.0
+      urls:
+        - podinfo-6.9.0.tgz
+`
+
+// newCacheTestServer serves a repository index with an ETag, answering conditional requests
+// with 304, and counts the index downloads and chart downloads.
+func newCacheTestServer(t *testing.T, chart []byte) (server *httptest.Server, indexes, notModified, charts *atomic.Int32) {
+	t.Helper()
+	indexes, notModified, charts = &atomic.Int32{}, &atomic.Int32{}, &atomic.Int32{}
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/index.yaml":
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				notModified.Add(1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			indexes.Add(1)
+			w.Header().Set("ETag", `"v1"`)
+			_, _ = w.Write([]byte(cacheTestIndex))
+		case "/podinfo-6.9.0.tgz":
+			charts.Add(1)
+			_, _ = w.Write(chart)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, indexes, notModified, charts
+}
+
+// useCache sets the package cache for the duration of a test.
+func useCache(t *testing.T, cache *Cache) {
+	t.Helper()
+	SetCache(cache)
+	t.Cleanup(func() {
+		SetCache(nil)
+	})
+}
+
+func TestCache_Index(t *testing.T) {
+	server, indexes, notModified, _ := newCacheTestServer(t, nil)
+	dir := t.TempDir()
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	newCache := func(offline bool) *Cache {
+		cache := NewCache(dir, time.Hour, offline)
+		cache.now = func() time.Time { return now }
+		return cache
+	}
+
+	// The first fetch downloads the index, later ones in the same process reuse it
+	useCache(t, newCache(false))
+	for i := 0; i < 2; i++ {
+		idx, err := fetchIndexYAML(context.Background(), server.URL)
+		require.NoError(t, err)
+		assert.Len(t, idx.Entries["podinfo"], 1)
+	}
+	assert.Equal(t, int32(1), indexes.Load())
+
+	// A fresh copy on disk is used without a request
+	useCache(t, newCache(false))
+	_, err := fetchIndexYAML(context.Background(), server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), indexes.Load())
+	assert.Equal(t, int32(0), notModified.Load())
+
+	// An expired copy is revalidated with its ETag
+	now = now.Add(2 * time.Hour)
+	useCache(t, newCache(false))
+	idx, err := fetchIndexYAML(context.Background(), server.URL)
+	require.NoError(t, err)
+	assert.Len(t, idx.Entries["podinfo"], 1)
+	assert.Equal(t, int32(1), indexes.Load())
+	assert.Equal(t, int32(1), notModified.Load())
+
+	// Revalidation renewed the copy
+	useCache(t, newCache(false))
+	_, err = fetchIndexYAML(context.Background(), server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), notModified.Load())
+
+	// Offline mode uses the cached copy however old, and fails for unknown repositories
+	now = now.Add(100 * time.Hour)
+	useCache(t, newCache(true))
+	_, err = fetchIndexYAML(context.Background(), server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), notModified.Load())
+	_, err = fetchIndexYAML(context.Background(), server.URL+"/other")
+	assert.True(t, errors.Is(err, ErrNotCached), "unexpected error: %v", err)
+}
+
+func TestCache_IndexConcurrent(t *testing.T) {
+	cache := NewCache("", time.Hour, false)
+	index := []byte("entries:\n  podinfo:\n    - version: 6.5.0\n")
+
+	// A repository that does not answer holds up neither the others nor the cache
+	release := make(chan struct{})
+	started := make(chan struct{})
+	var hung atomic.Int32
+	done := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			_, err := cache.index("https://hung.example.com", func(indexMeta) ([]byte, indexMeta, bool, error) {
+				if hung.Add(1) == 1 {
+					close(started)
+				}
+				<-release
+				return index, indexMeta{}, false, nil
+			})
+			done <- err
+		}()
+	}
+	<-started
+
+	idx, err := cache.index("https://charts.example.com", func(indexMeta) ([]byte, indexMeta, bool, error) {
+		return index, indexMeta{}, false, nil
+	})
+	require.NoError(t, err)
+	assert.Len(t, idx.Entries["podinfo"], 1)
+
+	// Concurrent fetches of the same index share one download
+	close(release)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, <-done)
+	}
+	assert.Equal(t, int32(1), hung.Load())
+}
+
+func TestCache_Chart(t *testing.T) {
+	chart := chartTarball(t, map[string]string{"podinfo/Chart.yaml": "name: podinfo\nversion: 6.9.0\n", "podinfo/values.yaml": "replicaCount: 1\n"})
+	server, _, _, charts := newCacheTestServer(t, chart)
+	dir := t.TempDir()
+
+	useCache(t, NewCache(dir, time.Hour, false))
+	for i := 0; i < 2; i++ {
+		values, err := DownloadAndExtractValuesYAML(context.Background(), server.URL, "podinfo", "6.9.0")
+		require.NoError(t, err)
+		assert.Equal(t, "replicaCount: 1\n", values)
+	}
+	assert.Equal(t, int32(1), charts.Load())
+
+	// Cached charts are available offline, other versions are not
+	useCache(t, NewCache(dir, time.Hour, true))
+	values, err := DownloadAndExtractValuesYAML(context.Background(), server.URL, "podinfo", "6.9.0")
+	require.NoError(t, err)
+	assert.Equal(t, "replicaCount: 1\n", values)
+	_, err = DownloadChart(context.Background(), server.URL, "podinfo", "6.8.0")
+	assert.True(t, errors.Is(err, ErrNotCached), "unexpected error: %v", err)
+	assert.Equal(t, int32(1), charts.Load())
+}
+
+func TestCache_OfflineOCI(t *testing.T) {
+	useCache(t, NewCache(t.TempDir(), time.Hour, true))
+	_, err := NewVersionFetcher().FetchChartVersions(context.Background(), "oci://registry.example.com/charts", "podinfo")
+	assert.True(t, errors.Is(err, ErrNotCached), "unexpected error: %v", err)
+}
+
+func TestCache_MemoryOnly(t *testing.T) {
+	server, indexes, _, _ := newCacheTestServer(t, nil)
+
+	useCache(t, NewCache("", time.Hour, false))
+	for i := 0; i < 2; i++ {
+		_, err := fetchIndexYAML(context.Background(), server.URL)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(1), indexes.Load())
+}
+
+func TestCache_Clean(t *testing.T) {
+	server, indexes, _, _ := newCacheTestServer(t, nil)
+	dir := filepath.Join(t.TempDir(), "cache")
+	cache := NewCache(dir, time.Hour, false)
+	useCache(t, cache)
+
+	_, err := fetchIndexYAML(context.Background(), server.URL)
+	require.NoError(t, err)
+	_, err = os.Stat(dir)
+	require.NoError(t, err)
+
+	require.NoError(t, cache.Clean())
+	_, err = os.Stat(dir)
+	assert.True(t, os.IsNotExist(err))
+
+	// The in-memory copy is dropped as well
+	_, err = fetchIndexYAML(context.Background(), server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), indexes.Load())
+}
+
+func TestDefaultCacheDir(t *testing.T) {
+	t.Setenv(CacheDirEnv, "/tmp/flux-cache")
+	dir, err := DefaultCacheDir()
+	require.NoError(t, err)
+	assert.Equal(t, "/tmp/flux-cache", dir)
+}
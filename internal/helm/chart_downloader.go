@@ -2,73 +2,169 @@
 package helm
 
 import (
-	"archive/tar"
-	"compress/gzip"
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"path"
 	"strings"
 )
 
 // DownloadAndExtractValuesYAML downloads the chart tarball and extracts values.yaml as a string.
-func DownloadAndExtractValuesYAML(repoURL, chartName, chartVersion string) (string, error) {
-	idx, err := fetchIndexYAML(repoURL)
+// Charts of OCI registries (oci:// URLs) are pulled through the OCI distribution API.
+func DownloadAndExtractValuesYAML(ctx context.Context, repoURL, chartName, chartVersion string) (string, error) {
+	data, err := DownloadChart(ctx, repoURL, chartName, chartVersion)
 	if err != nil {
 		return "", err
 	}
+	return extractValuesYAML(bytes.NewReader(data))
+}
+
+// DownloadChart returns the gzipped tarball of a chart version, through the cache set with SetCache.
+// Charts of OCI registries (oci:// URLs) are pulled through the OCI distribution API.
+// Tarballs are checked against the digest of the repository index and, when a keyring is set
+// with SetKeyring, against their provenance file, which they must have; failed checks return
+// ErrVerification.
+func DownloadChart(ctx context.Context, repoURL, chartName, chartVersion string) ([]byte, error) {
+	key := strings.TrimSuffix(repoURL, "/") + "/" + chartName + "@" + chartVersion
+
+	// The index is only fetched for what is not cached
+	var chartURL *url.URL
+	var digest string
+	resolveURL := func() error {
+		if chartURL != nil {
+			return nil
+		}
+		var err error
+		chartURL, digest, err = resolveChartURL(ctx, repoURL, chartName, chartVersion)
+		return err
+	}
+	data, err := currentCache().chart(key, func() ([]byte, error) {
+		if IsOCI(repoURL) {
+			return defaultOCIClient.pullChart(ctx, repoURL, chartName, chartVersion)
+		}
+		if err := resolveURL(); err != nil {
+			return nil, err
+		}
+		return download(ctx, chartURL, digest)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	keyring := currentKeyring()
+	if keyring == nil {
+		return data, nil
+	}
+	// Like `helm pull --verify`, a chart without provenance fails, so that stripping the signature
+	// does not skip the verification. Only provenance files found are cached.
+	prov, err := currentCache().chart(key+".prov", func() ([]byte, error) {
+		if IsOCI(repoURL) {
+			return defaultOCIClient.pullProvenance(ctx, repoURL, chartName, chartVersion)
+		}
+		if err := resolveURL(); err != nil {
+			return nil, err
+		}
+		provURL := *chartURL
+		provURL.Path += ".prov"
+		return download(ctx, &provURL, "")
+	})
+	if errors.Is(err, errNotFound) || err == nil && len(prov) == 0 {
+		return nil, fmt.Errorf("%w: chart %s %s has no provenance file", ErrVerification, chartName, chartVersion)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to download provenance: %w", err)
+	}
+	chartFile := chartName + "-" + chartVersion + ".tgz"
+	if chartURL != nil {
+		chartFile = path.Base(chartURL.Path)
+	}
+	if _, err := VerifyProvenance(keyring, prov, chartFile, data); err != nil {
+		return nil, fmt.Errorf("chart %s %s: %w", chartName, chartVersion, err)
+	}
+	return data, nil
+}
+
+// resolveChartURL returns the URL of a chart tarball listed in the repository index, and the
+// SHA-256 digest of the tarball when the index has one.
+func resolveChartURL(ctx context.Context, repoURL, chartName, chartVersion string) (*url.URL, string, error) {
+	idx, err := fetchIndexYAML(ctx, repoURL)
+	if err != nil {
+		return nil, "", err
+	}
 	chartEntries, ok := idx.Entries[chartName]
 	if !ok {
-		return "", fmt.Errorf("chart '%s' not found in repository", chartName)
-	}
-	var chartURL string
-	for _, entry := range chartEntries {
-		if entry.Version == chartVersion {
-			if len(entry.URLs) == 0 {
-				return "", fmt.Errorf("no tarball URL found for chart %s version %s", chartName, chartVersion)
-			}
-			chartURL = entry.URLs[0]
+		return nil, "", fmt.Errorf("chart '%s' not found in repository", chartName)
+	}
+	var entry *IndexEntry
+	for i := range chartEntries {
+		if chartEntries[i].Version == chartVersion {
+			entry = &chartEntries[i]
 			break
 		}
 	}
-	if chartURL == "" {
-		return "", fmt.Errorf("version %s not found for chart %s", chartVersion, chartName)
+	if entry == nil {
+		return nil, "", fmt.Errorf("version %s not found for chart %s", chartVersion, chartName)
+	}
+	if len(entry.URLs) == 0 {
+		return nil, "", fmt.Errorf("no tarball URL found for chart %s version %s", chartName, chartVersion)
+	}
+	// Chart URLs may be relative to the repository, as served by ChartMuseum
+	base, err := url.Parse(strings.TrimSuffix(repoURL, "/") + "/")
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid repository URL: %w", err)
+	}
+	resolved, err := base.Parse(entry.URLs[0])
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid chart URL %q: %w", entry.URLs[0], err)
 	}
-	resp, err := http.NewRequestWithContext(context.Background(), http.MethodGet, chartURL, http.NoBody)
+	return resolved, entry.Digest, nil
+}
+
+// errNotFound is returned by download for status 404.
+var errNotFound = errors.New("not found")
+
+// download fetches a chart tarball or provenance file, checking its hex SHA-256 digest when given.
+func download(ctx context.Context, location *url.URL, digest string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location.String(), http.NoBody)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request for chart: %w", err)
+		return nil, fmt.Errorf("failed to create request for chart: %w", err)
 	}
-	client := &http.Client{}
-	resp2, err := client.Do(resp)
+	auth, client := authFor(urlLocation(location), defaultClient)
+	auth.authorize(req)
+	resp, err := send(ctx, client, req)
 	if err != nil {
-		return "", fmt.Errorf("failed to download chart: %w", err)
+		return nil, fmt.Errorf("failed to download chart: %w", err)
 	}
 	defer func() {
-		if closeErr := resp2.Body.Close(); closeErr != nil {
-			// Log or handle close error appropriately in production
-			_ = closeErr
-		}
+		_ = resp.Body.Close()
 	}()
-	if resp2.StatusCode != 200 {
-		return "", fmt.Errorf("failed to download chart: status %d", resp2.StatusCode)
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("failed to download %s: %w", path.Base(location.Path), errNotFound)
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("failed to download chart: status %d", resp.StatusCode)
 	}
-	gzr, err := gzip.NewReader(resp2.Body)
+	data, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to create gzip reader: %w", err)
+		return nil, fmt.Errorf("failed to download chart: %w", err)
 	}
-	tr := tar.NewReader(gzr)
-	for {
-		hdr, err := tr.Next()
-		if err != nil {
-			break
-		}
-		if strings.HasSuffix(hdr.Name, "values.yaml") {
-			data, err := io.ReadAll(tr)
-			if err != nil {
-				return "", fmt.Errorf("failed to read values.yaml: %w", err)
-			}
-			return string(data), nil
+	if digest != "" {
+		if err := verifyDigest(data, "sha256:"+digest); err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrVerification, path.Base(location.Path), err)
 		}
 	}
-	return "", fmt.Errorf("values.yaml not found in chart")
+	return data, nil
+}
+
+// extractValuesYAML reads the values.yaml of the chart itself from a gzipped chart tarball.
+func extractValuesYAML(r io.Reader) (string, error) {
+	chart, err := LoadChart(r)
+	if err != nil {
+		return "", err
+	}
+	return chart.ValuesYAML()
 }
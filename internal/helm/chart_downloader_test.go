@@ -1,6 +1,7 @@
 package helm
 
 import (
+	"context"
 	"strings"
 	"testing"
 )
@@ -50,7 +51,7 @@ func TestDownloadAndExtractValuesYAML(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			values, err := DownloadAndExtractValuesYAML(tt.repoURL, tt.chartName, tt.chartVersion)
+			values, err := DownloadAndExtractValuesYAML(context.Background(), tt.repoURL, tt.chartName, tt.chartVersion)
 
 			if tt.expectError && err == nil {
 				t.Errorf("Expected error but got none")
@@ -83,6 +84,7 @@ func TestDownloadAndExtractValuesYAML(t *testing.T) {
 func TestDownloadAndExtractValuesYAML_ContentValidation(t *testing.T) {
 	// Test with a known working chart to validate content
 	values, err := DownloadAndExtractValuesYAML(
+		context.Background(),
 		"https://vantage-sh.github.io/helm-charts",
 		"vantage-kubernetes-agent",
 		"1.1.2",
@@ -0,0 +1,347 @@
+package helm
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/semver"
+	"github.com/EffectiveSloth/flux-app-generator/internal/values"
+)
+
+// maxChartSize limits the uncompressed size of a chart tarball, subcharts included.
+const maxChartSize = 100 << 20
+
+// Chart holds the files describing a chart: its metadata, default values, values schema,
+// README and CRDs, and the same for each subchart vendored in its charts/ directory.
+type Chart struct {
+	Metadata ChartMetadata
+	// Values is the values.yaml of the chart itself, not one of its subcharts.
+	Values string
+	// Schema is the values.schema.json of the chart, nil when it has none.
+	Schema []byte
+	// README is the README of the chart, empty when it has none.
+	README string
+	// CRDs are the files of the crds/ directory, sorted by name.
+	CRDs []ChartFile
+	// Subcharts are the charts vendored in the charts/ directory, by chart name.
+	Subcharts map[string]*Chart
+
+	hasValues bool
+}
+
+// ChartFile is a file of a chart, named by its path in the chart.
+type ChartFile struct {
+	Name string
+	Data []byte
+}
+
+// ChartMetadata is the content of Chart.yaml.
+type ChartMetadata struct {
+	APIVersion   string            `yaml:"apiVersion"`
+	Name         string            `yaml:"name"`
+	Version      string            `yaml:"version"`
+	AppVersion   string            `yaml:"appVersion"`
+	Description  string            `yaml:"description"`
+	Type         string            `yaml:"type"`
+	KubeVersion  string            `yaml:"kubeVersion"`
+	Deprecated   bool              `yaml:"deprecated"`
+	Dependencies []ChartDependency `yaml:"dependencies"`
+	Annotations  map[string]string `yaml:"annotations"`
+}
+
+// ChartDependency is a subchart listed in Chart.yaml, or in requirements.yaml for apiVersion v1 charts.
+type ChartDependency struct {
+	Name       string   `yaml:"name"`
+	Version    string   `yaml:"version"`
+	Repository string   `yaml:"repository"`
+	Condition  string   `yaml:"condition"`
+	Tags       []string `yaml:"tags"`
+	Alias      string   `yaml:"alias"`
+}
+
+// Key returns the key of the subchart values in the values of the parent chart.
+func (d ChartDependency) Key() string {
+	if d.Alias != "" {
+		return d.Alias
+	}
+	return d.Name
+}
+
+// ConditionPath returns the values key path enabling the subchart, split on dots, or nil when
+// it has no condition. Of several comma-separated conditions, the first is the one set when
+// toggling the subchart, as Helm uses the first condition found in the values.
+func (d ChartDependency) ConditionPath() []string {
+	condition, _, _ := strings.Cut(d.Condition, ",")
+	if condition = strings.TrimSpace(condition); condition == "" {
+		return nil
+	}
+	return strings.Split(condition, ".")
+}
+
+// InspectChart downloads a chart version and loads its metadata and files.
+func InspectChart(ctx context.Context, repoURL, chartName, chartVersion string) (*Chart, error) {
+	data, err := DownloadChart(ctx, repoURL, chartName, chartVersion)
+	if err != nil {
+		return nil, err
+	}
+	return LoadChart(bytes.NewReader(data))
+}
+
+// LoadChart loads a gzipped chart tarball. Its files are expected in a single top-level
+// directory, as packaged by helm package.
+func LoadChart(r io.Reader) (*Chart, error) {
+	gzr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+	}
+	files := make(map[string][]byte)
+	root := ""
+	tr := tar.NewReader(io.LimitReader(gzr, maxChartSize))
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read chart archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "/"))
+		dir, rest, ok := strings.Cut(name, "/")
+		if !ok || strings.HasPrefix(name, "../") {
+			continue
+		}
+		if root == "" {
+			root = dir
+		}
+		if dir != root {
+			return nil, fmt.Errorf("chart archive has several top-level directories: %s and %s", root, dir)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		files[rest] = data
+	}
+	return loadChartFiles(files)
+}
+
+// LoadChartPath loads a chart from a local chart directory or packaged chart (.tgz).
+func LoadChartPath(chartPath string) (*Chart, error) {
+	info, err := os.Stat(chartPath)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		f, err := os.Open(chartPath) // #nosec G304 -- chart path given by the user
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = f.Close() }()
+		return LoadChart(f)
+	}
+
+	files := make(map[string][]byte)
+	size := int64(0)
+	err = filepath.WalkDir(chartPath, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() && entry.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if size += info.Size(); size > maxChartSize {
+			return fmt.Errorf("chart %s is larger than %d bytes", chartPath, maxChartSize)
+		}
+		data, err := os.ReadFile(name) // #nosec G304 -- file of the chart directory
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(chartPath, name)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = data
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loadChartFiles(files)
+}
+
+// loadChartFiles loads a chart from its files, by path relative to the chart directory.
+func loadChartFiles(files map[string][]byte) (*Chart, error) {
+	metadata, ok := files["Chart.yaml"]
+	if !ok {
+		return nil, fmt.Errorf("no Chart.yaml found in chart")
+	}
+	chart := &Chart{Subcharts: make(map[string]*Chart)}
+	if err := yaml.Unmarshal(metadata, &chart.Metadata); err != nil {
+		return nil, fmt.Errorf("failed to parse Chart.yaml: %w", err)
+	}
+	if requirements, ok := files["requirements.yaml"]; ok && len(chart.Metadata.Dependencies) == 0 {
+		var legacy struct {
+			Dependencies []ChartDependency `yaml:"dependencies"`
+		}
+		if err := yaml.Unmarshal(requirements, &legacy); err != nil {
+			return nil, fmt.Errorf("failed to parse requirements.yaml: %w", err)
+		}
+		chart.Metadata.Dependencies = legacy.Dependencies
+	}
+
+	readme := ""
+	subcharts := make(map[string]map[string][]byte)
+	for name, data := range files {
+		switch {
+		case name == "values.yaml":
+			chart.Values, chart.hasValues = string(data), true
+		case name == "values.schema.json":
+			chart.Schema = data
+		case !strings.Contains(name, "/") && strings.HasPrefix(strings.ToUpper(name), "README"):
+			// README.md is preferred over README.txt or a plain README
+			if readme == "" || strings.EqualFold(name, "README.md") {
+				readme, chart.README = name, string(data)
+			}
+		case strings.HasPrefix(name, "crds/"):
+			chart.CRDs = append(chart.CRDs, ChartFile{Name: name, Data: data})
+		case strings.HasPrefix(name, "charts/"):
+			sub, rest, nested := strings.Cut(strings.TrimPrefix(name, "charts/"), "/")
+			if nested {
+				if subcharts[sub] == nil {
+					subcharts[sub] = make(map[string][]byte)
+				}
+				subcharts[sub][rest] = data
+			} else if strings.HasSuffix(sub, ".tgz") {
+				subchart, err := LoadChart(bytes.NewReader(data))
+				if err != nil {
+					return nil, fmt.Errorf("subchart %s: %w", name, err)
+				}
+				chart.Subcharts[subchart.Metadata.Name] = subchart
+			}
+		}
+	}
+	for dir, subFiles := range subcharts {
+		subchart, err := loadChartFiles(subFiles)
+		if err != nil {
+			return nil, fmt.Errorf("subchart charts/%s: %w", dir, err)
+		}
+		chart.Subcharts[subchart.Metadata.Name] = subchart
+	}
+	sort.Slice(chart.CRDs, func(i, j int) bool { return chart.CRDs[i].Name < chart.CRDs[j].Name })
+	return chart, nil
+}
+
+// CheckKubeVersion checks a Kubernetes version, such as v1.29.3, against the kubeVersion
+// constraint of the chart. Charts without a constraint accept every version.
+func (c *Chart) CheckKubeVersion(kubeVersion string) error {
+	if c.Metadata.KubeVersion == "" {
+		return nil
+	}
+	constraint, err := semver.ParseConstraint(c.Metadata.KubeVersion)
+	if err != nil {
+		return fmt.Errorf("invalid kubeVersion %q in Chart.yaml: %w", c.Metadata.KubeVersion, err)
+	}
+	version, err := semver.Parse(kubeVersion)
+	if err != nil {
+		return fmt.Errorf("invalid Kubernetes version %q: %w", kubeVersion, err)
+	}
+	if !constraint.Check(version) {
+		return fmt.Errorf("chart %s %s requires Kubernetes %s, not %s", c.Metadata.Name, c.Metadata.Version, c.Metadata.KubeVersion, kubeVersion)
+	}
+	return nil
+}
+
+// ValuesYAML returns the values.yaml of the chart, or an error when it has none.
+func (c *Chart) ValuesYAML() (string, error) {
+	if !c.hasValues {
+		return "", fmt.Errorf("values.yaml not found in chart")
+	}
+	return c.Values, nil
+}
+
+// Subchart returns the vendored chart of a dependency, nil when it is not vendored.
+func (c *Chart) Subchart(dependency ChartDependency) *Chart {
+	return c.Subcharts[dependency.Name]
+}
+
+// DependencyEnabled reports whether a dependency is enabled by the default values: the first of
+// its comma-separated conditions set to a boolean decides, and a dependency without one is enabled.
+func (c *Chart) DependencyEnabled(dependency ChartDependency) bool {
+	if dependency.Condition == "" {
+		return true
+	}
+	var values map[string]interface{}
+	if err := yaml.Unmarshal([]byte(c.Values), &values); err != nil {
+		return true
+	}
+	for _, condition := range strings.Split(dependency.Condition, ",") {
+		var value interface{} = values
+		for _, key := range strings.Split(strings.TrimSpace(condition), ".") {
+			mapping, ok := value.(map[string]interface{})
+			if !ok {
+				value = nil
+				break
+			}
+			value = mapping[key]
+		}
+		if enabled, ok := value.(bool); ok {
+			return enabled
+		}
+	}
+	return true
+}
+
+// ValuesWithSubcharts returns the values.yaml of the chart with the default values of its enabled
+// subcharts, and of theirs, nested under the dependency keys. enabled overrides, by dependency
+// key, whether a subchart of the chart itself is enabled; the default values decide otherwise.
+// Subcharts that are not vendored in the chart are left out.
+func (c *Chart) ValuesWithSubcharts(enabled map[string]bool) (string, error) {
+	parent, err := c.ValuesYAML()
+	if err != nil {
+		return "", err
+	}
+	var subcharts []values.Subchart
+	for _, dependency := range c.Metadata.Dependencies {
+		on, ok := enabled[dependency.Key()]
+		if !ok {
+			on = c.DependencyEnabled(dependency)
+		}
+		subchart := c.Subchart(dependency)
+		if !on || subchart == nil || !subchart.hasValues {
+			continue
+		}
+		defaults, err := subchart.ValuesWithSubcharts(nil)
+		if err != nil {
+			return "", fmt.Errorf("subchart %s: %w", dependency.Name, err)
+		}
+		subcharts = append(subcharts, values.Subchart{Key: dependency.Key(), Name: dependency.Name, Values: []byte(defaults)})
+	}
+	nested, err := values.WithSubcharts([]byte(parent), subcharts)
+	if err != nil {
+		return "", err
+	}
+	return string(nested), nil
+}
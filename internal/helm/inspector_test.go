@@ -0,0 +1,171 @@
+package helm
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const inspectorChartYAML = `apiVersion: v2
+name: umbrella
+version: 1.2.0
+appVersion: "2.0"
+description: An umbrella chart
+kubeVersion: ">=1.25.0-0"
+annotations:
+  artifacthub.io/license: Apache-2.0
+dependencies:
+  - name: redis
+    version: 17.x.x
+    repository: https://charts.bitnami.com/bitnami
+    condition: redis.enabled
+  - name: postgresql
+    alias: db
+    version: 12.0.0
+    repository: https://charts.bitnami.com/bitnami
+    condition: db.enabled,global.db.enabled
+  - name: common
+    version: 2.x.x
+    repository: https://charts.bitnami.com/bitnami
+`
+
+func TestLoadChart(t *testing.T) {
+	postgresql := chartTarball(t, map[string]string{
+		"postgresql/Chart.yaml":  "apiVersion: v2\nname: postgresql\nversion: 12.0.0\n",
+		"postgresql/values.yaml": "auth:\n  database: app\n",
+	})
+	data := chartTarball(t, map[string]string{
+		"umbrella/charts/redis/Chart.yaml":      "apiVersion: v2\nname: redis\nversion: 17.3.0\n",
+		"umbrella/charts/redis/values.yaml":     "architecture: replication\n",
+		"umbrella/charts/postgresql-12.0.0.tgz": string(postgresql),
+		"umbrella/Chart.yaml":                   inspectorChartYAML,
+		"umbrella/values.yaml":                  "redis:\n  enabled: false\ndb:\n  enabled: true\n",
+		"umbrella/values.schema.json":           `{"type": "object"}`,
+		"umbrella/README.md":                    "# Umbrella\n",
+		"umbrella/README.txt":                   "Umbrella\n",
+		"umbrella/crds/b.yaml":                  "kind: CustomResourceDefinition\n",
+		"umbrella/crds/a.yaml":                  "kind: CustomResourceDefinition\n",
+		"umbrella/templates/values.yaml":        "# not the chart values\n",
+	})
+
+	chart, err := LoadChart(bytes.NewReader(data))
+	require.NoError(t, err)
+
+	assert.Equal(t, "umbrella", chart.Metadata.Name)
+	assert.Equal(t, "1.2.0", chart.Metadata.Version)
+	assert.Equal(t, "2.0", chart.Metadata.AppVersion)
+	assert.Equal(t, ">=1.25.0-0", chart.Metadata.KubeVersion)
+	assert.Equal(t, "Apache-2.0", chart.Metadata.Annotations["artifacthub.io/license"])
+	assert.Equal(t, "redis:\n  enabled: false\ndb:\n  enabled: true\n", chart.Values)
+	assert.JSONEq(t, `{"type": "object"}`, string(chart.Schema))
+	assert.Equal(t, "# Umbrella\n", chart.README)
+	require.Len(t, chart.CRDs, 2)
+	assert.Equal(t, "crds/a.yaml", chart.CRDs[0].Name)
+
+	require.Len(t, chart.Metadata.Dependencies, 3)
+	redis, db, common := chart.Metadata.Dependencies[0], chart.Metadata.Dependencies[1], chart.Metadata.Dependencies[2]
+	assert.Equal(t, "db", db.Key())
+	assert.Equal(t, "redis", redis.Key())
+	assert.False(t, chart.DependencyEnabled(redis))
+	assert.True(t, chart.DependencyEnabled(db))
+	assert.True(t, chart.DependencyEnabled(common))
+
+	require.NotNil(t, chart.Subchart(redis))
+	assert.Equal(t, "architecture: replication\n", chart.Subchart(redis).Values)
+	require.NotNil(t, chart.Subchart(db))
+	assert.Equal(t, "auth:\n  database: app\n", chart.Subchart(db).Values)
+	assert.Nil(t, chart.Subchart(common))
+	assert.Equal(t, []string{"db", "enabled"}, db.ConditionPath())
+	assert.Nil(t, common.ConditionPath())
+
+	// Defaults of the enabled and vendored subcharts are nested under their keys
+	withSubcharts, err := chart.ValuesWithSubcharts(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "redis:\n  enabled: false\ndb:\n  auth:\n    database: app\n  enabled: true\n", withSubcharts)
+	withSubcharts, err = chart.ValuesWithSubcharts(map[string]bool{"redis": true, "db": false})
+	require.NoError(t, err)
+	assert.Equal(t, "redis:\n  architecture: replication\n  enabled: false\ndb:\n  enabled: true\n", withSubcharts)
+}
+
+func TestLoadChart_Legacy(t *testing.T) {
+	data := chartTarball(t, map[string]string{
+		"legacy/Chart.yaml":        "apiVersion: v1\nname: legacy\nversion: 0.1.0\n",
+		"legacy/requirements.yaml": "dependencies:\n  - name: mysql\n    version: 1.x\n    condition: mysql.enabled\n",
+	})
+	chart, err := LoadChart(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Len(t, chart.Metadata.Dependencies, 1)
+	assert.Equal(t, "mysql", chart.Metadata.Dependencies[0].Name)
+	assert.True(t, chart.DependencyEnabled(chart.Metadata.Dependencies[0]))
+
+	// Without values.yaml the values cannot be extracted
+	_, err = extractValuesYAML(bytes.NewReader(data))
+	assert.ErrorContains(t, err, "values.yaml not found")
+}
+
+func TestLoadChart_Invalid(t *testing.T) {
+	_, err := LoadChart(bytes.NewReader([]byte("not a tarball")))
+	assert.Error(t, err)
+
+	_, err = LoadChart(bytes.NewReader(chartTarball(t, map[string]string{"app/values.yaml": "a: 1\n"})))
+	assert.ErrorContains(t, err, "no Chart.yaml")
+
+	_, err = LoadChart(bytes.NewReader(chartTarball(t, map[string]string{
+		"app/Chart.yaml":   "name: app\n",
+		"other/Chart.yaml": "name: other\n",
+	})))
+	assert.ErrorContains(t, err, "several top-level directories")
+}
+
+func TestLoadChartPath(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "foo")
+	files := map[string]string{
+		"Chart.yaml":             "apiVersion: v2\nname: foo\nversion: 0.3.0\n",
+		"values.yaml":            "replicaCount: 1\n",
+		"templates/service.yaml": "kind: Service\n",
+		"charts/bar/Chart.yaml":  "apiVersion: v2\nname: bar\nversion: 1.0.0\n",
+		".git/config":            "[core]\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o750))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+
+	chart, err := LoadChartPath(dir)
+	require.NoError(t, err)
+	assert.Equal(t, "foo", chart.Metadata.Name)
+	assert.Equal(t, "0.3.0", chart.Metadata.Version)
+	assert.Equal(t, "replicaCount: 1\n", chart.Values)
+	assert.Contains(t, chart.Subcharts, "bar")
+
+	// Packaged charts are loaded like downloaded ones
+	archive := filepath.Join(t.TempDir(), "foo-0.3.0.tgz")
+	require.NoError(t, os.WriteFile(archive, chartTarball(t, map[string]string{
+		"foo/Chart.yaml":  "apiVersion: v2\nname: foo\nversion: 0.3.0\n",
+		"foo/values.yaml": "replicaCount: 2\n",
+	}), 0o600))
+	chart, err = LoadChartPath(archive)
+	require.NoError(t, err)
+	assert.Equal(t, "replicaCount: 2\n", chart.Values)
+
+	_, err = LoadChartPath(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+	_, err = LoadChartPath(t.TempDir())
+	assert.ErrorContains(t, err, "no Chart.yaml")
+}
+
+func TestChart_CheckKubeVersion(t *testing.T) {
+	chart := &Chart{Metadata: ChartMetadata{Name: "app", Version: "1.0.0", KubeVersion: ">=1.25.0-0"}}
+
+	assert.NoError(t, chart.CheckKubeVersion("v1.29.3"))
+	assert.NoError(t, chart.CheckKubeVersion("v1.29.3-gke.1"))
+	assert.ErrorContains(t, chart.CheckKubeVersion("v1.24.0"), "chart app 1.0.0 requires Kubernetes >=1.25.0-0, not v1.24.0")
+	assert.ErrorContains(t, chart.CheckKubeVersion("latest"), "invalid Kubernetes version")
+
+	assert.NoError(t, (&Chart{}).CheckKubeVersion("v1.10.0"))
+}
@@ -0,0 +1,378 @@
+package helm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+const (
+	// ociScheme prefixes the URL of charts stored in an OCI registry.
+	ociScheme = "oci://"
+	// ChartLayerMediaType is the media type of the layer holding the chart tarball in an OCI artifact.
+	ChartLayerMediaType = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+	// ProvenanceLayerMediaType is the media type of the layer holding the provenance file of a
+	// signed chart in an OCI artifact.
+	ProvenanceLayerMediaType = "application/vnd.cncf.helm.chart.provenance.v1.prov"
+
+	ociManifestMediaType = "application/vnd.oci.image.manifest.v1+json"
+	// maxOCIManifestSize bounds the manifests read from a registry.
+	maxOCIManifestSize = 4 << 20
+)
+
+// IsOCI reports whether the repository URL points to an OCI registry.
+func IsOCI(repoURL string) bool {
+	return strings.HasPrefix(repoURL, ociScheme)
+}
+
+// ChartTagFromVersion returns the OCI tag of a chart version. Tags cannot contain "+",
+// so Helm pushes build metadata with "_" instead.
+func ChartTagFromVersion(version string) string {
+	return strings.ReplaceAll(version, "+", "_")
+}
+
+// ociReference locates a chart repository in an OCI registry.
+type ociReference struct {
+	registry   string // Host and optional port
+	repository string // Path of the chart repository, e.g. "stefanprodan/charts/podinfo"
+}
+
+// parseOCIReference splits an oci:// repository URL and a chart name into a registry reference.
+func parseOCIReference(repoURL, chartName string) (ociReference, error) {
+	if !IsOCI(repoURL) {
+		return ociReference{}, fmt.Errorf("invalid OCI repository URL %q: must start with %s", repoURL, ociScheme)
+	}
+	if chartName == "" || strings.Contains(chartName, "/") {
+		return ociReference{}, fmt.Errorf("invalid chart name %q", chartName)
+	}
+	registry, path, _ := strings.Cut(strings.TrimSuffix(strings.TrimPrefix(repoURL, ociScheme), "/"), "/")
+	if registry == "" {
+		return ociReference{}, fmt.Errorf("invalid OCI repository URL %q: missing registry", repoURL)
+	}
+	repository := chartName
+	if path != "" {
+		repository = path + "/" + chartName
+	}
+	return ociReference{registry: registry, repository: repository}, nil
+}
+
+// ociClient talks to OCI registries through the distribution API. Bearer tokens are requested
+// when a registry asks for them, anonymously or with the credentials registered with SetAuth.
+type ociClient struct {
+	client *http.Client
+	scheme string // "https" unless testing against a plain HTTP registry
+
+	mu     sync.Mutex
+	tokens map[string]string // Bearer tokens by repository
+}
+
+var defaultOCIClient = newOCIClient(defaultClient)
+
+func newOCIClient(client *http.Client) *ociClient {
+	return &ociClient{client: client, scheme: "https", tokens: make(map[string]string)}
+}
+
+// ociManifest is the part of an OCI image manifest needed to find the chart layer.
+type ociManifest struct {
+	Layers []ociDescriptor `json:"layers"`
+}
+
+type ociDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+// listTags lists every tag of the repository, following the pagination links of the registry.
+func (c *ociClient) listTags(ctx context.Context, ref ociReference) ([]string, error) {
+	var tags []string
+	next := fmt.Sprintf("%s://%s/v2/%s/tags/list", c.scheme, ref.registry, ref.repository)
+	for next != "" {
+		resp, err := c.get(ctx, ref, next, "application/json")
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tags of %s/%s: %w", ref.registry, ref.repository, err)
+		}
+		var page struct {
+			Tags []string `json:"tags"`
+		}
+		err = json.NewDecoder(io.LimitReader(resp.Body, maxOCIManifestSize)).Decode(&page)
+		link := resp.Header.Get("Link")
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse tags of %s/%s: %w", ref.registry, ref.repository, err)
+		}
+		tags = append(tags, page.Tags...)
+
+		if next, err = nextPageURL(next, link); err != nil {
+			return nil, err
+		}
+	}
+	return tags, nil
+}
+
+// nextPageURL resolves the "next" link of a paginated registry response, or returns "" on the last page.
+func nextPageURL(current, link string) (string, error) {
+	if link == "" || !strings.Contains(link, `rel="next"`) {
+		return "", nil
+	}
+	start, end := strings.Index(link, "<"), strings.Index(link, ">")
+	if start < 0 || end < start {
+		return "", fmt.Errorf("invalid Link header %q", link)
+	}
+	base, err := url.Parse(current)
+	if err != nil {
+		return "", err
+	}
+	next, err := base.Parse(link[start+1 : end])
+	if err != nil {
+		return "", fmt.Errorf("invalid Link header %q: %w", link, err)
+	}
+	return next.String(), nil
+}
+
+// chartVersions lists the chart versions of an OCI repository, newest first.
+// Tags that are not versions, such as "latest" or signature tags, are skipped.
+func (c *ociClient) chartVersions(ctx context.Context, repoURL, chartName string) ([]ChartVersion, error) {
+	ref, err := parseOCIReference(repoURL, chartName)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := c.listTags(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	var versions []ChartVersion
+	for _, tag := range tags {
+		if !isVersionTag(tag) {
+			continue
+		}
+		version := strings.ReplaceAll(tag, "_", "+")
+		versions = append(versions, ChartVersion{
+			ChartVersion:  version,
+			DisplayString: fmt.Sprintf("%s\t%s\t\t", chartName, version),
+		})
+	}
+	SortVersions(versions)
+	return versions, nil
+}
+
+// isVersionTag reports whether a tag looks like a chart version, e.g. "1.2.3" or "v1.2.3".
+func isVersionTag(tag string) bool {
+	tag = strings.TrimPrefix(tag, "v")
+	return tag != "" && tag[0] >= '0' && tag[0] <= '9' && !strings.HasSuffix(tag, ".sig") && !strings.HasSuffix(tag, ".att")
+}
+
+// pullChart downloads the chart tarball of a chart version and checks its digest.
+func (c *ociClient) pullChart(ctx context.Context, repoURL, chartName, chartVersion string) ([]byte, error) {
+	data, err := c.pullLayer(ctx, repoURL, chartName, chartVersion, ChartLayerMediaType, "chart")
+	if errors.Is(err, errNotFound) {
+		return nil, fmt.Errorf("%s:%s is not a Helm chart: no %s layer", chartName, ChartTagFromVersion(chartVersion), ChartLayerMediaType)
+	}
+	return data, err
+}
+
+// pullProvenance downloads the provenance file of a chart version and checks its digest. It
+// returns errNotFound for charts that are not signed.
+func (c *ociClient) pullProvenance(ctx context.Context, repoURL, chartName, chartVersion string) ([]byte, error) {
+	return c.pullLayer(ctx, repoURL, chartName, chartVersion, ProvenanceLayerMediaType, "provenance")
+}
+
+// pullLayer downloads the layer of a chart version with the given media type, described by name
+// in errors, and checks its digest. It returns errNotFound when the artifact has no such layer.
+func (c *ociClient) pullLayer(ctx context.Context, repoURL, chartName, chartVersion, mediaType, name string) ([]byte, error) {
+	ref, err := parseOCIReference(repoURL, chartName)
+	if err != nil {
+		return nil, err
+	}
+	tag := ChartTagFromVersion(chartVersion)
+
+	resp, err := c.get(ctx, ref, fmt.Sprintf("%s://%s/v2/%s/manifests/%s", c.scheme, ref.registry, ref.repository, tag), ociManifestMediaType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch manifest of %s:%s: %w", ref.repository, tag, err)
+	}
+	var manifest ociManifest
+	err = json.NewDecoder(io.LimitReader(resp.Body, maxOCIManifestSize)).Decode(&manifest)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse manifest of %s:%s: %w", ref.repository, tag, err)
+	}
+
+	var layer *ociDescriptor
+	for i := range manifest.Layers {
+		if manifest.Layers[i].MediaType == mediaType {
+			layer = &manifest.Layers[i]
+			break
+		}
+	}
+	if layer == nil {
+		return nil, fmt.Errorf("%s layer of %s:%s: %w", name, ref.repository, tag, errNotFound)
+	}
+
+	resp, err = c.get(ctx, ref, fmt.Sprintf("%s://%s/v2/%s/blobs/%s", c.scheme, ref.registry, ref.repository, layer.Digest), "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s layer of %s:%s: %w", name, ref.repository, tag, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s layer of %s:%s: %w", name, ref.repository, tag, err)
+	}
+	if err := verifyDigest(data, layer.Digest); err != nil {
+		return nil, fmt.Errorf("%w: %s layer of %s:%s: %w", ErrVerification, name, ref.repository, tag, err)
+	}
+	return data, nil
+}
+
+// verifyDigest checks data against a "sha256:<hex>" digest.
+func verifyDigest(data []byte, digest string) error {
+	algorithm, expected, ok := strings.Cut(digest, ":")
+	if !ok || algorithm != "sha256" {
+		return fmt.Errorf("unsupported digest %q", digest)
+	}
+	sum := sha256.Sum256(data)
+	if actual := hex.EncodeToString(sum[:]); actual != expected {
+		return fmt.Errorf("digest mismatch: expected %s, got sha256:%s", digest, actual)
+	}
+	return nil
+}
+
+// get sends a GET request to the registry, fetching an anonymous bearer token when challenged.
+// The caller closes the body of the returned response, which always has status 200.
+func (c *ociClient) get(ctx context.Context, ref ociReference, target, accept string) (*http.Response, error) {
+	// Tag lists are not cached, and cached charts never reach the registry
+	if currentCache().isOffline() {
+		return nil, fmt.Errorf("registry %s: %w, run once without --offline", ref.registry, ErrNotCached)
+	}
+	resp, err := c.do(ctx, ref, target, accept)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		_ = resp.Body.Close()
+		if err := c.authenticate(ctx, ref, challenge); err != nil {
+			return nil, err
+		}
+		if resp, err = c.do(ctx, ref, target, accept); err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return resp, nil
+}
+
+func (c *ociClient) do(ctx context.Context, ref ociReference, target, accept string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	c.mu.Lock()
+	token := c.tokens[ref.registry+"/"+ref.repository]
+	c.mu.Unlock()
+	auth, client := authFor(ref.registry+"/"+ref.repository, c.client)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else {
+		auth.authorize(req)
+	}
+	return send(ctx, client, req)
+}
+
+// authenticate answers a bearer challenge with an anonymous pull token.
+func (c *ociClient) authenticate(ctx context.Context, ref ociReference, challenge string) error {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return fmt.Errorf("registry %s requires authentication: status 401", ref.registry)
+	}
+	attrs := parseChallengeParams(params)
+	realm, err := url.Parse(attrs["realm"])
+	if err != nil || realm.Host == "" {
+		return fmt.Errorf("registry %s sent an invalid authentication challenge", ref.registry)
+	}
+
+	query := realm.Query()
+	if service := attrs["service"]; service != "" {
+		query.Set("service", service)
+	}
+	scope := attrs["scope"]
+	if scope == "" {
+		scope = "repository:" + ref.repository + ":pull"
+	}
+	query.Set("scope", scope)
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), http.NoBody)
+	if err != nil {
+		return fmt.Errorf("failed to create token request: %w", err)
+	}
+	// Registry credentials are exchanged for a token, anonymous tokens are requested without them
+	auth, client := authFor(ref.registry+"/"+ref.repository, c.client)
+	if auth.Username != "" {
+		req.SetBasicAuth(auth.Username, auth.Password)
+	}
+	resp, err := send(ctx, client, req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch registry token: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch registry token: status %d", resp.StatusCode)
+	}
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxOCIManifestSize)).Decode(&body); err != nil {
+		return fmt.Errorf("failed to parse registry token: %w", err)
+	}
+	token := body.Token
+	if token == "" {
+		token = body.AccessToken
+	}
+	if token == "" {
+		return fmt.Errorf("registry %s returned an empty token", ref.registry)
+	}
+
+	c.mu.Lock()
+	c.tokens[ref.registry+"/"+ref.repository] = token
+	c.mu.Unlock()
+	return nil
+}
+
+// parseChallengeParams parses the comma-separated key="value" parameters of a WWW-Authenticate header.
+func parseChallengeParams(params string) map[string]string {
+	attrs := make(map[string]string)
+	for params != "" {
+		var key, value string
+		key, params, _ = strings.Cut(strings.TrimLeft(params, ", "), "=")
+		if strings.HasPrefix(params, `"`) {
+			end := strings.Index(params[1:], `"`)
+			if end < 0 {
+				break
+			}
+			value, params = params[1:end+1], params[end+2:]
+		} else {
+			value, params, _ = strings.Cut(params, ",")
+		}
+		attrs[strings.ToLower(strings.TrimSpace(key))] = value
+	}
+	return attrs
+}
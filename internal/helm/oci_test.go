@@ -0,0 +1,260 @@
+package helm
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chartTarball builds a gzipped chart tarball holding the given files.
+func chartTarball(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content))}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gzw.Close())
+	return buf.Bytes()
+}
+
+// testRegistry is an in-process stand-in for an OCI registry serving one chart repository.
+// Like ghcr.io, it requires an anonymous bearer token.
+type testRegistry struct {
+	repository string
+	tags       []string
+	pageSize   int
+	manifests  map[string][]byte
+	blobs      map[string][]byte
+}
+
+func newTestRegistry(t *testing.T, repository string) (*testRegistry, *httptest.Server) {
+	t.Helper()
+	registry := &testRegistry{repository: repository, manifests: map[string][]byte{}, blobs: map[string][]byte{}}
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			assert.Regexp(t, `^repository:charts/[a-z]+:pull$`, r.URL.Query().Get("scope"))
+			_, _ = w.Write([]byte(`{"token":"anonymous"}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer anonymous" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+server.URL+`/token",service="test-registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		registry.serve(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return registry, server
+}
+
+func (r *testRegistry) serve(w http.ResponseWriter, req *http.Request) {
+	prefix := "/v2/" + r.repository + "/"
+	if !strings.HasPrefix(req.URL.Path, prefix) {
+		http.NotFound(w, req)
+		return
+	}
+	switch rest := strings.TrimPrefix(req.URL.Path, prefix); {
+	case rest == "tags/list":
+		tags := r.tags
+		if last := req.URL.Query().Get("last"); last != "" {
+			for i, tag := range tags {
+				if tag == last {
+					tags = tags[i+1:]
+					break
+				}
+			}
+		}
+		if r.pageSize > 0 && len(tags) > r.pageSize {
+			tags = tags[:r.pageSize]
+			w.Header().Set("Link", `</v2/`+r.repository+`/tags/list?last=`+tags[len(tags)-1]+`>; rel="next"`)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"name": r.repository, "tags": tags})
+	case strings.HasPrefix(rest, "manifests/"):
+		manifest, ok := r.manifests[strings.TrimPrefix(rest, "manifests/")]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		w.Header().Set("Content-Type", ociManifestMediaType)
+		_, _ = w.Write(manifest)
+	case strings.HasPrefix(rest, "blobs/"):
+		blob, ok := r.blobs[strings.TrimPrefix(rest, "blobs/")]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		_, _ = w.Write(blob)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// push stores a chart version, with its layer under the given media type.
+func (r *testRegistry) push(t *testing.T, tag, mediaType string, layer []byte) {
+	t.Helper()
+	sum := sha256.Sum256(layer)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	manifest, err := json.Marshal(map[string]interface{}{
+		"schemaVersion": 2,
+		"mediaType":     ociManifestMediaType,
+		"layers":        []ociDescriptor{{MediaType: mediaType, Digest: digest, Size: int64(len(layer))}},
+	})
+	require.NoError(t, err)
+	r.manifests[tag] = manifest
+	r.blobs[digest] = layer
+	r.tags = append(r.tags, tag)
+}
+
+// pushSigned stores a signed chart version, with its chart and provenance layers.
+func (r *testRegistry) pushSigned(t *testing.T, tag string, chart, prov []byte) {
+	t.Helper()
+	r.push(t, tag, ChartLayerMediaType, chart)
+	sum := sha256.Sum256(prov)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	var manifest ociManifest
+	require.NoError(t, json.Unmarshal(r.manifests[tag], &manifest))
+	manifest.Layers = append(manifest.Layers, ociDescriptor{MediaType: ProvenanceLayerMediaType, Digest: digest, Size: int64(len(prov))})
+	data, err := json.Marshal(manifest)
+	require.NoError(t, err)
+	r.manifests[tag] = data
+	r.blobs[digest] = prov
+}
+
+// newTestOCIClient returns a client trusting the test registry and its oci:// repository URL.
+func newTestOCIClient(server *httptest.Server) (*ociClient, string) {
+	return newOCIClient(server.Client()), "oci://" + strings.TrimPrefix(server.URL, "https://") + "/charts"
+}
+
+func TestParseOCIReference(t *testing.T) {
+	tests := []struct {
+		name      string
+		repoURL   string
+		chartName string
+		expected  ociReference
+		wantErr   bool
+	}{
+		{"with path", "oci://ghcr.io/stefanprodan/charts", "podinfo", ociReference{"ghcr.io", "stefanprodan/charts/podinfo"}, false},
+		{"trailing slash", "oci://ghcr.io/stefanprodan/charts/", "podinfo", ociReference{"ghcr.io", "stefanprodan/charts/podinfo"}, false},
+		{"registry only", "oci://localhost:5000", "podinfo", ociReference{"localhost:5000", "podinfo"}, false},
+		{"not oci", "https://ghcr.io/stefanprodan/charts", "podinfo", ociReference{}, true},
+		{"missing registry", "oci:///charts", "podinfo", ociReference{}, true},
+		{"missing chart", "oci://ghcr.io/charts", "", ociReference{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref, err := parseOCIReference(tt.repoURL, tt.chartName)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, ref)
+		})
+	}
+}
+
+func TestOCIClient_ChartVersions(t *testing.T) {
+	registry, server := newTestRegistry(t, "charts/podinfo")
+	registry.tags = []string{"6.8.0", "latest", "6.9.0", "sha256-abc.sig", "6.9.1_build.1"}
+	registry.pageSize = 2
+	client, repoURL := newTestOCIClient(server)
+
+	versions, err := client.chartVersions(context.Background(), repoURL, "podinfo")
+	require.NoError(t, err)
+
+	var names []string
+	for _, version := range versions {
+		names = append(names, version.ChartVersion)
+	}
+	assert.Equal(t, []string{"6.9.1+build.1", "6.9.0", "6.8.0"}, names)
+
+	_, err = client.chartVersions(context.Background(), repoURL, "missing")
+	assert.ErrorContains(t, err, "status 404")
+}
+
+func TestOCIClient_PullChart(t *testing.T) {
+	registry, server := newTestRegistry(t, "charts/podinfo")
+	chart := chartTarball(t, map[string]string{
+		"podinfo/Chart.yaml":  "name: podinfo\nversion: 6.9.0\n",
+		"podinfo/values.yaml": "replicaCount: 1\n",
+	})
+	registry.push(t, "6.9.0", ChartLayerMediaType, chart)
+	registry.push(t, "1.0.0", "application/vnd.oci.image.layer.v1.tar+gzip", chart)
+	client, repoURL := newTestOCIClient(server)
+
+	data, err := client.pullChart(context.Background(), repoURL, "podinfo", "6.9.0")
+	require.NoError(t, err)
+	values, err := extractValuesYAML(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, "replicaCount: 1\n", values)
+
+	_, err = client.pullChart(context.Background(), repoURL, "podinfo", "1.0.0")
+	assert.ErrorContains(t, err, "is not a Helm chart")
+
+	// A tampered layer is rejected
+	for digest := range registry.blobs {
+		registry.blobs[digest] = chartTarball(t, map[string]string{"podinfo/values.yaml": "replicaCount: 5\n"})
+	}
+	_, err = client.pullChart(context.Background(), repoURL, "podinfo", "6.9.0")
+	assert.ErrorContains(t, err, "digest mismatch")
+}
+
+func TestOCIClient_PullProvenance(t *testing.T) {
+	registry, server := newTestRegistry(t, "charts/podinfo")
+	chart := chartTarball(t, map[string]string{"podinfo/Chart.yaml": "name: podinfo\nversion: 6.9.0\n"})
+	registry.pushSigned(t, "6.9.0", chart, []byte(provenanceTestProv))
+	registry.push(t, "6.8.0", ChartLayerMediaType, chart)
+	client, repoURL := newTestOCIClient(server)
+
+	prov, err := client.pullProvenance(context.Background(), repoURL, "podinfo", "6.9.0")
+	require.NoError(t, err)
+	assert.Equal(t, provenanceTestProv, string(prov))
+
+	// Unsigned charts have no provenance layer
+	_, err = client.pullProvenance(context.Background(), repoURL, "podinfo", "6.8.0")
+	assert.ErrorIs(t, err, errNotFound)
+}
+
+func TestVersionFetcher_OCI(t *testing.T) {
+	registry, server := newTestRegistry(t, "charts/podinfo")
+	registry.tags = []string{"6.8.0", "6.9.0"}
+	client, repoURL := newTestOCIClient(server)
+	fetcher := &VersionFetcher{fetchIndex: fetchIndexYAML, oci: client}
+
+	latest, err := fetcher.FetchLatestVersion(context.Background(), repoURL, "podinfo")
+	require.NoError(t, err)
+	assert.Equal(t, "6.9.0", latest.ChartVersion)
+
+	require.NoError(t, fetcher.ValidateChartExists(context.Background(), repoURL, "podinfo"))
+	assert.Error(t, fetcher.ValidateChartExists(context.Background(), repoURL, "missing"))
+
+	_, err = fetcher.ListCharts(context.Background(), repoURL)
+	assert.ErrorIs(t, err, ErrOCIChartListing)
+}
+
+func TestParseChallengeParams(t *testing.T) {
+	attrs := parseChallengeParams(`realm="https://ghcr.io/token",service="ghcr.io",scope="repository:user/image:pull"`)
+	assert.Equal(t, map[string]string{
+		"realm":   "https://ghcr.io/token",
+		"service": "ghcr.io",
+		"scope":   "repository:user/image:pull",
+	}, attrs)
+}
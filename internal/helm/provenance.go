@@ -0,0 +1,179 @@
+package helm
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrVerification is returned when a chart does not match its digest or provenance file. Such
+// charts must not be used.
+var ErrVerification = errors.New("chart verification failed")
+
+// activeKeyring is the keyring provenance files are verified with, nil when they are not.
+var activeKeyring struct {
+	sync.Mutex
+	keyring openpgp.EntityList
+}
+
+// SetKeyring sets the keyring that verifies the provenance files of downloaded charts, like
+// `helm pull --verify`. Charts without a provenance file fail the verification. A nil keyring
+// disables the verification.
+func SetKeyring(keyring openpgp.EntityList) {
+	activeKeyring.Lock()
+	defer activeKeyring.Unlock()
+	activeKeyring.keyring = keyring
+}
+
+// currentKeyring returns the keyring set with SetKeyring.
+func currentKeyring() openpgp.EntityList {
+	activeKeyring.Lock()
+	defer activeKeyring.Unlock()
+	return activeKeyring.keyring
+}
+
+// armoredPublicKeyBlock starts an ASCII-armored block of public keys.
+var armoredPublicKeyBlock = []byte("-----BEGIN PGP PUBLIC KEY BLOCK-----")
+
+// LoadKeyring reads a keyring file, such as the output of `gpg --export` with or without
+// --armor, or a legacy pubring.gpg.
+func LoadKeyring(path string) (openpgp.EntityList, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- keyring chosen by the user
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyring: %w", err)
+	}
+	keyring, err := ReadKeyring(data)
+	if err != nil {
+		return nil, fmt.Errorf("keyring %s: %w", path, err)
+	}
+	return keyring, nil
+}
+
+// ReadKeyring parses binary public keys, or any number of ASCII-armored blocks of public keys.
+// Keys with unsupported algorithms are skipped; a keyring without any supported key is an error.
+func ReadKeyring(data []byte) (openpgp.EntityList, error) {
+	if !bytes.Contains(data, armoredPublicKeyBlock) {
+		return readKeyring(bytes.NewReader(data))
+	}
+	var keyring openpgp.EntityList
+	for rest := data; ; {
+		i := bytes.Index(rest, armoredPublicKeyBlock)
+		if i < 0 {
+			break
+		}
+		block, err := armor.Decode(bytes.NewReader(rest[i:]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid armored keyring: %w", err)
+		}
+		entities, err := readKeyring(block.Body)
+		if err != nil {
+			return nil, err
+		}
+		keyring = append(keyring, entities...)
+		rest = rest[i+len(armoredPublicKeyBlock):]
+	}
+	return keyring, nil
+}
+
+// readKeyring parses binary public keys.
+func readKeyring(r io.Reader) (openpgp.EntityList, error) {
+	keyring, err := openpgp.ReadKeyRing(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid keyring: %w", err)
+	}
+	if len(keyring) == 0 {
+		return nil, errors.New("no supported public key found")
+	}
+	return keyring, nil
+}
+
+// clearsignHashes are the hash algorithms named by the Hash header of clearsigned messages.
+var clearsignHashes = map[string]crypto.Hash{
+	"SHA1":   crypto.SHA1,
+	"SHA224": crypto.SHA224,
+	"SHA256": crypto.SHA256,
+	"SHA384": crypto.SHA384,
+	"SHA512": crypto.SHA512,
+}
+
+// verifyClearsigned checks the signature of a clearsigned message with the keyring, rejecting
+// revoked and expired keys. The signature must use a hash algorithm listed by the Hash header of
+// the message, when it has one, and not a broken one such as SHA-1.
+func verifyClearsigned(keyring openpgp.EntityList, block *clearsign.Block) (*openpgp.Entity, error) {
+	config := &packet.Config{}
+	var expected []crypto.Hash
+	for _, name := range block.Headers.Values("Hash") {
+		hash, ok := clearsignHashes[name]
+		if !ok {
+			return nil, fmt.Errorf("unsupported hash %s", name)
+		}
+		expected = append(expected, hash)
+	}
+
+	var sig *packet.Signature
+	var signer *openpgp.Entity
+	var err error
+	if len(expected) > 0 {
+		sig, signer, err = openpgp.VerifyDetachedSignatureAndHash(keyring, bytes.NewReader(block.Bytes), block.ArmoredSignature.Body, expected, config)
+	} else {
+		sig, signer, err = openpgp.VerifyDetachedSignature(keyring, bytes.NewReader(block.Bytes), block.ArmoredSignature.Body, config)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if config.RejectMessageHashAlgorithm(sig.Hash) {
+		return nil, fmt.Errorf("signature uses the insecure hash %s", sig.Hash)
+	}
+	return signer, nil
+}
+
+// provenanceFiles is the part of a provenance file listing the digests of the chart archives.
+type provenanceFiles struct {
+	Files map[string]string `yaml:"files"`
+}
+
+// VerifyProvenance checks that a Helm provenance file is signed by a key of the keyring and lists
+// the SHA-256 digest of the chart archive, named chartFile, whose content is data. The key that
+// signed the provenance is returned. Every failure wraps ErrVerification.
+func VerifyProvenance(keyring openpgp.EntityList, prov []byte, chartFile string, data []byte) (*openpgp.Entity, error) {
+	block, _ := clearsign.Decode(prov)
+	if block == nil {
+		return nil, fmt.Errorf("%w: provenance is not a clearsigned message", ErrVerification)
+	}
+	signer, err := verifyClearsigned(keyring, block)
+	if err != nil {
+		return nil, fmt.Errorf("%w: provenance: %w", ErrVerification, err)
+	}
+
+	// The Chart.yaml of the chart, then the digests in a second YAML document
+	_, files, found := strings.Cut(string(block.Plaintext), "\n...\n")
+	if !found {
+		return nil, fmt.Errorf("%w: provenance without file digests", ErrVerification)
+	}
+	var parsed provenanceFiles
+	if err := yaml.Unmarshal([]byte(files), &parsed); err != nil {
+		return nil, fmt.Errorf("%w: invalid provenance: %w", ErrVerification, err)
+	}
+	expected, ok := parsed.Files[chartFile]
+	if !ok {
+		return nil, fmt.Errorf("%w: provenance has no digest for %s", ErrVerification, chartFile)
+	}
+	sum := sha256.Sum256(data)
+	if actual := "sha256:" + hex.EncodeToString(sum[:]); actual != expected {
+		return nil, fmt.Errorf("%w: %s does not match its provenance: expected %s, got %s", ErrVerification, chartFile, expected, actual)
+	}
+	return signer, nil
+}
@@ -0,0 +1,331 @@
+package helm
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The podinfo 6.9.0 chart packaged and signed with `helm package --sign` by the Ed25519 key.
+const (
+	provenanceTestChart  = "H4sIAAAAAAAAA+3USw6CMBSFYcasoivAFlqITN2D80YxkvAKr8Tdq0QGaowTQI3/N2lDB73J6aEq92lxKFfOjORFZMywXjyuw15po7WW0h++R0EUOMLMOdSoa1pbC7HEVd+ouuW/Odq69U42z6a/4xpwqPXr/I2+z1+pQEWOkNOP8uzP87dVuk3qJi2LWPS+W9g8icXtUbj9eBJ6a0+6n54V0xv739usS5p5fgBv++/Lx/4bHdL/JdRJlaU7uym7oo2FouMAAAAAAAAAAAAAAPygM/CZRMkAKAAA"
+	provenanceTestDigest = "bcd07239ce0b6671de84230cac2904855aa5f59b6187ffa4e75be4b76d1aa990"
+	provenanceTestProv   = `-----BEGIN PGP SIGNED MESSAGE-----
+Hash: SHA512
+
+apiVersion: v2
+name: podinfo
+version: 6.9.0
+
+...
+files:
+  podinfo-6.9.0.tgz: sha256:bcd07239ce0b6671de84230cac2904855aa5f59b6187ffa4e75be4b76d1aa990
+-----BEGIN PGP SIGNATURE-----
+
+iIkEARYKADEWIQQg891oxtIpj67xZO5aubFjnqz/6gUCatHN4RMcY2hhcnRzQGV4
+YW1wbGUuY29tAAoJEFq5sWOerP/qO1gBALovuOPiVmcSqItZ+BDISqe7Wb+Awf2w
+VcI7r7O7hMJwAQCZpoK/HBh1tE3QPj0HHSWrNrUl/Q2JRpuA6wRmbV6BAg==
+=ufXj
+-----END PGP SIGNATURE-----
+`
+	provenanceTestSigner = `-----BEGIN PGP PUBLIC KEY BLOCK-----
+
+mDMEatHN1BYJKwYBBAHaRw8BAQdA+MoWQdbuvp/3uHznZD9o+A9oZrh7nsijmpRv
+TYbjZR20IUNoYXJ0IFNpZ25lciA8Y2hhcnRzQGV4YW1wbGUuY29tPoiQBBMWCAA4
+FiEEIPPdaMbSKY+u8WTuWrmxY56s/+oFAmrRzdQCGwMFCwkIBwIGFQoJCAsCBBYC
+AwECHgECF4AACgkQWrmxY56s/+qpXAEApdCeZP9OAIGW2+Sujy8axQOdOCx42p6W
+ILSE4Yn65VIA/Rs2hvvPyV/hrkNbSaTkO6CVw/qoe8pe1vU1Ij9+aZcM
+=LBOL
+-----END PGP PUBLIC KEY BLOCK-----
+`
+	provenanceTestOther = `-----BEGIN PGP PUBLIC KEY BLOCK-----
+
+mQENBGrRzdQBCACoJ8/s8UE3YLD5Tc7k9utKiI22DKNdjp618itTGnCKvTdknNoF
+gdMwIUNbTT1qadNLbZFkQtQ6bcyMiO0JhmTAx0uLpzNaF4HwV6Jsi8B8oDcnpA2c
+hKF/tmJvxe1HNFShrVSjb11Kq/inFQjrjillm8inHne+z4aYIYQjsQeYRjf2/2RN
+wubJfPxfw3z7elC0LCsKiptXJpjBlaIjgo7CTedqzuDTj46ZfjTjzWfvtZXBRpcq
+RM7mt3/eDRVGSjZtENcRPwoRTe/0L6GbwJA96n0uGqRmNYD6m5ZmHp7EdtcXaVL6
+qPU7J+oxWAiAHtW+693T7AFhFucToYb2Th+7ABEBAAG0HFJTQSBTaWduZXIgPHJz
+YUBleGFtcGxlLmNvbT6JAU4EEwEKADgWIQQZc11dqItdNphybOvFONCcKKrk9wUC
+atHN1AIbAwULCQgHAgYVCgkICwIEFgIDAQIeAQIXgAAKCRDFONCcKKrk90GlB/4m
+U10olLoL0eAQNyYXJf2lgIavfsJipgbQ8P8Z8DLIMDPIyxEp8zEVCyj92Am5pdlz
+7XaDuYdh2TXCCXtAOO+RM/9cEQyQagzo6pVnDVRlnSFpeC9RoloK/bg1V1FNWKb/
+B3IQ7vGf6UuhWYvKGH5ZmvrpohwYw0s2fyYZ1PnDQSBvISgb5DYPArIhsgIYy3KD
+/SvCn2gvzjbCaiMiGomqmw7CrJCR6CbpNS1W5g5NFAbKig/39Bz17pBq5AztGL/o
+WbGPr18uHug34JdnZUIaPlZBbUDqB/t3Qf576zbomkkTz9Sr32+lUTl02Rsdy8+Y
+nXB2rpR8JOrk8wIvbqri
+=pcNW
+-----END PGP PUBLIC KEY BLOCK-----
+`
+)
+
+// newProvenanceTestServer serves the signed podinfo chart, listed with the given digest, and its
+// provenance file unless prov is empty.
+func newProvenanceTestServer(t *testing.T, digest, prov string) *httptest.Server {
+	t.Helper()
+	chart, err := base64.StdEncoding.DecodeString(provenanceTestChart)
+	require.NoError(t, err)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/index.yaml":
+			_, _ = w.Write([]byte(cacheTestIndex + "      digest: " + digest + "\n"))
+		case "/podinfo-6.9.0.tgz":
+			_, _ = w.Write(chart)
+		case "/podinfo-6.9.0.tgz.prov":
+			if prov == "" {
+				http.NotFound(w, r)
+				return
+			}
+			_, _ = w.Write([]byte(prov))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// useKeyring sets the package keyring for the duration of a test.
+func useKeyring(t *testing.T, armored string) {
+	t.Helper()
+	keyring, err := ReadKeyring([]byte(armored))
+	require.NoError(t, err)
+	SetKeyring(keyring)
+	t.Cleanup(func() {
+		SetKeyring(nil)
+	})
+}
+
+func TestDownloadChart_Digest(t *testing.T) {
+	server := newProvenanceTestServer(t, provenanceTestDigest, "")
+	values, err := DownloadAndExtractValuesYAML(context.Background(), server.URL, "podinfo", "6.9.0")
+	require.NoError(t, err)
+	assert.Equal(t, "replicaCount: 1\n", values)
+
+	server = newProvenanceTestServer(t, strings.Repeat("0", 64), "")
+	_, err = DownloadChart(context.Background(), server.URL, "podinfo", "6.9.0")
+	assert.True(t, errors.Is(err, ErrVerification), "unexpected error: %v", err)
+	assert.ErrorContains(t, err, "digest mismatch")
+}
+
+func TestDownloadChart_Provenance(t *testing.T) {
+	tests := []struct {
+		name    string
+		keyring string
+		prov    string
+		message string
+	}{
+		{"signed by a trusted key", provenanceTestSigner, provenanceTestProv, ""},
+		{"without provenance", provenanceTestSigner, "", "has no provenance file"},
+		{"signed by an unknown key", provenanceTestOther, provenanceTestProv, "unknown entity"},
+		{"tampered provenance", provenanceTestSigner, strings.Replace(provenanceTestProv, "version: 6.9.0", "version: 6.9.1", 1), "invalid signature"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useKeyring(t, tt.keyring)
+			server := newProvenanceTestServer(t, "", tt.prov)
+			_, err := DownloadChart(context.Background(), server.URL, "podinfo", "6.9.0")
+			if tt.message == "" {
+				require.NoError(t, err)
+				return
+			}
+			assert.True(t, errors.Is(err, ErrVerification), "unexpected error: %v", err)
+			assert.ErrorContains(t, err, tt.message)
+		})
+	}
+}
+
+func TestDownloadChart_ProvenanceCached(t *testing.T) {
+	useKeyring(t, provenanceTestSigner)
+	dir := t.TempDir()
+	useCache(t, NewCache(dir, time.Hour, false))
+	server := newProvenanceTestServer(t, provenanceTestDigest, provenanceTestProv)
+	_, err := DownloadChart(context.Background(), server.URL, "podinfo", "6.9.0")
+	require.NoError(t, err)
+
+	// The cached chart is verified with the cached provenance offline
+	useCache(t, NewCache(dir, time.Hour, true))
+	server.Close()
+	_, err = DownloadChart(context.Background(), server.URL, "podinfo", "6.9.0")
+	require.NoError(t, err)
+}
+
+func TestDownloadChart_MissingProvenanceNotCached(t *testing.T) {
+	useKeyring(t, provenanceTestSigner)
+	useCache(t, NewCache(t.TempDir(), time.Hour, false))
+
+	// The repository serves the chart without its provenance, then with it
+	var handler atomic.Value
+	handler.Store(newProvenanceTestServer(t, provenanceTestDigest, "").Config.Handler)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.Load().(http.Handler).ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	_, err := DownloadChart(context.Background(), server.URL, "podinfo", "6.9.0")
+	assert.True(t, errors.Is(err, ErrVerification), "unexpected error: %v", err)
+
+	handler.Store(newProvenanceTestServer(t, provenanceTestDigest, provenanceTestProv).Config.Handler)
+	_, err = DownloadChart(context.Background(), server.URL, "podinfo", "6.9.0")
+	require.NoError(t, err)
+}
+
+func TestVerifyProvenance(t *testing.T) {
+	keyring, err := ReadKeyring([]byte(provenanceTestSigner))
+	require.NoError(t, err)
+	chart, err := base64.StdEncoding.DecodeString(provenanceTestChart)
+	require.NoError(t, err)
+
+	signer, err := VerifyProvenance(keyring, []byte(provenanceTestProv), "podinfo-6.9.0.tgz", chart)
+	require.NoError(t, err)
+	assert.Contains(t, signer.Identities, "Chart Signer <charts@example.com>")
+
+	_, err = VerifyProvenance(keyring, []byte(provenanceTestProv), "podinfo-6.9.0.tgz", append(chart, 0))
+	assert.ErrorContains(t, err, "does not match its provenance")
+	_, err = VerifyProvenance(keyring, []byte(provenanceTestProv), "other-1.0.0.tgz", chart)
+	assert.ErrorContains(t, err, "no digest for other-1.0.0.tgz")
+	_, err = VerifyProvenance(keyring, []byte("name: podinfo\n"), "podinfo-6.9.0.tgz", chart)
+	assert.True(t, errors.Is(err, ErrVerification), "unexpected error: %v", err)
+}
+
+func TestReadKeyring(t *testing.T) {
+	keyring, err := ReadKeyring([]byte(provenanceTestSigner + provenanceTestOther))
+	require.NoError(t, err)
+	require.Len(t, keyring, 2)
+	assert.Contains(t, keyring[0].Identities, "Chart Signer <charts@example.com>")
+
+	// Binary keyrings, such as gpg --export without --armor, are read too
+	var binary []byte
+	for _, key := range []string{provenanceTestSigner, provenanceTestOther} {
+		block, err := armor.Decode(strings.NewReader(key))
+		require.NoError(t, err)
+		data, err := io.ReadAll(block.Body)
+		require.NoError(t, err)
+		binary = append(binary, data...)
+	}
+	path := filepath.Join(t.TempDir(), "pubring.gpg")
+	require.NoError(t, os.WriteFile(path, binary, 0o600))
+	keyring, err = LoadKeyring(path)
+	require.NoError(t, err)
+	assert.Len(t, keyring, 2)
+
+	_, err = ReadKeyring([]byte("not a keyring"))
+	assert.Error(t, err)
+	_, err = LoadKeyring(filepath.Join(t.TempDir(), "missing.gpg"))
+	assert.ErrorContains(t, err, "failed to read keyring")
+}
+
+// signProvenance clearsigns the provenance of the test chart with the key, at the time and with
+// the hash of the config. The signature is made by hand, as go-crypto refuses to sign with hashes
+// such as SHA-1.
+func signProvenance(t *testing.T, key *openpgp.Entity, config *packet.Config) string {
+	t.Helper()
+	text, _, found := strings.Cut(provenanceTestProv, "\n-----BEGIN PGP SIGNATURE-----")
+	require.True(t, found)
+	_, text, _ = strings.Cut(text, "\n\n")
+
+	sig := &packet.Signature{
+		Version:      key.PrimaryKey.Version,
+		SigType:      packet.SigTypeText,
+		PubKeyAlgo:   key.PrimaryKey.PubKeyAlgo,
+		Hash:         config.Hash(),
+		CreationTime: config.Now(),
+		IssuerKeyId:  &key.PrimaryKey.KeyId,
+	}
+	h := config.Hash().New()
+	_, err := io.WriteString(h, strings.ReplaceAll(text, "\n", "\r\n"))
+	require.NoError(t, err)
+	require.NoError(t, sig.Sign(h, key.PrivateKey, config))
+
+	var armored bytes.Buffer
+	w, err := armor.Encode(&armored, "PGP SIGNATURE", nil)
+	require.NoError(t, err)
+	require.NoError(t, sig.Serialize(w))
+	require.NoError(t, w.Close())
+	for name, hash := range clearsignHashes {
+		if hash == config.Hash() {
+			return "-----BEGIN PGP SIGNED MESSAGE-----\nHash: " + name + "\n\n" + text + "\n" + armored.String() + "\n"
+		}
+	}
+	t.Fatalf("no clearsign name for %s", config.Hash())
+	return ""
+}
+
+// newSigningKey generates an Ed25519 key signing provenance files.
+func newSigningKey(t *testing.T, config *packet.Config) *openpgp.Entity {
+	t.Helper()
+	if config == nil {
+		config = &packet.Config{}
+	}
+	config.Algorithm = packet.PubKeyAlgoEdDSA
+	key, err := openpgp.NewEntity("Test Signer", "", "test@example.com", config)
+	require.NoError(t, err)
+	return key
+}
+
+func TestVerifyProvenance_RejectedSignatures(t *testing.T) {
+	chart, err := base64.StdEncoding.DecodeString(provenanceTestChart)
+	require.NoError(t, err)
+
+	sha256 := &packet.Config{DefaultHash: crypto.SHA256}
+	key := newSigningKey(t, nil)
+	prov := signProvenance(t, key, sha256)
+	_, err = VerifyProvenance(openpgp.EntityList{key}, []byte(prov), "podinfo-6.9.0.tgz", chart)
+	require.NoError(t, err)
+
+	revoked := newSigningKey(t, nil)
+	revokedProv := signProvenance(t, revoked, sha256)
+	require.NoError(t, revoked.RevokeKey(packet.KeyCompromised, "compromised", nil))
+	// Valid for an hour, two days ago
+	past := &packet.Config{
+		DefaultHash:     crypto.SHA256,
+		Time:            func() time.Time { return time.Now().Add(-48 * time.Hour) },
+		KeyLifetimeSecs: 3600,
+	}
+	expired := newSigningKey(t, past)
+	expiredProv := signProvenance(t, expired, past)
+	// Without the salt notation, which has no size for SHA-1
+	noSalt := false
+	sha1Prov := signProvenance(t, key, &packet.Config{DefaultHash: crypto.SHA1, NonDeterministicSignaturesViaNotation: &noSalt})
+	other, err := ReadKeyring([]byte(provenanceTestOther))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		keyring openpgp.EntityList
+		prov    string
+		err     string
+	}{
+		{"unknown key", other, prov, "unknown entity"},
+		{"tampered", openpgp.EntityList{key}, strings.Replace(prov, "version: 6.9.0", "version: 6.9.1", 1), "invalid signature"},
+		{"revoked key", openpgp.EntityList{revoked}, revokedProv, "revoked"},
+		{"expired key", openpgp.EntityList{expired}, expiredProv, "expired"},
+		{"SHA-1", openpgp.EntityList{key}, sha1Prov, "insecure hash"},
+		{"hash header mismatch", openpgp.EntityList{key}, strings.Replace(prov, "Hash: SHA256", "Hash: SHA512", 1), "mismatch with cleartext message headers"},
+		{"unsupported hash header", openpgp.EntityList{key}, strings.Replace(prov, "Hash: SHA256", "Hash: MD4", 1), "unsupported hash"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := VerifyProvenance(tt.keyring, []byte(tt.prov), "podinfo-6.9.0.tgz", chart)
+			require.True(t, errors.Is(err, ErrVerification), "unexpected error: %v", err)
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
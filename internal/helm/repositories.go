@@ -0,0 +1,110 @@
+package helm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Repository is an entry of the repositories.yaml file of the Helm CLI.
+type Repository struct {
+	Name                  string `yaml:"name"`
+	URL                   string `yaml:"url"`
+	Username              string `yaml:"username"`
+	Password              string `yaml:"password"`
+	CAFile                string `yaml:"caFile"`
+	CertFile              string `yaml:"certFile"`
+	KeyFile               string `yaml:"keyFile"`
+	InsecureSkipTLSVerify bool   `yaml:"insecure_skip_tls_verify"`
+}
+
+// Auth returns the credentials and TLS settings of the repository.
+func (r Repository) Auth() Auth {
+	return Auth{
+		Username:              r.Username,
+		Password:              r.Password,
+		CAFile:                r.CAFile,
+		CertFile:              r.CertFile,
+		KeyFile:               r.KeyFile,
+		InsecureSkipTLSVerify: r.InsecureSkipTLSVerify,
+	}
+}
+
+// DefaultRepositoriesFile returns the path of the repositories.yaml file of the Helm CLI,
+// following the same environment variables and platform defaults as Helm.
+func DefaultRepositoriesFile() string {
+	if path := os.Getenv("HELM_REPOSITORY_CONFIG"); path != "" {
+		return path
+	}
+	if home := os.Getenv("HELM_CONFIG_HOME"); home != "" {
+		return filepath.Join(home, "repositories.yaml")
+	}
+
+	var base string
+	switch runtime.GOOS {
+	case "darwin":
+		if home, err := os.UserHomeDir(); err == nil {
+			base = filepath.Join(home, "Library", "Preferences")
+		}
+	case "windows":
+		base = os.Getenv("APPDATA")
+	default:
+		if base = os.Getenv("XDG_CONFIG_HOME"); base == "" {
+			if home, err := os.UserHomeDir(); err == nil {
+				base = filepath.Join(home, ".config")
+			}
+		}
+	}
+	if base == "" {
+		return ""
+	}
+	return filepath.Join(base, "helm", "repositories.yaml")
+}
+
+// LoadRepositories reads the repositories of a Helm repositories.yaml file.
+// A missing file holds no repositories.
+func LoadRepositories(path string) ([]Repository, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path) // #nosec G304 -- path of the Helm configuration
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Helm repositories: %w", err)
+	}
+
+	var file struct {
+		Repositories []Repository `yaml:"repositories"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse Helm repositories %s: %w", path, err)
+	}
+	return file.Repositories, nil
+}
+
+// FindRepository returns the repository with the given URL, ignoring trailing slashes.
+func FindRepository(repositories []Repository, repoURL string) (Repository, bool) {
+	for _, repository := range repositories {
+		if strings.TrimSuffix(repository.URL, "/") == strings.TrimSuffix(repoURL, "/") {
+			return repository, true
+		}
+	}
+	return Repository{}, false
+}
+
+// FindRepositoryByName returns the repository with the given name.
+func FindRepositoryByName(repositories []Repository, name string) (Repository, bool) {
+	for _, repository := range repositories {
+		if repository.Name == name {
+			return repository, true
+		}
+	}
+	return Repository{}, false
+}
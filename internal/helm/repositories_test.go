@@ -0,0 +1,65 @@
+package helm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const helmRepositoriesFile = `apiVersion: ""
+generated: "0001-01-01T00:00:00Z"
+repositories:
+- caFile: /etc/ssl/internal-ca.crt
+  certFile: ""
+  insecure_skip_tls_verify: false
+  keyFile: ""
+  name: internal
+  pass_credentials_all: false
+  password: s3cret
+  url: https://charts.internal.example.com/
+  username: robot
+- name: bitnami
+  url: https://charts.bitnami.com/bitnami
+`
+
+func TestLoadRepositories(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "repositories.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(helmRepositoriesFile), 0o600))
+
+	repositories, err := LoadRepositories(path)
+	require.NoError(t, err)
+	require.Len(t, repositories, 2)
+
+	repository, ok := FindRepository(repositories, "https://charts.internal.example.com")
+	require.True(t, ok)
+	assert.Equal(t, Auth{Username: "robot", Password: "s3cret", CAFile: "/etc/ssl/internal-ca.crt"}, repository.Auth())
+
+	_, ok = FindRepository(repositories, "https://charts.example.com")
+	assert.False(t, ok)
+
+	repository, ok = FindRepositoryByName(repositories, "bitnami")
+	require.True(t, ok)
+	assert.Equal(t, "https://charts.bitnami.com/bitnami", repository.URL)
+	_, ok = FindRepositoryByName(repositories, "stable")
+	assert.False(t, ok)
+
+	repositories, err = LoadRepositories(filepath.Join(t.TempDir(), "missing.yaml"))
+	require.NoError(t, err)
+	assert.Empty(t, repositories)
+
+	require.NoError(t, os.WriteFile(path, []byte("repositories: {"), 0o600))
+	_, err = LoadRepositories(path)
+	assert.ErrorContains(t, err, "failed to parse Helm repositories")
+}
+
+func TestDefaultRepositoriesFile(t *testing.T) {
+	t.Setenv("HELM_REPOSITORY_CONFIG", "/tmp/repositories.yaml")
+	assert.Equal(t, "/tmp/repositories.yaml", DefaultRepositoriesFile())
+
+	t.Setenv("HELM_REPOSITORY_CONFIG", "")
+	t.Setenv("HELM_CONFIG_HOME", "/tmp/helm")
+	assert.Equal(t, filepath.Join("/tmp/helm", "repositories.yaml"), DefaultRepositoriesFile())
+}
@@ -0,0 +1,147 @@
+package helm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ChartMatch is a chart found by SearchCharts, with its newest stable version.
+type ChartMatch struct {
+	Repository  Repository
+	Name        string
+	Version     string
+	AppVersion  string
+	Description string
+}
+
+// DefaultRepositoryCacheDir returns the directory the Helm CLI caches repository indexes in,
+// following the same environment variables and platform defaults as Helm.
+func DefaultRepositoryCacheDir() string {
+	if dir := os.Getenv("HELM_REPOSITORY_CACHE"); dir != "" {
+		return dir
+	}
+	if home := os.Getenv("HELM_CACHE_HOME"); home != "" {
+		return filepath.Join(home, "repository")
+	}
+
+	var base string
+	switch runtime.GOOS {
+	case "darwin":
+		if home, err := os.UserHomeDir(); err == nil {
+			base = filepath.Join(home, "Library", "Caches")
+		}
+	case "windows":
+		base = os.TempDir()
+	default:
+		if base = os.Getenv("XDG_CACHE_HOME"); base == "" {
+			if home, err := os.UserHomeDir(); err == nil {
+				base = filepath.Join(home, ".cache")
+			}
+		}
+	}
+	if base == "" {
+		return ""
+	}
+	return filepath.Join(base, "helm", "repository")
+}
+
+// LoadCachedIndex reads the index of a repository cached by `helm repo update`.
+func LoadCachedIndex(cacheDir, name string) (*IndexYAML, error) {
+	if cacheDir == "" {
+		return nil, fmt.Errorf("index of %s: %w", name, os.ErrNotExist)
+	}
+	data, err := os.ReadFile(filepath.Join(cacheDir, name+"-index.yaml")) // #nosec G304 -- Helm cache of a configured repository
+	if err != nil {
+		return nil, err
+	}
+	return parseIndex(data)
+}
+
+// SearchCharts looks for charts whose name contains the query, case-insensitively, in every
+// repository. The indexes cached by the Helm CLI are used when present, the others are fetched
+// like any index. Repositories whose index is unavailable are skipped; an error is only returned
+// when no index could be read. Exact matches come first, then charts by name.
+func (vf *VersionFetcher) SearchCharts(ctx context.Context, repositories []Repository, query string) ([]ChartMatch, error) {
+	query = strings.ToLower(strings.TrimSpace(query))
+	cacheDir := DefaultRepositoryCacheDir()
+
+	results := make([][]ChartMatch, len(repositories))
+	errs := make([]error, len(repositories))
+	var wg sync.WaitGroup
+	for i, repository := range repositories {
+		if IsOCI(repository.URL) {
+			errs[i] = fmt.Errorf("%s: %w", repository.Name, ErrOCIChartListing)
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			idx, err := LoadCachedIndex(cacheDir, repository.Name)
+			if err != nil {
+				idx, err = vf.fetchIndex(ctx, repository.URL)
+			}
+			if err != nil {
+				errs[i] = fmt.Errorf("%s: %w", repository.Name, err)
+				return
+			}
+			results[i] = idx.search(repository, query)
+		}()
+	}
+	wg.Wait()
+
+	var matches []ChartMatch
+	available := false
+	for i := range repositories {
+		matches = append(matches, results[i]...)
+		available = available || errs[i] == nil
+	}
+	if !available && len(repositories) > 0 {
+		return nil, fmt.Errorf("no repository index available: %w", errors.Join(errs...))
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		iExact, jExact := strings.ToLower(matches[i].Name) == query, strings.ToLower(matches[j].Name) == query
+		switch {
+		case iExact != jExact:
+			return iExact
+		case matches[i].Name != matches[j].Name:
+			return matches[i].Name < matches[j].Name
+		}
+		return matches[i].Repository.Name < matches[j].Repository.Name
+	})
+	return matches, nil
+}
+
+// search returns the charts of the index whose name contains the lowercase query.
+func (idx *IndexYAML) search(repository Repository, query string) []ChartMatch {
+	var matches []ChartMatch
+	for name := range idx.Entries {
+		if !strings.Contains(strings.ToLower(name), query) {
+			continue
+		}
+		versions, _ := idx.chartVersions(name)
+		if len(versions) == 0 {
+			continue
+		}
+		// The newest stable version, or the newest prerelease of charts without one
+		latest := versions[0]
+		if stable, err := FilterVersions(versions, VersionFilter{}); err == nil && len(stable) > 0 {
+			latest = stable[0]
+		}
+		matches = append(matches, ChartMatch{
+			Repository:  repository,
+			Name:        name,
+			Version:     latest.ChartVersion,
+			AppVersion:  latest.AppVersion,
+			Description: latest.Description,
+		})
+	}
+	return matches
+}
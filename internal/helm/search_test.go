@@ -0,0 +1,74 @@
+package helm
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const cachedBitnamiIndex = `apiVersion: v1
+entries:
+  nginx:
+    - version: 15.0.0
+      appVersion: 1.25.0
+      description: NGINX Open Source
+    - version: 16.0.0-rc.1
+  nginx-ingress-controller:
+    - version: 11.0.0
+  redis:
+    - version: 19.0.0
+`
+
+func TestSearchCharts(t *testing.T) {
+	cacheDir := t.TempDir()
+	t.Setenv("HELM_REPOSITORY_CACHE", cacheDir)
+	require.NoError(t, os.WriteFile(filepath.Join(cacheDir, "bitnami-index.yaml"), []byte(cachedBitnamiIndex), 0o600))
+
+	// Repositories missing from the Helm cache are fetched, unavailable ones are skipped
+	vf := NewMockVersionFetcher(func(_ context.Context, repoURL string) (*IndexYAML, error) {
+		if repoURL == "https://kubernetes.github.io/ingress-nginx" {
+			return parseIndex([]byte("entries:\n  ingress-nginx:\n    - version: 4.10.0\n"))
+		}
+		return nil, errors.New("unreachable")
+	})
+	repositories := []Repository{
+		{Name: "ingress-nginx", URL: "https://kubernetes.github.io/ingress-nginx"},
+		{Name: "bitnami", URL: "https://charts.bitnami.com/bitnami"},
+		{Name: "offline", URL: "https://charts.offline.example.com"},
+	}
+
+	matches, err := vf.SearchCharts(context.Background(), repositories, "NGINX")
+	require.NoError(t, err)
+	require.Len(t, matches, 3)
+	assert.Equal(t, ChartMatch{
+		Repository:  repositories[1],
+		Name:        "nginx",
+		Version:     "15.0.0",
+		AppVersion:  "1.25.0",
+		Description: "NGINX Open Source",
+	}, matches[0])
+	assert.Equal(t, "ingress-nginx", matches[1].Name)
+	assert.Equal(t, "ingress-nginx", matches[1].Repository.Name)
+	assert.Equal(t, "nginx-ingress-controller", matches[2].Name)
+
+	matches, err = vf.SearchCharts(context.Background(), repositories, "postgresql")
+	require.NoError(t, err)
+	assert.Empty(t, matches)
+
+	_, err = vf.SearchCharts(context.Background(), repositories[2:], "nginx")
+	assert.ErrorContains(t, err, "no repository index available")
+}
+
+func TestDefaultRepositoryCacheDir(t *testing.T) {
+	t.Setenv("HELM_REPOSITORY_CACHE", "/tmp/helm-cache")
+	assert.Equal(t, "/tmp/helm-cache", DefaultRepositoryCacheDir())
+
+	t.Setenv("HELM_REPOSITORY_CACHE", "")
+	t.Setenv("HELM_CACHE_HOME", "/tmp/helm")
+	assert.Equal(t, filepath.Join("/tmp/helm", "repository"), DefaultRepositoryCacheDir())
+}
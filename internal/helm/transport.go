@@ -0,0 +1,155 @@
+package helm
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Defaults of the network settings.
+const (
+	DefaultTimeout = 30 * time.Second
+	DefaultRetries = 2
+	DefaultBackoff = 500 * time.Millisecond
+)
+
+// Network holds the settings of the requests to repositories and registries.
+type Network struct {
+	// Timeout limits each attempt of a request, including reading its body. Zero disables it.
+	Timeout time.Duration
+	// Retries is how many times a request failing with a network error, status 429 or a 5xx
+	// status is retried.
+	Retries int
+	// Backoff is the delay before the first retry, doubled for each following retry.
+	Backoff time.Duration
+}
+
+// DefaultNetwork returns the default network settings.
+func DefaultNetwork() Network {
+	return Network{Timeout: DefaultTimeout, Retries: DefaultRetries, Backoff: DefaultBackoff}
+}
+
+// activeNetwork holds the settings set with SetNetwork.
+var activeNetwork = struct {
+	sync.Mutex
+	network Network
+}{network: DefaultNetwork()}
+
+// SetNetwork sets the timeout and retries of every request.
+func SetNetwork(network Network) {
+	activeNetwork.Lock()
+	defer activeNetwork.Unlock()
+	activeNetwork.network = network
+}
+
+// currentNetwork returns the settings set with SetNetwork.
+func currentNetwork() Network {
+	activeNetwork.Lock()
+	defer activeNetwork.Unlock()
+	return activeNetwork.network
+}
+
+// defaultClient is the HTTP client of hosts without TLS settings.
+var defaultClient = &http.Client{Transport: newTransport()}
+
+// newTransport returns a transport going through the proxies of the HTTP_PROXY, HTTPS_PROXY and
+// NO_PROXY environment variables.
+func newTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxyFromEnvironment
+	return transport
+}
+
+// proxyFromEnvironment returns the proxy of a request. Unlike http.ProxyFromEnvironment, the
+// environment is read for every request.
+func proxyFromEnvironment(req *http.Request) (*url.URL, error) {
+	return httpproxy.FromEnvironment().ProxyFunc()(req.URL)
+}
+
+// send sends a request with the timeout and retries of the network settings. Requests failing
+// with a transient network error, status 429 or a 5xx status are retried with an exponential backoff,
+// and the last response is returned whatever its status. The caller closes its body.
+// The request must not have a body.
+func send(ctx context.Context, client *http.Client, req *http.Request) (*http.Response, error) {
+	network := currentNetwork()
+	backoff := network.Backoff
+	for attempt := 0; ; attempt++ {
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if network.Timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, network.Timeout)
+		}
+		resp, err := client.Do(req.Clone(attemptCtx)) // #nosec G107 -- the repository is chosen by the user
+		last := attempt >= network.Retries
+		switch {
+		case err == nil && (last || !retryable(resp.StatusCode)):
+			// The attempt lasts until the body is read
+			resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+			return resp, nil
+		case err == nil:
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			_ = resp.Body.Close()
+			err = fmt.Errorf("status %d", resp.StatusCode)
+		case ctx.Err() != nil:
+			cancel()
+			return nil, ctx.Err()
+		case errors.Is(attemptCtx.Err(), context.DeadlineExceeded):
+			err = fmt.Errorf("request timed out after %s", network.Timeout)
+		case permanent(err):
+			cancel()
+			return nil, err
+		}
+		cancel()
+		if last {
+			return nil, err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// retryable reports whether a request failing with the status may succeed when retried.
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// permanent reports whether a request failing with a network error fails the same way when
+// retried, such as with an unknown host or an untrusted certificate.
+func permanent(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsNotFound
+	}
+	var verifyErr *tls.CertificateVerificationError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	return errors.As(err, &verifyErr) || errors.As(err, &unknownAuthority) || errors.As(err, &hostnameErr)
+}
+
+// cancelBody releases the context of a request once its body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,136 @@
+package helm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useNetwork sets the network settings for the duration of a test.
+func useNetwork(t *testing.T, network Network) {
+	t.Helper()
+	SetNetwork(network)
+	t.Cleanup(func() {
+		SetNetwork(DefaultNetwork())
+	})
+}
+
+func TestSend_Retries(t *testing.T) {
+	useNetwork(t, Network{Timeout: time.Second, Retries: 2, Backoff: time.Millisecond})
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/flaky/index.yaml":
+			if requests.Add(1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			_, _ = w.Write([]byte(cacheTestIndex))
+		case "/down/index.yaml":
+			requests.Add(1)
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			requests.Add(1)
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	// Server errors are retried until the request succeeds
+	idx, err := fetchIndexYAML(context.Background(), server.URL+"/flaky")
+	require.NoError(t, err)
+	assert.Len(t, idx.Entries["podinfo"], 1)
+	assert.Equal(t, int32(3), requests.Load())
+
+	// The status of the last attempt is reported
+	requests.Store(0)
+	_, err = fetchIndexYAML(context.Background(), server.URL+"/down")
+	assert.ErrorContains(t, err, "status 502")
+	assert.Equal(t, int32(3), requests.Load())
+
+	// Client errors are not retried
+	requests.Store(0)
+	_, err = fetchIndexYAML(context.Background(), server.URL+"/missing")
+	assert.ErrorContains(t, err, "status 404")
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestSend_Timeout(t *testing.T) {
+	useNetwork(t, Network{Timeout: 20 * time.Millisecond, Retries: 1, Backoff: time.Millisecond})
+
+	var requests atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	_, err := fetchIndexYAML(context.Background(), server.URL)
+	assert.ErrorContains(t, err, "request timed out after 20ms")
+	assert.Equal(t, int32(2), requests.Load())
+}
+
+func TestSend_Cancel(t *testing.T) {
+	useNetwork(t, Network{Retries: 5, Backoff: time.Hour})
+
+	started := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	done := make(chan error)
+	go func() {
+		_, err := fetchIndexYAML(ctx, server.URL)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		assert.True(t, errors.Is(err, context.Canceled), "unexpected error: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the request was not cancelled")
+	}
+}
+
+func TestSend_Proxy(t *testing.T) {
+	var proxied atomic.Value
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied.Store(r.URL.String())
+		_, _ = w.Write([]byte(cacheTestIndex))
+	}))
+	defer proxy.Close()
+	t.Setenv("HTTP_PROXY", proxy.URL)
+	t.Setenv("NO_PROXY", "")
+
+	idx, err := fetchIndexYAML(context.Background(), "http://charts.example.com/stable")
+	require.NoError(t, err)
+	assert.Len(t, idx.Entries["podinfo"], 1)
+	assert.Equal(t, "http://charts.example.com/stable/index.yaml", proxied.Load())
+
+	// Hosts listed in NO_PROXY are reached directly
+	t.Setenv("NO_PROXY", "example.com")
+	_, err = fetchIndexYAML(context.Background(), "http://charts.example.com/stable")
+	require.Error(t, err)
+	assert.False(t, strings.Contains(err.Error(), "status"), "unexpected error: %v", err)
+}
@@ -2,23 +2,31 @@ package helm
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"sort"
+	"strings"
 
-	"gopkg.in/yaml.v3"
+	"github.com/EffectiveSloth/flux-app-generator/internal/semver"
 )
 
 // IndexYAML represents the structure of a Helm repository index.yaml file.
 type IndexYAML struct {
-	Entries map[string][]struct {
-		Version     string   `yaml:"version"`
-		AppVersion  string   `yaml:"appVersion"`
-		Description string   `yaml:"description"`
-		URLs        []string `yaml:"urls"`
-	} `yaml:"entries"`
+	Entries map[string][]IndexEntry `yaml:"entries"`
+}
+
+// IndexEntry is a chart version listed in a repository index.
+type IndexEntry struct {
+	Version     string   `yaml:"version"`
+	AppVersion  string   `yaml:"appVersion"`
+	Description string   `yaml:"description"`
+	Keywords    []string `yaml:"keywords"`
+	Deprecated  bool     `yaml:"deprecated"`
+	URLs        []string `yaml:"urls"`
+	Digest      string   `yaml:"digest"` // SHA-256 of the chart tarball, in hex
 }
 
 // ChartVersion represents a chart version with metadata.
@@ -29,24 +37,94 @@ type ChartVersion struct {
 	DisplayString string
 }
 
-type fetchIndexYAMLFunc func(repoURL string) (*IndexYAML, error)
+// IsPrerelease reports whether the chart version is a semver prerelease, such as 2.0.0-rc.1.
+func (v ChartVersion) IsPrerelease() bool {
+	parsed, err := semver.Parse(v.ChartVersion)
+	return err == nil && parsed.IsPrerelease()
+}
+
+// VersionFilter selects chart versions.
+type VersionFilter struct {
+	// Constraint is a semver constraint, such as "~1.4" or ">=2 <3", the versions must satisfy.
+	// Empty matches every version.
+	Constraint string
+	// IncludePrerelease keeps prerelease versions, which are hidden by default. A constraint only
+	// matches the prereleases it names, e.g. ">=2.0.0-0", whatever this setting.
+	IncludePrerelease bool
+}
+
+// SortVersions sorts chart versions newest first, by semver precedence. Versions that are not
+// valid semver come last, in reverse lexical order.
+func SortVersions(versions []ChartVersion) {
+	parsed := make(map[string]semver.Version, len(versions))
+	for _, version := range versions {
+		if v, err := semver.Parse(version.ChartVersion); err == nil {
+			parsed[version.ChartVersion] = v
+		}
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		a, aOK := parsed[versions[i].ChartVersion]
+		b, bOK := parsed[versions[j].ChartVersion]
+		switch {
+		case aOK && bOK:
+			return a.Compare(b) > 0
+		case aOK != bOK:
+			return aOK
+		}
+		return versions[i].ChartVersion > versions[j].ChartVersion
+	})
+}
+
+// FilterVersions returns the versions selected by the filter, in their original order.
+func FilterVersions(versions []ChartVersion, filter VersionFilter) ([]ChartVersion, error) {
+	var constraint *semver.Constraint
+	if filter.Constraint != "" {
+		var err error
+		if constraint, err = semver.ParseConstraint(filter.Constraint); err != nil {
+			return nil, err
+		}
+	}
+
+	var selected []ChartVersion
+	for _, version := range versions {
+		if constraint != nil {
+			v, err := semver.Parse(version.ChartVersion)
+			if err != nil || !constraint.Check(v) {
+				continue
+			}
+		} else if !filter.IncludePrerelease && version.IsPrerelease() {
+			continue
+		}
+		selected = append(selected, version)
+	}
+	return selected, nil
+}
+
+type fetchIndexYAMLFunc func(ctx context.Context, repoURL string) (*IndexYAML, error)
 
 // VersionFetcher handles fetching chart versions from Helm repositories.
+// Charts of OCI registries are listed through the OCI distribution API instead of an index.
 type VersionFetcher struct {
 	fetchIndex fetchIndexYAMLFunc
+	oci        *ociClient
 }
 
 // NewVersionFetcher creates a new version fetcher.
 func NewVersionFetcher() *VersionFetcher {
-	return &VersionFetcher{fetchIndex: fetchIndexYAML}
+	return &VersionFetcher{fetchIndex: fetchIndexYAML, oci: defaultOCIClient}
 }
 
 // NewMockVersionFetcher creates a VersionFetcher with a custom fetchIndex function (for testing).
 func NewMockVersionFetcher(mock fetchIndexYAMLFunc) *VersionFetcher {
-	return &VersionFetcher{fetchIndex: mock}
+	return &VersionFetcher{fetchIndex: mock, oci: defaultOCIClient}
 }
 
-func fetchIndexYAML(repoURL string) (*IndexYAML, error) {
+// ErrOCIChartListing is returned when listing the charts of an OCI registry, which the
+// distribution API does not support. The chart name has to be known instead.
+var ErrOCIChartListing = errors.New("OCI registries cannot list their charts, enter the chart name")
+
+// fetchIndexYAML returns the index of a repository, through the cache set with SetCache.
+func fetchIndexYAML(ctx context.Context, repoURL string) (*IndexYAML, error) {
 	// Validate the URL to prevent potential security issues.
 	parsedURL, err := url.Parse(repoURL)
 	if err != nil {
@@ -55,22 +133,35 @@ func fetchIndexYAML(repoURL string) (*IndexYAML, error) {
 	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
 		return nil, fmt.Errorf("unsupported URL scheme: %s", parsedURL.Scheme)
 	}
+	return currentCache().index(repoURL, func(meta indexMeta) ([]byte, indexMeta, bool, error) {
+		return downloadIndexYAML(ctx, parsedURL, meta)
+	})
+}
 
-	indexURL := repoURL
+// downloadIndexYAML downloads the index of a repository. The request is conditional when meta
+// holds the ETag or Last-Modified date of a cached copy, which is still current on status 304.
+func downloadIndexYAML(ctx context.Context, repoURL *url.URL, meta indexMeta) ([]byte, indexMeta, bool, error) {
+	indexURL := repoURL.String()
 	if indexURL[len(indexURL)-1] != '/' {
 		indexURL += "/"
 	}
 	indexURL += "index.yaml"
 
-	ctx := context.Background()
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, indexURL, http.NoBody)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request for index.yaml: %w", err)
+		return nil, meta, false, fmt.Errorf("failed to create request for index.yaml: %w", err)
+	}
+	if meta.ETag != "" {
+		req.Header.Set("If-None-Match", meta.ETag)
 	}
-	client := &http.Client{}
-	resp, err := client.Do(req) // #nosec G107
+	if meta.LastModified != "" {
+		req.Header.Set("If-Modified-Since", meta.LastModified)
+	}
+	auth, client := authFor(urlLocation(repoURL), defaultClient)
+	auth.authorize(req)
+	resp, err := send(ctx, client, req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch index.yaml: %w", err)
+		return nil, meta, false, fmt.Errorf("failed to fetch index.yaml: %w", err)
 	}
 	defer func() {
 		if closeErr := resp.Body.Close(); closeErr != nil {
@@ -78,49 +169,116 @@ func fetchIndexYAML(repoURL string) (*IndexYAML, error) {
 			fmt.Printf("Warning: failed to close response body: %v\n", closeErr)
 		}
 	}()
+	if resp.StatusCode == http.StatusNotModified && (meta.ETag != "" || meta.LastModified != "") {
+		return nil, meta, true, nil
+	}
 	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("failed to fetch index.yaml: status %d", resp.StatusCode)
+		return nil, meta, false, fmt.Errorf("failed to fetch index.yaml: status %d", resp.StatusCode)
 	}
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read index.yaml: %w", err)
+		return nil, meta, false, fmt.Errorf("failed to read index.yaml: %w", err)
 	}
-	var idx IndexYAML
-	if err := yaml.Unmarshal(body, &idx); err != nil {
-		return nil, fmt.Errorf("failed to parse index.yaml: %w", err)
+	fresh := indexMeta{ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+	return body, fresh, false, nil
+}
+
+// ChartSummary describes a chart of a repository by its newest version.
+type ChartSummary struct {
+	Name        string
+	Description string
+	Keywords    []string
+	// Deprecated is set when the newest version of the chart is deprecated.
+	Deprecated bool
+}
+
+// Matches reports whether every word of the query is found in the name, a keyword or the
+// description of the chart, case-insensitively. An empty query matches every chart.
+func (c ChartSummary) Matches(query string) bool {
+	text := strings.ToLower(c.Name + "\n" + strings.Join(c.Keywords, "\n") + "\n" + c.Description)
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		if !strings.Contains(text, word) {
+			return false
+		}
 	}
-	return &idx, nil
+	return true
 }
 
-// ListCharts fetches all chart names and their descriptions from a Helm repository.
-func (vf *VersionFetcher) ListCharts(repoURL string) ([]struct{ Name, Description string }, error) {
-	idx, err := vf.fetchIndex(repoURL)
+// FilterCharts returns the charts matching the query, charts whose name matches first. Deprecated
+// charts are left out unless includeDeprecated is set.
+func FilterCharts(charts []ChartSummary, query string, includeDeprecated bool) []ChartSummary {
+	var byName, others []ChartSummary
+	for _, chart := range charts {
+		if (chart.Deprecated && !includeDeprecated) || !chart.Matches(query) {
+			continue
+		}
+		if strings.Contains(strings.ToLower(chart.Name), strings.ToLower(strings.TrimSpace(query))) {
+			byName = append(byName, chart)
+		} else {
+			others = append(others, chart)
+		}
+	}
+	return append(byName, others...)
+}
+
+// ListCharts fetches all charts of a Helm repository, sorted by name.
+func (vf *VersionFetcher) ListCharts(ctx context.Context, repoURL string) ([]ChartSummary, error) {
+	if IsOCI(repoURL) {
+		return nil, ErrOCIChartListing
+	}
+	idx, err := vf.fetchIndex(ctx, repoURL)
 	if err != nil {
 		return nil, err
 	}
-	var charts []struct{ Name, Description string }
-	for name, entries := range idx.Entries {
-		desc := ""
-		if len(entries) > 0 {
-			desc = entries[0].Description
+	charts := make([]ChartSummary, 0, len(idx.Entries))
+	for name := range idx.Entries {
+		chart := ChartSummary{Name: name}
+		if entry, ok := idx.latestEntry(name); ok {
+			chart.Description, chart.Keywords, chart.Deprecated = entry.Description, entry.Keywords, entry.Deprecated
 		}
-		charts = append(charts, struct{ Name, Description string }{Name: name, Description: desc})
+		charts = append(charts, chart)
 	}
 	// Sort charts by name.
 	sort.Slice(charts, func(i, j int) bool { return charts[i].Name < charts[j].Name })
 	return charts, nil
 }
 
+// latestEntry returns the newest version of a chart of the index.
+func (idx *IndexYAML) latestEntry(chartName string) (IndexEntry, bool) {
+	versions, ok := idx.chartVersions(chartName)
+	if !ok || len(versions) == 0 {
+		return IndexEntry{}, false
+	}
+	for _, entry := range idx.Entries[chartName] {
+		if entry.Version == versions[0].ChartVersion {
+			return entry, true
+		}
+	}
+	return IndexEntry{}, false
+}
+
 // FetchChartVersions fetches available versions for a chart from a repository.
-func (vf *VersionFetcher) FetchChartVersions(repoURL, chartName string) ([]ChartVersion, error) {
-	idx, err := vf.fetchIndex(repoURL)
+func (vf *VersionFetcher) FetchChartVersions(ctx context.Context, repoURL, chartName string) ([]ChartVersion, error) {
+	if IsOCI(repoURL) {
+		return vf.oci.chartVersions(ctx, repoURL, chartName)
+	}
+	idx, err := vf.fetchIndex(ctx, repoURL)
 	if err != nil {
 		return nil, err
 	}
-	chart, exists := idx.Entries[chartName]
+	versions, exists := idx.chartVersions(chartName)
 	if !exists {
 		return nil, fmt.Errorf("chart '%s' not found in repository", chartName)
 	}
+	return versions, nil
+}
+
+// chartVersions returns the versions of a chart of the index, newest first.
+func (idx *IndexYAML) chartVersions(chartName string) ([]ChartVersion, bool) {
+	chart, exists := idx.Entries[chartName]
+	if !exists {
+		return nil, false
+	}
 	var versions []ChartVersion
 	for _, version := range chart {
 		if version.Version != "" {
@@ -133,28 +291,50 @@ func (vf *VersionFetcher) FetchChartVersions(repoURL, chartName string) ([]Chart
 			})
 		}
 	}
-	// Sort versions (newest first, lexicographically).
-	sort.Slice(versions, func(i, j int) bool {
-		return versions[i].ChartVersion > versions[j].ChartVersion
-	})
-	return versions, nil
+	SortVersions(versions)
+	return versions, true
+}
+
+// FetchLatestVersion fetches the latest stable version for a chart.
+func (vf *VersionFetcher) FetchLatestVersion(ctx context.Context, repoURL, chartName string) (ChartVersion, error) {
+	return vf.FetchMatchingVersion(ctx, repoURL, chartName, VersionFilter{})
 }
 
-// FetchLatestVersion fetches the latest version for a chart.
-func (vf *VersionFetcher) FetchLatestVersion(repoURL, chartName string) (ChartVersion, error) {
-	versions, err := vf.FetchChartVersions(repoURL, chartName)
+// FetchMatchingVersion fetches the latest version for a chart selected by the filter.
+func (vf *VersionFetcher) FetchMatchingVersion(ctx context.Context, repoURL, chartName string, filter VersionFilter) (ChartVersion, error) {
+	versions, err := vf.FetchChartVersions(ctx, repoURL, chartName)
 	if err != nil {
 		return ChartVersion{}, err
 	}
 	if len(versions) == 0 {
 		return ChartVersion{}, fmt.Errorf("no versions found for chart '%s'", chartName)
 	}
-	return versions[0], nil
+	matching, err := FilterVersions(versions, filter)
+	if err != nil {
+		return ChartVersion{}, err
+	}
+	if len(matching) == 0 {
+		if filter.Constraint != "" {
+			return ChartVersion{}, fmt.Errorf("no version of chart '%s' matches %s", chartName, filter.Constraint)
+		}
+		return ChartVersion{}, fmt.Errorf("no stable version found for chart '%s', include prereleases to use one", chartName)
+	}
+	return matching[0], nil
 }
 
 // ValidateChartExists checks if a chart exists in the repository.
-func (vf *VersionFetcher) ValidateChartExists(repoURL, chartName string) error {
-	idx, err := vf.fetchIndex(repoURL)
+func (vf *VersionFetcher) ValidateChartExists(ctx context.Context, repoURL, chartName string) error {
+	if IsOCI(repoURL) {
+		ref, err := parseOCIReference(repoURL, chartName)
+		if err != nil {
+			return err
+		}
+		if _, err := vf.oci.listTags(ctx, ref); err != nil {
+			return fmt.Errorf("chart '%s' not found in registry: %w", chartName, err)
+		}
+		return nil
+	}
+	idx, err := vf.fetchIndex(ctx, repoURL)
 	if err != nil {
 		return err
 	}
@@ -1,25 +1,29 @@
 package helm
 
 import (
+	"context"
 	"fmt"
-	"sort"
+	"reflect"
 	"testing"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/semver"
 )
 
 func TestChartVersion_Sorting(t *testing.T) {
 	versions := []ChartVersion{
 		{ChartVersion: "1.0.1", AppVersion: "1.0.1", Description: "Old version"},
-		{ChartVersion: "1.1.0", AppVersion: "1.0.28", Description: "New version"},
+		{ChartVersion: "1.9.0", AppVersion: "1.0.28", Description: "Older minor"},
+		{ChartVersion: "1.10.0-rc.1", AppVersion: "1.0.29", Description: "Release candidate"},
+		{ChartVersion: "latest", Description: "Not semver"},
+		{ChartVersion: "1.10.0", AppVersion: "1.0.29", Description: "New version"},
 		{ChartVersion: "1.0.40", AppVersion: "1.0.28", Description: "Middle version"},
 	}
 
-	// Sort versions (newest first) by chart version
-	sort.Slice(versions, func(i, j int) bool {
-		return versions[i].ChartVersion > versions[j].ChartVersion
-	})
+	// Sort versions (newest first) by semver precedence
+	SortVersions(versions)
 
 	// Check that versions are sorted correctly
-	expected := []string{"1.1.0", "1.0.40", "1.0.1"}
+	expected := []string{"1.10.0", "1.10.0-rc.1", "1.9.0", "1.0.40", "1.0.1", "latest"}
 	for i, version := range versions {
 		if version.ChartVersion != expected[i] {
 			t.Errorf("Expected version %s at position %d, got %s", expected[i], i, version.ChartVersion)
@@ -29,6 +33,52 @@ func TestChartVersion_Sorting(t *testing.T) {
 	t.Logf("Versions sorted correctly: %v", expected)
 }
 
+func TestFilterVersions(t *testing.T) {
+	versions := []ChartVersion{
+		{ChartVersion: "2.1.0-rc.1"},
+		{ChartVersion: "2.0.0"},
+		{ChartVersion: "1.5.2"},
+		{ChartVersion: "1.4.7"},
+		{ChartVersion: "1.4.0"},
+		{ChartVersion: "main"},
+	}
+	names := func(versions []ChartVersion) []string {
+		var names []string
+		for _, version := range versions {
+			names = append(names, version.ChartVersion)
+		}
+		return names
+	}
+
+	tests := []struct {
+		name     string
+		filter   VersionFilter
+		expected []string
+	}{
+		{"stable by default", VersionFilter{}, []string{"2.0.0", "1.5.2", "1.4.7", "1.4.0", "main"}},
+		{"prereleases included", VersionFilter{IncludePrerelease: true}, []string{"2.1.0-rc.1", "2.0.0", "1.5.2", "1.4.7", "1.4.0", "main"}},
+		{"tilde", VersionFilter{Constraint: "~1.4"}, []string{"1.4.7", "1.4.0"}},
+		{"range", VersionFilter{Constraint: ">=2 <3"}, []string{"2.0.0"}},
+		{"prerelease range", VersionFilter{Constraint: ">=2.0.0-0"}, []string{"2.1.0-rc.1", "2.0.0"}},
+		{"no match", VersionFilter{Constraint: "^3"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filtered, err := FilterVersions(versions, tt.filter)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if fmt.Sprint(names(filtered)) != fmt.Sprint(tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, names(filtered))
+			}
+		})
+	}
+
+	if _, err := FilterVersions(versions, VersionFilter{Constraint: "~latest"}); err == nil {
+		t.Error("Expected error for an invalid constraint")
+	}
+}
+
 func TestChartVersion_DisplayString(t *testing.T) {
 	chartName := "vantage-kubernetes-agent"
 	version := ChartVersion{
@@ -86,7 +136,7 @@ func TestVersionFetcher_ListCharts(t *testing.T) {
 
 	t.Run("valid repo", func(t *testing.T) {
 		repoURL := "https://vantage-sh.github.io/helm-charts"
-		charts, err := fetcher.ListCharts(repoURL)
+		charts, err := fetcher.ListCharts(context.Background(), repoURL)
 		if err != nil {
 			t.Fatalf("Failed to list charts: %v", err)
 		}
@@ -108,7 +158,7 @@ func TestVersionFetcher_ListCharts(t *testing.T) {
 	})
 
 	t.Run("invalid repo", func(t *testing.T) {
-		_, err := fetcher.ListCharts("https://not-a-real-helm-repo")
+		_, err := fetcher.ListCharts(context.Background(), "https://not-a-real-helm-repo")
 		if err == nil {
 			t.Error("Expected error for invalid repo URL, got nil")
 		}
@@ -120,7 +170,7 @@ func TestVersionFetcher_FetchChartVersions(t *testing.T) {
 	repoURL := "https://vantage-sh.github.io/helm-charts"
 	chartName := "vantage-kubernetes-agent"
 
-	versions, err := fetcher.FetchChartVersions(repoURL, chartName)
+	versions, err := fetcher.FetchChartVersions(context.Background(), repoURL, chartName)
 	if err != nil {
 		t.Fatalf("Failed to fetch chart versions: %v", err)
 	}
@@ -131,7 +181,9 @@ func TestVersionFetcher_FetchChartVersions(t *testing.T) {
 
 	// Test that versions are sorted (newest first)
 	if len(versions) > 1 {
-		if versions[0].ChartVersion <= versions[1].ChartVersion {
+		newest, _ := semver.Parse(versions[0].ChartVersion)
+		next, _ := semver.Parse(versions[1].ChartVersion)
+		if newest.Compare(next) <= 0 {
 			t.Errorf("Versions not sorted correctly. Expected %s > %s",
 				versions[0].ChartVersion, versions[1].ChartVersion)
 		}
@@ -157,14 +209,9 @@ func TestVersionFetcher_FetchChartVersions(t *testing.T) {
 	t.Logf("Latest version: %s (App: %s)", versions[0].ChartVersion, versions[0].AppVersion)
 }
 
-func mockFetchIndexYAML(_ string) (*IndexYAML, error) {
+func mockFetchIndexYAML(_ context.Context, _ string) (*IndexYAML, error) {
 	return &IndexYAML{
-		Entries: map[string][]struct {
-			Version     string   `yaml:"version"`
-			AppVersion  string   `yaml:"appVersion"`
-			Description string   `yaml:"description"`
-			URLs        []string `yaml:"urls"`
-		}{
+		Entries: map[string][]IndexEntry{
 			"airbyte": {
 				{Version: "1.2.3", AppVersion: "2.3.4", Description: "desc1", URLs: []string{"https://example.com/airbyte-1.2.3.tgz"}},
 				{Version: "1.2.2", AppVersion: "2.3.3", Description: "desc2", URLs: []string{"https://example.com/airbyte-1.2.2.tgz"}},
@@ -173,33 +220,23 @@ func mockFetchIndexYAML(_ string) (*IndexYAML, error) {
 	}, nil
 }
 
-func mockFetchIndexYAMLEmpty(_ string) (*IndexYAML, error) {
-	return &IndexYAML{Entries: map[string][]struct {
-		Version     string   `yaml:"version"`
-		AppVersion  string   `yaml:"appVersion"`
-		Description string   `yaml:"description"`
-		URLs        []string `yaml:"urls"`
-	}{}}, nil
+func mockFetchIndexYAMLEmpty(_ context.Context, _ string) (*IndexYAML, error) {
+	return &IndexYAML{Entries: map[string][]IndexEntry{}}, nil
 }
 
-func mockFetchIndexYAMLNoChart(_ string) (*IndexYAML, error) {
-	return &IndexYAML{Entries: map[string][]struct {
-		Version     string   `yaml:"version"`
-		AppVersion  string   `yaml:"appVersion"`
-		Description string   `yaml:"description"`
-		URLs        []string `yaml:"urls"`
-	}{
+func mockFetchIndexYAMLNoChart(_ context.Context, _ string) (*IndexYAML, error) {
+	return &IndexYAML{Entries: map[string][]IndexEntry{
 		"other": {{Version: "0.1.0", AppVersion: "0.1.0", Description: "other chart", URLs: []string{"https://example.com/other-0.1.0.tgz"}}},
 	}}, nil
 }
 
-func mockFetchIndexYAMLError(_ string) (*IndexYAML, error) {
+func mockFetchIndexYAMLError(_ context.Context, _ string) (*IndexYAML, error) {
 	return nil, fmt.Errorf("mock error")
 }
 
 func TestVersionFetcher_FetchLatestVersion(t *testing.T) {
 	vf := NewMockVersionFetcher(mockFetchIndexYAML)
-	latest, err := vf.FetchLatestVersion("mock", "airbyte")
+	latest, err := vf.FetchLatestVersion(context.Background(), "mock", "airbyte")
 	if err != nil {
 		t.Fatalf("Failed to fetch latest version: %v", err)
 	}
@@ -211,9 +248,62 @@ func TestVersionFetcher_FetchLatestVersion(t *testing.T) {
 	}
 }
 
+func mockFetchIndexYAMLPrereleases(_ context.Context, _ string) (*IndexYAML, error) {
+	return &IndexYAML{Entries: map[string][]IndexEntry{
+		"airbyte": {
+			{Version: "1.9.0", AppVersion: "2.3.4"},
+			{Version: "1.10.0", AppVersion: "2.4.0"},
+			{Version: "1.11.0-beta.1", AppVersion: "2.5.0"},
+		},
+		"nightly": {
+			{Version: "0.1.0-nightly.2"},
+			{Version: "0.1.0-nightly.10"},
+		},
+	}}, nil
+}
+
+func TestVersionFetcher_FetchLatestVersion_Semver(t *testing.T) {
+	vf := NewMockVersionFetcher(mockFetchIndexYAMLPrereleases)
+	latest, err := vf.FetchLatestVersion(context.Background(), "mock", "airbyte")
+	if err != nil {
+		t.Fatalf("Failed to fetch latest version: %v", err)
+	}
+	if latest.ChartVersion != "1.10.0" {
+		t.Errorf("Expected chart version '1.10.0', got '%s'", latest.ChartVersion)
+	}
+
+	if _, err := vf.FetchLatestVersion(context.Background(), "mock", "nightly"); err == nil {
+		t.Error("Expected error for a chart with prereleases only")
+	}
+	latest, err = vf.FetchMatchingVersion(context.Background(), "mock", "nightly", VersionFilter{IncludePrerelease: true})
+	if err != nil {
+		t.Fatalf("Failed to fetch latest prerelease: %v", err)
+	}
+	if latest.ChartVersion != "0.1.0-nightly.10" {
+		t.Errorf("Expected chart version '0.1.0-nightly.10', got '%s'", latest.ChartVersion)
+	}
+}
+
+func TestVersionFetcher_FetchMatchingVersion(t *testing.T) {
+	vf := NewMockVersionFetcher(mockFetchIndexYAMLPrereleases)
+	matching, err := vf.FetchMatchingVersion(context.Background(), "mock", "airbyte", VersionFilter{Constraint: "~1.9"})
+	if err != nil {
+		t.Fatalf("Failed to fetch matching version: %v", err)
+	}
+	if matching.ChartVersion != "1.9.0" {
+		t.Errorf("Expected chart version '1.9.0', got '%s'", matching.ChartVersion)
+	}
+
+	_, err = vf.FetchMatchingVersion(context.Background(), "mock", "airbyte", VersionFilter{Constraint: "^2"})
+	expectedErr := "no version of chart 'airbyte' matches ^2"
+	if err == nil || err.Error() != expectedErr {
+		t.Errorf("Expected error '%s', got '%v'", expectedErr, err)
+	}
+}
+
 func TestVersionFetcher_FetchLatestVersion_NoVersions(t *testing.T) {
 	vf := NewMockVersionFetcher(mockFetchIndexYAMLEmpty)
-	_, err := vf.FetchLatestVersion("mock", "airbyte")
+	_, err := vf.FetchLatestVersion(context.Background(), "mock", "airbyte")
 	if err == nil {
 		t.Error("Expected error for no versions")
 	}
@@ -225,7 +315,7 @@ func TestVersionFetcher_FetchLatestVersion_NoVersions(t *testing.T) {
 
 func TestVersionFetcher_ValidateChartExists(t *testing.T) {
 	vf := NewMockVersionFetcher(mockFetchIndexYAML)
-	err := vf.ValidateChartExists("mock", "airbyte")
+	err := vf.ValidateChartExists(context.Background(), "mock", "airbyte")
 	if err != nil {
 		t.Errorf("Expected no error for existing chart: %v", err)
 	}
@@ -233,7 +323,7 @@ func TestVersionFetcher_ValidateChartExists(t *testing.T) {
 
 func TestVersionFetcher_ValidateChartExists_NotFound(t *testing.T) {
 	vf := NewMockVersionFetcher(mockFetchIndexYAMLNoChart)
-	err := vf.ValidateChartExists("mock", "airbyte")
+	err := vf.ValidateChartExists(context.Background(), "mock", "airbyte")
 	if err == nil {
 		t.Error("Expected error for non-existent chart")
 	}
@@ -243,9 +333,78 @@ func TestVersionFetcher_ValidateChartExists_NotFound(t *testing.T) {
 	}
 }
 
+const searchIndex = `entries:
+  nginx:
+    - version: 15.0.0
+      description: NGINX Open Source web server
+      keywords: [http, web, proxy]
+  legacy-proxy:
+    - version: 2.0.0
+      description: Old reverse proxy
+      deprecated: true
+    - version: 1.0.0
+      description: Reverse proxy
+  redis:
+    - version: 19.0.0
+      description: In-memory data store
+      keywords: [cache, database]
+`
+
+func TestVersionFetcher_ListCharts_Index(t *testing.T) {
+	vf := NewMockVersionFetcher(func(context.Context, string) (*IndexYAML, error) {
+		return parseIndex([]byte(searchIndex))
+	})
+	charts, err := vf.ListCharts(context.Background(), "mock")
+	if err != nil {
+		t.Fatalf("Failed to list charts: %v", err)
+	}
+	// The newest version describes the chart
+	want := []ChartSummary{
+		{Name: "legacy-proxy", Description: "Old reverse proxy", Deprecated: true},
+		{Name: "nginx", Description: "NGINX Open Source web server", Keywords: []string{"http", "web", "proxy"}},
+		{Name: "redis", Description: "In-memory data store", Keywords: []string{"cache", "database"}},
+	}
+	if !reflect.DeepEqual(charts, want) {
+		t.Errorf("Expected %+v, got %+v", want, charts)
+	}
+}
+
+func TestFilterCharts(t *testing.T) {
+	idx, err := parseIndex([]byte(searchIndex))
+	if err != nil {
+		t.Fatal(err)
+	}
+	charts, _ := NewMockVersionFetcher(func(context.Context, string) (*IndexYAML, error) { return idx, nil }).ListCharts(context.Background(), "mock")
+
+	names := func(charts []ChartSummary) []string {
+		var names []string
+		for _, chart := range charts {
+			names = append(names, chart.Name)
+		}
+		return names
+	}
+	tests := []struct {
+		query             string
+		includeDeprecated bool
+		want              []string
+	}{
+		{"", false, []string{"nginx", "redis"}},
+		{"", true, []string{"legacy-proxy", "nginx", "redis"}},
+		{"PROXY", true, []string{"legacy-proxy", "nginx"}}, // Name matches come first
+		{"proxy", false, []string{"nginx"}},
+		{"data cache", false, []string{"redis"}},
+		{"web database", false, nil},
+	}
+	for _, tt := range tests {
+		if got := names(FilterCharts(charts, tt.query, tt.includeDeprecated)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("FilterCharts(%q, %v) = %v, want %v", tt.query, tt.includeDeprecated, got, tt.want)
+		}
+	}
+}
+
 func TestVersionFetcher_ListCharts_Error(t *testing.T) {
 	vf := NewMockVersionFetcher(mockFetchIndexYAMLError)
-	_, err := vf.ListCharts("mock")
+	_, err := vf.ListCharts(context.Background(), "mock")
 	if err == nil {
 		t.Error("Expected error from mock fetchIndexYAML in ListCharts")
 	}
@@ -253,7 +412,7 @@ func TestVersionFetcher_ListCharts_Error(t *testing.T) {
 
 func TestVersionFetcher_FetchChartVersions_Error(t *testing.T) {
 	vf := NewMockVersionFetcher(mockFetchIndexYAMLError)
-	_, err := vf.FetchChartVersions("mock", "airbyte")
+	_, err := vf.FetchChartVersions(context.Background(), "mock", "airbyte")
 	if err == nil {
 		t.Error("Expected error from mock fetchIndexYAML in FetchChartVersions")
 	}
@@ -261,7 +420,7 @@ func TestVersionFetcher_FetchChartVersions_Error(t *testing.T) {
 
 func TestVersionFetcher_ValidateChartExists_Error(t *testing.T) {
 	vf := NewMockVersionFetcher(mockFetchIndexYAMLError)
-	err := vf.ValidateChartExists("mock", "airbyte")
+	err := vf.ValidateChartExists(context.Background(), "mock", "airbyte")
 	if err == nil {
 		t.Error("Expected error from mock fetchIndexYAML in ValidateChartExists")
 	}
@@ -269,7 +428,7 @@ func TestVersionFetcher_ValidateChartExists_Error(t *testing.T) {
 
 func TestVersionFetcher_FetchLatestVersion_Error(t *testing.T) {
 	vf := NewMockVersionFetcher(mockFetchIndexYAMLError)
-	_, err := vf.FetchLatestVersion("mock", "airbyte")
+	_, err := vf.FetchLatestVersion(context.Background(), "mock", "airbyte")
 	if err == nil {
 		t.Error("Expected error from mock fetchIndexYAML in FetchLatestVersion")
 	}
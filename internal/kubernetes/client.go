@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
@@ -85,6 +86,22 @@ func (c *Client) GetNamespaces(ctx context.Context) ([]string, error) {
 	return names, nil
 }
 
+// NamespaceLabels returns the labels of a namespace, and whether it exists in the cluster.
+func (c *Client) NamespaceLabels(ctx context.Context, name string) (map[string]string, bool, error) {
+	if c.clientset == nil {
+		return nil, false, fmt.Errorf("kubernetes client is not initialized")
+	}
+
+	ns, err := c.clientset.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get namespace %s: %w", name, err)
+	}
+	return ns.Labels, true, nil
+}
+
 // GetServices returns a list of services in the specified namespace.
 func (c *Client) GetServices(ctx context.Context, namespace string) ([]string, error) {
 	if c.clientset == nil {
@@ -241,6 +258,18 @@ func (c *Client) TestConnection(ctx context.Context) error {
 	return nil
 }
 
+// ServerVersion returns the Kubernetes version of the cluster, e.g. v1.29.3.
+func (c *Client) ServerVersion() (string, error) {
+	if c.clientset == nil {
+		return "", fmt.Errorf("kubernetes client is not initialized")
+	}
+	info, err := c.clientset.Discovery().ServerVersion()
+	if err != nil {
+		return "", fmt.Errorf("failed to get the kubernetes version: %w", err)
+	}
+	return info.GitVersion, nil
+}
+
 // GetClusterSecretStores returns a list of all ClusterSecretStore resources in the cluster.
 func (c *Client) GetClusterSecretStores(ctx context.Context) ([]string, error) {
 	if c.dynamic == nil {
@@ -11,6 +11,8 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
 	"k8s.io/client-go/kubernetes/fake"
 )
 
@@ -124,6 +126,36 @@ func TestClient_GetNamespaces_WithFakeClient(t *testing.T) {
 	assert.Contains(t, namespaces, "kube-system")
 }
 
+func TestClient_NamespaceLabels_WithFakeClient(t *testing.T) {
+	fakeClientset := fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "apps", Labels: map[string]string{"team": "web"}}})
+	client := &Client{clientset: fakeClientset}
+
+	labels, exists, err := client.NamespaceLabels(context.Background(), "apps")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, map[string]string{"team": "web"}, labels)
+
+	_, exists, err = client.NamespaceLabels(context.Background(), "missing")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	_, _, err = (&Client{}).NamespaceLabels(context.Background(), "apps")
+	assert.Error(t, err)
+}
+
+func TestClient_ServerVersion_WithFakeClient(t *testing.T) {
+	fakeClientset := fake.NewSimpleClientset()
+	fakeClientset.Discovery().(*fakediscovery.FakeDiscovery).FakedServerVersion = &version.Info{GitVersion: "v1.29.3"}
+	client := &Client{clientset: fakeClientset}
+
+	serverVersion, err := client.ServerVersion()
+	assert.NoError(t, err)
+	assert.Equal(t, "v1.29.3", serverVersion)
+
+	_, err = (&Client{}).ServerVersion()
+	assert.Error(t, err)
+}
+
 func TestClient_GetServices_WithFakeClient(t *testing.T) {
 	// Create a fake clientset for testing
 	fakeClientset := fake.NewSimpleClientset()
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/semver"
+)
+
+// environmentName matches the DNS labels environments are named with, as their name is part of
+// directory and ConfigMap names.
+var environmentName = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// Environment is a Kustomize overlay of the app, e.g. staging or production, patching the base
+// HelmRelease. Empty fields keep the value of the base.
+type Environment struct {
+	Name         string                 `json:"name" yaml:"name"`
+	ChartVersion string                 `json:"chartVersion,omitempty" yaml:"chartVersion,omitempty"` // Chart version deployed in the environment
+	Interval     string                 `json:"interval,omitempty" yaml:"interval,omitempty"`         // Reconciliation interval of the HelmRelease
+	Values       map[string]interface{} `json:"values,omitempty" yaml:"values,omitempty"`             // Values merged over the base values
+}
+
+// OCIChartTag returns the OCI tag of the chart version of the environment, where "+" is replaced by "_".
+func (e Environment) OCIChartTag() string {
+	return strings.ReplaceAll(e.ChartVersion, "+", "_")
+}
+
+// HasEnvironments reports whether the app is generated as a base with one overlay per environment
+// instead of a single directory.
+func (c *AppConfig) HasEnvironments() bool {
+	return len(c.Environments) > 0
+}
+
+// ValidateEnvironments checks that environments have unique names that can name directories and
+// ConfigMaps, and valid overrides.
+func (c *AppConfig) ValidateEnvironments() error {
+	seen := make(map[string]bool, len(c.Environments))
+	for i, env := range c.Environments {
+		field := fmt.Sprintf("spec.environments[%d]", i)
+		if !environmentName.MatchString(env.Name) {
+			return &SpecError{Field: field + ".name", Message: fmt.Sprintf("%q must be lowercase alphanumeric characters or '-'", env.Name)}
+		}
+		if seen[env.Name] {
+			return &SpecError{Field: field + ".name", Message: fmt.Sprintf("duplicate environment %q", env.Name)}
+		}
+		seen[env.Name] = true
+
+		if env.ChartVersion != "" {
+			if c.UsesGitRepository() {
+				return &SpecError{Field: field + ".chartVersion", Message: fmt.Sprintf("does not apply to charts of a %s", SourceKindGitRepository)}
+			}
+			if _, err := semver.Parse(env.ChartVersion); err != nil {
+				return &SpecError{Field: field + ".chartVersion", Message: err.Error()}
+			}
+		}
+		if env.Interval != "" {
+			if _, err := time.ParseDuration(env.Interval); err != nil {
+				return &SpecError{Field: field + ".interval", Message: err.Error()}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateEnvironments(t *testing.T) {
+	tests := []struct {
+		name         string
+		sourceKind   string
+		environments []Environment
+		field        string
+	}{
+		{"valid", "", []Environment{{Name: "staging"}, {Name: "prod-eu", ChartVersion: "1.2.3", Interval: "10m"}}, ""},
+		{"invalid name", "", []Environment{{Name: "Staging"}}, "spec.environments[0].name"},
+		{"duplicate name", "", []Environment{{Name: "prod"}, {Name: "prod"}}, "spec.environments[1].name"},
+		{"invalid chart version", "", []Environment{{Name: "prod", ChartVersion: "latest"}}, "spec.environments[0].chartVersion"},
+		{"chart version of a git chart", SourceKindGitRepository, []Environment{{Name: "prod", ChartVersion: "1.2.3"}}, "spec.environments[0].chartVersion"},
+		{"invalid interval", "", []Environment{{Name: "prod", Interval: "often"}}, "spec.environments[0].interval"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &AppConfig{SourceKind: tt.sourceKind, Environments: tt.environments}
+			err := config.ValidateEnvironments()
+			if tt.field == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			specErr, ok := err.(*SpecError)
+			if !ok || specErr.Field != tt.field {
+				t.Errorf("expected an error on %s, got %v", tt.field, err)
+			}
+		})
+	}
+}
+
+func TestParseSpec_Environments(t *testing.T) {
+	data := `apiVersion: ` + SpecAPIVersion + `
+kind: ` + SpecKind + `
+spec:
+  appName: podinfo
+  namespace: apps
+  helmRepoName: podinfo
+  helmRepoURL: https://stefanprodan.github.io/podinfo
+  chartName: podinfo
+  chartVersion: 6.9.0
+  valuesPrefill: empty
+  environments:
+    - name: staging
+    - name: production
+      chartVersion: 6.8.0
+      values:
+        replicaCount: 3
+`
+	spec, err := ParseSpec([]byte(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !spec.Spec.HasEnvironments() || len(spec.Spec.Environments) != 2 {
+		t.Fatalf("expected two environments, got %+v", spec.Spec.Environments)
+	}
+	production := spec.Spec.Environments[1]
+	if production.ChartVersion != "6.8.0" || production.Values["replicaCount"] != 3 {
+		t.Errorf("unexpected production environment %+v", production)
+	}
+
+	if _, err := ParseSpec([]byte(strings.Replace(data, "name: staging", "name: Staging", 1))); err == nil {
+		t.Error("expected an invalid environment name to be rejected")
+	}
+}
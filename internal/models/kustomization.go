@@ -0,0 +1,99 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultFluxNamespace is the namespace of the Flux controllers and of the GitRepository of the
+// cluster, as set up by flux bootstrap.
+const DefaultFluxNamespace = "flux-system"
+
+// FluxKustomization is the Flux Kustomization (kustomize.toolkit.fluxcd.io) that reconciles the
+// app from the Git repository of the cluster.
+type FluxKustomization struct {
+	ClustersDir     string        `json:"clustersDir" yaml:"clustersDir"`                             // Cluster directory the Kustomization is written to, holding one directory per environment with environments
+	Namespace       string        `json:"namespace,omitempty" yaml:"namespace,omitempty"`             // Namespace of the Kustomization, flux-system by default
+	SourceRef       string        `json:"sourceRef,omitempty" yaml:"sourceRef,omitempty"`             // GitRepository the app is read from, flux-system by default
+	Prune           bool          `json:"prune" yaml:"prune"`                                         // Delete the resources removed from the app
+	Wait            bool          `json:"wait,omitempty" yaml:"wait,omitempty"`                       // Wait for every resource of the app to be ready, instead of the health checks
+	Timeout         string        `json:"timeout,omitempty" yaml:"timeout,omitempty"`                 // Timeout of the apply and health checks
+	TargetNamespace string        `json:"targetNamespace,omitempty" yaml:"targetNamespace,omitempty"` // Namespace every resource of the app is moved to
+	DependsOn       []string      `json:"dependsOn,omitempty" yaml:"dependsOn,omitempty"`             // Kustomizations reconciled before the app
+	HealthChecks    []HealthCheck `json:"healthChecks,omitempty" yaml:"healthChecks,omitempty"`       // Resources that must be ready for the app to be
+	// AppPath is the path of the app directory in the Git repository, e.g. ./apps/podinfo. It
+	// depends on where the app is generated, ./<appName> when empty.
+	AppPath string `json:"-" yaml:"-"`
+}
+
+// HealthCheck is a resource whose readiness the Flux Kustomization checks. An empty namespace is
+// the namespace of the app.
+type HealthCheck struct {
+	APIVersion string `json:"apiVersion" yaml:"apiVersion"`
+	Kind       string `json:"kind" yaml:"kind"`
+	Name       string `json:"name" yaml:"name"`
+	Namespace  string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+}
+
+// setDefaults fills the namespace and source of the Kustomization with the ones of flux bootstrap.
+func (k *FluxKustomization) setDefaults() {
+	if k.Namespace == "" {
+		k.Namespace = DefaultFluxNamespace
+	}
+	if k.SourceRef == "" {
+		k.SourceRef = DefaultFluxNamespace
+	}
+}
+
+// copy returns a copy of the Kustomization that does not share its lists.
+func (k *FluxKustomization) copy() *FluxKustomization {
+	if k == nil {
+		return nil
+	}
+	clone := *k
+	clone.DependsOn = append([]string(nil), k.DependsOn...)
+	clone.HealthChecks = append([]HealthCheck(nil), k.HealthChecks...)
+	return &clone
+}
+
+// Dependencies returns the Kustomizations reconciled before the app.
+func (k *FluxKustomization) Dependencies() []Dependency {
+	return parseDependencies(k.DependsOn)
+}
+
+// ValidateFluxKustomization checks the settings of the Flux Kustomization of the app, if any.
+func (c *AppConfig) ValidateFluxKustomization() error {
+	k := c.FluxKustomization
+	if k == nil {
+		return nil
+	}
+	if strings.TrimSpace(k.ClustersDir) == "" {
+		return &SpecError{Field: "spec.fluxKustomization.clustersDir", Message: "value is required"}
+	}
+	if k.TargetNamespace != "" && !validNamespace(k.TargetNamespace) {
+		return namespaceError("spec.fluxKustomization.targetNamespace", k.TargetNamespace)
+	}
+	if k.Timeout != "" {
+		if _, err := time.ParseDuration(k.Timeout); err != nil {
+			return &SpecError{Field: "spec.fluxKustomization.timeout", Message: err.Error()}
+		}
+	}
+	for i, name := range k.DependsOn {
+		if strings.TrimSpace(name) == "" {
+			return &SpecError{Field: fmt.Sprintf("spec.fluxKustomization.dependsOn[%d]", i), Message: "value is required"}
+		}
+	}
+	for i, check := range k.HealthChecks {
+		for _, field := range []struct{ name, value string }{
+			{"apiVersion", check.APIVersion},
+			{"kind", check.Kind},
+			{"name", check.Name},
+		} {
+			if strings.TrimSpace(field.value) == "" {
+				return &SpecError{Field: fmt.Sprintf("spec.fluxKustomization.healthChecks[%d].%s", i, field.name), Message: "value is required"}
+			}
+		}
+	}
+	return nil
+}
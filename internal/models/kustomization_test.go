@@ -0,0 +1,83 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateFluxKustomization(t *testing.T) {
+	tests := []struct {
+		name          string
+		kustomization *FluxKustomization
+		field         string
+	}{
+		{"none", nil, ""},
+		{"valid", &FluxKustomization{ClustersDir: "clusters/production", Timeout: "5m", DependsOn: []string{"infrastructure"}, HealthChecks: []HealthCheck{{APIVersion: "apps/v1", Kind: "Deployment", Name: "podinfo"}}}, ""},
+		{"missing clusters dir", &FluxKustomization{}, "spec.fluxKustomization.clustersDir"},
+		{"invalid timeout", &FluxKustomization{ClustersDir: "clusters", Timeout: "soon"}, "spec.fluxKustomization.timeout"},
+		{"target namespace with a dot", &FluxKustomization{ClustersDir: "clusters", TargetNamespace: "apps.web"}, "spec.fluxKustomization.targetNamespace"},
+		{"target namespace too long", &FluxKustomization{ClustersDir: "clusters", TargetNamespace: strings.Repeat("a", 64)}, "spec.fluxKustomization.targetNamespace"},
+		{"empty dependency", &FluxKustomization{ClustersDir: "clusters", DependsOn: []string{" "}}, "spec.fluxKustomization.dependsOn[0]"},
+		{"health check without kind", &FluxKustomization{ClustersDir: "clusters", HealthChecks: []HealthCheck{{APIVersion: "apps/v1", Name: "podinfo"}}}, "spec.fluxKustomization.healthChecks[0].kind"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &AppConfig{FluxKustomization: tt.kustomization}
+			err := config.ValidateFluxKustomization()
+			if tt.field == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			specErr, ok := err.(*SpecError)
+			if !ok || specErr.Field != tt.field {
+				t.Errorf("expected an error on %s, got %v", tt.field, err)
+			}
+		})
+	}
+}
+
+func TestParseSpec_FluxKustomization(t *testing.T) {
+	data := `apiVersion: ` + SpecAPIVersion + `
+kind: ` + SpecKind + `
+spec:
+  appName: podinfo
+  namespace: apps
+  helmRepoName: podinfo
+  helmRepoURL: https://stefanprodan.github.io/podinfo
+  chartName: podinfo
+  chartVersion: 6.9.0
+  valuesPrefill: empty
+  fluxKustomization:
+    clustersDir: clusters/production
+    prune: true
+    healthChecks:
+      - apiVersion: helm.toolkit.fluxcd.io/v2
+        kind: HelmRelease
+        name: podinfo
+`
+	spec, err := ParseSpec([]byte(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	k := spec.Spec.FluxKustomization
+	if k == nil || k.ClustersDir != "clusters/production" || !k.Prune || len(k.HealthChecks) != 1 {
+		t.Fatalf("unexpected Flux Kustomization %+v", k)
+	}
+	if k.Namespace != DefaultFluxNamespace || k.SourceRef != DefaultFluxNamespace {
+		t.Errorf("expected the flux-system namespace and source by default, got %q and %q", k.Namespace, k.SourceRef)
+	}
+
+	// The spec keeps its own copy of the Kustomization
+	saved := NewAppSpec(&spec.Spec)
+	saved.Spec.FluxKustomization.HealthChecks[0].Name = "other"
+	if k.HealthChecks[0].Name != "podinfo" {
+		t.Error("expected NewAppSpec to copy the health checks")
+	}
+
+	if _, err := ParseSpec([]byte(strings.Replace(data, "clustersDir: clusters/production", "timeout: 5m", 1))); err == nil {
+		t.Error("expected a Flux Kustomization without clusters directory to be rejected")
+	}
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// namespaceName matches namespace names, DNS labels of at most maxNamespaceLength characters.
+var namespaceName = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// maxNamespaceLength is the maximum length of a namespace name.
+const maxNamespaceLength = 63
+
+// validNamespace reports whether name can name a namespace.
+func validNamespace(name string) bool {
+	return len(name) <= maxNamespaceLength && namespaceName.MatchString(name)
+}
+
+// namespaceError reports an invalid namespace name in field.
+func namespaceError(field, name string) error {
+	return &SpecError{Field: field, Message: fmt.Sprintf("%q must be a DNS label of at most %d lowercase alphanumeric characters or '-'", name, maxNamespaceLength)}
+}
+
+// Pod Security Admission levels enforced on the namespace of the app.
+const (
+	PodSecurityPrivileged = "privileged"
+	PodSecurityBaseline   = "baseline"
+	PodSecurityRestricted = "restricted"
+)
+
+// PodSecurityEnforceLabel is the namespace label Pod Security Admission enforces a level with.
+const PodSecurityEnforceLabel = "pod-security.kubernetes.io/enforce"
+
+// NamespaceManifest is the Namespace of the app, generated into dependencies/ for apps whose
+// namespace is not created elsewhere.
+type NamespaceManifest struct {
+	Labels        map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Annotations   map[string]string `json:"annotations,omitempty" yaml:"annotations,omitempty"`
+	PodSecurity   string            `json:"podSecurity,omitempty" yaml:"podSecurity,omitempty"` // Pod Security Admission level enforced on the namespace
+	Kustomization bool              `json:"kustomization" yaml:"kustomization"`                 // List the Namespace in kustomization.yaml, so that Flux applies it with the app
+}
+
+// ManifestLabels returns the labels of the Namespace, with the Pod Security Admission level.
+func (n *NamespaceManifest) ManifestLabels() map[string]string {
+	labels := make(map[string]string, len(n.Labels)+1)
+	for key, value := range n.Labels {
+		labels[key] = value
+	}
+	if n.PodSecurity != "" {
+		labels[PodSecurityEnforceLabel] = n.PodSecurity
+	}
+	return labels
+}
+
+// copy returns a copy of the Namespace that does not share its maps.
+func (n *NamespaceManifest) copy() *NamespaceManifest {
+	if n == nil {
+		return nil
+	}
+	clone := *n
+	clone.Labels = copyStrings(n.Labels)
+	clone.Annotations = copyStrings(n.Annotations)
+	return &clone
+}
+
+// copyStrings returns a copy of a map of strings, nil when it is empty.
+func copyStrings(m map[string]string) map[string]string {
+	if len(m) == 0 {
+		return nil
+	}
+	clone := make(map[string]string, len(m))
+	for key, value := range m {
+		clone[key] = value
+	}
+	return clone
+}
+
+// ValidateNamespaceManifest checks the labels, annotations and Pod Security Admission level of
+// the Namespace of the app, if any.
+func (c *AppConfig) ValidateNamespaceManifest() error {
+	n := c.NamespaceManifest
+	if n == nil {
+		return nil
+	}
+	switch n.PodSecurity {
+	case "", PodSecurityPrivileged, PodSecurityBaseline, PodSecurityRestricted:
+	default:
+		return &SpecError{Field: "spec.namespaceManifest.podSecurity", Message: fmt.Sprintf("%q must be %q, %q or %q", n.PodSecurity, PodSecurityPrivileged, PodSecurityBaseline, PodSecurityRestricted)}
+	}
+	for _, field := range []struct {
+		name  string
+		pairs map[string]string
+	}{{"labels", n.Labels}, {"annotations", n.Annotations}} {
+		for key := range field.pairs {
+			if strings.TrimSpace(key) == "" || strings.ContainsAny(key, " :") {
+				return &SpecError{Field: "spec.namespaceManifest." + field.name, Message: fmt.Sprintf("invalid key %q", key)}
+			}
+		}
+	}
+	if _, ok := n.Labels[PodSecurityEnforceLabel]; ok && n.PodSecurity != "" {
+		return &SpecError{Field: "spec.namespaceManifest.labels", Message: fmt.Sprintf("%s is set by podSecurity", PodSecurityEnforceLabel)}
+	}
+	return nil
+}
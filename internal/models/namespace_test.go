@@ -0,0 +1,56 @@
+package models
+
+import "testing"
+
+func TestValidateNamespaceManifest(t *testing.T) {
+	tests := []struct {
+		name      string
+		namespace *NamespaceManifest
+		field     string
+	}{
+		{"none", nil, ""},
+		{"valid", &NamespaceManifest{Labels: map[string]string{"app.kubernetes.io/part-of": "shop"}, Annotations: map[string]string{"owner": "web-team"}, PodSecurity: PodSecurityRestricted, Kustomization: true}, ""},
+		{"enforce label without level", &NamespaceManifest{Labels: map[string]string{PodSecurityEnforceLabel: PodSecurityBaseline}}, ""},
+		{"invalid pod security", &NamespaceManifest{PodSecurity: "strict"}, "spec.namespaceManifest.podSecurity"},
+		{"invalid label", &NamespaceManifest{Labels: map[string]string{"my team": "web"}}, "spec.namespaceManifest.labels"},
+		{"invalid annotation", &NamespaceManifest{Annotations: map[string]string{"": "web"}}, "spec.namespaceManifest.annotations"},
+		{"enforce label and level", &NamespaceManifest{Labels: map[string]string{PodSecurityEnforceLabel: PodSecurityBaseline}, PodSecurity: PodSecurityRestricted}, "spec.namespaceManifest.labels"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &AppConfig{NamespaceManifest: tt.namespace}
+			err := config.ValidateNamespaceManifest()
+			if tt.field == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			specErr, ok := err.(*SpecError)
+			if !ok || specErr.Field != tt.field {
+				t.Errorf("expected an error on %s, got %v", tt.field, err)
+			}
+		})
+	}
+}
+
+func TestNamespaceManifest_ManifestLabels(t *testing.T) {
+	n := &NamespaceManifest{Labels: map[string]string{"team": "web"}, PodSecurity: PodSecurityBaseline}
+	labels := n.ManifestLabels()
+	if len(labels) != 2 || labels["team"] != "web" || labels[PodSecurityEnforceLabel] != PodSecurityBaseline {
+		t.Errorf("unexpected labels %v", labels)
+	}
+	if _, ok := n.Labels[PodSecurityEnforceLabel]; ok {
+		t.Error("expected ManifestLabels not to change the labels")
+	}
+}
+
+func TestNewAppSpec_NamespaceManifest(t *testing.T) {
+	config := &AppConfig{NamespaceManifest: &NamespaceManifest{Labels: map[string]string{"team": "web"}}}
+	spec := NewAppSpec(config)
+	spec.Spec.NamespaceManifest.Labels["team"] = "shop"
+	if config.NamespaceManifest.Labels["team"] != "web" {
+		t.Error("expected NewAppSpec to copy the Namespace")
+	}
+}
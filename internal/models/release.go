@@ -0,0 +1,150 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// releaseName matches the Helm release names, DNS subdomains of at most 53 characters.
+var releaseName = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+
+// maxReleaseNameLength is the length Helm limits release names to.
+const maxReleaseNameLength = 53
+
+// CRD policies of the install and upgrade of a HelmRelease.
+const (
+	CRDPolicySkip          = "Skip"
+	CRDPolicyCreate        = "Create"
+	CRDPolicyCreateReplace = "CreateReplace"
+)
+
+// Drift detection modes of a HelmRelease.
+const (
+	DriftDetectionEnabled  = "enabled"
+	DriftDetectionWarn     = "warn"
+	DriftDetectionDisabled = "disabled"
+)
+
+// ReleaseOptions are the optional settings of the HelmRelease. Only the fields that are set are
+// written into helm-release.yaml, Flux defaults apply to the others.
+type ReleaseOptions struct {
+	ReleaseName          string   `json:"releaseName,omitempty" yaml:"releaseName,omitempty"`                   // Helm release name, [<targetNamespace>-]<name> by default
+	TargetNamespace      string   `json:"targetNamespace,omitempty" yaml:"targetNamespace,omitempty"`           // Namespace the release is installed in
+	ServiceAccountName   string   `json:"serviceAccountName,omitempty" yaml:"serviceAccountName,omitempty"`     // Service account the release is installed with
+	Timeout              string   `json:"timeout,omitempty" yaml:"timeout,omitempty"`                           // Timeout of the Helm actions
+	MaxHistory           *int     `json:"maxHistory,omitempty" yaml:"maxHistory,omitempty"`                     // Revisions kept in the release history, 0 for all
+	DependsOn            []string `json:"dependsOn,omitempty" yaml:"dependsOn,omitempty"`                       // HelmReleases installed before, as name or namespace/name
+	InstallRetries       int      `json:"installRetries,omitempty" yaml:"installRetries,omitempty"`             // Remediation retries of a failed install, -1 for unlimited
+	InstallCRDs          string   `json:"installCRDs,omitempty" yaml:"installCRDs,omitempty"`                   // CRD policy of the install
+	UpgradeRetries       int      `json:"upgradeRetries,omitempty" yaml:"upgradeRetries,omitempty"`             // Remediation retries of a failed upgrade, -1 for unlimited
+	RemediateLastFailure *bool    `json:"remediateLastFailure,omitempty" yaml:"remediateLastFailure,omitempty"` // Remediate the last failed upgrade once retries are exhausted
+	UpgradeCRDs          string   `json:"upgradeCRDs,omitempty" yaml:"upgradeCRDs,omitempty"`                   // CRD policy of the upgrades
+	Test                 bool     `json:"test,omitempty" yaml:"test,omitempty"`                                 // Run the Helm tests after install and upgrade
+	DriftDetection       string   `json:"driftDetection,omitempty" yaml:"driftDetection,omitempty"`             // Drift detection mode of the release
+}
+
+// Dependency is a Flux object reconciled before the one depending on it, in the same namespace
+// when Namespace is empty.
+type Dependency struct {
+	Name      string
+	Namespace string
+}
+
+// ParseDependency parses a dependsOn entry, a name or namespace/name.
+func ParseDependency(s string) Dependency {
+	if namespace, name, ok := strings.Cut(s, "/"); ok {
+		return Dependency{Name: name, Namespace: namespace}
+	}
+	return Dependency{Name: s}
+}
+
+// parseDependencies parses dependsOn entries.
+func parseDependencies(dependsOn []string) []Dependency {
+	dependencies := make([]Dependency, 0, len(dependsOn))
+	for _, s := range dependsOn {
+		dependencies = append(dependencies, ParseDependency(s))
+	}
+	return dependencies
+}
+
+// Dependencies returns the HelmReleases installed before the release.
+func (r *ReleaseOptions) Dependencies() []Dependency {
+	return parseDependencies(r.DependsOn)
+}
+
+// IsZero reports whether no option is set.
+func (r *ReleaseOptions) IsZero() bool {
+	return r == nil || r.ReleaseName == "" && r.TargetNamespace == "" && r.ServiceAccountName == "" &&
+		r.Timeout == "" && r.MaxHistory == nil && len(r.DependsOn) == 0 && r.InstallRetries == 0 &&
+		r.InstallCRDs == "" && r.UpgradeRetries == 0 && r.RemediateLastFailure == nil && r.UpgradeCRDs == "" &&
+		!r.Test && r.DriftDetection == ""
+}
+
+// copy returns a copy of the options that does not share their lists and pointers.
+func (r *ReleaseOptions) copy() *ReleaseOptions {
+	if r == nil {
+		return nil
+	}
+	clone := *r
+	clone.DependsOn = append([]string(nil), r.DependsOn...)
+	if r.MaxHistory != nil {
+		maxHistory := *r.MaxHistory
+		clone.MaxHistory = &maxHistory
+	}
+	if r.RemediateLastFailure != nil {
+		remediate := *r.RemediateLastFailure
+		clone.RemediateLastFailure = &remediate
+	}
+	return &clone
+}
+
+// ValidateRelease checks the options of the HelmRelease, if any.
+func (c *AppConfig) ValidateRelease() error {
+	r := c.Release
+	if r == nil {
+		return nil
+	}
+	if r.ReleaseName != "" && (len(r.ReleaseName) > maxReleaseNameLength || !releaseName.MatchString(r.ReleaseName)) {
+		return &SpecError{Field: "spec.release.releaseName", Message: fmt.Sprintf("%q must be a DNS subdomain of at most %d characters", r.ReleaseName, maxReleaseNameLength)}
+	}
+	if r.TargetNamespace != "" && !validNamespace(r.TargetNamespace) {
+		return namespaceError("spec.release.targetNamespace", r.TargetNamespace)
+	}
+	if r.ServiceAccountName != "" && !releaseName.MatchString(r.ServiceAccountName) {
+		return &SpecError{Field: "spec.release.serviceAccountName", Message: fmt.Sprintf("%q must be lowercase alphanumeric characters, '-' or '.'", r.ServiceAccountName)}
+	}
+	if r.Timeout != "" {
+		if _, err := time.ParseDuration(r.Timeout); err != nil {
+			return &SpecError{Field: "spec.release.timeout", Message: err.Error()}
+		}
+	}
+	if r.MaxHistory != nil && *r.MaxHistory < 0 {
+		return &SpecError{Field: "spec.release.maxHistory", Message: "must be 0 or more"}
+	}
+	for i, name := range r.DependsOn {
+		if dependency := ParseDependency(name); strings.TrimSpace(dependency.Name) == "" {
+			return &SpecError{Field: fmt.Sprintf("spec.release.dependsOn[%d]", i), Message: "value is required"}
+		}
+	}
+	if r.InstallRetries < -1 {
+		return &SpecError{Field: "spec.release.installRetries", Message: "must be -1 (unlimited) or more"}
+	}
+	if r.UpgradeRetries < -1 {
+		return &SpecError{Field: "spec.release.upgradeRetries", Message: "must be -1 (unlimited) or more"}
+	}
+	for field, value := range map[string]string{"spec.release.installCRDs": r.InstallCRDs, "spec.release.upgradeCRDs": r.UpgradeCRDs} {
+		switch value {
+		case "", CRDPolicySkip, CRDPolicyCreate, CRDPolicyCreateReplace:
+		default:
+			return &SpecError{Field: field, Message: fmt.Sprintf("%q must be %q, %q or %q", value, CRDPolicySkip, CRDPolicyCreate, CRDPolicyCreateReplace)}
+		}
+	}
+	switch r.DriftDetection {
+	case "", DriftDetectionEnabled, DriftDetectionWarn, DriftDetectionDisabled:
+	default:
+		return &SpecError{Field: "spec.release.driftDetection", Message: fmt.Sprintf("%q must be %q, %q or %q", r.DriftDetection, DriftDetectionEnabled, DriftDetectionWarn, DriftDetectionDisabled)}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateRelease(t *testing.T) {
+	negative, zero := -1, 0
+	tests := []struct {
+		name    string
+		release *ReleaseOptions
+		field   string
+	}{
+		{"none", nil, ""},
+		{"valid", &ReleaseOptions{ReleaseName: "podinfo.web", TargetNamespace: "web", Timeout: "10m", MaxHistory: &zero, DependsOn: []string{"infra/cert-manager"}, InstallRetries: -1, UpgradeRetries: 3, InstallCRDs: CRDPolicyCreate, UpgradeCRDs: CRDPolicyCreateReplace, DriftDetection: DriftDetectionWarn}, ""},
+		{"invalid release name", &ReleaseOptions{ReleaseName: "Podinfo"}, "spec.release.releaseName"},
+		{"release name too long", &ReleaseOptions{ReleaseName: strings.Repeat("a", 54)}, "spec.release.releaseName"},
+		{"invalid target namespace", &ReleaseOptions{TargetNamespace: "my_apps"}, "spec.release.targetNamespace"},
+		{"target namespace with a dot", &ReleaseOptions{TargetNamespace: "apps.web"}, "spec.release.targetNamespace"},
+		{"target namespace too long", &ReleaseOptions{TargetNamespace: strings.Repeat("a", 64)}, "spec.release.targetNamespace"},
+		{"invalid timeout", &ReleaseOptions{Timeout: "later"}, "spec.release.timeout"},
+		{"negative max history", &ReleaseOptions{MaxHistory: &negative}, "spec.release.maxHistory"},
+		{"empty dependency", &ReleaseOptions{DependsOn: []string{"infra/"}}, "spec.release.dependsOn[0]"},
+		{"invalid retries", &ReleaseOptions{UpgradeRetries: -2}, "spec.release.upgradeRetries"},
+		{"invalid CRD policy", &ReleaseOptions{InstallCRDs: "Replace"}, "spec.release.installCRDs"},
+		{"invalid drift detection", &ReleaseOptions{DriftDetection: "on"}, "spec.release.driftDetection"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &AppConfig{Release: tt.release}
+			err := config.ValidateRelease()
+			if tt.field == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			specErr, ok := err.(*SpecError)
+			if !ok || specErr.Field != tt.field {
+				t.Errorf("expected an error on %s, got %v", tt.field, err)
+			}
+		})
+	}
+}
+
+func TestNewAppSpec_Release(t *testing.T) {
+	maxHistory := 3
+	config := &AppConfig{Release: &ReleaseOptions{MaxHistory: &maxHistory}}
+	spec := NewAppSpec(config)
+	*spec.Spec.Release.MaxHistory = 10
+	if *config.Release.MaxHistory != 3 {
+		t.Error("expected NewAppSpec to copy the release options")
+	}
+
+	// Unset options are left out of the spec
+	if spec := NewAppSpec(&AppConfig{Release: &ReleaseOptions{}}); spec.Spec.Release != nil {
+		t.Errorf("expected no release options, got %+v", spec.Spec.Release)
+	}
+}
@@ -0,0 +1,309 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/plugins"
+	"github.com/EffectiveSloth/flux-app-generator/internal/semver"
+)
+
+const (
+	// SpecAPIVersion is the current version of the app spec file format.
+	SpecAPIVersion = "flux-app-generator.effectivesloth.io/v1alpha1"
+	// SpecKind is the kind of the app spec file.
+	SpecKind = "AppSpec"
+
+	// ValuesPrefillDefault initializes the values file from the chart's default values.
+	ValuesPrefillDefault = "default"
+	// ValuesPrefillEmpty initializes an empty values file.
+	ValuesPrefillEmpty = "empty"
+	// ValuesPrefillOverrides initializes the values file with a subset of the chart's default values
+	// and keeps all defaults in a commented reference file.
+	ValuesPrefillOverrides = "overrides"
+
+	// RawValuesKey is the Values key holding a raw YAML document to write as the values file.
+	RawValuesKey = "__raw_yaml__"
+
+	defaultSpecInterval = "5m"
+)
+
+// AppSpec is the versioned, serializable description of an application.
+type AppSpec struct {
+	APIVersion string    `json:"apiVersion" yaml:"apiVersion"`
+	Kind       string    `json:"kind" yaml:"kind"`
+	Spec       AppConfig `json:"spec" yaml:"spec"`
+}
+
+// SpecError represents an invalid app spec.
+type SpecError struct {
+	Field   string
+	Message string
+}
+
+func (e *SpecError) Error() string {
+	return fmt.Sprintf("invalid app spec field '%s': %s", e.Field, e.Message)
+}
+
+// NewAppSpec wraps a configuration into an app spec of the current version.
+// Generated fields and raw values are left out so that the spec only holds the user's answers.
+func NewAppSpec(config *AppConfig) *AppSpec {
+	spec := config.copy()
+	delete(spec.Values, RawValuesKey)
+	if len(spec.Values) == 0 {
+		spec.Values = nil
+	}
+	if len(spec.ValuesKeys) == 0 {
+		spec.ValuesKeys = nil
+	}
+	if len(spec.Subcharts) == 0 {
+		spec.Subcharts = nil
+	}
+	if len(spec.Environments) == 0 {
+		spec.Environments = nil
+	}
+	if len(spec.ValuesSources) == 0 {
+		spec.ValuesSources = nil
+	}
+	if spec.Release.IsZero() {
+		spec.Release = nil
+	}
+	spec.PluginFiles = nil
+	spec.DefaultValues = ""
+
+	return &AppSpec{
+		APIVersion: SpecAPIVersion,
+		Kind:       SpecKind,
+		Spec:       *spec,
+	}
+}
+
+// copy returns a copy of the configuration that does not share its values map or plugin list.
+func (c *AppConfig) copy() *AppConfig {
+	clone := *c
+	if c.Values != nil {
+		clone.Values = make(map[string]interface{}, len(c.Values))
+		for k, v := range c.Values {
+			clone.Values[k] = v
+		}
+	}
+	if c.Subcharts != nil {
+		clone.Subcharts = make(map[string]bool, len(c.Subcharts))
+		for k, v := range c.Subcharts {
+			clone.Subcharts[k] = v
+		}
+	}
+	clone.ValuesKeys = append([]string(nil), c.ValuesKeys...)
+	clone.Environments = append([]Environment(nil), c.Environments...)
+	clone.ValuesSources = append([]ValuesSource(nil), c.ValuesSources...)
+	clone.Release = c.Release.copy()
+	clone.NamespaceManifest = c.NamespaceManifest.copy()
+	clone.FluxKustomization = c.FluxKustomization.copy()
+	clone.Plugins = append([]plugins.PluginConfig(nil), c.Plugins...)
+	clone.PluginFiles = append([]string(nil), c.PluginFiles...)
+	return &clone
+}
+
+// LoadSpec reads, defaults and validates an app spec from a YAML or JSON file.
+func LoadSpec(path string) (*AppSpec, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- path is provided by the user
+	if err != nil {
+		return nil, fmt.Errorf("failed to read app spec %s: %w", path, err)
+	}
+
+	spec, err := ParseSpec(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load app spec %s: %w", path, err)
+	}
+	return spec, nil
+}
+
+// ParseSpec decodes, defaults and validates an app spec. JSON input is accepted as it is valid YAML.
+func ParseSpec(data []byte) (*AppSpec, error) {
+	var spec AppSpec
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&spec); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("app spec is empty")
+		}
+		return nil, fmt.Errorf("failed to parse app spec: %w", err)
+	}
+
+	spec.setDefaults()
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	return &spec, nil
+}
+
+// setDefaults fills the optional fields the same way the wizard does.
+func (s *AppSpec) setDefaults() {
+	if s.Spec.Interval == "" {
+		s.Spec.Interval = defaultSpecInterval
+	}
+	if s.Spec.FluxKustomization != nil {
+		s.Spec.FluxKustomization.setDefaults()
+	}
+	if s.Spec.ValuesPrefill == "" {
+		if len(s.Spec.Values) > 0 {
+			s.Spec.ValuesPrefill = ValuesPrefillEmpty
+		} else {
+			s.Spec.ValuesPrefill = ValuesPrefillDefault
+		}
+	}
+}
+
+// Validate checks that the spec is of a supported version and fully describes an application.
+func (s *AppSpec) Validate() error {
+	if s.APIVersion != SpecAPIVersion {
+		return &SpecError{Field: "apiVersion", Message: fmt.Sprintf("unsupported version %q, expected %q", s.APIVersion, SpecAPIVersion)}
+	}
+	if s.Kind != SpecKind {
+		return &SpecError{Field: "kind", Message: fmt.Sprintf("unsupported kind %q, expected %q", s.Kind, SpecKind)}
+	}
+
+	type requirement struct {
+		field string
+		value string
+	}
+	required := []requirement{
+		{"spec.appName", s.Spec.AppName},
+		{"spec.namespace", s.Spec.Namespace},
+		{"spec.helmRepoName", s.Spec.HelmRepoName},
+		{"spec.chartName", s.Spec.ChartName},
+	}
+	switch {
+	case s.Spec.UsesGitRepository():
+		// Charts of a Git repository are referenced by path, their version is the one in Git
+		required = append(required,
+			requirement{"spec.gitURL", s.Spec.GitURL},
+			requirement{"spec.gitBranch", s.Spec.GitBranch},
+			requirement{"spec.chartPath", s.Spec.ChartPath})
+	case s.Spec.VersionConstraint == "":
+		// The chart version can be resolved from a version constraint instead
+		required = append(required,
+			requirement{"spec.helmRepoURL", s.Spec.HelmRepoURL},
+			requirement{"spec.chartVersion", s.Spec.ChartVersion})
+	default:
+		required = append(required, requirement{"spec.helmRepoURL", s.Spec.HelmRepoURL})
+	}
+	for _, r := range required {
+		if strings.TrimSpace(r.value) == "" {
+			return &SpecError{Field: r.field, Message: "value is required"}
+		}
+	}
+
+	if s.Spec.VersionConstraint != "" {
+		if s.Spec.UsesGitRepository() {
+			return &SpecError{Field: "spec.versionConstraint", Message: fmt.Sprintf("does not apply to charts of a %s", SourceKindGitRepository)}
+		}
+		constraint, err := semver.ParseConstraint(s.Spec.VersionConstraint)
+		if err != nil {
+			return &SpecError{Field: "spec.versionConstraint", Message: err.Error()}
+		}
+		if s.Spec.ChartVersion != "" {
+			version, err := semver.Parse(s.Spec.ChartVersion)
+			if err != nil || !constraint.Check(version) {
+				return &SpecError{Field: "spec.chartVersion", Message: fmt.Sprintf("%q does not match the version constraint %q", s.Spec.ChartVersion, s.Spec.VersionConstraint)}
+			}
+		}
+	} else if s.Spec.TrackConstraint {
+		return &SpecError{Field: "spec.trackConstraint", Message: "requires a versionConstraint"}
+	}
+
+	if _, err := time.ParseDuration(s.Spec.Interval); err != nil {
+		return &SpecError{Field: "spec.interval", Message: err.Error()}
+	}
+
+	switch s.Spec.ValuesPrefill {
+	case ValuesPrefillDefault, ValuesPrefillOverrides:
+		if len(s.Spec.Values) > 0 {
+			return &SpecError{Field: "spec.valuesPrefill", Message: fmt.Sprintf("'%s' cannot be combined with explicit values", s.Spec.ValuesPrefill)}
+		}
+	case ValuesPrefillEmpty:
+	default:
+		return &SpecError{Field: "spec.valuesPrefill", Message: fmt.Sprintf("must be %q, %q or %q", ValuesPrefillDefault, ValuesPrefillOverrides, ValuesPrefillEmpty)}
+	}
+	switch s.Spec.SourceKind {
+	case "", SourceKindHelmRepository:
+	case SourceKindOCIRepository:
+		if !s.Spec.IsOCI() {
+			return &SpecError{Field: "spec.sourceKind", Message: fmt.Sprintf("%q requires an oci:// helmRepoURL", SourceKindOCIRepository)}
+		}
+	case SourceKindGitRepository:
+		if s.Spec.HelmRepoURL != "" {
+			return &SpecError{Field: "spec.helmRepoURL", Message: fmt.Sprintf("does not apply to charts of a %s", SourceKindGitRepository)}
+		}
+	default:
+		return &SpecError{Field: "spec.sourceKind", Message: fmt.Sprintf("must be %q, %q or %q", SourceKindHelmRepository, SourceKindOCIRepository, SourceKindGitRepository)}
+	}
+	if err := s.Spec.Validate(); err != nil {
+		return err
+	}
+	if len(s.Spec.ValuesKeys) > 0 && s.Spec.ValuesPrefill != ValuesPrefillOverrides {
+		return &SpecError{Field: "spec.valuesKeys", Message: fmt.Sprintf("only applies to valuesPrefill %q", ValuesPrefillOverrides)}
+	}
+	for key := range s.Spec.Subcharts {
+		if strings.TrimSpace(key) == "" {
+			return &SpecError{Field: "spec.subcharts", Message: "subchart keys must not be empty"}
+		}
+	}
+
+	registry := plugins.NewRegistry(nil)
+	for i, pluginConfig := range s.Spec.Plugins {
+		field := fmt.Sprintf("spec.plugins[%d]", i)
+		plugin, exists := registry.Get(pluginConfig.PluginName)
+		if !exists {
+			return &SpecError{Field: field, Message: fmt.Sprintf("unknown plugin '%s'", pluginConfig.PluginName)}
+		}
+		if err := plugin.Validate(pluginConfig.Values); err != nil {
+			return &SpecError{Field: field, Message: err.Error()}
+		}
+	}
+
+	return nil
+}
+
+// Marshal encodes the spec as JSON when the path has a .json extension and as YAML otherwise.
+func (s *AppSpec) Marshal(path string) ([]byte, error) {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		data, err := json.MarshalIndent(s, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode app spec: %w", err)
+		}
+		return append(data, '\n'), nil
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(s); err != nil {
+		return nil, fmt.Errorf("failed to encode app spec: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode app spec: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// SaveSpec writes the spec to a YAML or JSON file depending on its extension.
+func SaveSpec(path string, spec *AppSpec) error {
+	data, err := spec.Marshal(path)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write app spec %s: %w", path, err)
+	}
+	return nil
+}
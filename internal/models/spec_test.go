@@ -0,0 +1,314 @@
+package models
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/plugins"
+)
+
+func newTestConfig() *AppConfig {
+	return &AppConfig{
+		AppName:       "test-app",
+		Namespace:     "default",
+		HelmRepoName:  "test-repo",
+		HelmRepoURL:   "https://example.com/repo",
+		ChartName:     "test-chart",
+		ChartVersion:  "1.0.0",
+		Interval:      "5m",
+		ValuesPrefill: ValuesPrefillDefault,
+		Values:        map[string]interface{}{},
+		Plugins: []plugins.PluginConfig{
+			{
+				PluginName: "externalsecret",
+				Values: map[string]interface{}{
+					"name":               "test-secret",
+					"secret_store_type":  "ClusterSecretStore",
+					"secret_store_name":  "vault-backend",
+					"secret_key":         "secret/myapp",
+					"target_secret_name": "test-target",
+					"refresh_interval":   "60m",
+				},
+			},
+		},
+		PluginFiles: []string{"dependencies/external-secret-test-target.yaml"},
+	}
+}
+
+func TestSaveAndLoadSpec_RoundTrip(t *testing.T) {
+	for _, name := range []string{"app.yaml", "app.json"} {
+		t.Run(name, func(t *testing.T) {
+			config := newTestConfig()
+			config.Subcharts = map[string]bool{"redis": false}
+			path := filepath.Join(t.TempDir(), name)
+
+			if err := SaveSpec(path, NewAppSpec(config)); err != nil {
+				t.Fatalf("failed to save spec: %v", err)
+			}
+
+			spec, err := LoadSpec(path)
+			if err != nil {
+				t.Fatalf("failed to load spec: %v", err)
+			}
+
+			if spec.APIVersion != SpecAPIVersion || spec.Kind != SpecKind {
+				t.Errorf("unexpected header %s/%s", spec.APIVersion, spec.Kind)
+			}
+			loaded := spec.Spec
+			if loaded.AppName != config.AppName || loaded.Namespace != config.Namespace ||
+				loaded.HelmRepoName != config.HelmRepoName || loaded.HelmRepoURL != config.HelmRepoURL ||
+				loaded.ChartName != config.ChartName || loaded.ChartVersion != config.ChartVersion ||
+				loaded.Interval != config.Interval || loaded.ValuesPrefill != config.ValuesPrefill {
+				t.Errorf("loaded spec does not match config: %+v", loaded)
+			}
+			if len(loaded.Plugins) != 1 || loaded.Plugins[0].PluginName != "externalsecret" {
+				t.Fatalf("expected externalsecret plugin, got %+v", loaded.Plugins)
+			}
+			if loaded.Plugins[0].Values["target_secret_name"] != "test-target" {
+				t.Errorf("plugin values were not preserved: %+v", loaded.Plugins[0].Values)
+			}
+			if enabled, ok := loaded.Subcharts["redis"]; !ok || enabled {
+				t.Errorf("subchart toggles were not preserved: %v", loaded.Subcharts)
+			}
+			if loaded.PluginFiles != nil {
+				t.Errorf("expected generated plugin files to be omitted, got %v", loaded.PluginFiles)
+			}
+		})
+	}
+}
+
+func TestNewAppSpec_OmitsRawValues(t *testing.T) {
+	config := newTestConfig()
+	config.Values[RawValuesKey] = "replicaCount: 1\n"
+
+	spec := NewAppSpec(config)
+	if spec.Spec.Values != nil {
+		t.Errorf("expected raw values to be omitted, got %v", spec.Spec.Values)
+	}
+	if _, ok := config.Values[RawValuesKey]; !ok {
+		t.Error("expected the original config to be left untouched")
+	}
+}
+
+func TestParseSpec_Defaults(t *testing.T) {
+	spec, err := ParseSpec([]byte(`apiVersion: flux-app-generator.effectivesloth.io/v1alpha1
+kind: AppSpec
+spec:
+  appName: test-app
+  namespace: default
+  helmRepoName: test-repo
+  helmRepoURL: https://example.com/repo
+  chartName: test-chart
+  chartVersion: 1.0.0
+  values:
+    replicaCount: 2
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if spec.Spec.Interval != "5m" {
+		t.Errorf("expected default interval 5m, got %s", spec.Spec.Interval)
+	}
+	if spec.Spec.ValuesPrefill != ValuesPrefillEmpty {
+		t.Errorf("expected explicit values to imply the empty prefill, got %s", spec.Spec.ValuesPrefill)
+	}
+	if spec.Spec.Values["replicaCount"] != 2 {
+		t.Errorf("expected replicaCount 2, got %v", spec.Spec.Values["replicaCount"])
+	}
+}
+
+func TestParseSpec_GitRepository(t *testing.T) {
+	spec, err := ParseSpec([]byte(`apiVersion: flux-app-generator.effectivesloth.io/v1alpha1
+kind: AppSpec
+spec:
+  appName: foo
+  namespace: default
+  helmRepoName: fleet
+  sourceKind: GitRepository
+  gitURL: ssh://git@github.com/acme/fleet.git
+  gitBranch: main
+  chartPath: ./charts/foo
+  chartName: foo
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !spec.Spec.UsesGitRepository() {
+		t.Errorf("expected the chart to be pulled from a GitRepository")
+	}
+}
+
+func TestParseSpec_Invalid(t *testing.T) {
+	valid := NewAppSpec(newTestConfig())
+	data, err := valid.Marshal("app.yaml")
+	if err != nil {
+		t.Fatalf("failed to marshal spec: %v", err)
+	}
+	base := string(data)
+
+	tests := []struct {
+		name    string
+		input   string
+		message string
+	}{
+		{"empty", "", "app spec is empty"},
+		{"unknown field", base + "extra: true\n", "field extra not found"},
+		{"wrong version", strings.Replace(base, "v1alpha1", "v9", 1), "apiVersion"},
+		{"wrong kind", strings.Replace(base, "kind: AppSpec", "kind: Other", 1), "kind"},
+		{"missing chart version", strings.Replace(base, "chartVersion: 1.0.0", "chartVersion: \"\"", 1), "spec.chartVersion"},
+		{"invalid interval", strings.Replace(base, "interval: 5m", "interval: often", 1), "spec.interval"},
+		{"invalid version constraint", strings.Replace(base, "interval: 5m", "interval: 5m\n  versionConstraint: ~latest", 1), "spec.versionConstraint"},
+		{"version outside constraint", strings.Replace(base, "interval: 5m", "interval: 5m\n  versionConstraint: ^2.0.0", 1), "does not match the version constraint"},
+		{"tracking without constraint", strings.Replace(base, "interval: 5m", "interval: 5m\n  trackConstraint: true", 1), "spec.trackConstraint"},
+		{"invalid prefill", strings.Replace(base, "valuesPrefill: default", "valuesPrefill: partial", 1), "spec.valuesPrefill"},
+		{"empty subchart key", strings.Replace(base, "valuesPrefill: default", "valuesPrefill: default\n  subcharts: {\"\": true}", 1), "spec.subcharts"},
+		{"values keys without overrides", strings.Replace(base, "valuesPrefill: default", "valuesPrefill: default\n  valuesKeys: [image]", 1), "spec.valuesKeys"},
+		{"invalid source kind", strings.Replace(base, "interval: 5m", "interval: 5m\n  sourceKind: Bucket", 1), "spec.sourceKind"},
+		{"git repository without git url", strings.Replace(base, "interval: 5m", "interval: 5m\n  sourceKind: GitRepository", 1), "spec.gitURL"},
+		{"git repository with helm url", strings.Replace(base, "interval: 5m", "interval: 5m\n  sourceKind: GitRepository\n  gitURL: https://github.com/acme/fleet\n  gitBranch: main\n  chartPath: ./charts/foo", 1), "spec.helmRepoURL"},
+		{"oci repository without oci url", strings.Replace(base, "interval: 5m", "interval: 5m\n  sourceKind: OCIRepository", 1), "requires an oci:// helmRepoURL"},
+		{"unknown plugin", strings.Replace(base, "plugin_name: externalsecret", "plugin_name: unknown", 1), "unknown plugin 'unknown'"},
+		{"invalid plugin values", strings.Replace(base, "secret_store_type: ClusterSecretStore", "secret_store_type: Vault", 1), "spec.plugins[0]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseSpec([]byte(tt.input))
+			if err == nil {
+				t.Fatal("expected error but got none")
+			}
+			if !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("expected error containing %q, got %v", tt.message, err)
+			}
+		})
+	}
+}
+
+func TestValidate_DefaultPrefillWithValues(t *testing.T) {
+	config := newTestConfig()
+	config.Values = map[string]interface{}{"replicaCount": 2}
+
+	err := NewAppSpec(config).Validate()
+	var specErr *SpecError
+	if !errors.As(err, &specErr) || specErr.Field != "spec.valuesPrefill" {
+		t.Errorf("expected valuesPrefill spec error, got %v", err)
+	}
+}
+
+func TestValidate_OverridesPrefill(t *testing.T) {
+	config := newTestConfig()
+	config.ValuesPrefill = ValuesPrefillOverrides
+	config.ValuesKeys = []string{"image", "resources.limits"}
+
+	if err := NewAppSpec(config).Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	config.Values = map[string]interface{}{"replicaCount": 2}
+	err := NewAppSpec(config).Validate()
+	var specErr *SpecError
+	if !errors.As(err, &specErr) || specErr.Field != "spec.valuesPrefill" {
+		t.Errorf("expected valuesPrefill spec error, got %v", err)
+	}
+}
+
+func TestValidate_VersionConstraint(t *testing.T) {
+	config := newTestConfig()
+	config.ChartVersion = ""
+	config.VersionConstraint = "~1.0"
+	config.TrackConstraint = true
+
+	if err := NewAppSpec(config).Validate(); err != nil {
+		t.Errorf("expected the constraint to replace the chart version, got %v", err)
+	}
+	if config.ReleaseVersion() != "~1.0" {
+		t.Errorf("expected the tracked constraint as release version, got %s", config.ReleaseVersion())
+	}
+
+	config.ChartVersion = "1.0.3"
+	config.TrackConstraint = false
+	if err := NewAppSpec(config).Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if config.ReleaseVersion() != "1.0.3" {
+		t.Errorf("expected the pinned version as release version, got %s", config.ReleaseVersion())
+	}
+}
+
+func TestLoadSpec_MissingFile(t *testing.T) {
+	if _, err := LoadSpec(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestAppConfig_OCI(t *testing.T) {
+	config := newTestConfig()
+	config.HelmRepoURL = "oci://ghcr.io/stefanprodan/charts/"
+	config.ChartName = "podinfo"
+	config.ChartVersion = "6.9.0+build.1"
+	config.SourceKind = SourceKindOCIRepository
+
+	if !config.IsOCI() || !config.UsesOCIRepository() {
+		t.Error("expected an OCI chart pulled through an OCIRepository")
+	}
+	if url := config.OCIChartURL(); url != "oci://ghcr.io/stefanprodan/charts/podinfo" {
+		t.Errorf("unexpected chart URL %s", url)
+	}
+	if tag := config.OCIChartTag(); tag != "6.9.0_build.1" {
+		t.Errorf("unexpected chart tag %s", tag)
+	}
+	if err := NewAppSpec(config).Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestAppConfig_ValidateVerification(t *testing.T) {
+	newConfig := func() *AppConfig {
+		config := newTestConfig()
+		config.HelmRepoURL = "oci://ghcr.io/stefanprodan/charts"
+		config.SourceKind = SourceKindOCIRepository
+		config.CosignVerify = true
+		return config
+	}
+
+	tests := []struct {
+		name    string
+		edit    func(c *AppConfig)
+		message string
+	}{
+		{"keyless", func(_ *AppConfig) {}, ""},
+		{"public keys", func(c *AppConfig) { c.CosignSecretRef = "cosign-pub" }, ""},
+		{"helm repository of type oci", func(c *AppConfig) { c.SourceKind = SourceKindHelmRepository }, ""},
+		{"oidc identity", func(c *AppConfig) {
+			c.CosignOIDCIssuer, c.CosignOIDCSubject = "^https://token.actions.githubusercontent.com$", "^https://github.com/stefanprodan/.*$"
+		}, ""},
+		{"not an oci chart", func(c *AppConfig) { c.HelmRepoURL, c.SourceKind = "https://stefanprodan.github.io/podinfo", "" }, "spec.cosignVerify"},
+		{"secret without verify", func(c *AppConfig) { c.CosignVerify, c.CosignSecretRef = false, "cosign-pub" }, "requires cosignVerify"},
+		{"issuer without subject", func(c *AppConfig) { c.CosignOIDCIssuer = "^https://accounts.google.com$" }, "spec.cosignOIDCSubject"},
+		{"identity with public keys", func(c *AppConfig) {
+			c.CosignSecretRef, c.CosignOIDCIssuer, c.CosignOIDCSubject = "cosign-pub", ".*", ".*"
+		}, "only applies to keyless verification"},
+		{"identity in a helm release", func(c *AppConfig) {
+			c.SourceKind, c.CosignOIDCIssuer, c.CosignOIDCSubject = "", ".*", ".*"
+		}, `requires sourceKind "OCIRepository"`},
+		{"invalid regexp", func(c *AppConfig) { c.CosignOIDCIssuer, c.CosignOIDCSubject = ".*", "(" }, "spec.cosignOIDCSubject"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newConfig()
+			tt.edit(config)
+			err := NewAppSpec(config).Validate()
+			if tt.message == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("expected error containing %q, got %v", tt.message, err)
+			}
+		})
+	}
+}
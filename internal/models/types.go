@@ -2,19 +2,148 @@
 package models
 
 import (
+	"fmt"
+	"regexp"
+	"strings"
+
 	"github.com/EffectiveSloth/flux-app-generator/internal/plugins"
 )
 
+// Kinds of Flux source a chart is pulled from.
+const (
+	// SourceKindHelmRepository pulls the chart through a HelmRepository, of type oci for OCI registries.
+	SourceKindHelmRepository = "HelmRepository"
+	// SourceKindOCIRepository pulls the chart of an OCI registry through an OCIRepository referenced by chartRef.
+	SourceKindOCIRepository = "OCIRepository"
+	// SourceKindGitRepository pulls a chart stored in a Git repository, by its path, through a GitRepository.
+	SourceKindGitRepository = "GitRepository"
+)
+
 // AppConfig represents the complete configuration for generating a Flux application.
 type AppConfig struct {
-	AppName      string
-	Namespace    string
-	HelmRepoName string
-	HelmRepoURL  string
-	ChartName    string
-	ChartVersion string
-	Interval     string
-	Values       map[string]interface{}
-	Plugins      []plugins.PluginConfig
-	PluginFiles  []string // Relative paths to plugin-generated files
+	AppName           string                 `json:"appName" yaml:"appName"`
+	Namespace         string                 `json:"namespace" yaml:"namespace"`
+	HelmRepoName      string                 `json:"helmRepoName" yaml:"helmRepoName"`
+	HelmRepoURL       string                 `json:"helmRepoURL" yaml:"helmRepoURL"`
+	ChartName         string                 `json:"chartName" yaml:"chartName"`
+	ChartVersion      string                 `json:"chartVersion" yaml:"chartVersion"`
+	VersionConstraint string                 `json:"versionConstraint,omitempty" yaml:"versionConstraint,omitempty"` // Semver constraint the chart version is resolved from
+	TrackConstraint   bool                   `json:"trackConstraint,omitempty" yaml:"trackConstraint,omitempty"`     // Write the constraint instead of the chart version
+	Interval          string                 `json:"interval" yaml:"interval"`
+	SourceKind        string                 `json:"sourceKind,omitempty" yaml:"sourceKind,omitempty"`               // "HelmRepository" (default), "OCIRepository" or "GitRepository"
+	GitURL            string                 `json:"gitURL,omitempty" yaml:"gitURL,omitempty"`                       // URL of the GitRepository holding the chart
+	GitBranch         string                 `json:"gitBranch,omitempty" yaml:"gitBranch,omitempty"`                 // Branch of the GitRepository
+	ChartPath         string                 `json:"chartPath,omitempty" yaml:"chartPath,omitempty"`                 // Path of the chart in the GitRepository, e.g. ./charts/foo
+	RepoSecretRef     string                 `json:"repoSecretRef,omitempty" yaml:"repoSecretRef,omitempty"`         // Secret with the repository credentials
+	RepoCertSecretRef string                 `json:"repoCertSecretRef,omitempty" yaml:"repoCertSecretRef,omitempty"` // Secret with the repository CA and client certificate
+	CosignVerify      bool                   `json:"cosignVerify,omitempty" yaml:"cosignVerify,omitempty"`           // Have Flux verify the cosign signature of the OCI chart
+	CosignSecretRef   string                 `json:"cosignSecretRef,omitempty" yaml:"cosignSecretRef,omitempty"`     // Secret with the cosign public keys, keyless verification without
+	CosignOIDCIssuer  string                 `json:"cosignOIDCIssuer,omitempty" yaml:"cosignOIDCIssuer,omitempty"`   // Regexp of the OIDC issuer of keyless signatures
+	CosignOIDCSubject string                 `json:"cosignOIDCSubject,omitempty" yaml:"cosignOIDCSubject,omitempty"` // Regexp of the OIDC subject of keyless signatures
+	ValuesPrefill     string                 `json:"valuesPrefill,omitempty" yaml:"valuesPrefill,omitempty"`         // "default", "overrides" or "empty"
+	ValuesKeys        []string               `json:"valuesKeys,omitempty" yaml:"valuesKeys,omitempty"`               // Keys of an "overrides" values file
+	Subcharts         map[string]bool        `json:"subcharts,omitempty" yaml:"subcharts,omitempty"`                 // Subcharts enabled or disabled through their condition, by dependency key
+	Values            map[string]interface{} `json:"values,omitempty" yaml:"values,omitempty"`
+	ValuesKind        string                 `json:"valuesKind,omitempty" yaml:"valuesKind,omitempty"`               // "ConfigMap" (default), "Secret" or "inline"
+	ValuesSources     []ValuesSource         `json:"valuesSources,omitempty" yaml:"valuesSources,omitempty"`         // ConfigMaps and Secrets layered over the values
+	Release           *ReleaseOptions        `json:"release,omitempty" yaml:"release,omitempty"`                     // Optional settings of the HelmRelease
+	NamespaceManifest *NamespaceManifest     `json:"namespaceManifest,omitempty" yaml:"namespaceManifest,omitempty"` // Namespace generated into dependencies/, not generated when nil
+	Environments      []Environment          `json:"environments,omitempty" yaml:"environments,omitempty"`           // Overlays of a base app directory, one per environment
+	FluxKustomization *FluxKustomization     `json:"fluxKustomization,omitempty" yaml:"fluxKustomization,omitempty"` // Flux Kustomization reconciling the app, not generated when nil
+	Plugins           []plugins.PluginConfig `json:"plugins,omitempty" yaml:"plugins,omitempty"`
+	PluginFiles       []string               `json:"-" yaml:"-"` // Relative paths to plugin-generated files
+	DefaultValues     string                 `json:"-" yaml:"-"` // Commented reference copy of the chart default values
+}
+
+// IsOCI reports whether the chart is stored in an OCI registry.
+func (c *AppConfig) IsOCI() bool {
+	return strings.HasPrefix(c.HelmRepoURL, "oci://")
+}
+
+// UsesOCIRepository reports whether the chart is pulled through an OCIRepository instead of a HelmRepository.
+func (c *AppConfig) UsesOCIRepository() bool {
+	return c.SourceKind == SourceKindOCIRepository
+}
+
+// UsesGitRepository reports whether the chart is pulled from a Git repository by its path.
+func (c *AppConfig) UsesGitRepository() bool {
+	return c.SourceKind == SourceKindGitRepository
+}
+
+// ReleaseVersion returns the chart version written in the generated manifests: the version
+// constraint when it is tracked, so that Flux follows new matching versions, and the pinned
+// chart version otherwise.
+func (c *AppConfig) ReleaseVersion() string {
+	if c.TrackConstraint && c.VersionConstraint != "" {
+		return c.VersionConstraint
+	}
+	return c.ChartVersion
+}
+
+// OCIChartURL returns the oci:// URL of the chart itself, as used by an OCIRepository.
+func (c *AppConfig) OCIChartURL() string {
+	return strings.TrimSuffix(c.HelmRepoURL, "/") + "/" + c.ChartName
+}
+
+// OCIChartTag returns the OCI tag of the chart version, where "+" is replaced by "_".
+func (c *AppConfig) OCIChartTag() string {
+	return strings.ReplaceAll(c.ChartVersion, "+", "_")
+}
+
+// Validate checks the settings of the app beyond its required fields: the cosign verification,
+// environments, values sources, HelmRelease options, Namespace and Flux Kustomization.
+func (c *AppConfig) Validate() error {
+	for _, validate := range []func() error{
+		c.ValidateVerification,
+		c.ValidateEnvironments,
+		c.ValidateValuesSources,
+		c.ValidateRelease,
+		c.ValidateNamespaceManifest,
+		c.ValidateFluxKustomization,
+	} {
+		if err := validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateVerification checks the cosign verification settings: they only apply to OCI charts, and
+// OIDC identities only to keyless verification by an OCIRepository, the HelmRelease chart
+// template not supporting them.
+func (c *AppConfig) ValidateVerification() error {
+	if !c.CosignVerify {
+		for field, value := range map[string]string{
+			"spec.cosignSecretRef":   c.CosignSecretRef,
+			"spec.cosignOIDCIssuer":  c.CosignOIDCIssuer,
+			"spec.cosignOIDCSubject": c.CosignOIDCSubject,
+		} {
+			if value != "" {
+				return &SpecError{Field: field, Message: "requires cosignVerify"}
+			}
+		}
+		return nil
+	}
+	if !c.IsOCI() {
+		return &SpecError{Field: "spec.cosignVerify", Message: "requires an oci:// helmRepoURL"}
+	}
+	if c.CosignOIDCIssuer == "" && c.CosignOIDCSubject == "" {
+		return nil
+	}
+	switch {
+	case c.CosignOIDCIssuer == "":
+		return &SpecError{Field: "spec.cosignOIDCIssuer", Message: "value is required with cosignOIDCSubject"}
+	case c.CosignOIDCSubject == "":
+		return &SpecError{Field: "spec.cosignOIDCSubject", Message: "value is required with cosignOIDCIssuer"}
+	case c.CosignSecretRef != "":
+		return &SpecError{Field: "spec.cosignOIDCIssuer", Message: "only applies to keyless verification, without cosignSecretRef"}
+	case !c.UsesOCIRepository():
+		return &SpecError{Field: "spec.cosignOIDCIssuer", Message: fmt.Sprintf("requires sourceKind %q", SourceKindOCIRepository)}
+	}
+	for field, value := range map[string]string{"spec.cosignOIDCIssuer": c.CosignOIDCIssuer, "spec.cosignOIDCSubject": c.CosignOIDCSubject} {
+		if _, err := regexp.Compile(value); err != nil {
+			return &SpecError{Field: field, Message: err.Error()}
+		}
+	}
+	return nil
 }
@@ -119,3 +119,33 @@ func TestAppConfig_ZeroValues(t *testing.T) {
 		t.Error("Expected Values to be nil for zero config")
 	}
 }
+
+func TestAppConfig_Validate(t *testing.T) {
+	config := AppConfig{AppName: "test-app", Namespace: "default", HelmRepoURL: "https://example.com/repo"}
+	if err := config.Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(c *AppConfig)
+		field  string
+	}{
+		{"verification", func(c *AppConfig) { c.CosignSecretRef = "cosign-pub" }, "spec.cosignSecretRef"},
+		{"values sources", func(c *AppConfig) { c.ValuesKind = "file" }, "spec.valuesKind"},
+		{"release", func(c *AppConfig) { c.Release = &ReleaseOptions{Timeout: "soon"} }, "spec.release.timeout"},
+		{"namespace", func(c *AppConfig) { c.NamespaceManifest = &NamespaceManifest{PodSecurity: "strict"} }, "spec.namespaceManifest.podSecurity"},
+		{"flux kustomization", func(c *AppConfig) { c.FluxKustomization = &FluxKustomization{} }, "spec.fluxKustomization.clustersDir"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := config
+			tt.modify(&c)
+			err := c.Validate()
+			specErr, ok := err.(*SpecError)
+			if !ok || specErr.Field != tt.field {
+				t.Errorf("expected an error on %s, got %v", tt.field, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package models
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Ways the values of the app are delivered to the HelmRelease.
+const (
+	// ValuesKindConfigMap reads the values from a ConfigMap generated from helm-values.yaml.
+	ValuesKindConfigMap = "ConfigMap"
+	// ValuesKindSecret reads the values from a Secret generated from helm-values.yaml, for
+	// sensitive values encrypted with SOPS.
+	ValuesKindSecret = "Secret"
+	// ValuesKindInline writes the values into the HelmRelease itself, as spec.values.
+	ValuesKindInline = "inline"
+)
+
+// DefaultValuesKey is the key of the generated ConfigMaps and Secrets holding the values, and the
+// one Flux reads by default.
+const DefaultValuesKey = "values.yaml"
+
+// reservedValuesFiles are the files of release/ that layered values files cannot be written to.
+var reservedValuesFiles = map[string]bool{
+	"helm-release.yaml":         true,
+	"helm-values.yaml":          true,
+	"helm-values.defaults.yaml": true,
+}
+
+// ValuesSource is a ConfigMap or Secret layered over the values of the app, in the order of
+// valuesFrom. With File, the values file is generated into release/ and turned into the ConfigMap
+// or Secret by kustomization.yaml; without, an existing ConfigMap or Secret is referenced.
+type ValuesSource struct {
+	Kind       string `json:"kind" yaml:"kind"`                                 // ConfigMap or Secret
+	Name       string `json:"name" yaml:"name"`                                 // Name of the ConfigMap or Secret, in the namespace of the app
+	ValuesKey  string `json:"valuesKey,omitempty" yaml:"valuesKey,omitempty"`   // Key holding the values, values.yaml by default
+	TargetPath string `json:"targetPath,omitempty" yaml:"targetPath,omitempty"` // Values path the content of the key is set at, instead of being merged
+	Optional   bool   `json:"optional,omitempty" yaml:"optional,omitempty"`     // Ignore the source when it does not exist
+	File       string `json:"file,omitempty" yaml:"file,omitempty"`             // Values file generated into release/ for the source
+}
+
+// Key returns the key of the source holding the values.
+func (s ValuesSource) Key() string {
+	if s.ValuesKey == "" {
+		return DefaultValuesKey
+	}
+	return s.ValuesKey
+}
+
+// ValuesFile is a values file of the app that kustomization.yaml turns into a ConfigMap or Secret.
+type ValuesFile struct {
+	Name string // Name of the ConfigMap or Secret
+	Key  string // Key holding the content of the file
+	Path string // Slash-separated path of the file, relative to the app directory
+}
+
+// UsesInlineValues reports whether the values are written into the HelmRelease instead of being
+// read from a ConfigMap or Secret.
+func (c *AppConfig) UsesInlineValues() bool {
+	return c.ValuesKind == ValuesKindInline
+}
+
+// ValuesObjectKind returns the kind of the objects holding the values of the app and of its
+// environments, ConfigMap unless they are Secrets.
+func (c *AppConfig) ValuesObjectKind() string {
+	if c.ValuesKind == ValuesKindSecret {
+		return ValuesKindSecret
+	}
+	return ValuesKindConfigMap
+}
+
+// ValuesFiles returns the values files turned into objects of the kind, the values of the app
+// first, then the layered sources that are generated.
+func (c *AppConfig) ValuesFiles(kind string) []ValuesFile {
+	var files []ValuesFile
+	if !c.UsesInlineValues() && c.ValuesObjectKind() == kind {
+		files = append(files, ValuesFile{Name: c.AppName + "-values", Key: DefaultValuesKey, Path: "release/helm-values.yaml"})
+	}
+	for _, source := range c.ValuesSources {
+		if source.File != "" && source.Kind == kind {
+			files = append(files, ValuesFile{Name: source.Name, Key: source.Key(), Path: "release/" + source.File})
+		}
+	}
+	return files
+}
+
+// ValidateValuesSources checks how the values are delivered and the sources layered over them.
+func (c *AppConfig) ValidateValuesSources() error {
+	switch c.ValuesKind {
+	case "", ValuesKindConfigMap, ValuesKindSecret, ValuesKindInline:
+	default:
+		return &SpecError{Field: "spec.valuesKind", Message: fmt.Sprintf("%q must be %q, %q or %q", c.ValuesKind, ValuesKindConfigMap, ValuesKindSecret, ValuesKindInline)}
+	}
+	generated := make(map[string]bool, len(c.ValuesSources))
+	files := make(map[string]bool, len(c.ValuesSources))
+	for i, source := range c.ValuesSources {
+		field := fmt.Sprintf("spec.valuesSources[%d]", i)
+		if source.Kind != ValuesKindConfigMap && source.Kind != ValuesKindSecret {
+			return &SpecError{Field: field + ".kind", Message: fmt.Sprintf("%q must be %q or %q", source.Kind, ValuesKindConfigMap, ValuesKindSecret)}
+		}
+		if !releaseName.MatchString(source.Name) {
+			return &SpecError{Field: field + ".name", Message: fmt.Sprintf("%q must be lowercase alphanumeric characters, '-' or '.'", source.Name)}
+		}
+		if source.Name == c.AppName+"-values" {
+			return &SpecError{Field: field + ".name", Message: fmt.Sprintf("%q holds the values of the app", source.Name)}
+		}
+		if strings.ContainsAny(source.ValuesKey, " /") {
+			return &SpecError{Field: field + ".valuesKey", Message: fmt.Sprintf("invalid key %q", source.ValuesKey)}
+		}
+		if source.File == "" {
+			continue
+		}
+		if generated[source.Kind+"/"+source.Name] {
+			return &SpecError{Field: field + ".name", Message: fmt.Sprintf("%s %q is already generated", source.Kind, source.Name)}
+		}
+		generated[source.Kind+"/"+source.Name] = true
+		if source.TargetPath != "" {
+			return &SpecError{Field: field + ".targetPath", Message: "only applies to existing ConfigMaps and Secrets, without file"}
+		}
+		if source.File != path.Base(source.File) || path.Ext(source.File) != ".yaml" || reservedValuesFiles[source.File] {
+			return &SpecError{Field: field + ".file", Message: fmt.Sprintf("%q must be a .yaml file name other than the ones of the app", source.File)}
+		}
+		if files[source.File] {
+			return &SpecError{Field: field + ".file", Message: fmt.Sprintf("duplicate file %q", source.File)}
+		}
+		files[source.File] = true
+	}
+	return nil
+}
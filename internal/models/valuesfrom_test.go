@@ -0,0 +1,67 @@
+package models
+
+import "testing"
+
+func TestValidateValuesSources(t *testing.T) {
+	tests := []struct {
+		name    string
+		kind    string
+		sources []ValuesSource
+		field   string
+	}{
+		{"default", "", nil, ""},
+		{"valid", ValuesKindInline, []ValuesSource{{Kind: ValuesKindConfigMap, Name: "common", File: "common.yaml"}, {Kind: ValuesKindSecret, Name: "db", ValuesKey: "password", TargetPath: "auth.password", Optional: true}}, ""},
+		{"invalid kind", "Vault", nil, "spec.valuesKind"},
+		{"invalid source kind", "", []ValuesSource{{Kind: "Vault", Name: "db"}}, "spec.valuesSources[0].kind"},
+		{"invalid name", "", []ValuesSource{{Kind: ValuesKindSecret, Name: "DB"}}, "spec.valuesSources[0].name"},
+		{"name of the app values", "", []ValuesSource{{Kind: ValuesKindSecret, Name: "podinfo-values"}}, "spec.valuesSources[0].name"},
+		{"invalid key", "", []ValuesSource{{Kind: ValuesKindSecret, Name: "db", ValuesKey: "a/b"}}, "spec.valuesSources[0].valuesKey"},
+		{"generated twice", "", []ValuesSource{{Kind: ValuesKindSecret, Name: "db", File: "a.yaml"}, {Kind: ValuesKindSecret, Name: "db", File: "b.yaml"}}, "spec.valuesSources[1].name"},
+		{"target path of a file", "", []ValuesSource{{Kind: ValuesKindSecret, Name: "db", File: "db.yaml", TargetPath: "auth"}}, "spec.valuesSources[0].targetPath"},
+		{"file in a directory", "", []ValuesSource{{Kind: ValuesKindSecret, Name: "db", File: "secrets/db.yaml"}}, "spec.valuesSources[0].file"},
+		{"file of the app", "", []ValuesSource{{Kind: ValuesKindConfigMap, Name: "common", File: "helm-values.yaml"}}, "spec.valuesSources[0].file"},
+		{"duplicate file", "", []ValuesSource{{Kind: ValuesKindConfigMap, Name: "a", File: "a.yaml"}, {Kind: ValuesKindSecret, Name: "b", File: "a.yaml"}}, "spec.valuesSources[1].file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &AppConfig{AppName: "podinfo", ValuesKind: tt.kind, ValuesSources: tt.sources}
+			err := config.ValidateValuesSources()
+			if tt.field == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			specErr, ok := err.(*SpecError)
+			if !ok || specErr.Field != tt.field {
+				t.Errorf("expected an error on %s, got %v", tt.field, err)
+			}
+		})
+	}
+}
+
+func TestAppConfig_ValuesFiles(t *testing.T) {
+	config := &AppConfig{
+		AppName: "podinfo",
+		ValuesSources: []ValuesSource{
+			{Kind: ValuesKindSecret, Name: "podinfo-secrets", File: "secrets.yaml"},
+			{Kind: ValuesKindConfigMap, Name: "shared", ValuesKey: "shared.yaml"},
+		},
+	}
+	if files := config.ValuesFiles(ValuesKindConfigMap); len(files) != 1 || files[0] != (ValuesFile{Name: "podinfo-values", Key: DefaultValuesKey, Path: "release/helm-values.yaml"}) {
+		t.Errorf("unexpected ConfigMap files %v", files)
+	}
+	if files := config.ValuesFiles(ValuesKindSecret); len(files) != 1 || files[0].Path != "release/secrets.yaml" {
+		t.Errorf("unexpected Secret files %v", files)
+	}
+
+	config.ValuesKind = ValuesKindSecret
+	if files := config.ValuesFiles(ValuesKindSecret); len(files) != 2 || files[0].Name != "podinfo-values" {
+		t.Errorf("expected the values of the app in a Secret, got %v", files)
+	}
+	config.ValuesKind = ValuesKindInline
+	if files := config.ValuesFiles(ValuesKindConfigMap); len(files) != 0 {
+		t.Errorf("expected no values file with inline values, got %v", files)
+	}
+}
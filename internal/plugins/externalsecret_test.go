@@ -3,6 +3,7 @@ package plugins
 import (
 	"testing"
 
+	"github.com/EffectiveSloth/flux-app-generator/internal/filesystem"
 	"github.com/EffectiveSloth/flux-app-generator/internal/kubernetes"
 	"github.com/stretchr/testify/assert"
 )
@@ -82,7 +83,7 @@ func TestExternalSecretPlugin_Generate(t *testing.T) {
 		"Namespace":          "default",
 	}
 
-	err := plugin.GenerateFile(values, "/tmp", "default")
+	err := plugin.GenerateFile(filesystem.NewMemFS(), values, "/tmp", "default")
 
 	assert.NoError(t, err)
 }
@@ -101,7 +102,7 @@ func TestExternalSecretPlugin_GenerateWithSecretStore(t *testing.T) {
 		"Namespace":          "default",
 	}
 
-	err := plugin.GenerateFile(values, "/tmp", "default")
+	err := plugin.GenerateFile(filesystem.NewMemFS(), values, "/tmp", "default")
 
 	assert.NoError(t, err)
 }
@@ -116,7 +117,7 @@ func TestExternalSecretPlugin_GenerateMissingRequiredFields(t *testing.T) {
 		// Missing other required fields
 	}
 
-	err := plugin.GenerateFile(values, "/tmp", "default")
+	err := plugin.GenerateFile(filesystem.NewMemFS(), values, "/tmp", "default")
 
 	// Should still generate but with empty values for missing fields
 	assert.NoError(t, err)
@@ -170,7 +171,7 @@ func TestExternalSecretPlugin_TemplateGeneration(t *testing.T) {
 		"Namespace":          "test-namespace",
 	}
 
-	err := plugin.GenerateFile(values, "/tmp", "test-namespace")
+	err := plugin.GenerateFile(filesystem.NewMemFS(), values, "/tmp", "test-namespace")
 
 	assert.NoError(t, err)
 }
@@ -189,7 +190,7 @@ func TestExternalSecretPlugin_FilePathGeneration(t *testing.T) {
 		"Namespace":          "default",
 	}
 
-	err := plugin.GenerateFile(values, "/tmp", "default")
+	err := plugin.GenerateFile(filesystem.NewMemFS(), values, "/tmp", "default")
 
 	assert.NoError(t, err)
 }
@@ -231,7 +232,7 @@ func TestExternalSecretPlugin_WithDifferentRefreshIntervals(t *testing.T) {
 				"Namespace":          "default",
 			}
 
-			err := plugin.GenerateFile(values, "/tmp", "default")
+			err := plugin.GenerateFile(filesystem.NewMemFS(), values, "/tmp", "default")
 
 			assert.NoError(t, err)
 		})
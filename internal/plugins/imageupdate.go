@@ -3,11 +3,12 @@ package plugins
 import (
 	"encoding/json"
 	"fmt"
-	"os"
-	"path/filepath"
+	"strings"
 	"text/template"
 
 	"github.com/charmbracelet/huh"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/filesystem"
 )
 
 const (
@@ -516,7 +517,16 @@ func (p *ImageUpdatePlugin) configureImageUpdateAutomation() (ImageUpdateAutomat
 }
 
 // GenerateFile creates the three image update automation files directly in the main directory.
-func (p *ImageUpdatePlugin) GenerateFile(values map[string]interface{}, appDir, namespace string) error {
+func (p *ImageUpdatePlugin) GenerateFile(fsys filesystem.FS, values map[string]interface{}, appDir, namespace string) error {
+	files, err := p.RenderFiles(values, namespace)
+	if err != nil {
+		return err
+	}
+	return writeRenderedFiles(fsys, p.name, appDir, files)
+}
+
+// RenderFiles renders the three image update automation files without writing them.
+func (p *ImageUpdatePlugin) RenderFiles(values map[string]interface{}, namespace string) (map[string]string, error) {
 	// Parse image repositories and policies from JSON
 	var imageRepositories []ImageRepository
 	var imagePolicies []ImagePolicy
@@ -524,7 +534,7 @@ func (p *ImageUpdatePlugin) GenerateFile(values map[string]interface{}, appDir,
 	if repoData, exists := values["image_repositories"]; exists {
 		if repoStr, ok := repoData.(string); ok {
 			if err := json.Unmarshal([]byte(repoStr), &imageRepositories); err != nil {
-				return fmt.Errorf("failed to parse image repositories: %v", err)
+				return nil, fmt.Errorf("failed to parse image repositories: %v", err)
 			}
 		}
 	}
@@ -532,7 +542,7 @@ func (p *ImageUpdatePlugin) GenerateFile(values map[string]interface{}, appDir,
 	if policyData, exists := values["image_policies"]; exists {
 		if policyStr, ok := policyData.(string); ok {
 			if err := json.Unmarshal([]byte(policyStr), &imagePolicies); err != nil {
-				return fmt.Errorf("failed to parse image policies: %v", err)
+				return nil, fmt.Errorf("failed to parse image policies: %v", err)
 			}
 		}
 	}
@@ -604,41 +614,32 @@ spec:
     strategy: {{.update_strategy}}`,
 	}
 
+	rendered := make(map[string]string, len(files))
 	for filename, templateStr := range files {
-		outputPath := filepath.Join(appDir, filename)
-		if err := p.generateSingleFile(templateStr, outputPath, templateData); err != nil {
-			return fmt.Errorf("failed to generate %s: %v", filename, err)
+		content, err := p.renderSingleFile(templateStr, templateData)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate %s: %v", filename, err)
 		}
+		rendered[filename] = content
 	}
 
-	return nil
+	return rendered, nil
 }
 
-// generateSingleFile is a helper method to generate a single file from a template.
-func (p *ImageUpdatePlugin) generateSingleFile(templateStr, outputPath string, data interface{}) error {
+// renderSingleFile is a helper method to render a single file from a template.
+func (p *ImageUpdatePlugin) renderSingleFile(templateStr string, data interface{}) (string, error) {
 	tmpl, err := template.New("template").Parse(templateStr)
 	if err != nil {
-		return fmt.Errorf("failed to parse template: %w", err)
+		return "", fmt.Errorf("failed to parse template: %w", err)
 	}
 
-	file, err := os.Create(outputPath)
-	if err != nil {
-		return fmt.Errorf("failed to create %s: %w", outputPath, err)
-	}
-	defer func() {
-		if closeErr := file.Close(); closeErr != nil {
-			fmt.Printf("Warning: failed to close file %s: %v\n", outputPath, closeErr)
-		}
-	}()
-
-	if err := tmpl.Execute(file, data); err != nil {
-		return fmt.Errorf("failed to execute template: %w", err)
+	var content strings.Builder
+	if err := tmpl.Execute(&content, data); err != nil {
+		return "", fmt.Errorf("failed to execute template: %w", err)
 	}
 
 	// Ensure file ends with a newline
-	if _, err := file.WriteString("\n"); err != nil {
-		return fmt.Errorf("failed to write newline: %w", err)
-	}
+	content.WriteString("\n")
 
-	return nil
+	return content.String(), nil
 }
@@ -5,6 +5,8 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/filesystem"
 )
 
 func TestNewImageUpdatePlugin(t *testing.T) {
@@ -363,7 +365,7 @@ func TestImageUpdatePlugin_GenerateFile(t *testing.T) {
 			tempDir := t.TempDir()
 			namespace := DefaultFluxNamespace
 
-			err := plugin.GenerateFile(tt.values, tempDir, namespace)
+			err := plugin.GenerateFile(filesystem.OS{}, tt.values, tempDir, namespace)
 
 			if tt.expectError {
 				if err == nil {
@@ -401,6 +403,34 @@ func TestImageUpdatePlugin_GenerateFile(t *testing.T) {
 	}
 }
 
+// Test that rendering keeps the files in memory
+func TestImageUpdatePlugin_RenderFiles(t *testing.T) {
+	plugin := NewImageUpdatePlugin()
+	values := map[string]interface{}{
+		"automation_name":          "home-automation",
+		"image_repositories":       `[{"name":"myapp","image":"myregistry/myapp","interval":"6h"}]`,
+		"image_policies":           `[{"name":"myapp","repository":"myapp","policyType":"semver","range":"*"}]`,
+		"git_repository_name":      DefaultFluxNamespace,
+		"git_repository_namespace": DefaultFluxNamespace,
+		"update_path":              "./apps/test",
+		"git_branch":               "main",
+		"author_name":              "Test Author",
+		"author_email":             "test@example.com",
+		"automation_interval":      "10m",
+	}
+
+	files, err := plugin.RenderFiles(values, "test-namespace")
+	if err != nil {
+		t.Fatalf("RenderFiles failed: %v", err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("expected 3 rendered files, got %d", len(files))
+	}
+	if !strings.Contains(files["image-repository.yaml"], "name: myapp") {
+		t.Errorf("unexpected image repository content: %s", files["image-repository.yaml"])
+	}
+}
+
 // Test constants
 func TestImageUpdatePlugin_Constants(t *testing.T) {
 	if PolicyTypeSemver != "semver" {
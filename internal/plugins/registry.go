@@ -2,6 +2,7 @@ package plugins
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/EffectiveSloth/flux-app-generator/internal/kubernetes"
 )
@@ -63,12 +64,13 @@ func (r *Registry) Get(name string) (Plugin, bool) {
 	return plugin, exists
 }
 
-// List returns all registered plugins.
+// List returns all registered plugins sorted by name.
 func (r *Registry) List() []Plugin {
 	plugins := make([]Plugin, 0, len(r.plugins))
 	for _, plugin := range r.plugins {
 		plugins = append(plugins, plugin)
 	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name() < plugins[j].Name() })
 	return plugins
 }
 
@@ -3,6 +3,7 @@ package plugins
 import (
 	"testing"
 
+	"github.com/EffectiveSloth/flux-app-generator/internal/filesystem"
 	"github.com/EffectiveSloth/flux-app-generator/internal/kubernetes"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -142,7 +143,7 @@ func TestRegistry_PluginGeneration(t *testing.T) {
 		"Namespace":          "default",
 	}
 
-	err := plugin.GenerateFile(values, "/tmp", "default")
+	err := plugin.GenerateFile(filesystem.NewMemFS(), values, "/tmp", "default")
 	assert.NoError(t, err)
 }
 
@@ -160,7 +161,7 @@ func TestRegistry_PluginGenerationWithMissingValues(t *testing.T) {
 		// Missing other required values
 	}
 
-	err := plugin.GenerateFile(values, "/tmp", "default")
+	err := plugin.GenerateFile(filesystem.NewMemFS(), values, "/tmp", "default")
 	assert.NoError(t, err) // Should still generate without error
 }
 
@@ -246,7 +247,7 @@ func TestRegistry_PluginWithNilClient(t *testing.T) {
 		"Namespace":          "default",
 	}
 
-	err := plugin.GenerateFile(values, "/tmp", "default")
+	err := plugin.GenerateFile(filesystem.NewMemFS(), values, "/tmp", "default")
 	assert.NoError(t, err)
 }
 
@@ -311,8 +312,8 @@ func TestRegistry_PluginTemplateConsistency(t *testing.T) {
 		"Namespace":          "default",
 	}
 
-	err1 := plugin.GenerateFile(values, "/tmp", "default")
-	err2 := plugin.GenerateFile(values, "/tmp", "default")
+	err1 := plugin.GenerateFile(filesystem.NewMemFS(), values, "/tmp", "default")
+	err2 := plugin.GenerateFile(filesystem.NewMemFS(), values, "/tmp", "default")
 
 	assert.NoError(t, err1)
 	assert.NoError(t, err2)
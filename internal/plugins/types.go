@@ -2,10 +2,12 @@ package plugins
 
 import (
 	"fmt"
-	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"text/template"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/filesystem"
 )
 
 // VariableType represents the different types of input variables a plugin can have.
@@ -64,8 +66,11 @@ type Plugin interface {
 	// Validate checks if the provided values are valid for this plugin.
 	Validate(values map[string]interface{}) error
 
-	// GenerateFile creates the output file using the template and values.
-	GenerateFile(values map[string]interface{}, appDir, namespace string) error
+	// RenderFiles renders the output file(s) in memory, keyed by path relative to the app directory.
+	RenderFiles(values map[string]interface{}, namespace string) (map[string]string, error)
+
+	// GenerateFile creates the output file(s) in fsys using the template and values.
+	GenerateFile(fsys filesystem.FS, values map[string]interface{}, appDir, namespace string) error
 }
 
 // CustomConfigPlugin defines an interface for plugins that need custom configuration collection.
@@ -171,8 +176,8 @@ func (p *BasePlugin) validateVariableType(variable *Variable, value interface{})
 	return nil
 }
 
-// GenerateFile creates the output file using the template and values.
-func (p *BasePlugin) GenerateFile(values map[string]interface{}, appDir, namespace string) error {
+// RenderFiles renders the output file using the template and values without writing it.
+func (p *BasePlugin) RenderFiles(values map[string]interface{}, namespace string) (map[string]string, error) {
 	// Create template data combining values with namespace
 	templateData := make(map[string]interface{})
 	for k, v := range values {
@@ -183,7 +188,7 @@ func (p *BasePlugin) GenerateFile(values map[string]interface{}, appDir, namespa
 	// Parse the file path template
 	pathTmpl, err := template.New("filepath").Parse(p.filePath)
 	if err != nil {
-		return &TemplateError{
+		return nil, &TemplateError{
 			Plugin:  p.name,
 			Type:    "filepath",
 			Message: err.Error(),
@@ -192,52 +197,26 @@ func (p *BasePlugin) GenerateFile(values map[string]interface{}, appDir, namespa
 
 	var pathBuf strings.Builder
 	if err := pathTmpl.Execute(&pathBuf, templateData); err != nil {
-		return &TemplateError{
+		return nil, &TemplateError{
 			Plugin:  p.name,
 			Type:    "filepath",
 			Message: err.Error(),
 		}
 	}
 
-	outputPath := filepath.Join(appDir, pathBuf.String())
-
-	// Ensure output directory exists
-	if err := os.MkdirAll(filepath.Dir(outputPath), 0o755); err != nil {
-		return &FileError{
-			Plugin:    p.name,
-			Operation: "create_directory",
-			Path:      filepath.Dir(outputPath),
-			Message:   err.Error(),
-		}
-	}
-
 	// Parse and execute the YAML template
 	tmpl, err := template.New("plugin").Parse(p.template)
 	if err != nil {
-		return &TemplateError{
+		return nil, &TemplateError{
 			Plugin:  p.name,
 			Type:    "yaml",
 			Message: err.Error(),
 		}
 	}
 
-	file, err := os.Create(outputPath)
-	if err != nil {
-		return &FileError{
-			Plugin:    p.name,
-			Operation: "create_file",
-			Path:      outputPath,
-			Message:   err.Error(),
-		}
-	}
-	defer func() {
-		if closeErr := file.Close(); closeErr != nil {
-			fmt.Printf("Warning: failed to close file %s: %v\n", outputPath, closeErr)
-		}
-	}()
-
-	if err := tmpl.Execute(file, templateData); err != nil {
-		return &TemplateError{
+	var content strings.Builder
+	if err := tmpl.Execute(&content, templateData); err != nil {
+		return nil, &TemplateError{
 			Plugin:  p.name,
 			Type:    "yaml",
 			Message: err.Error(),
@@ -245,12 +224,50 @@ func (p *BasePlugin) GenerateFile(values map[string]interface{}, appDir, namespa
 	}
 
 	// Ensure file ends with a newline
-	if _, err := file.WriteString("\n"); err != nil {
-		return &FileError{
-			Plugin:    p.name,
-			Operation: "write_newline",
-			Path:      outputPath,
-			Message:   err.Error(),
+	content.WriteString("\n")
+
+	return map[string]string{pathBuf.String(): content.String()}, nil
+}
+
+// GenerateFile creates the output file in fsys using the template and values.
+func (p *BasePlugin) GenerateFile(fsys filesystem.FS, values map[string]interface{}, appDir, namespace string) error {
+	files, err := p.RenderFiles(values, namespace)
+	if err != nil {
+		return err
+	}
+	return writeRenderedFiles(fsys, p.name, appDir, files)
+}
+
+// writeRenderedFiles writes rendered files below the app directory, creating parent directories as needed.
+func writeRenderedFiles(fsys filesystem.FS, pluginName, appDir string, files map[string]string) error {
+	fsys = filesystem.Default(fsys)
+
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		outputPath := filepath.Join(appDir, path)
+
+		// Ensure output directory exists
+		if err := fsys.MkdirAll(filepath.Dir(outputPath), 0o755); err != nil {
+			return &FileError{
+				Plugin:    pluginName,
+				Operation: "create_directory",
+				Path:      filepath.Dir(outputPath),
+				Message:   err.Error(),
+			}
+		}
+
+		if err := fsys.WriteFile(outputPath, []byte(files[path]), 0o600); err != nil {
+			return &FileError{
+				Plugin:    pluginName,
+				Operation: "write_file",
+				Path:      outputPath,
+				Message:   err.Error(),
+			}
 		}
 	}
 
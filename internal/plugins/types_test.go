@@ -5,6 +5,8 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/EffectiveSloth/flux-app-generator/internal/filesystem"
 )
 
 func TestVariableType_Constants(t *testing.T) {
@@ -190,7 +192,7 @@ func TestBasePlugin_GenerateFile(t *testing.T) {
 		"test_value": "example",
 	}
 
-	err := plugin.GenerateFile(values, appDir, "test-namespace")
+	err := plugin.GenerateFile(filesystem.OS{}, values, appDir, "test-namespace")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -223,7 +225,7 @@ func TestBasePlugin_GenerateFile_InvalidTemplate(t *testing.T) {
 	tempDir := t.TempDir()
 	values := map[string]interface{}{}
 
-	err := plugin.GenerateFile(values, tempDir, "test-namespace")
+	err := plugin.GenerateFile(filesystem.OS{}, values, tempDir, "test-namespace")
 	if err == nil {
 		t.Errorf("expected error for invalid template")
 	}
@@ -243,7 +245,7 @@ func TestBasePlugin_GenerateFile_InvalidFilePath(t *testing.T) {
 	tempDir := t.TempDir()
 	values := map[string]interface{}{}
 
-	err := plugin.GenerateFile(values, tempDir, "test-namespace")
+	err := plugin.GenerateFile(filesystem.OS{}, values, tempDir, "test-namespace")
 	if err == nil {
 		t.Errorf("expected error for invalid file path template")
 	}
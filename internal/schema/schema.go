@@ -0,0 +1,195 @@
+// Package schema validates YAML documents, such as Helm values files, against JSON schemas.
+package schema
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/santhosh-tekuri/jsonschema/v6/kind"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"gopkg.in/yaml.v3"
+)
+
+// schemaURL is the location the schema is compiled at, like Helm does for values.schema.json.
+const schemaURL = "file:///values.schema.json"
+
+// printer formats the messages of violations, like the errors of the jsonschema package.
+var printer = message.NewPrinter(language.English)
+
+// Schema is a compiled JSON schema.
+//
+// Schemas are compiled and validated with github.com/santhosh-tekuri/jsonschema, as Helm does,
+// with drafts 4 to 2020-12. Formats are asserted, and $ref resolves references to other files
+// and to http and https URLs.
+type Schema struct {
+	schema *jsonschema.Schema
+}
+
+// Violation is a value breaking a rule of the schema.
+type Violation struct {
+	// Pointer is the JSON pointer of the value, such as /image/tag, empty for the document itself.
+	Pointer string
+	// Line is the line of the value in the document, 0 when it is not in the document.
+	Line    int
+	Message string
+}
+
+func (v Violation) String() string {
+	pointer := v.Pointer
+	if pointer == "" {
+		pointer = "/"
+	}
+	if v.Line == 0 {
+		return fmt.Sprintf("%s: %s", pointer, v.Message)
+	}
+	return fmt.Sprintf("line %d: %s: %s", v.Line, pointer, v.Message)
+}
+
+// httpLoader loads the schemas referenced by http and https URLs.
+type httpLoader struct {
+	client *http.Client
+}
+
+func (l httpLoader) Load(url string) (any, error) {
+	resp, err := l.client.Get(url) // #nosec G107 -- URL referenced by the schema
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return jsonschema.UnmarshalJSON(resp.Body)
+}
+
+// Compile parses and compiles a JSON schema. Invalid schemas, such as ones with patterns Go
+// cannot compile or references that cannot be loaded, are an error.
+func Compile(data []byte) (*Schema, error) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON schema: %w", err)
+	}
+	remote := httpLoader{client: &http.Client{Timeout: 30 * time.Second}}
+	compiler := jsonschema.NewCompiler()
+	compiler.UseLoader(jsonschema.SchemeURLLoader{
+		"file":  jsonschema.FileLoader{},
+		"http":  remote,
+		"https": remote,
+	})
+	compiler.AssertFormat()
+	if err := compiler.AddResource(schemaURL, doc); err != nil {
+		return nil, fmt.Errorf("invalid JSON schema: %w", err)
+	}
+	compiled, err := compiler.Compile(schemaURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON schema: %w", err)
+	}
+	return &Schema{schema: compiled}, nil
+}
+
+// Validate validates a YAML document or node against the schema. Violations are sorted by line.
+func (s *Schema) Validate(node *yaml.Node) []Violation {
+	if node != nil && node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			node = nil
+		} else {
+			node = node.Content[0]
+		}
+	}
+	if node == nil || node.Kind == 0 {
+		// An empty document is an empty mapping, like empty values files for Helm
+		node = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	}
+	lines := make(map[string]int)
+	err := s.schema.Validate(decode(node, "", node.Line, lines))
+	if err == nil {
+		return nil
+	}
+
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		return []Violation{{Line: node.Line, Message: err.Error()}}
+	}
+	violations := collect(validationErr, lines, nil)
+	sort.SliceStable(violations, func(i, j int) bool {
+		if violations[i].Line != violations[j].Line {
+			return violations[i].Line < violations[j].Line
+		}
+		return violations[i].Pointer < violations[j].Pointer
+	})
+	return violations
+}
+
+// collect adds the violations of a validation error: the errors that are not merely the group
+// of the errors of a subschema, such as the ones of the schema itself, $ref or allOf.
+func collect(err *jsonschema.ValidationError, lines map[string]int, violations []Violation) []Violation {
+	switch err.ErrorKind.(type) {
+	case *kind.Schema, *kind.Reference, *kind.Group, *kind.AllOf:
+		if len(err.Causes) > 0 {
+			for _, cause := range err.Causes {
+				violations = collect(cause, lines, violations)
+			}
+			return violations
+		}
+	}
+	var pointer strings.Builder
+	for _, token := range err.InstanceLocation {
+		pointer.WriteString("/" + escape(token))
+	}
+	return append(violations, Violation{
+		Pointer: pointer.String(),
+		Line:    lines[pointer.String()],
+		Message: err.ErrorKind.LocalizedString(printer),
+	})
+}
+
+// decode returns the value of a node, decoded like encoding/json would decode its JSON
+// equivalent, and records the line of each value below it by JSON pointer. The line of mapping
+// values is the line of their key, rather than the one of their first child for block mappings
+// and sequences.
+func decode(node *yaml.Node, pointer string, line int, lines map[string]int) any {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	lines[pointer] = line
+	switch node.Kind {
+	case yaml.MappingNode:
+		object := make(map[string]any, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			object[key.Value] = decode(node.Content[i+1], pointer+"/"+escape(key.Value), key.Line, lines)
+		}
+		return object
+	case yaml.SequenceNode:
+		array := make([]any, len(node.Content))
+		for i, item := range node.Content {
+			array[i] = decode(item, pointer+"/"+strconv.Itoa(i), item.Line, lines)
+		}
+		return array
+	}
+
+	var value any
+	if err := node.Decode(&value); err != nil {
+		return node.Value
+	}
+	switch value.(type) {
+	case nil, bool, int, int64, uint64, float64, string:
+		return value
+	default:
+		// Timestamps and binary values are strings in JSON
+		return node.Value
+	}
+}
+
+// escape escapes a key as a JSON pointer token.
+func escape(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
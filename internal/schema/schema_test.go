@@ -0,0 +1,187 @@
+package schema
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const testSchema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "required": ["image"],
+  "properties": {
+    "replicaCount": {"type": "integer", "minimum": 1},
+    "image": {"$ref": "#/definitions/image"},
+    "service": {
+      "type": "object",
+      "properties": {
+        "type": {"enum": ["ClusterIP", "NodePort", "LoadBalancer"]},
+        "port": {"type": "integer", "maximum": 65535}
+      },
+      "additionalProperties": false
+    },
+    "tolerations": {"type": "array", "items": {"type": "object", "required": ["key"]}},
+    "ratio": {"type": "number", "multipleOf": 0.1},
+    "labels": {"type": "object", "patternProperties": {"^[a-z./-]+$": {"type": "string"}}, "additionalProperties": false}
+  },
+  "definitions": {
+    "image": {
+      "type": "object",
+      "required": ["repository"],
+      "properties": {
+        "repository": {"type": "string", "minLength": 1},
+        "tag": {"type": "string", "pattern": "^v?[0-9]"},
+        "pullPolicy": {"type": "string"}
+      }
+    }
+  }
+}`
+
+func validate(t *testing.T, schemaJSON, document string) []Violation {
+	t.Helper()
+	s, err := Compile([]byte(schemaJSON))
+	require.NoError(t, err)
+	var node yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte(document), &node))
+	return s.Validate(&node)
+}
+
+func TestValidate(t *testing.T) {
+	valid := `replicaCount: 2
+image:
+  repository: ghcr.io/stefanprodan/podinfo
+  tag: "6.9.0"
+service:
+  type: ClusterIP
+  port: 9898
+tolerations:
+  - key: dedicated
+ratio: 0.3
+labels:
+  app.kubernetes.io/part-of: podinfo
+`
+	assert.Empty(t, validate(t, testSchema, valid))
+
+	invalid := `replicaCount: "2"
+image:
+  tag: 6.9
+service:
+  type: Ingress
+  port: 70000
+  extra: true
+tolerations:
+  - effect: NoSchedule
+ratio: 0.25
+labels:
+  Team: apps
+`
+	var got []string
+	for _, violation := range validate(t, testSchema, invalid) {
+		got = append(got, violation.String())
+	}
+	assert.Equal(t, []string{
+		"line 1: /replicaCount: got string, want integer",
+		"line 2: /image: missing property 'repository'",
+		"line 3: /image/tag: got number, want string",
+		"line 4: /service: additional properties 'extra' not allowed",
+		"line 5: /service/type: value must be one of 'ClusterIP', 'NodePort', 'LoadBalancer'",
+		"line 6: /service/port: maximum: got 70,000, want 65,535",
+		"line 9: /tolerations/0: missing property 'key'",
+		"line 10: /ratio: multipleOf: got 0.25, want 0.1",
+		"line 11: /labels: additional properties 'Team' not allowed",
+	}, got)
+}
+
+func TestValidate_Combinators(t *testing.T) {
+	schemaJSON := `{
+  "properties": {
+    "persistence": {
+      "if": {"properties": {"enabled": {"const": true}}},
+      "then": {"required": ["size"]}
+    },
+    "port": {"oneOf": [{"type": "integer"}, {"type": "string", "pattern": "^[0-9]+$"}]},
+    "mode": {"anyOf": [{"const": "standalone"}, {"const": "replication"}]},
+    "name": {"not": {"const": "default"}},
+    "a~b/c": {"type": "boolean"}
+  }
+}`
+	assert.Empty(t, validate(t, schemaJSON, "persistence:\n  enabled: false\nport: \"8080\"\nmode: standalone\n"))
+
+	violations := validate(t, schemaJSON, "persistence:\n  enabled: true\nport: [80]\nmode: cluster\nname: default\na~b/c: 1\n")
+	require.Len(t, violations, 5)
+	assert.Equal(t, Violation{Pointer: "/persistence", Line: 1, Message: "missing property 'size'"}, violations[0])
+	assert.Equal(t, "'oneOf' failed, none matched", violations[1].Message)
+	assert.Equal(t, "'anyOf' failed", violations[2].Message)
+	assert.Equal(t, "'not' failed", violations[3].Message)
+	// Keys are escaped in JSON pointers
+	assert.Equal(t, "/a~0b~1c", violations[4].Pointer)
+}
+
+func TestValidate_EmptyDocument(t *testing.T) {
+	violations := validate(t, `{"type": "object", "required": ["image"]}`, "")
+	require.Len(t, violations, 1)
+	assert.Equal(t, "/: missing property 'image'", violations[0].String())
+
+	assert.Len(t, validate(t, `false`, "a: 1\n"), 1)
+	assert.Empty(t, validate(t, `true`, "a: 1\n"))
+}
+
+func TestValidate_Refs(t *testing.T) {
+	// Recursive schemas terminate, and references to other documents are loaded
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/port.json" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"type": "integer", "minimum": 1}`))
+	}))
+	t.Cleanup(server.Close)
+	schemaJSON := `{
+  "$defs": {"node": {"type": "object", "properties": {"children": {"type": "array", "items": {"$ref": "#/$defs/node"}}}}},
+  "properties": {
+    "tree": {"$ref": "#/$defs/node"},
+    "port": {"$ref": "` + server.URL + `/port.json"}
+  }
+}`
+	assert.Empty(t, validate(t, schemaJSON, "tree:\n  children:\n    - children: []\nport: 80\n"))
+	violations := validate(t, schemaJSON, "tree:\n  children:\n    - children: 1\nport: web\n")
+	require.Len(t, violations, 2)
+	assert.Equal(t, "/tree/children/0/children", violations[0].Pointer)
+	assert.Equal(t, Violation{Pointer: "/port", Line: 4, Message: "got string, want integer"}, violations[1])
+
+	_, err := Compile([]byte(`{"properties": {"pod": {"$ref": "` + server.URL + `/pod.json"}}}`))
+	assert.ErrorContains(t, err, "404")
+}
+
+func TestValidate_FormatAndUnevaluatedProperties(t *testing.T) {
+	schemaJSON := `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "properties": {"url": {"type": "string", "format": "uri"}},
+  "allOf": [{"properties": {"port": {"type": "integer"}}}],
+  "unevaluatedProperties": false
+}`
+	assert.Empty(t, validate(t, schemaJSON, "url: https://example.com\nport: 80\n"))
+
+	violations := validate(t, schemaJSON, "url: not a uri\nport: 80\nextra: true\n")
+	require.Len(t, violations, 2)
+	assert.Equal(t, "/url", violations[0].Pointer)
+	assert.Contains(t, violations[0].Message, "is not valid uri")
+	assert.Equal(t, Violation{Pointer: "/extra", Line: 3, Message: "false schema"}, violations[1])
+}
+
+func TestCompile_Invalid(t *testing.T) {
+	_, err := Compile([]byte(`{"type": `))
+	assert.ErrorContains(t, err, "invalid JSON schema")
+
+	_, err = Compile([]byte(`[]`))
+	assert.ErrorContains(t, err, "invalid JSON schema")
+
+	// Patterns Go cannot compile make the schema invalid
+	_, err = Compile([]byte(`{"properties": {"a": {"pattern": "^(?!x)"}}}`))
+	assert.ErrorContains(t, err, "invalid JSON schema")
+}
@@ -0,0 +1,245 @@
+package semver
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Constraint is a set of version ranges, such as "~1.4" or ">=2 <3 || ^4.0.0".
+//
+// The syntax and semantics are the ones of the Masterminds/semver library used by Flux:
+// ranges separated by "||" are alternatives, and the comparisons of a range, separated by spaces
+// or commas, must all hold. A prerelease version only satisfies a comparison naming a prerelease,
+// so that "^1.0.0" never resolves to 1.1.0-rc.1.
+type Constraint struct {
+	original string
+	ranges   [][]comparison
+}
+
+// comparison is a single comparison of a constraint, as the interval a version must be in.
+// A nil bound is unbounded.
+type comparison struct {
+	lower, upper         *Version
+	lowerIncl, upperIncl bool
+	// negate inverts the interval, for "!=" comparisons.
+	negate bool
+	// prerelease is set when the comparison names a prerelease version.
+	prerelease bool
+}
+
+// operators are the comparison operators, longest first so that prefixes match correctly.
+var operators = []string{"~>", ">=", "<=", "!=", "=>", "=<", "~", "^", ">", "<", "="}
+
+// ParseConstraint parses a version constraint.
+func ParseConstraint(s string) (*Constraint, error) {
+	c := &Constraint{original: strings.TrimSpace(s)}
+	if c.original == "" {
+		return nil, fmt.Errorf("invalid constraint: empty")
+	}
+
+	for _, alternative := range strings.Split(c.original, "||") {
+		tokens := tokenize(alternative)
+		if len(tokens) == 0 {
+			return nil, fmt.Errorf("invalid constraint %q: empty range", s)
+		}
+
+		var comparisons []comparison
+		for i := 0; i < len(tokens); i++ {
+			// Hyphen ranges: "1.2 - 1.4.5"
+			if i+2 < len(tokens) && tokens[i+1] == "-" {
+				cmp, err := parseHyphenRange(tokens[i], tokens[i+2])
+				if err != nil {
+					return nil, fmt.Errorf("invalid constraint %q: %w", s, err)
+				}
+				comparisons = append(comparisons, cmp)
+				i += 2
+				continue
+			}
+			cmp, err := parseComparison(tokens[i])
+			if err != nil {
+				return nil, fmt.Errorf("invalid constraint %q: %w", s, err)
+			}
+			comparisons = append(comparisons, cmp)
+		}
+		c.ranges = append(c.ranges, comparisons)
+	}
+	return c, nil
+}
+
+// tokenize splits a range into its comparisons, joining operators written apart from their
+// version, as in ">= 1.2".
+func tokenize(s string) []string {
+	fields := strings.Fields(strings.ReplaceAll(s, ",", " "))
+	var tokens []string
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		if isOperator(field) && i+1 < len(fields) {
+			field += fields[i+1]
+			i++
+		}
+		tokens = append(tokens, field)
+	}
+	return tokens
+}
+
+func isOperator(s string) bool {
+	for _, op := range operators {
+		if s == op {
+			return true
+		}
+	}
+	return false
+}
+
+// parseComparison parses an operator and a possibly partial version, such as "~1.4" or "<=2.x".
+func parseComparison(s string) (comparison, error) {
+	op := ""
+	for _, candidate := range operators {
+		if strings.HasPrefix(s, candidate) {
+			op = candidate
+			break
+		}
+	}
+	v, given, err := parse(strings.TrimPrefix(s, op), true)
+	if err != nil {
+		return comparison{}, err
+	}
+	cmp := comparison{prerelease: v.IsPrerelease()}
+	next := increment(v, given)
+
+	switch op {
+	case "", "=":
+		cmp.setLower(v, true)
+		if given == 3 {
+			cmp.setUpper(v, true)
+		} else if given > 0 {
+			cmp.setUpper(next, false)
+		}
+	case "!=":
+		cmp.setLower(v, true)
+		if given == 3 {
+			cmp.setUpper(v, true)
+		} else if given > 0 {
+			cmp.setUpper(next, false)
+		}
+		cmp.negate = true
+	case ">":
+		if given == 3 {
+			cmp.setLower(v, false)
+		} else if given > 0 {
+			cmp.setLower(next, true)
+		} else {
+			// Nothing is greater than any version
+			cmp.setUpper(Version{}, false)
+		}
+	case ">=", "=>":
+		cmp.setLower(v, true)
+	case "<":
+		cmp.setUpper(v, false)
+	case "<=", "=<":
+		if given == 3 {
+			cmp.setUpper(v, true)
+		} else if given > 0 {
+			cmp.setUpper(next, false)
+		}
+	case "~", "~>":
+		cmp.setLower(v, true)
+		switch {
+		case given == 1:
+			cmp.setUpper(Version{Major: v.Major + 1}, false)
+		case given > 1:
+			cmp.setUpper(Version{Major: v.Major, Minor: v.Minor + 1}, false)
+		}
+	case "^":
+		cmp.setLower(v, true)
+		switch {
+		case given == 0:
+		case v.Major > 0 || given == 1:
+			cmp.setUpper(Version{Major: v.Major + 1}, false)
+		case v.Minor > 0 || given == 2:
+			cmp.setUpper(Version{Minor: v.Minor + 1}, false)
+		default:
+			cmp.setUpper(Version{Patch: v.Patch + 1}, false)
+		}
+	}
+	return cmp, nil
+}
+
+// parseHyphenRange parses the bounds of a hyphen range. A partial upper bound includes all its
+// versions: "1.2 - 1.4" is ">=1.2.0 <1.5.0".
+func parseHyphenRange(from, to string) (comparison, error) {
+	lower, _, err := parse(from, true)
+	if err != nil {
+		return comparison{}, err
+	}
+	upper, given, err := parse(to, true)
+	if err != nil {
+		return comparison{}, err
+	}
+	cmp := comparison{prerelease: lower.IsPrerelease() || upper.IsPrerelease()}
+	cmp.setLower(lower, true)
+	if given == 3 {
+		cmp.setUpper(upper, true)
+	} else if given > 0 {
+		cmp.setUpper(increment(upper, given), false)
+	}
+	return cmp, nil
+}
+
+// increment returns the lowest version above every version matching the first given numbers of v.
+func increment(v Version, given int) Version {
+	switch given {
+	case 1:
+		return Version{Major: v.Major + 1}
+	case 2:
+		return Version{Major: v.Major, Minor: v.Minor + 1}
+	}
+	return Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1}
+}
+
+func (c *comparison) setLower(v Version, inclusive bool) {
+	c.lower, c.lowerIncl = &v, inclusive
+}
+
+func (c *comparison) setUpper(v Version, inclusive bool) {
+	c.upper, c.upperIncl = &v, inclusive
+}
+
+// check reports whether v satisfies the comparison.
+func (c comparison) check(v Version) bool {
+	if v.IsPrerelease() && !c.prerelease {
+		return false
+	}
+	in := true
+	if c.lower != nil {
+		cmp := v.Compare(*c.lower)
+		in = cmp > 0 || (cmp == 0 && c.lowerIncl)
+	}
+	if in && c.upper != nil {
+		cmp := v.Compare(*c.upper)
+		in = cmp < 0 || (cmp == 0 && c.upperIncl)
+	}
+	return in != c.negate
+}
+
+// Check reports whether v satisfies the constraint.
+func (c *Constraint) Check(v Version) bool {
+	for _, comparisons := range c.ranges {
+		ok := true
+		for _, cmp := range comparisons {
+			if !cmp.check(v) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the constraint as it was parsed.
+func (c *Constraint) String() string {
+	return c.original
+}
@@ -0,0 +1,217 @@
+// Package semver parses semantic versions and the version constraints Flux accepts in a
+// HelmRelease chart version or an OCIRepository semver reference.
+package semver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a semantic version, such as 1.2.3-rc.1+build.5.
+type Version struct {
+	Major      uint64
+	Minor      uint64
+	Patch      uint64
+	Prerelease []string
+	Metadata   string
+
+	original string
+}
+
+// Parse parses a version. A leading "v" and missing minor or patch numbers are accepted,
+// as chart repositories commonly use them: "v1.2" is 1.2.0.
+func Parse(s string) (Version, error) {
+	v, _, err := parse(s, false)
+	return v, err
+}
+
+// IsExact reports whether s is a complete version rather than a constraint. A partial version
+// such as "1.2" is a constraint, matching any 1.2.x version.
+func IsExact(s string) bool {
+	_, given, err := parse(s, false)
+	return err == nil && given == 3
+}
+
+// parse parses a version, returning how many of its major, minor and patch numbers are given.
+// When wildcards is true, "x", "X" and "*" end the version, as in "1.2.x".
+func parse(s string, wildcards bool) (Version, int, error) {
+	v := Version{original: s}
+	rest := strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if rest == "" {
+		return Version{}, 0, fmt.Errorf("invalid version %q: empty", s)
+	}
+
+	if i := strings.IndexByte(rest, '+'); i >= 0 {
+		v.Metadata = rest[i+1:]
+		rest = rest[:i]
+		if !validIdentifiers(v.Metadata, false) {
+			return Version{}, 0, fmt.Errorf("invalid version %q: invalid build metadata", s)
+		}
+	}
+	if i := strings.IndexByte(rest, '-'); i >= 0 {
+		prerelease := rest[i+1:]
+		rest = rest[:i]
+		if !validIdentifiers(prerelease, true) {
+			return Version{}, 0, fmt.Errorf("invalid version %q: invalid prerelease", s)
+		}
+		v.Prerelease = strings.Split(prerelease, ".")
+	}
+
+	numbers := strings.Split(rest, ".")
+	if len(numbers) > 3 {
+		return Version{}, 0, fmt.Errorf("invalid version %q: too many numbers", s)
+	}
+	given := 0
+	for i, number := range numbers {
+		if wildcards && isWildcard(number) {
+			if len(v.Prerelease) > 0 || v.Metadata != "" {
+				return Version{}, 0, fmt.Errorf("invalid version %q: wildcard with a prerelease", s)
+			}
+			for _, after := range numbers[i+1:] {
+				if !isWildcard(after) {
+					return Version{}, 0, fmt.Errorf("invalid version %q: number after a wildcard", s)
+				}
+			}
+			break
+		}
+		n, err := parseNumber(number)
+		if err != nil {
+			return Version{}, 0, fmt.Errorf("invalid version %q: %w", s, err)
+		}
+		switch i {
+		case 0:
+			v.Major = n
+		case 1:
+			v.Minor = n
+		case 2:
+			v.Patch = n
+		}
+		given++
+	}
+	if given < 3 && len(v.Prerelease) > 0 {
+		return Version{}, 0, fmt.Errorf("invalid version %q: prerelease of a partial version", s)
+	}
+	return v, given, nil
+}
+
+func isWildcard(s string) bool {
+	return s == "x" || s == "X" || s == "*"
+}
+
+// parseNumber parses a version number, rejecting leading zeros.
+func parseNumber(s string) (uint64, error) {
+	if s == "" {
+		return 0, fmt.Errorf("empty number")
+	}
+	if len(s) > 1 && s[0] == '0' {
+		return 0, fmt.Errorf("number %q has a leading zero", s)
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	return n, nil
+}
+
+// validIdentifiers reports whether s is a dot-separated list of alphanumeric identifiers.
+// Numeric prerelease identifiers cannot have leading zeros.
+func validIdentifiers(s string, prerelease bool) bool {
+	if s == "" {
+		return false
+	}
+	for _, id := range strings.Split(s, ".") {
+		if id == "" {
+			return false
+		}
+		numeric := true
+		for _, r := range id {
+			switch {
+			case r >= '0' && r <= '9':
+			case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '-':
+				numeric = false
+			default:
+				return false
+			}
+		}
+		if prerelease && numeric && len(id) > 1 && id[0] == '0' {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the version as it was parsed.
+func (v Version) String() string {
+	if v.original != "" {
+		return v.original
+	}
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if len(v.Prerelease) > 0 {
+		s += "-" + strings.Join(v.Prerelease, ".")
+	}
+	if v.Metadata != "" {
+		s += "+" + v.Metadata
+	}
+	return s
+}
+
+// IsPrerelease reports whether the version has a prerelease, such as 1.0.0-rc.1.
+func (v Version) IsPrerelease() bool {
+	return len(v.Prerelease) > 0
+}
+
+// Compare returns -1, 0 or 1 when v is lower than, equal to or greater than o, following the
+// semver precedence rules: prereleases come before their release and build metadata is ignored.
+func (v Version) Compare(o Version) int {
+	if c := compareNumbers(v.Major, o.Major); c != 0 {
+		return c
+	}
+	if c := compareNumbers(v.Minor, o.Minor); c != 0 {
+		return c
+	}
+	if c := compareNumbers(v.Patch, o.Patch); c != 0 {
+		return c
+	}
+
+	switch {
+	case len(v.Prerelease) == 0 && len(o.Prerelease) == 0:
+		return 0
+	case len(v.Prerelease) == 0:
+		return 1
+	case len(o.Prerelease) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.Prerelease) && i < len(o.Prerelease); i++ {
+		if c := compareIdentifiers(v.Prerelease[i], o.Prerelease[i]); c != 0 {
+			return c
+		}
+	}
+	return compareNumbers(uint64(len(v.Prerelease)), uint64(len(o.Prerelease)))
+}
+
+func compareNumbers(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compareIdentifiers compares prerelease identifiers: numeric ones numerically and before
+// alphanumeric ones, which compare in ASCII order.
+func compareIdentifiers(a, b string) int {
+	an, aErr := strconv.ParseUint(a, 10, 64)
+	bn, bErr := strconv.ParseUint(b, 10, 64)
+	switch {
+	case aErr == nil && bErr == nil:
+		return compareNumbers(an, bn)
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,133 @@
+package semver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	v, err := Parse("v1.2.3-rc.1+build.5")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), v.Major)
+	assert.Equal(t, uint64(2), v.Minor)
+	assert.Equal(t, uint64(3), v.Patch)
+	assert.Equal(t, []string{"rc", "1"}, v.Prerelease)
+	assert.Equal(t, "build.5", v.Metadata)
+	assert.Equal(t, "v1.2.3-rc.1+build.5", v.String())
+	assert.True(t, v.IsPrerelease())
+
+	v, err = Parse("1.2")
+	require.NoError(t, err)
+	assert.Equal(t, "1.2.0", Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch}.String())
+
+	for _, invalid := range []string{"", "latest", "1.2.3.4", "01.2.3", "1.2.3-01", "1.2.3-", "1.2.3+", "1.x", "1.2-rc.1", "1.2.3-rc_1"} {
+		_, err := Parse(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestIsExact(t *testing.T) {
+	assert.True(t, IsExact("1.2.3"))
+	assert.True(t, IsExact("v1.2.3-rc.1"))
+	assert.False(t, IsExact("1.2"))
+	assert.False(t, IsExact("~1.2.3"))
+	assert.False(t, IsExact(">=1.0.0 <2.0.0"))
+}
+
+func TestVersion_Compare(t *testing.T) {
+	// Versions in ascending order, from the semver specification and common chart versions
+	ordered := []string{
+		"0.9.0",
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"1.9.0",
+		"1.10.0",
+		"2.0.0",
+	}
+	for i := range ordered {
+		for j := range ordered {
+			a, err := Parse(ordered[i])
+			require.NoError(t, err)
+			b, err := Parse(ordered[j])
+			require.NoError(t, err)
+
+			want := 0
+			switch {
+			case i < j:
+				want = -1
+			case i > j:
+				want = 1
+			}
+			assert.Equal(t, want, a.Compare(b), "%s vs %s", ordered[i], ordered[j])
+		}
+	}
+
+	a, _ := Parse("1.0.0+build.1")
+	b, _ := Parse("v1.0.0+build.2")
+	assert.Equal(t, 0, a.Compare(b), "build metadata is ignored")
+}
+
+func TestConstraint_Check(t *testing.T) {
+	tests := []struct {
+		constraint string
+		match      []string
+		noMatch    []string
+	}{
+		{"1.2.3", []string{"1.2.3", "v1.2.3+build"}, []string{"1.2.4", "1.2.3-rc.1"}},
+		{"1.2", []string{"1.2.0", "1.2.9"}, []string{"1.3.0", "1.1.9"}},
+		{"1.2.x", []string{"1.2.0", "1.2.9"}, []string{"1.3.0"}},
+		{"*", []string{"0.0.1", "10.0.0"}, []string{"1.0.0-rc.1"}},
+		{"!=1.2.3", []string{"1.2.2", "1.2.4"}, []string{"1.2.3"}},
+		{">1.2.3", []string{"1.2.4", "2.0.0"}, []string{"1.2.3", "1.0.0"}},
+		{">1.2", []string{"1.3.0"}, []string{"1.2.9"}},
+		{">=1.2", []string{"1.2.0", "3.0.0"}, []string{"1.1.9"}},
+		{"<1.2", []string{"1.1.9"}, []string{"1.2.0"}},
+		{"<=1.2", []string{"1.2.9"}, []string{"1.3.0"}},
+		{"~1.4", []string{"1.4.0", "1.4.12"}, []string{"1.5.0", "1.3.9"}},
+		{"~1.4.2", []string{"1.4.2", "1.4.9"}, []string{"1.4.1", "1.5.0"}},
+		{"~1", []string{"1.0.0", "1.9.0"}, []string{"2.0.0"}},
+		{"^1.2.3", []string{"1.2.3", "1.99.0"}, []string{"1.2.2", "2.0.0"}},
+		{"^0.2.3", []string{"0.2.3", "0.2.9"}, []string{"0.3.0"}},
+		{"^0.0.3", []string{"0.0.3"}, []string{"0.0.4"}},
+		{"^0", []string{"0.0.1", "0.9.9"}, []string{"1.0.0"}},
+		{">=2 <3", []string{"2.0.0", "2.99.1"}, []string{"1.9.9", "3.0.0", "2.1.0-rc.1"}},
+		{">= 2, < 3", []string{"2.5.0"}, []string{"3.0.0"}},
+		{"1.2 - 1.4.5", []string{"1.2.0", "1.4.5"}, []string{"1.4.6", "1.1.0"}},
+		{"1.2 - 1.4", []string{"1.4.9"}, []string{"1.5.0"}},
+		{"^1.0.0 || ~3.1", []string{"1.5.0", "3.1.4"}, []string{"2.0.0", "3.2.0"}},
+		{">=2.0.0-0", []string{"2.0.0-rc.1", "2.1.0"}, []string{"1.9.0"}},
+		{"~2.0.0-rc.1", []string{"2.0.0-rc.2", "2.0.1"}, []string{"2.0.0-beta.1", "2.1.0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.constraint, func(t *testing.T) {
+			c, err := ParseConstraint(tt.constraint)
+			require.NoError(t, err)
+			assert.Equal(t, tt.constraint, c.String())
+			for _, s := range tt.match {
+				v, err := Parse(s)
+				require.NoError(t, err)
+				assert.True(t, c.Check(v), "%s should match %s", s, tt.constraint)
+			}
+			for _, s := range tt.noMatch {
+				v, err := Parse(s)
+				require.NoError(t, err)
+				assert.False(t, c.Check(v), "%s should not match %s", s, tt.constraint)
+			}
+		})
+	}
+}
+
+func TestParseConstraint_Invalid(t *testing.T) {
+	for _, invalid := range []string{"", "latest", ">=", "~1.2.3.4", "1.2 ||", ">=1.x.y"} {
+		_, err := ParseConstraint(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
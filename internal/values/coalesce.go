@@ -0,0 +1,72 @@
+package values
+
+import (
+	"gopkg.in/yaml.v3"
+)
+
+// Coalesce returns the values a chart is rendered with, like Helm: the chart defaults overridden
+// by ours, mappings being merged key by key and a null value removing the default.
+//
+// Nodes of ours keep their position, so that errors point to the right line of our values file,
+// while nodes only coming from the defaults have line 0.
+func Coalesce(defaults, ours []byte) (*yaml.Node, error) {
+	base, err := parseMapping(defaults)
+	if err != nil {
+		return nil, err
+	}
+	overrides, err := parseMapping(ours)
+	if err != nil {
+		return nil, err
+	}
+	result := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	if base != nil {
+		result = detach(base)
+	}
+	if overrides != nil {
+		result.Line, result.Column = overrides.Line, overrides.Column
+		coalesceMapping(result, overrides)
+	}
+	return result, nil
+}
+
+// coalesceMapping applies the keys of overrides to the mapping node.
+func coalesceMapping(node, overrides *yaml.Node) {
+	for i := 0; i+1 < len(overrides.Content); i += 2 {
+		key, value := overrides.Content[i], overrides.Content[i+1]
+		index := -1
+		for j := 0; j+1 < len(node.Content); j += 2 {
+			if node.Content[j].Value == key.Value {
+				index = j
+				break
+			}
+		}
+
+		switch {
+		case value.Kind == yaml.ScalarNode && value.ShortTag() == "!!null":
+			if index >= 0 {
+				node.Content = append(node.Content[:index], node.Content[index+2:]...)
+			}
+		case index < 0:
+			node.Content = append(node.Content, key, value)
+		case value.Kind == yaml.MappingNode && node.Content[index+1].Kind == yaml.MappingNode:
+			node.Content[index] = key
+			merged := node.Content[index+1]
+			merged.Line, merged.Column = value.Line, value.Column
+			coalesceMapping(merged, value)
+		default:
+			node.Content[index], node.Content[index+1] = key, value
+		}
+	}
+}
+
+// detach returns a deep copy of a node without position, aliases being resolved.
+func detach(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	clone := &yaml.Node{Kind: node.Kind, Style: node.Style, Tag: node.Tag, Value: node.Value}
+	for _, child := range node.Content {
+		clone.Content = append(clone.Content, detach(child))
+	}
+	return clone
+}
@@ -0,0 +1,48 @@
+package values
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestCoalesce(t *testing.T) {
+	ours := `replicaCount: 3
+image:
+  tag: "1.27"
+metrics: ~
+extra:
+  enabled: true
+`
+	node, err := Coalesce([]byte(oldDefaults), []byte(ours))
+	require.NoError(t, err)
+
+	var coalesced map[string]interface{}
+	require.NoError(t, node.Decode(&coalesced))
+	assert.Equal(t, map[string]interface{}{
+		"replicaCount":   3,
+		"image":          map[string]interface{}{"repository": "nginx", "tag": "1.27"},
+		"service":        map[string]interface{}{"type": "ClusterIP", "port": 80},
+		"podAnnotations": map[string]interface{}{},
+		"legacyMode":     true,
+		"extra":          map[string]interface{}{"enabled": true},
+	}, coalesced)
+
+	// Our values keep their lines, the defaults have none
+	assert.Equal(t, 1, lookupValue(node, []string{"replicaCount"}).Line)
+	assert.Equal(t, 3, lookupValue(node, []string{"image", "tag"}).Line)
+	assert.Equal(t, 0, lookupValue(node, []string{"image", "repository"}).Line)
+	assert.Equal(t, 0, lookupKey(node, []string{"legacyMode"}).Line)
+}
+
+func TestCoalesce_Empty(t *testing.T) {
+	node, err := Coalesce(nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, yaml.MappingNode, node.Kind)
+	assert.Empty(t, node.Content)
+
+	_, err = Coalesce([]byte("a: 1\n"), []byte("- a\n"))
+	assert.ErrorContains(t, err, "expected a mapping")
+}
@@ -0,0 +1,465 @@
+// Package values merges Helm values files across chart versions.
+package values
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ChangeKind classifies a difference found while merging values.
+type ChangeKind string
+
+const (
+	// ChangeUpdated is a value equal to the old default that now follows the new default.
+	ChangeUpdated ChangeKind = "updated"
+	// ChangeAdded is a key added upstream, copied into values files that are full copies of the defaults.
+	ChangeAdded ChangeKind = "added"
+	// ChangeRemoved is a value equal to the old default whose key was removed upstream.
+	ChangeRemoved ChangeKind = "removed"
+	// ConflictRemoved is an override of a key that was removed upstream.
+	ConflictRemoved ChangeKind = "removed-upstream"
+	// ConflictRenamed is an override of a key that was removed upstream and most likely moved to RenamedTo.
+	ConflictRenamed ChangeKind = "renamed-upstream"
+	// ConflictChangedDefault is an override of a key whose default changed upstream.
+	ConflictChangedDefault ChangeKind = "changed-default"
+)
+
+// IsConflict reports whether the change needs a decision from the user.
+func (k ChangeKind) IsConflict() bool {
+	return k == ConflictRemoved || k == ConflictRenamed || k == ConflictChangedDefault
+}
+
+// Change is a difference found for one key while merging.
+type Change struct {
+	Path       string
+	Kind       ChangeKind
+	OldDefault interface{}
+	NewDefault interface{}
+	Ours       interface{}
+	RenamedTo  string
+}
+
+// String describes the change on a single line.
+func (c Change) String() string {
+	switch c.Kind {
+	case ChangeUpdated:
+		return fmt.Sprintf("%s: default changed from %s to %s, updated", c.Path, format(c.OldDefault), format(c.NewDefault))
+	case ChangeAdded:
+		return fmt.Sprintf("%s: added upstream with default %s", c.Path, format(c.NewDefault))
+	case ChangeRemoved:
+		return fmt.Sprintf("%s: removed upstream, removed", c.Path)
+	case ConflictRemoved:
+		return fmt.Sprintf("%s: removed upstream, our value %s is no longer used", c.Path, format(c.Ours))
+	case ConflictRenamed:
+		return fmt.Sprintf("%s: renamed upstream to %s, our value %s is no longer used", c.Path, c.RenamedTo, format(c.Ours))
+	case ConflictChangedDefault:
+		return fmt.Sprintf("%s: default changed from %s to %s, we override it with %s", c.Path, format(c.OldDefault), format(c.NewDefault), format(c.Ours))
+	default:
+		return c.Path
+	}
+}
+
+// Report lists the changes found while merging, in document order.
+type Report struct {
+	Changes []Change
+}
+
+// Conflicts returns the changes that need a decision from the user.
+func (r *Report) Conflicts() []Change {
+	var conflicts []Change
+	for _, change := range r.Changes {
+		if change.Kind.IsConflict() {
+			conflicts = append(conflicts, change)
+		}
+	}
+	return conflicts
+}
+
+// String renders the report, conflicts first.
+func (r *Report) String() string {
+	var b strings.Builder
+	conflicts := r.Conflicts()
+	if len(conflicts) > 0 {
+		fmt.Fprintf(&b, "%d conflict(s):\n", len(conflicts))
+		for _, change := range conflicts {
+			fmt.Fprintf(&b, "  ! %s\n", change)
+		}
+	}
+	if merged := len(r.Changes) - len(conflicts); merged > 0 {
+		fmt.Fprintf(&b, "%d merged change(s):\n", merged)
+		for _, change := range r.Changes {
+			if !change.Kind.IsConflict() {
+				fmt.Fprintf(&b, "  - %s\n", change)
+			}
+		}
+	}
+	return b.String()
+}
+
+// MergeResult is the outcome of a three-way merge.
+type MergeResult struct {
+	// Values is the merged values file. Conflicting keys keep our value and are preceded by a
+	// "# CONFLICT" comment, so the file stays valid YAML.
+	Values []byte
+	Report Report
+}
+
+// Changed reports whether the merged values differ from ours.
+func (r *MergeResult) Changed() bool {
+	return len(r.Report.Changes) > 0
+}
+
+// Merge performs a three-way merge of a values file (ours) between the default values of the old
+// chart version and those of the new one. A key missing from ours is not overridden and keeps
+// following the chart default. Values equal to the old default follow the new default, while
+// overrides of keys that changed or disappeared upstream are kept and reported as conflicts.
+// When ours is a full copy of the old defaults, keys added upstream are copied into it as well.
+func Merge(oldDefaults, newDefaults, ours []byte) (*MergeResult, error) {
+	base, err := decodeMap(oldDefaults)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse old default values: %w", err)
+	}
+	theirs, err := decodeMap(newDefaults)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse new default values: %w", err)
+	}
+	var theirsDoc yaml.Node
+	if err := yaml.Unmarshal(newDefaults, &theirsDoc); err != nil {
+		return nil, fmt.Errorf("failed to parse new default values: %w", err)
+	}
+	var oursDoc yaml.Node
+	if err := yaml.Unmarshal(ours, &oursDoc); err != nil {
+		return nil, fmt.Errorf("failed to parse values: %w", err)
+	}
+
+	result := &MergeResult{Values: ours}
+	root := documentMapping(&oursDoc)
+	if root == nil {
+		return result, nil
+	}
+
+	m := &merger{
+		base:      base,
+		theirs:    theirs,
+		theirsDoc: documentMapping(&theirsDoc),
+		fullCopy:  isFullCopy(root, base),
+	}
+	m.mergeMapping(root, nil, base, theirs)
+	result.Report = m.report
+	if !result.Changed() {
+		return result, nil
+	}
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&oursDoc); err != nil {
+		return nil, fmt.Errorf("failed to encode merged values: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode merged values: %w", err)
+	}
+	result.Values = out.Bytes()
+	return result, nil
+}
+
+type merger struct {
+	base      map[string]interface{}
+	theirs    map[string]interface{}
+	theirsDoc *yaml.Node
+	fullCopy  bool
+	report    Report
+}
+
+func (m *merger) mergeMapping(node *yaml.Node, path []string, base, theirs map[string]interface{}) {
+	present := make(map[string]bool)
+
+	for i := 0; i+1 < len(node.Content); {
+		key, value := node.Content[i], node.Content[i+1]
+		keyPath := append(append([]string(nil), path...), key.Value)
+		present[key.Value] = true
+
+		baseValue, inBase := base[key.Value]
+		theirsValue, inTheirs := theirs[key.Value]
+		baseMap, baseIsMap := baseValue.(map[string]interface{})
+		theirsMap, theirsIsMap := theirsValue.(map[string]interface{})
+
+		// Recurse into mappings that are mappings upstream as well
+		if value.Kind == yaml.MappingNode && (baseIsMap || !inBase) && (theirsIsMap || !inTheirs) && (inBase || inTheirs) {
+			wasEmpty := len(value.Content) == 0
+			m.mergeMapping(value, keyPath, baseMap, theirsMap)
+			if len(value.Content) == 0 && inBase && !inTheirs {
+				if wasEmpty {
+					m.add(Change{Path: formatPath(keyPath), Kind: ChangeRemoved, OldDefault: baseValue})
+				}
+				node.Content = append(node.Content[:i], node.Content[i+2:]...)
+				continue
+			}
+			i += 2
+			continue
+		}
+
+		var ours interface{}
+		_ = value.Decode(&ours)
+		switch {
+		case !inBase:
+			// Our own key, or a key upstream only started to define: nothing to merge
+		case reflect.DeepEqual(ours, baseValue) && !inTheirs:
+			m.add(Change{Path: formatPath(keyPath), Kind: ChangeRemoved, OldDefault: baseValue})
+			node.Content = append(node.Content[:i], node.Content[i+2:]...)
+			continue
+		case reflect.DeepEqual(ours, baseValue):
+			if !reflect.DeepEqual(baseValue, theirsValue) {
+				m.add(Change{Path: formatPath(keyPath), Kind: ChangeUpdated, OldDefault: baseValue, NewDefault: theirsValue})
+				node.Content[i+1] = m.theirsNode(keyPath, theirsValue)
+			}
+		case !inTheirs:
+			change := Change{Path: formatPath(keyPath), Kind: ConflictRemoved, OldDefault: baseValue, Ours: ours}
+			if renamed := m.findRename(keyPath, baseValue); renamed != "" {
+				change.Kind = ConflictRenamed
+				change.RenamedTo = renamed
+			}
+			m.add(change)
+			markConflict(key, change)
+		case !reflect.DeepEqual(baseValue, theirsValue) && !reflect.DeepEqual(ours, theirsValue):
+			change := Change{Path: formatPath(keyPath), Kind: ConflictChangedDefault, OldDefault: baseValue, NewDefault: theirsValue, Ours: ours}
+			m.add(change)
+			markConflict(key, change)
+		}
+		i += 2
+	}
+
+	// New keys are only copied into mappings that exist in the old defaults
+	if !m.fullCopy || base == nil {
+		return
+	}
+	for _, name := range sortedKeys(theirs) {
+		if present[name] {
+			continue
+		}
+		if _, inBase := base[name]; inBase {
+			continue
+		}
+		keyPath := append(append([]string(nil), path...), name)
+		m.add(Change{Path: formatPath(keyPath), Kind: ChangeAdded, NewDefault: theirs[name]})
+		keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name}
+		if upstreamKey := lookupKey(m.theirsDoc, keyPath); upstreamKey != nil {
+			keyNode.HeadComment = upstreamKey.HeadComment
+		}
+		node.Content = append(node.Content, keyNode, m.theirsNode(keyPath, theirs[name]))
+	}
+}
+
+func (m *merger) add(change Change) {
+	m.report.Changes = append(m.report.Changes, change)
+}
+
+// theirsNode returns the node of the new defaults at path, keeping its comments and style.
+func (m *merger) theirsNode(path []string, value interface{}) *yaml.Node {
+	if node := lookupValue(m.theirsDoc, path); node != nil {
+		return node
+	}
+	var node yaml.Node
+	_ = node.Encode(value)
+	return &node
+}
+
+// findRename looks for the key a removed key most likely moved to: a key added upstream with the
+// same name, or failing that, with the same default value.
+func (m *merger) findRename(path []string, oldDefault interface{}) string {
+	added := addedPaths(m.base, m.theirs, nil)
+	name := path[len(path)-1]
+
+	var byName, byValue []string
+	for _, candidate := range added {
+		if candidate.path[len(candidate.path)-1] == name {
+			byName = append(byName, formatPath(candidate.path))
+		}
+		if isDistinctive(oldDefault) && reflect.DeepEqual(candidate.value, oldDefault) {
+			byValue = append(byValue, formatPath(candidate.path))
+		}
+	}
+	switch {
+	case len(byName) == 1:
+		return byName[0]
+	case len(byName) == 0 && len(byValue) == 1:
+		return byValue[0]
+	default:
+		return ""
+	}
+}
+
+type valuePath struct {
+	path  []string
+	value interface{}
+}
+
+// addedPaths returns the leaf paths of theirs that do not exist in base.
+func addedPaths(base, theirs map[string]interface{}, path []string) []valuePath {
+	var added []valuePath
+	for _, name := range sortedKeys(theirs) {
+		keyPath := append(append([]string(nil), path...), name)
+		baseValue, inBase := base[name]
+		theirsMap, theirsIsMap := theirs[name].(map[string]interface{})
+		baseMap, baseIsMap := baseValue.(map[string]interface{})
+		switch {
+		case theirsIsMap && (baseIsMap || !inBase):
+			added = append(added, addedPaths(baseMap, theirsMap, keyPath)...)
+		case !inBase:
+			added = append(added, valuePath{path: keyPath, value: theirs[name]})
+		}
+	}
+	return added
+}
+
+// isDistinctive reports whether a default value is specific enough to identify a renamed key.
+func isDistinctive(value interface{}) bool {
+	switch v := value.(type) {
+	case nil, bool:
+		return false
+	case string:
+		return len(v) > 3
+	case int, float64:
+		return true
+	case map[string]interface{}:
+		return len(v) > 0
+	case []interface{}:
+		return len(v) > 0
+	default:
+		return false
+	}
+}
+
+// markConflict adds a conflict marker comment above the key.
+func markConflict(key *yaml.Node, change Change) {
+	marker := "# CONFLICT (" + string(change.Kind) + "): " + change.String()
+	if key.HeadComment == "" {
+		key.HeadComment = marker
+	} else {
+		key.HeadComment += "\n" + marker
+	}
+}
+
+// isFullCopy reports whether ours holds every top-level key of the defaults, as a values file
+// prefilled with the chart defaults does.
+func isFullCopy(root *yaml.Node, base map[string]interface{}) bool {
+	if len(base) == 0 {
+		return false
+	}
+	keys := make(map[string]bool, len(root.Content)/2)
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		keys[root.Content[i].Value] = true
+	}
+	for name := range base {
+		if !keys[name] {
+			return false
+		}
+	}
+	return true
+}
+
+func decodeMap(data []byte) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// documentMapping returns the top-level mapping of a document, or nil for empty documents.
+func documentMapping(doc *yaml.Node) *yaml.Node {
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil
+	}
+	return doc.Content[0]
+}
+
+// lookupKey returns the key node at path, or nil.
+func lookupKey(node *yaml.Node, path []string) *yaml.Node {
+	key, _ := lookup(node, path)
+	return key
+}
+
+// lookupValue returns the value node at path, or nil.
+func lookupValue(node *yaml.Node, path []string) *yaml.Node {
+	_, value := lookup(node, path)
+	return value
+}
+
+func lookup(node *yaml.Node, path []string) (*yaml.Node, *yaml.Node) {
+	var key *yaml.Node
+	for _, name := range path {
+		if node == nil || node.Kind != yaml.MappingNode {
+			return nil, nil
+		}
+		var next *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == name {
+				key, next = node.Content[i], node.Content[i+1]
+				break
+			}
+		}
+		if next == nil {
+			return nil, nil
+		}
+		node = next
+	}
+	return key, node
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formatPath renders a key path like a Helm --set path, quoting keys that contain dots.
+func formatPath(path []string) string {
+	parts := make([]string, len(path))
+	for i, name := range path {
+		if strings.Contains(name, ".") {
+			parts[i] = fmt.Sprintf("%q", name)
+		} else {
+			parts[i] = name
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// format renders a value on a single line for reports.
+func format(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		out, err := yaml.Marshal(value)
+		if err != nil {
+			return fmt.Sprint(value)
+		}
+		var flow yaml.Node
+		if err := yaml.Unmarshal(out, &flow); err == nil && len(flow.Content) > 0 {
+			setFlowStyle(flow.Content[0])
+			if out, err := yaml.Marshal(flow.Content[0]); err == nil {
+				return strings.TrimSpace(string(out))
+			}
+		}
+		return strings.TrimSpace(string(out))
+	case string:
+		return fmt.Sprintf("%q", value)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+func setFlowStyle(node *yaml.Node) {
+	node.Style = yaml.FlowStyle
+	for _, child := range node.Content {
+		setFlowStyle(child)
+	}
+}
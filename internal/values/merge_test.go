@@ -0,0 +1,146 @@
+package values
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const oldDefaults = `replicaCount: 1
+image:
+  repository: nginx
+  tag: "1.25"
+service:
+  type: ClusterIP
+  port: 80
+metrics:
+  enabled: false
+podAnnotations: {}
+legacyMode: true
+`
+
+const newDefaults = `replicaCount: 1
+image:
+  repository: nginx
+  tag: "1.27"
+service:
+  type: ClusterIP
+  port: 8080
+metrics:
+  enabled: false
+  # Scrape interval used by the ServiceMonitor
+  interval: 30s
+podAnnotations: {}
+compat:
+  legacyMode: false
+`
+
+func decode(t *testing.T, data []byte) map[string]interface{} {
+	t.Helper()
+	values := make(map[string]interface{})
+	require.NoError(t, yaml.Unmarshal(data, &values))
+	return values
+}
+
+func changesByPath(report Report) map[string]Change {
+	changes := make(map[string]Change)
+	for _, change := range report.Changes {
+		changes[change.Path] = change
+	}
+	return changes
+}
+
+func TestMerge_Overrides(t *testing.T) {
+	ours := `# Our overrides
+replicaCount: 3
+service:
+  port: 9090 # exposed through the ingress
+legacyMode: false
+`
+	result, err := Merge([]byte(oldDefaults), []byte(newDefaults), []byte(ours))
+	require.NoError(t, err)
+
+	changes := changesByPath(result.Report)
+	require.Len(t, changes, 2)
+	assert.Equal(t, ConflictChangedDefault, changes["service.port"].Kind)
+	assert.Equal(t, 80, changes["service.port"].OldDefault)
+	assert.Equal(t, 8080, changes["service.port"].NewDefault)
+	assert.Equal(t, ConflictRenamed, changes["legacyMode"].Kind)
+	assert.Equal(t, "compat.legacyMode", changes["legacyMode"].RenamedTo)
+	assert.Len(t, result.Report.Conflicts(), 2)
+
+	// Overrides are kept, missing keys keep following the defaults
+	assert.Equal(t, map[string]interface{}{
+		"replicaCount": 3,
+		"service":      map[string]interface{}{"port": 9090},
+		"legacyMode":   false,
+	}, decode(t, result.Values))
+	assert.Contains(t, string(result.Values), "# Our overrides")
+	assert.Contains(t, string(result.Values), "# exposed through the ingress")
+	assert.Contains(t, string(result.Values), "# CONFLICT (changed-default): service.port: default changed from 80 to 8080, we override it with 9090")
+	assert.Contains(t, string(result.Values), "# CONFLICT (renamed-upstream): legacyMode: renamed upstream to compat.legacyMode")
+}
+
+func TestMerge_FullCopy(t *testing.T) {
+	ours := `replicaCount: 2
+image:
+  repository: nginx
+  tag: "1.25"
+service:
+  type: ClusterIP
+  port: 80
+metrics:
+  enabled: false
+podAnnotations: {}
+legacyMode: true
+`
+	result, err := Merge([]byte(oldDefaults), []byte(newDefaults), []byte(ours))
+	require.NoError(t, err)
+
+	changes := changesByPath(result.Report)
+	assert.Equal(t, ChangeUpdated, changes["image.tag"].Kind)
+	assert.Equal(t, ChangeUpdated, changes["service.port"].Kind)
+	assert.Equal(t, ChangeAdded, changes["metrics.interval"].Kind)
+	assert.Equal(t, ChangeAdded, changes["compat"].Kind)
+	assert.Equal(t, ChangeRemoved, changes["legacyMode"].Kind)
+	assert.Empty(t, result.Report.Conflicts())
+
+	expected := decode(t, []byte(newDefaults))
+	expected["replicaCount"] = 2
+	assert.Equal(t, expected, decode(t, result.Values))
+	assert.Contains(t, string(result.Values), "# Scrape interval used by the ServiceMonitor")
+}
+
+func TestMerge_Unchanged(t *testing.T) {
+	ours := "# nothing changed upstream for these\nreplicaCount: 3\nimage:\n    repository: custom\n"
+	result, err := Merge([]byte(oldDefaults), []byte(oldDefaults), []byte(ours))
+	require.NoError(t, err)
+
+	assert.False(t, result.Changed())
+	assert.Equal(t, ours, string(result.Values), "the file is left byte for byte untouched")
+}
+
+func TestMerge_EmptyValues(t *testing.T) {
+	result, err := Merge([]byte(oldDefaults), []byte(newDefaults), []byte("\n"))
+	require.NoError(t, err)
+	assert.False(t, result.Changed())
+}
+
+func TestMerge_InvalidYAML(t *testing.T) {
+	_, err := Merge([]byte(oldDefaults), []byte(newDefaults), []byte("a: [b"))
+	assert.Error(t, err)
+}
+
+func TestReport_String(t *testing.T) {
+	report := Report{Changes: []Change{
+		{Path: "image.tag", Kind: ChangeUpdated, OldDefault: "1.25", NewDefault: "1.27"},
+		{Path: "legacyMode", Kind: ConflictRemoved, Ours: false},
+	}}
+	assert.Equal(t, `1 conflict(s):
+  ! legacyMode: removed upstream, our value false is no longer used
+1 merged change(s):
+  - image.tag: default changed from "1.25" to "1.27", updated
+`, report.String())
+}
@@ -0,0 +1,215 @@
+package values
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CuratedKeys are the keys an overrides-only values file is seeded with when present in the chart defaults.
+var CuratedKeys = []string{"replicaCount", "image", "resources", "ingress"}
+
+// Key is a key of a values file, as listed in the key selector.
+type Key struct {
+	Path  string // Dotted path, keys containing dots are quoted
+	Depth int    // 0 for top-level keys
+	Leaf  bool   // Whether the value is not a mapping
+}
+
+// Keys lists the keys of a values file down to maxDepth levels, in document order.
+func Keys(data []byte, maxDepth int) ([]Key, error) {
+	root, err := parseMapping(data)
+	if err != nil {
+		return nil, err
+	}
+	var keys []Key
+	var walk func(node *yaml.Node, path []string)
+	walk = func(node *yaml.Node, path []string) {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			keyPath := append(append([]string(nil), path...), node.Content[i].Value)
+			value := node.Content[i+1]
+			leaf := value.Kind != yaml.MappingNode || len(value.Content) == 0
+			keys = append(keys, Key{Path: formatPath(keyPath), Depth: len(path), Leaf: leaf})
+			if !leaf && len(keyPath) < maxDepth {
+				walk(value, keyPath)
+			}
+		}
+	}
+	if root != nil {
+		walk(root, nil)
+	}
+	return keys, nil
+}
+
+// DefaultKeys returns the curated keys present in the values file.
+func DefaultKeys(data []byte) ([]string, error) {
+	root, err := parseMapping(data)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, key := range CuratedKeys {
+		if _, value := lookup(root, []string{key}); value != nil {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// Overrides extracts the given keys, with their default values and comments, from a values file.
+// The keys keep the order they have upstream. A key nested in another selected key is ignored.
+func Overrides(data []byte, paths []string) ([]byte, error) {
+	root, err := parseMapping(data)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make([][]string, 0, len(paths))
+	for _, path := range paths {
+		parts, err := ParsePath(path)
+		if err != nil {
+			return nil, err
+		}
+		if _, value := lookup(root, parts); value == nil {
+			return nil, fmt.Errorf("key %s not found in the default values", path)
+		}
+		selected = append(selected, parts)
+	}
+
+	out := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	if root != nil {
+		copySelected(root, out, nil, selected)
+	}
+	if len(out.Content) == 0 {
+		return []byte("{}\n"), nil
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(out); err != nil {
+		return nil, fmt.Errorf("failed to encode values: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode values: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// copySelected copies the selected subtrees of src into dst, following the order of src.
+func copySelected(src, dst *yaml.Node, path []string, selected [][]string) {
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+		keyPath := append(append([]string(nil), path...), key.Value)
+
+		switch selection(keyPath, selected) {
+		case selectedWhole:
+			dst.Content = append(dst.Content, key, value)
+		case selectedPartly:
+			child := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			copySelected(value, child, keyPath, selected)
+			dst.Content = append(dst.Content, key, child)
+		}
+	}
+}
+
+const (
+	selectedNone = iota
+	selectedWhole
+	selectedPartly
+)
+
+// selection reports whether path is selected, or is the parent of a selected path.
+func selection(path []string, selected [][]string) int {
+	result := selectedNone
+	for _, s := range selected {
+		if len(s) <= len(path) && equalPrefix(path, s) {
+			return selectedWhole
+		}
+		if len(s) > len(path) && equalPrefix(s, path) {
+			result = selectedPartly
+		}
+	}
+	return result
+}
+
+func equalPrefix(path, prefix []string) bool {
+	for i := range prefix {
+		if path[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ParsePath splits a dotted key path. Keys containing dots are double-quoted, e.g. podLabels."app.kubernetes.io/name".
+func ParsePath(path string) ([]string, error) {
+	var parts []string
+	for rest := path; ; {
+		var part string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				return nil, fmt.Errorf("invalid key path %q: unterminated quote", path)
+			}
+			unquoted, err := strconv.Unquote(rest[:end+2])
+			if err != nil {
+				return nil, fmt.Errorf("invalid key path %q: %w", path, err)
+			}
+			part, rest = unquoted, rest[end+2:]
+			if rest != "" && !strings.HasPrefix(rest, ".") {
+				return nil, fmt.Errorf("invalid key path %q", path)
+			}
+		} else if dot := strings.Index(rest, "."); dot >= 0 {
+			part, rest = rest[:dot], rest[dot:]
+		} else {
+			part, rest = rest, ""
+		}
+		if part == "" {
+			return nil, fmt.Errorf("invalid key path %q: empty key", path)
+		}
+		parts = append(parts, part)
+		if rest == "" {
+			return parts, nil
+		}
+		rest = rest[1:]
+	}
+}
+
+// CommentedReference returns the values file fully commented out, below the given header lines,
+// so it can be kept next to the applied values as a reference.
+func CommentedReference(data []byte, header ...string) string {
+	var b strings.Builder
+	for _, line := range header {
+		b.WriteString("# " + line + "\n")
+	}
+	if len(header) > 0 {
+		b.WriteString("\n")
+	}
+	for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		if line == "" {
+			b.WriteString("#\n")
+			continue
+		}
+		b.WriteString("# " + line + "\n")
+	}
+	return b.String()
+}
+
+// parseMapping parses a values file and returns its top-level mapping, or nil when it is empty.
+func parseMapping(data []byte) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse values: %w", err)
+	}
+	if doc.Kind == 0 || len(doc.Content) == 0 {
+		return nil, nil
+	}
+	if doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("failed to parse values: expected a mapping")
+	}
+	return doc.Content[0], nil
+}
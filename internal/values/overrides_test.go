@@ -0,0 +1,141 @@
+package values
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const chartDefaults = `# Number of replicas
+replicaCount: 1
+image:
+  # Image repository
+  repository: nginx
+  tag: "1.27"
+podLabels:
+  app.kubernetes.io/part-of: web
+resources:
+  limits:
+    cpu: 100m
+  requests:
+    cpu: 50m
+service:
+  type: ClusterIP
+`
+
+func TestKeys(t *testing.T) {
+	keys, err := Keys([]byte(chartDefaults), 2)
+	require.NoError(t, err)
+
+	assert.Equal(t, []Key{
+		{Path: "replicaCount", Depth: 0, Leaf: true},
+		{Path: "image", Depth: 0},
+		{Path: "image.repository", Depth: 1, Leaf: true},
+		{Path: "image.tag", Depth: 1, Leaf: true},
+		{Path: "podLabels", Depth: 0},
+		{Path: `podLabels."app.kubernetes.io/part-of"`, Depth: 1, Leaf: true},
+		{Path: "resources", Depth: 0},
+		{Path: "resources.limits", Depth: 1},
+		{Path: "resources.requests", Depth: 1},
+		{Path: "service", Depth: 0},
+		{Path: "service.type", Depth: 1, Leaf: true},
+	}, keys)
+
+	keys, err = Keys(nil, 2)
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+}
+
+func TestDefaultKeys(t *testing.T) {
+	keys, err := DefaultKeys([]byte(chartDefaults))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"replicaCount", "image", "resources"}, keys)
+}
+
+func TestOverrides(t *testing.T) {
+	tests := []struct {
+		name     string
+		keys     []string
+		expected string
+	}{
+		{
+			name: "upstream order and comments",
+			keys: []string{"image", "replicaCount"},
+			expected: `# Number of replicas
+replicaCount: 1
+image:
+  # Image repository
+  repository: nginx
+  tag: "1.27"
+`,
+		},
+		{
+			name: "nested keys",
+			keys: []string{"resources.limits", `podLabels."app.kubernetes.io/part-of"`},
+			expected: `podLabels:
+  app.kubernetes.io/part-of: web
+resources:
+  limits:
+    cpu: 100m
+`,
+		},
+		{
+			name: "key nested in a selected key",
+			keys: []string{"service.type", "service"},
+			expected: `service:
+  type: ClusterIP
+`,
+		},
+		{
+			name:     "no keys",
+			expected: "{}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := Overrides([]byte(chartDefaults), tt.keys)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, string(out))
+		})
+	}
+
+	_, err := Overrides([]byte(chartDefaults), []string{"ingress"})
+	assert.ErrorContains(t, err, "key ingress not found")
+}
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected []string
+		wantErr  bool
+	}{
+		{path: "image", expected: []string{"image"}},
+		{path: "resources.limits.cpu", expected: []string{"resources", "limits", "cpu"}},
+		{path: `podLabels."app.kubernetes.io/name"`, expected: []string{"podLabels", "app.kubernetes.io/name"}},
+		{path: `"a.b".c`, expected: []string{"a.b", "c"}},
+		{path: "image..tag", wantErr: true},
+		{path: `podLabels."app`, wantErr: true},
+		{path: `"a"b`, wantErr: true},
+		{path: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			parts, err := ParsePath(tt.path)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, parts)
+		})
+	}
+}
+
+func TestCommentedReference(t *testing.T) {
+	out := CommentedReference([]byte("a: 1\n\nb:\n  c: 2\n"), "Defaults of chart x")
+	assert.Equal(t, "# Defaults of chart x\n\n# a: 1\n#\n# b:\n#   c: 2\n", out)
+	assert.Equal(t, "# a: 1\n", CommentedReference([]byte("a: 1\n")))
+}
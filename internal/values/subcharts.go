@@ -0,0 +1,143 @@
+package values
+
+import (
+	"bytes"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Subchart holds the default values of a subchart, which umbrella charts configure under a key
+// named after the dependency.
+type Subchart struct {
+	Key    string // Alias or name of the dependency
+	Name   string // Name of the subchart, for comments
+	Values []byte
+}
+
+// WithSubcharts nests the default values of subcharts under their keys in the values of the
+// parent chart, keeping the comments of both. Values the parent chart sets for a subchart take
+// precedence over the subchart defaults, like when Helm renders the chart. The parent values are
+// returned unchanged when there is nothing to nest.
+func WithSubcharts(parent []byte, subcharts []Subchart) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(parent, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse values: %w", err)
+	}
+	root := documentMapping(&doc)
+	if root == nil {
+		if doc.Kind != 0 && len(doc.Content) > 0 && doc.Content[0].ShortTag() != "!!null" {
+			return nil, fmt.Errorf("failed to parse values: expected a mapping")
+		}
+		root = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{root}}
+	}
+
+	nested := false
+	for _, subchart := range subcharts {
+		defaults, err := parseMapping(subchart.Values)
+		if err != nil {
+			return nil, fmt.Errorf("subchart %s: %w", subchart.Name, err)
+		}
+		if defaults == nil || len(defaults.Content) == 0 {
+			continue
+		}
+		key, value := lookup(root, []string{subchart.Key})
+		switch {
+		case key == nil:
+			key = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: subchart.Key}
+			key.HeadComment = fmt.Sprintf("Default values of the %s subchart", subchart.Name)
+			if len(root.Content) == 0 {
+				root.Style = 0
+			}
+			root.Content = append(root.Content, key, defaults)
+		case value.Kind == yaml.MappingNode:
+			coalesceMapping(defaults, value)
+			replaceValue(root, key, defaults)
+		case value.ShortTag() == "!!null":
+			replaceValue(root, key, defaults)
+		default:
+			// The parent chart replaces the subchart values altogether
+			continue
+		}
+		nested = true
+	}
+	if !nested {
+		return parent, nil
+	}
+	return encodeDocument(&doc)
+}
+
+// Set sets the value at a key path of a values file, creating the missing mappings. The comments
+// and order of the other keys are kept.
+func Set(data []byte, path []string, value interface{}) ([]byte, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("empty key path")
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse values: %w", err)
+	}
+	node := documentMapping(&doc)
+	if node == nil {
+		if doc.Kind != 0 && len(doc.Content) > 0 && doc.Content[0].ShortTag() != "!!null" {
+			return nil, fmt.Errorf("failed to parse values: expected a mapping")
+		}
+		node = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		doc = yaml.Node{Kind: yaml.DocumentNode, HeadComment: doc.HeadComment, Content: []*yaml.Node{node}}
+	}
+
+	var encoded yaml.Node
+	if err := encoded.Encode(value); err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %w", formatPath(path), err)
+	}
+	for i, name := range path {
+		key, child := lookup(node, []string{name})
+		last := i == len(path)-1
+		switch {
+		case key == nil:
+			key = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name}
+			child = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			if last {
+				child = &encoded
+			}
+			if len(node.Content) == 0 {
+				// An empty {} gets keys in block style
+				node.Style = 0
+			}
+			node.Content = append(node.Content, key, child)
+		case last:
+			encoded.LineComment = child.LineComment
+			replaceValue(node, key, &encoded)
+		case child.Kind != yaml.MappingNode:
+			child = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			replaceValue(node, key, child)
+		}
+		node = child
+	}
+	return encodeDocument(&doc)
+}
+
+// replaceValue replaces the value of a key of a mapping node.
+func replaceValue(mapping, key, value *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i] == key {
+			mapping.Content[i+1] = value
+			return
+		}
+	}
+}
+
+// encodeDocument encodes a values document with the two-space indentation of Helm charts.
+func encodeDocument(doc *yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, fmt.Errorf("failed to encode values: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode values: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,78 @@
+package values
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const umbrellaDefaults = `# Umbrella chart
+replicaCount: 1
+redis:
+  # Redis is disabled by default
+  enabled: false
+  architecture: standalone
+`
+
+func TestWithSubcharts(t *testing.T) {
+	out, err := WithSubcharts([]byte(umbrellaDefaults), []Subchart{
+		{Key: "redis", Name: "redis", Values: []byte("architecture: replication\n# Persistence of the data\npersistence:\n  size: 8Gi\n")},
+		{Key: "db", Name: "postgresql", Values: []byte("auth:\n  database: app\n")},
+		{Key: "common", Name: "common", Values: []byte("")},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `# Umbrella chart
+replicaCount: 1
+redis:
+  architecture: standalone
+  # Persistence of the data
+  persistence:
+    size: 8Gi
+  # Redis is disabled by default
+  enabled: false
+# Default values of the postgresql subchart
+db:
+  auth:
+    database: app
+`, string(out))
+
+	// Nothing to nest leaves the values untouched
+	out, err = WithSubcharts([]byte(umbrellaDefaults), []Subchart{{Key: "common", Name: "common"}})
+	require.NoError(t, err)
+	assert.Equal(t, umbrellaDefaults, string(out))
+
+	out, err = WithSubcharts(nil, []Subchart{{Key: "db", Name: "postgresql", Values: []byte("auth: {}\n")}})
+	require.NoError(t, err)
+	assert.Equal(t, "# Default values of the postgresql subchart\ndb:\n  auth: {}\n", string(out))
+
+	_, err = WithSubcharts([]byte("- a\n"), nil)
+	assert.Error(t, err)
+}
+
+func TestSet(t *testing.T) {
+	out, err := Set([]byte(umbrellaDefaults), []string{"redis", "enabled"}, true)
+	require.NoError(t, err)
+	assert.Equal(t, `# Umbrella chart
+replicaCount: 1
+redis:
+  # Redis is disabled by default
+  enabled: true
+  architecture: standalone
+`, string(out))
+
+	out, err = Set([]byte("# Overrides\nimage:\n  tag: v1\n"), []string{"postgresql", "enabled"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, "# Overrides\nimage:\n  tag: v1\npostgresql:\n  enabled: false\n", string(out))
+
+	out, err = Set([]byte("{}\n"), []string{"redis", "enabled"}, true)
+	require.NoError(t, err)
+	assert.Equal(t, "redis:\n  enabled: true\n", string(out))
+
+	out, err = Set(nil, []string{"redis", "enabled"}, true)
+	require.NoError(t, err)
+	assert.Equal(t, "redis:\n  enabled: true\n", string(out))
+
+	_, err = Set([]byte(umbrellaDefaults), nil, true)
+	assert.Error(t, err)
+}